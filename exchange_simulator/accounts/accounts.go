@@ -556,6 +556,10 @@ func (a *Account) SetDeposit(deposit float64) {
 	a.highestAvailableMargin = deposit
 }
 
+func (a *Account) GetName() string {
+	return a.name
+}
+
 func (a *Account) SetName(name string) {
 	a.name = name
 }
@@ -703,3 +707,93 @@ func (a *Account) SetMargin(m int64) {
 	a.margin = m
 }
 
+
+// ===== CHECKPOINTS ===============================================================================
+
+// State is what changes as an account trades, the rest comes from setting it up again
+type State struct {
+	MarginAvailable float64
+	CurrentBalance  float64
+	CommissionsPaid float64
+
+	HighestEquity  float64
+	HighestBalance float64
+	LowestEquity   float64
+	LowestBalance  float64
+
+	HighestAvailableMargin float64
+	LowestAvailableMargin  float64
+
+	MarginCalled bool
+
+	LastEquityHigh float64
+	LastEquityLow  float64
+	WorstDrawdown  float64
+
+	Orders []orders.State
+}
+
+func (a *Account) State() State {
+	s := State{
+		MarginAvailable: a.marginAvailable,
+		CurrentBalance:  a.currentBalance,
+		CommissionsPaid: a.commissionsPaid,
+
+		HighestEquity:  a.highestEquity,
+		HighestBalance: a.highestBalance,
+		LowestEquity:   a.lowestEquity,
+		LowestBalance:  a.lowestBalance,
+
+		HighestAvailableMargin: a.highestAvailableMargin,
+		LowestAvailableMargin:  a.lowestAvailableMargin,
+
+		MarginCalled: a.marginCalled,
+
+		LastEquityHigh: a.lastEquityHigh,
+		LastEquityLow:  a.lastEquityLow,
+		WorstDrawdown:  a.worstDrawdown,
+	}
+
+	for el := a.Orders.Front(); el != nil; el = el.Next() {
+		s.Orders = append(s.Orders, el.Value.(*orders.Order).State())
+	}
+
+	return s
+}
+
+// Restore replaces the account's orders with new ones from s
+func (a *Account) Restore(s State) {
+	a.marginAvailable = s.MarginAvailable
+	a.currentBalance = s.CurrentBalance
+	a.commissionsPaid = s.CommissionsPaid
+
+	a.highestEquity = s.HighestEquity
+	a.highestBalance = s.HighestBalance
+	a.lowestEquity = s.LowestEquity
+	a.lowestBalance = s.LowestBalance
+
+	a.highestAvailableMargin = s.HighestAvailableMargin
+	a.lowestAvailableMargin = s.LowestAvailableMargin
+
+	a.marginCalled = s.MarginCalled
+
+	a.lastEquityHigh = s.LastEquityHigh
+	a.lastEquityLow = s.LastEquityLow
+	a.worstDrawdown = s.WorstDrawdown
+
+	a.Orders.Init()
+	for _, o := range s.Orders {
+		a.Orders.PushBack(o.Order())
+	}
+}
+
+// Order finds an order by its ID, nil if the account doesn't have it
+func (a *Account) Order(id int64) *orders.Order {
+	for el := a.Orders.Front(); el != nil; el = el.Next() {
+		if o := el.Value.(*orders.Order); id == o.ID {
+			return o
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package accounts
+
+import (
+	"sort"
+	"time"
+
+	"../orders"
+)
+
+// ===== TAGS ======================================================================================
+
+// TagStats(ALL_TAGS) is every order, the whole portfolio
+const ALL_TAGS = "*"
+
+// Closed orders for one portfolio strategy, see orders.Order.Tag
+type TagStats struct {
+	Trades int64
+	Wins   int64
+
+	Profit      float64 // after commission
+	MaxDrawdown float64 // from the peak of closed trade profit
+
+	MaxLots     float64       // open at once
+	InMarket    time.Duration // with at least one order open
+	TotalPeriod time.Duration // from the first open to the last close
+}
+
+func (ts TagStats) WinPercentage() float64 {
+	if 0 == ts.Trades {
+		return 0.0
+	}
+
+	return 100.0 * float64(ts.Wins) / float64(ts.Trades)
+}
+
+func (ts TagStats) PercentInMarket() float64 {
+	if 0 == ts.TotalPeriod {
+		return 0.0
+	}
+
+	return 100.0 * float64(ts.InMarket) / float64(ts.TotalPeriod)
+}
+
+type byCloseTime []*orders.Order
+
+func (bc byCloseTime) Len() int           { return len(bc) }
+func (bc byCloseTime) Swap(i, j int)      { bc[i], bc[j] = bc[j], bc[i] }
+func (bc byCloseTime) Less(i, j int) bool { return bc[i].ClosedAt.Before(bc[j].ClosedAt) }
+
+// lots opening (> 0) or closing (< 0) at a time
+type lotChange struct {
+	at   time.Time
+	lots float64
+}
+
+type byTime []lotChange
+
+func (bt byTime) Len() int      { return len(bt) }
+func (bt byTime) Swap(i, j int) { bt[i], bt[j] = bt[j], bt[i] }
+
+// closes first, so back to back orders aren't counted as open at once
+func (bt byTime) Less(i, j int) bool {
+	if bt[i].at.Equal(bt[j].at) {
+		return bt[i].lots < bt[j].lots
+	}
+
+	return bt[i].at.Before(bt[j].at)
+}
+
+func (a *Account) TagStats(tag string) TagStats {
+	ts := TagStats{}
+
+	closed := []*orders.Order{}
+
+	for el := a.Orders.Front(); el != nil; el = el.Next() {
+		o := el.Value.(*orders.Order)
+
+		if o.IsClosed() && (ALL_TAGS == tag || o.Tag == tag) {
+			closed = append(closed, o)
+		}
+	}
+
+	if 0 == len(closed) {
+		return ts
+	}
+
+	sort.Sort(byCloseTime(closed))
+
+	changes := []lotChange{}
+	peak := 0.0
+
+	for _, o := range closed {
+		profit := o.Profit() + o.Commission()
+
+		ts.Trades += 1
+		if profit > 0.0 {
+			ts.Wins += 1
+		}
+
+		ts.Profit += profit
+
+		if ts.Profit > peak {
+			peak = ts.Profit
+		} else if peak - ts.Profit > ts.MaxDrawdown {
+			ts.MaxDrawdown = peak - ts.Profit
+		}
+
+		changes = append(changes, lotChange{o.OpenedAt, o.LotSize}, lotChange{o.ClosedAt, -o.LotSize})
+	}
+
+	sort.Sort(byTime(changes))
+
+	ts.TotalPeriod = changes[len(changes) - 1].at.Sub(changes[0].at)
+
+	open := 0.0
+	for i, change := range changes {
+		// lots are float, so closing everything might not get back to exactly 0
+		if open > 0.001 {
+			ts.InMarket += change.at.Sub(changes[i - 1].at)
+		}
+
+		open += change.lots
+
+		if open > ts.MaxLots {
+			ts.MaxLots = open
+		}
+	}
+
+	return ts
+}
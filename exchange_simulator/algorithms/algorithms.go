@@ -3,6 +3,7 @@ package algorithms
 import (
 	"container/list"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 	"time"
@@ -10,8 +11,13 @@ import (
 	"../accounts"
 	"../brokers"
 	"../candles"
+	"../circuit_breakers"
 	"../indicators"
+	"../journals"
+	"../loggers"
 	"../orders"
+	"../schedulers"
+	"../stops"
 	"../ticks"
 	"../utils"
 
@@ -21,38 +27,318 @@ import (
 )
 
 func NewWithDeets(acc *accounts.Account, f func(*Algorithm, *ticks.MarketTick)) *Algorithm {
-	algo := Algorithm{Account: acc, logic: f}
+	return NewWithStrategy(acc, &FuncStrategy{Logic: f})
+}
+
+func NewWithStrategy(acc *accounts.Account, s Strategy) *Algorithm {
+	algo := Algorithm{Account: acc, Scheduler: schedulers.NewScheduler()}
+	algo.members = []*member{{strategy: s, weight: 1.0, scheduler: algo.Scheduler}}
+	algo.initLog()
 
 	return &algo
 }
 
+// ===== CIRCUIT BREAKERS ==========================================================================
+
+// AddCircuitBreaker stops new orders, and closes the open ones if it Flattens, while b's tripped.
+// Trips and resets are listed after the account summary.
+func (a *Algorithm) AddCircuitBreaker(b *circuit_breakers.Breaker) {
+	if nil == a.breakers {
+		a.breakers = circuit_breakers.NewPanel()
+	}
+
+	a.breakers.Add(b)
+}
+
+func (a *Algorithm) CircuitBreakers() *circuit_breakers.Panel {
+	return a.breakers
+}
+
+func (a *Algorithm) updateBreakers(tick *ticks.MarketTick) {
+	equity := a.Account.GetBalance()
+	if a.Account.HasOpenOrders() {
+		equity = a.Account.GetEquity()
+	}
+
+	seen := len(a.breakers.Events)
+	tripped := a.breakers.Update(tick.Time, equity)
+
+	for _, ev := range a.breakers.Events[seen:] {
+		entry := journals.Entry{At: ev.At, Type: journals.CIRCUIT_BREAKER_RESET, Equity: ev.Equity}
+		if ev.Tripped {
+			entry.Type = journals.CIRCUIT_BREAKER_TRIPPED
+		}
+
+		entry.Name, entry.Reason = ev.Breaker.String(), ev.Detail
+		a.record(entry)
+	}
+
+	for _, b := range tripped {
+		if b.Flattens() && a.Account.HasOpenOrders() {
+			a.closeAllOrders(orders.CLOSED_BY_CIRCUIT_BREAKER)
+		}
+	}
+}
+
+// ===== LOGGING ===================================================================================
+
+func (a *Algorithm) initLog() {
+	a.log = loggers.Component("algorithms")
+
+	if nil != a.Account {
+		a.log = a.log.With("algorithm", a.Account.GetName())
+	}
+
+	a.log = a.log.Clock(a.now)
+}
+
+// now is the time of the tick being dealt with, zero before the first one
+func (a *Algorithm) now() time.Time {
+	if nil == a.lastTick {
+		return time.Time{}
+	}
+
+	return a.lastTick.Time
+}
+
+// Log is for strategies to log with, see loggers.Configure. Lines are stamped with the time of the
+// tick being dealt with, and in a portfolio they're tagged with the strategy.
+func (a *Algorithm) Log() *loggers.Logger {
+	if nil != a.active && nil != a.active.log {
+		return a.active.log
+	}
+
+	return a.log
+}
+
+// ===== JOURNAL ===================================================================================
+
+// SetJournal records what happens to the account in j, along with whatever the strategies Record
+func (a *Algorithm) SetJournal(j *journals.Journal) {
+	a.journal = j
+}
+
+func (a *Algorithm) Journal() *journals.Journal {
+	return a.journal
+}
+
+// Record journals a strategy's own event, e.g. why it decided not to trade. Data has to be
+// something encoding/json can write.
+func (a *Algorithm) Record(name string, data map[string]interface{}) {
+	a.record(journals.Entry{At: a.now(), Type: journals.CUSTOM, Name: name, Data: data})
+}
+
+func (a *Algorithm) record(e journals.Entry) {
+	if nil == a.journal {
+		return
+	}
+
+	e.Account = a.Account.GetName()
+
+	if "" == e.Strategy && nil != a.active {
+		e.Strategy = a.active.tag
+	}
+
+	a.journal.Record(e)
+}
+
+func (a *Algorithm) recordMarginCall(tick *ticks.MarketTick, equity float64, reason string) {
+	a.record(journals.Entry{
+		At:      tick.Time,
+		Type:    journals.MARGIN_CALL,
+		Balance: a.Account.GetBalance(),
+		Equity:  equity,
+		Reason:  reason,
+	})
+}
+
+// recordFinalState is what journals.Replay checks the account it rebuilds against
+func (a *Algorithm) recordFinalState() {
+	if nil == a.lastTick {
+		return
+	}
+
+	a.record(journals.Entry{
+		At:         a.lastTick.Time,
+		Type:       journals.FINAL_STATE,
+		Balance:    a.Account.GetBalance(),
+		Equity:     a.Account.GetEquity(),
+		Drawdown:   a.Account.GetDrawdown(),
+		Trades:     int64(a.Account.Orders.Len()),
+		OpenOrders: int64(len(a.Account.OpenOrders())),
+	})
+}
+
+// ===== BROKER ====================================================================================
+
+// algorithmBroker sits between the algorithm and its broker, holding back orders while a circuit
+// breaker's tripped and scaling portfolio strategies' lots by their weight, to no less than the
+// smallest lot
+type algorithmBroker struct {
+	brokers.Broker
+
+	a *Algorithm
+
+	rejection *brokers.Rejection // the last one, until an order gets through
+}
+
+func (ab *algorithmBroker) weighted(lots float64) float64 {
+	if nil == ab.a.active || 1.0 == ab.a.active.weight {
+		return lots
+	}
+
+	return math.Max(0.01, math.Floor(lots * ab.a.active.weight * 100.0 + 0.5) / 100.0)
+}
+
+func (ab *algorithmBroker) open(direction orders.TradeDirection, acc *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit, fill func(float64) *orders.Order) *orders.Order {
+	request := func(t journals.EventType) journals.Entry {
+		e := journals.Request(t, tick.Time, symbol, direction, lots, float64(sl.Pips), float64(tp.Pips))
+		e.Bid, e.Ask = tick.OpenBid, tick.OpenAsk
+
+		return e
+	}
+
+	ab.a.record(request(journals.ORDER_SUBMITTED))
+
+	if nil != ab.a.breakers {
+		if b, tripped := ab.a.breakers.Tripped(); tripped {
+			ab.rejection = &brokers.Rejection{
+				At:        tick.Time,
+				Account:   acc,
+				Symbol:    symbol,
+				Direction: direction,
+				Lots:      lots,
+				Reason:    "circuit breaker tripped: " + b.String(),
+			}
+
+			rejected := request(journals.ORDER_REJECTED)
+			rejected.Reason = ab.rejection.Reason
+			ab.a.record(rejected)
+
+			return nil
+		}
+	}
+
+	ab.rejection = nil
+
+	o := fill(ab.weighted(lots))
+
+	if nil == o {
+		rejected := request(journals.ORDER_REJECTED)
+		if r, ok := ab.LastRejection(acc); ok {
+			rejected.Reason = r.Reason
+		}
+
+		ab.a.record(rejected)
+	}
+
+	return o
+}
+
+func (ab *algorithmBroker) OpenBuyOrder(acc *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
+	return ab.open(orders.BUY, acc, symbol, tick, lots, sl, tp, func(lots float64) *orders.Order {
+		return ab.Broker.OpenBuyOrder(acc, symbol, tick, lots, sl, tp)
+	})
+}
+
+func (ab *algorithmBroker) OpenSellOrder(acc *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
+	return ab.open(orders.SELL, acc, symbol, tick, lots, sl, tp, func(lots float64) *orders.Order {
+		return ab.Broker.OpenSellOrder(acc, symbol, tick, lots, sl, tp)
+	})
+}
+
+func (ab *algorithmBroker) LastRejection(acc *accounts.Account) (brokers.Rejection, bool) {
+	if nil != ab.rejection {
+		return *ab.rejection, true
+	}
+
+	if r, ok := ab.Broker.(brokers.Rejecter); ok {
+		return r.LastRejection(acc)
+	}
+
+	return brokers.Rejection{}, false
+}
+
+// LastRejection is why the broker last refused to open an order for the account, if the broker
+// can say, e.g. a risk_managers.RiskManager
+func (a *Algorithm) LastRejection() (brokers.Rejection, bool) {
+	if r, ok := a.Broker.(brokers.Rejecter); ok {
+		return r.LastRejection(a.Account)
+	}
+
+	return brokers.Rejection{}, false
+}
+
+// closeAllOrders closes every open order for reason
+func (a *Algorithm) closeAllOrders(reason orders.CloseReason) {
+	for _, o := range a.Account.OpenOrders() {
+		o.CloseReason = reason
+	}
+
+	a.Broker.CloseAllOrders(a.Account, a.latestTicks)
+}
+
 // ===== ALGORITHMS ================================================================================
 
 type Algorithm struct {
 	Account *accounts.Account
 	Broker  brokers.Broker
 
-	Charts map[string]map[string]*candles.CandleChart
+	Charts map[string]map[string]candles.Chart
 
 	currencies []string
 
-	firstTick bool
-	lastTick  *ticks.MarketTick
+	firstTick   bool
+	lastTick    *ticks.MarketTick
+	latestTicks map[string]*ticks.MarketTick // by symbol
 
 	customData map[string]interface{}
 
-	indis map[string]map[string]indicators.Indicator
+	indis     map[string]map[string]indicators.Indicator
+	indiDeps  map[string][]string // name -> names of the indicators it takes inputs from
+	indiNames []string            // in the order they were added
+	indiOrder []string            // dependencies first
+
+	indiBindings map[string]Binding             // as added
+	resolved     map[string]Binding             // with defaults filled in, see resolveBindings
+	watched      map[string]bool
+	closedBars   []closedBar                    // in the order they closed, since the last tick
+	crossBars    map[string][][]*candles.Candle // cross indicator -> candles waiting, by symbol
+
+	// timers fire against tick time once the algorithm's warm, just before OnBarClose and OnTick.
+	// In a portfolio each strategy sees its own during its hooks.
+	Scheduler *schedulers.Scheduler
+
+	breakers *circuit_breakers.Panel
 
-	logic func(*Algorithm, *ticks.MarketTick)
+	journal *journals.Journal
+
+	// stamped with the time of the tick being dealt with, see Log
+	log *loggers.Logger
+
+	members   []*member
+	active    *member    // whose hook is running
+	portfolio bool
+	barCloses []barClose // since the last tick
+	day       time.Time  // trading day the strategy was last told about
 
 	hasStartupDelay bool
 	startupDelay    time.Duration
 	firstTickAfter  time.Time
 
+	history map[string]int64 // chart descriptor -> finished candles the logic needs
+	startAt time.Time
+	warm    bool
+
+	// like Scheduler, each strategy in a portfolio has its own
 	TradingDecision trading_decisions.TradingDecision
 
 	tickChannel   chan *ticks.MarketTick
 	tickWaitGroup sync.WaitGroup
+	synced        chan bool
+
+	started  bool
+	draining bool // margin called or over the drawdown limit, so ignoring ticks until the end
 
 	leadingTicks map[string]*list.List
 
@@ -92,18 +378,183 @@ func (a *Algorithm) SetStartupDelay(t time.Duration) {
 	a.hasStartupDelay = true
 }
 
+// ===== WARM UP ===================================================================================
+
+// RequireHistory holds the logic back until every currency's descriptor chart has n finished
+// candles, for logic that reads the charts directly. Indicators don't need it, the logic waits for
+// all of them to be Ready anyway.
+func (a *Algorithm) RequireHistory(descriptor string, n int64) {
+	if nil == a.history {
+		a.history = make(map[string]int64)
+	}
+
+	if n > a.history[descriptor] {
+		a.history[descriptor] = n
+	}
+}
+
+// SetStartDate makes ticks before t warm-up only: charts and indicators see them, the logic doesn't.
+// See exchanges.Exchange.SetStartDate.
+func (a *Algorithm) SetStartDate(t time.Time) {
+	a.startAt = t
+}
+
+// IsWarm is true once every indicator is Ready and every chart has the history it was required to,
+// and stays true from then on
+func (a *Algorithm) IsWarm() bool {
+	if a.warm {
+		return true
+	}
+
+	for _, currency := range a.currencies {
+		for _, indi := range a.indis[currency] {
+			if !indi.Ready() {
+				return false
+			}
+		}
+
+		for descriptor, n := range a.history {
+			if a.Charts[currency][descriptor].Finished() < n {
+				return false
+			}
+		}
+	}
+
+	a.warm = true
+
+	return true
+}
+
+// WarmUpPeriod is how much history before the start date should be enough to warm up, with room
+// for a weekend. It's false if there's no telling, i.e. an indicator isn't an
+// indicators.WarmUpper or something is on a chart that doesn't go by time.
+func (a *Algorithm) WarmUpPeriod() (time.Duration, bool) {
+	longest := time.Duration(0)
+	periods := make(map[string]time.Duration)
+
+	for _, name := range a.indiOrder {
+		indi := a.indis[a.currencies[0]][name]
+
+		wu, ok := indi.(indicators.WarmUpper)
+		if !ok {
+			return 0, false
+		}
+
+		period, timed := candles.PeriodOf(a.resolved[name].Chart)
+		if !timed {
+			return 0, false
+		}
+
+		// the first candle is usually a partial one
+		d := time.Duration(wu.WarmUp() + 1) * period
+
+		if di, ok := indi.(indicators.DerivedIndicator); ok {
+			inputs := time.Duration(0)
+			for _, input := range di.Inputs() {
+				if periods[input.Indicator] > inputs {
+					inputs = periods[input.Indicator]
+				}
+			}
+
+			d += inputs
+		}
+
+		periods[name] = d
+
+		if d > longest {
+			longest = d
+		}
+	}
+
+	for descriptor, n := range a.history {
+		period, timed := candles.PeriodOf(descriptor)
+		if !timed {
+			return 0, false
+		}
+
+		if d := time.Duration(n + 1) * period; d > longest {
+			longest = d
+		}
+	}
+
+	if 0 == longest {
+		return 0, true
+	}
+
+	return longest * 7 / 5 + 48 * time.Hour, true
+}
+
 func (a *Algorithm) Init(b brokers.Broker) {
 	if 0 == len(a.currencies) {
 		panic("you must subscribe to at least one currency")
 	}
 
-	a.Broker = b
+	if 0 == len(a.members) {
+		panic("a portfolio needs at least one strategy, see AddStrategy")
+	}
+
+	a.Broker = &algorithmBroker{Broker: b, a: a}
+
 	a.tickChannel = make(chan *ticks.MarketTick, 10000)
+	a.synced = make(chan bool)
+
+	// added here rather than in TickReceiverLoop, which might not have started by StopReceiverLoop
+	a.tickWaitGroup.Add(1)
 	a.firstTick = true
 
+	a.checkIndicatorInputs()
+	a.resolveBindings()
+
+	for descriptor, n := range a.history {
+		for _, currency := range a.currencies {
+			a.chart(currency, descriptor).Keep(n)
+		}
+	}
+
+	a.watched = make(map[string]bool)
+	a.closedBars = []closedBar{}
+	a.crossBars = make(map[string][][]*candles.Candle)
+
+	initialized := make(map[indicators.Indicator]bool)
+
 	for _, currency := range a.currencies {
-		for _, indi := range a.indis[currency] {
+		for _, name := range a.indiOrder {
+			indi := a.indis[currency][name]
+
+			// indicators bound to one symbol are shared by every currency
+			if initialized[indi] {
+				continue
+			}
+			initialized[indi] = true
+
 			indi.Init()
+
+			switch indi := indi.(type) {
+			case indicators.CrossIndicator:
+				for _, symbol := range indi.Symbols() {
+					a.watchChart(symbol, a.resolved[name].Chart)
+				}
+
+				a.crossBars[name] = make([][]*candles.Candle, len(indi.Symbols()))
+			case indicators.BarIndicator, indicators.DerivedIndicator:
+				_, symbol, descriptor := a.bindingKey(currency, name)
+				chart := a.watchChart(symbol, descriptor)
+
+				if ci, ok := indi.(indicators.ChartIndicator); ok {
+					ci.SetChart(chart)
+				}
+			}
+		}
+	}
+
+	for symbol, charts := range a.Charts {
+		for period, chart := range charts {
+			bc := barClose{symbol: symbol, period: period}
+
+			chart.OnClose(func(candle *candles.Candle) {
+				bc.candle = candle
+				a.barCloses = append(a.barCloses, bc)
+			})
 		}
 	}
 
@@ -116,31 +567,67 @@ func (a *Algorithm) OnTick(tick *ticks.MarketTick) {
 
 func (a *Algorithm) PrintSummary() {
 	a.Account.PrintSummary()
+
+	if a.portfolio {
+		a.printPortfolioSummary()
+	}
+
+	if nil != a.breakers {
+		a.breakers.PrintEvents()
+	}
 }
 
 func (a *Algorithm) SendTick(tick *ticks.MarketTick) {
 	a.tickChannel <- tick
 }
 
+// Sync waits for the algorithm to deal with every tick it's been sent, e.g. before a checkpoint
+func (a *Algorithm) Sync() {
+	a.tickChannel <- nil
+	<- a.synced
+}
+
 func (a *Algorithm) StopReceiverLoop() {
 	close(a.tickChannel)
 
 	a.tickWaitGroup.Wait()
 }
 
-func (a *Algorithm) TickReceiverLoop() {
-	a.tickWaitGroup.Add(1)
+// Start tells the strategies the run's starting, if they haven't been told. TickReceiverLoop does
+// it, resuming from a checkpoint does it first so they can set their timers up again.
+func (a *Algorithm) Start() {
+	if a.started {
+		return
+	}
+	a.started = true
+
+	a.latestTicks = make(map[string]*ticks.MarketTick)
 
-	latestTicks := make(map[string]*ticks.MarketTick)
+	a.each(func(s Strategy) { s.OnStart(a) })
+}
+
+func (a *Algorithm) TickReceiverLoop() {
+	a.Start()
 
 	for {
 		tick, ok := <- a.tickChannel
 		if !ok {
-			a.Broker.CloseAllOrders(a.Account, latestTicks)
+			a.closeAllOrders(orders.CLOSED_AT_END)
+			a.each(func(s Strategy) { s.OnStop(a) })
+			a.recordFinalState()
 			break
 		}
 
-		latestTicks[tick.Symbol] = tick
+		if nil == tick {
+			a.synced <- true
+			continue
+		}
+
+		if a.draining {
+			continue
+		}
+
+		a.latestTicks[tick.Symbol] = tick
 
 		if a.firstTick {
 			if a.hasStartupDelay {
@@ -151,6 +638,8 @@ func (a *Algorithm) TickReceiverLoop() {
 
 			a.firstTick = false
 			a.lastTick = tick
+
+			a.record(journals.Entry{At: tick.Time, Type: journals.ACCOUNT_OPENED, Balance: a.Account.GetBalance()})
 		}
 
 		// ----- PREPROCESSING -------------------------------------------------------------
@@ -176,58 +665,75 @@ func (a *Algorithm) TickReceiverLoop() {
 			margin := a.Account.UpdateMarginAvailable()
 
 			if a.Account.HasExceededDrawdown() {
-				fmt.Printf(
-					"Account has violated max drawdown, closing all orders (curr: %.2f%%, max: %.2f%%)\n",
-					a.Account.GetDrawdown(),
-					a.Account.GetDrawdownLimit(),
+				a.log.At(tick.Time).Warn(
+					"account has violated max drawdown, closing all orders",
+					"drawdown", a.Account.GetDrawdown(),
+					"max", a.Account.GetDrawdownLimit(),
 				)
 
-				a.Broker.CloseAllOrders(a.Account, latestTicks)
+				a.record(journals.Entry{
+					At:       tick.Time,
+					Type:     journals.DRAWDOWN_EXCEEDED,
+					Equity:   equity,
+					Drawdown: a.Account.GetDrawdown(),
+					Reason:   fmt.Sprintf("max drawdown is %.2f%%", a.Account.GetDrawdownLimit()),
+				})
+
+				a.closeAllOrders(orders.CLOSED_BY_DRAWDOWN)
 			} else if equity <= accounts.MINIMUM_EQUITY {
-				fmt.Printf(
-					"Equity is below minimum, closing all orders (curr: %.2f, min: %.2f)\n",
-					equity,
-					accounts.MINIMUM_EQUITY,
+				a.log.At(tick.Time).Warn(
+					"equity is below minimum, closing all orders",
+					"equity", equity,
+					"min", accounts.MINIMUM_EQUITY,
 				)
 
 				a.Account.MarginCalled()
-				a.Broker.CloseAllOrders(a.Account, latestTicks)
+				a.recordMarginCall(tick, equity, "equity below minimum")
+				a.closeAllOrders(orders.CLOSED_BY_MARGIN_CALL)
+				a.each(func(s Strategy) { s.OnMarginCall(a) })
 			} else if margin <= accounts.MINIMUM_MARGIN {
-				fmt.Printf(
-					"Margin available is below minimum, TODO: close biggest order (curr: %s, min: %s)\n",
-					utils.FormatMoney(margin),
-					utils.FormatMoney(accounts.MINIMUM_EQUITY),
+				// TODO: close the biggest order instead
+				a.log.At(tick.Time).Warn(
+					"margin available is below minimum, closing all orders",
+					"margin", margin,
+					"min", accounts.MINIMUM_MARGIN,
 				)
 
 				a.Account.MarginCalled()
-				a.Broker.CloseAllOrders(a.Account, latestTicks)
+				a.recordMarginCall(tick, equity, "margin available below minimum")
+				a.closeAllOrders(orders.CLOSED_BY_MARGIN_CALL)
+				a.each(func(s Strategy) { s.OnMarginCall(a) })
 			}
 
-			// If we just got margin called or hit the DD limit, empty our channel and exit.
+			// If we just got margin called or hit the DD limit, ignore the rest of the ticks.
 			if !a.Account.CanTrade() {
-				for {
-					_, ok := <- a.tickChannel
-					if !ok {
-						break
-					}
-				}
+				a.draining = true
 			}
 		}
 
+		if nil != a.breakers {
+			a.updateBreakers(tick)
+		}
+
 		a.recordLeadingTick(tick)
 
+		a.barCloses = a.barCloses[:0]
 		a.updateCharts(tick)
 		a.runIndicators(tick)
 
+		a.lastTick = tick
+
+		if tick.Time.Before(a.startAt) || !a.IsWarm() {
+			continue
+		}
+
 		if a.hasStartupDelay && tick.Time.Before(a.firstTickAfter) {
 			continue
 		}
 
 		// ----- PROCESSING ----------------------------------------------------------------
 
-		a.logic(a, tick)
-
-		a.lastTick = tick
+		a.dispatch(tick)
 	}
 
 	a.tickWaitGroup.Done()
@@ -241,11 +747,7 @@ func (a *Algorithm) AddCurrency(currencies ...string) {
 		a.currencies = append(a.currencies, currency)
 
 		if !strings.HasSuffix(currency, "USD") {
-			fmt.Println("")
-			fmt.Println("******************************************************************")
-			fmt.Println(" WARNING: non-USD base currencies will not calculate P/L properly")
-			fmt.Println("******************************************************************")
-			fmt.Println("")
+			a.log.Warn("non-USD base currencies will not calculate P/L properly", "currency", currency)
 		}
 	}
 }
@@ -256,15 +758,23 @@ func (a *Algorithm) WantsCurrency(s string) bool {
 
 // ===== CANDLESTICK CHARTS ========================================================================
 
+// AttachCharts takes chart descriptors, e.g. "M5", "HA:H1" or "RENKO:10", see candles.NewChart
 func (a *Algorithm) AttachCharts(periods ...string) {
 	for i := range periods {
-		a.attachChart(periods[i])
+		a.attachChart(periods[i], time.UTC, 0)
 	}
 }
 
-func (a *Algorithm) attachChart(period string) {
+// AttachAlignedCharts splits candles at offset past midnight in loc, see candles.NewAlignedChart
+func (a *Algorithm) AttachAlignedCharts(loc *time.Location, offset time.Duration, periods ...string) {
+	for i := range periods {
+		a.attachChart(periods[i], loc, offset)
+	}
+}
+
+func (a *Algorithm) attachChart(period string, loc *time.Location, offset time.Duration) {
 	if nil == a.Charts {
-		a.Charts = make(map[string]map[string]*candles.CandleChart)
+		a.Charts = make(map[string]map[string]candles.Chart)
 	}
 
 	exists := false
@@ -272,7 +782,7 @@ func (a *Algorithm) attachChart(period string) {
 	for _, currency := range a.currencies {
 		_, exists = a.Charts[currency]
 		if !exists {
-			a.Charts[currency] = make(map[string]*candles.CandleChart)
+			a.Charts[currency] = make(map[string]candles.Chart)
 		}
 
 		_, exists = a.Charts[currency][period]
@@ -280,71 +790,41 @@ func (a *Algorithm) attachChart(period string) {
 			panic("Chart already attached: " + period)
 		}
 
-		a.Charts[currency][period] = candles.NewCandleChart(period, 61)
+		a.Charts[currency][period] = candles.NewAlignedChart(period, 61, loc, offset)
 	}
 }
 
-func (a *Algorithm) updateCharts(tick *ticks.MarketTick) {
-	for _, currency := range a.currencies {
-		if currency != tick.Symbol {
-			continue
-		}
-
-		for _, chart := range a.Charts[currency] {
-			chart.Update(tick, a.lastTick)
-		}
+// chart attaches the chart to symbol alone if the algorithm didn't ask for it
+func (a *Algorithm) chart(symbol, descriptor string) candles.Chart {
+	if !a.WantsCurrency(symbol) {
+		panic("indicators can only be bound to subscribed currencies, not " + symbol)
 	}
-}
 
-// ===== INDICATORS ================================================================================
-
-func (a *Algorithm) AddIndicator(name string, indi func() indicators.Indicator) {
-	if nil == a.indis {
-		a.indis = make(map[string]map[string]indicators.Indicator)
+	if nil == a.Charts {
+		a.Charts = make(map[string]map[string]candles.Chart)
 	}
 
-	exists := false
-
-	for _, currency := range a.currencies {
-		_, exists = a.indis[currency]
-		if !exists {
-			a.indis[currency] = make(map[string]indicators.Indicator)
-		}
-
-		_, exists = a.indis[currency][name]
-		if exists {
-			panic("attempted to add indicator \"" + name + "\" which already exists!")
-		}
-
-		a.indis[currency][name] = indi()
+	if _, exists := a.Charts[symbol]; !exists {
+		a.Charts[symbol] = make(map[string]candles.Chart)
 	}
-}
 
-func (a *Algorithm) ReadIndicator(currency, name string) indicators.Indicator {
-	indi, ok := a.indis[currency][name]
-	if !ok {
-		panic("Unknown indicator: " + name)
+	chart, exists := a.Charts[symbol][descriptor]
+	if !exists {
+		chart = candles.NewChart(descriptor, 61)
+		a.Charts[symbol][descriptor] = chart
 	}
 
-	return indi
+	return chart
 }
 
-func (a *Algorithm) dumpIndis() {
-	for _, currency := range a.currencies {
-		for key, value := range a.indis[currency] {
-			fmt.Printf("[%s][%s] -> %#v\n", currency, key, value)
-		}
-	}
-}
-
-func (a *Algorithm) runIndicators(tick *ticks.MarketTick) {
+func (a *Algorithm) updateCharts(tick *ticks.MarketTick) {
 	for _, currency := range a.currencies {
 		if currency != tick.Symbol {
 			continue
 		}
 
-		for _, indi := range a.indis[currency] {
-			indi.OnTick(a, tick)
+		for _, chart := range a.Charts[currency] {
+			chart.Update(tick, a.lastTick)
 		}
 	}
 }
@@ -0,0 +1,294 @@
+package algorithms
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"../candles"
+	"../indicators"
+	"../indicators/cross_symbol"
+	"../indicators/moving_averages"
+	"../indicators/oscillators"
+	"../orders"
+	"../ticks"
+)
+
+func newTestAlgorithm() *Algorithm {
+	a := NewWithDeets(nil, func(*Algorithm, *ticks.MarketTick) {})
+	a.AddCurrency("EURUSD")
+
+	return a
+}
+
+func smaOf(periods int64, input string) func() indicators.Indicator {
+	return func() indicators.Indicator {
+		return indicators.NewComposite(moving_averages.NewSMA(periods), input, "value")
+	}
+}
+
+func TestIndicatorOrder(t *testing.T) {
+	a := newTestAlgorithm()
+
+	// added out of order on purpose, inputs can come later
+	a.AddIndicator("rsi_of_ema", func() indicators.Indicator {
+		return indicators.NewComposite(oscillators.NewRSI(14), "ema", "value")
+	})
+	a.AddIndicator("sma_of_rsi", smaOf(5, "rsi_of_ema"))
+	a.AddIndicator("ema", func() indicators.Indicator { return moving_averages.NewEMA(10) })
+
+	order := strings.Join(a.indiOrder, ",")
+	if "ema,rsi_of_ema,sma_of_rsi" != order {
+		t.Fatalf("Expected inputs first, got %s", order)
+	}
+
+	a.checkIndicatorInputs()
+	a.resolveBindings()
+
+	ema, rsi := moving_averages.NewEMA(10), oscillators.NewRSI(14)
+
+	for i := 0; i < 100; i++ {
+		c := &candles.Candle{CloseBid: 1.3 + float64(i % 7) / 1000.0}
+
+		a.closedBars = []closedBar{{key: "EURUSD M1", candle: c}}
+		a.runBarIndicators()
+
+		ema.OnBar(c)
+		if ema.Ready() {
+			rsi.Add(ema.Value())
+		}
+	}
+
+	if !a.IndicatorReady("EURUSD", "rsi_of_ema") || a.ReadFloat("EURUSD", "rsi_of_ema", "value", 0) != rsi.Value() {
+		t.Errorf("Expected RSI of EMA %.4f, got %.4f", rsi.Value(), a.ReadFloat("EURUSD", "rsi_of_ema", "value", 0))
+	}
+
+	if !a.IndicatorReady("EURUSD", "sma_of_rsi") {
+		t.Errorf("Expected SMA of RSI to be ready")
+	}
+}
+
+func TestIndicatorCyclesAreRejected(t *testing.T) {
+	a := newTestAlgorithm()
+
+	a.AddIndicator("a", smaOf(3, "b"))
+	a.AddIndicator("b", smaOf(3, "c"))
+
+	defer func() {
+		r := recover()
+		if nil == r {
+			t.Fatalf("Expected a cycle to panic")
+		}
+
+		if "indicator dependency cycle: a -> b -> c -> a" != r {
+			t.Errorf("Unexpected panic: %v", r)
+		}
+
+		if _, exists := a.indiDeps["c"]; exists {
+			t.Errorf("Expected the rejected indicator not to be added")
+		}
+	}()
+
+	a.AddIndicator("c", smaOf(3, "a"))
+}
+
+func TestUnknownInputsPanicAtInit(t *testing.T) {
+	a := newTestAlgorithm()
+	a.AddIndicator("a", smaOf(3, "missing"))
+
+	defer func() {
+		if nil == recover() {
+			t.Fatalf("Expected an unknown input to panic")
+		}
+	}()
+
+	a.checkIndicatorInputs()
+}
+
+func TestIndicatorsOnOtherCharts(t *testing.T) {
+	a := newTestAlgorithm()
+	a.AddCurrency("GBPUSD")
+
+	a.AddIndicator("m1", func() indicators.Indicator { return moving_averages.NewSMA(1) })
+	a.AddIndicatorOn("h1", Binding{Chart: "H1"}, func() indicators.Indicator { return moving_averages.NewSMA(1) })
+	a.AddIndicatorOn("gbp", Binding{Symbol: "GBPUSD", Chart: "M5"}, func() indicators.Indicator {
+		return moving_averages.NewSMA(1)
+	})
+	a.AddIndicator("h1_of_h1", smaOf(2, "h1"))
+
+	a.Init(nil)
+
+	if a.ReadIndicator("EURUSD", "gbp") != a.ReadIndicator("GBPUSD", "gbp") {
+		t.Fatalf("Expected one indicator bound to GBPUSD for both currencies")
+	}
+
+	start := time.Date(2015, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	// three hours of EURUSD, one tick a minute
+	for i := 0; i <= 180; i++ {
+		bid := 1.2 + float64(i) / 10000.0
+		tick := ticks.NewRawTick("EURUSD", start.Add(time.Duration(i) * time.Minute), bid, bid + 0.0002)
+
+		a.updateCharts(tick)
+		a.runIndicators(tick)
+		a.lastTick = tick
+	}
+
+	counts := map[string]int64{"m1": indicators.DEFAULT_LOOKBACK, "h1": 3, "h1_of_h1": 2, "gbp": 0}
+	for name, expected := range counts {
+		if got := a.ReadIndicator("EURUSD", name).Outputs().Float("value").Len(); got != expected {
+			t.Errorf("Expected %d %s values, got %d", expected, name, got)
+		}
+	}
+
+	if a.IndicatorReady("GBPUSD", "m1") {
+		t.Errorf("Expected GBPUSD's own indicator to stay empty without GBPUSD ticks")
+	}
+}
+
+// one tick can close several bricks, each has to go all the way through the DAG before the next
+func TestMultiBrickTick(t *testing.T) {
+	a := newTestAlgorithm()
+
+	a.AddIndicatorOn("close", Binding{Chart: "RENKO:10"}, func() indicators.Indicator { return moving_averages.NewSMA(1) })
+	a.AddIndicator("close_of_close", smaOf(1, "close"))
+
+	a.Init(nil)
+
+	start := time.Date(2015, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	// 1.2035 closes three bricks at once
+	for i, bid := range []float64{1.2000, 1.2005, 1.2035} {
+		tick := ticks.NewRawTick("EURUSD", start.Add(time.Duration(i) * time.Minute), bid, bid + 0.0002)
+
+		a.updateCharts(tick)
+		a.runIndicators(tick)
+		a.lastTick = tick
+	}
+
+	closes := a.ReadIndicator("EURUSD", "close").Outputs().Float("value")
+	derived := a.ReadIndicator("EURUSD", "close_of_close").Outputs().Float("value")
+
+	if 3 != closes.Len() || 3 != derived.Len() {
+		t.Fatalf("Expected 3 bricks through both indicators, got %d and %d", closes.Len(), derived.Len())
+	}
+
+	for ago, expected := range []float64{1.2030, 1.2020, 1.2010} {
+		if !closeEnough(expected, closes.At(int64(ago))) || !closeEnough(expected, derived.At(int64(ago))) {
+			t.Errorf("Expected %.4f %d bricks back, got %.4f and %.4f", expected, ago, closes.At(int64(ago)), derived.At(int64(ago)))
+		}
+	}
+}
+
+func TestCrossIndicatorsWaitForEverySymbol(t *testing.T) {
+	a := newTestAlgorithm()
+	a.AddCurrency("GBPUSD")
+
+	a.AddIndicator("spread", func() indicators.Indicator {
+		return cross_symbol.NewSpread("EURUSD", "GBPUSD", 1.0, 2)
+	})
+
+	a.Init(nil)
+
+	start := time.Date(2015, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	// GBPUSD ticks 30 seconds behind EURUSD and skips minute 3 entirely
+	for i := 0; i <= 6; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+
+		eur := ticks.NewRawTick("EURUSD", at, 1.2 + float64(i) / 100.0, 1.2002 + float64(i) / 100.0)
+		a.updateCharts(eur)
+		a.runIndicators(eur)
+		a.lastTick = eur
+
+		if 3 == i {
+			continue
+		}
+
+		gbp := ticks.NewRawTick("GBPUSD", at.Add(30 * time.Second), 1.5, 1.5002)
+		a.updateCharts(gbp)
+		a.runIndicators(gbp)
+		a.lastTick = gbp
+	}
+
+	// minutes 0, 1, 2, 4 and 5 closed for both, minute 6 is still open
+	spread := a.ReadIndicator("GBPUSD", "spread").(*cross_symbol.Spread)
+	if 4 != spread.Outputs().Float("value").Len() {
+		t.Fatalf("Expected 4 spreads, got %d", spread.Outputs().Float("value").Len())
+	}
+
+	if !closeEnough(spread.Value(), 1.25 - 1.5) {
+		t.Errorf("Expected the last spread to be minute 5's, got %.4f", spread.Value())
+	}
+}
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a - b) < 1e-9
+}
+
+func TestWarmUp(t *testing.T) {
+	a := newTestAlgorithm()
+
+	a.AddIndicatorOn("sma", Binding{Chart: "M5"}, func() indicators.Indicator { return moving_averages.NewSMA(3) })
+	a.AddIndicator("sma_of_sma", smaOf(2, "sma"))
+	a.RequireHistory("M1", 10)
+
+	a.Init(nil)
+
+	// 3 + 1 M5 candles for the SMA, 2 + 1 for the SMA of it, with room for a weekend
+	period, ok := a.WarmUpPeriod()
+	if !ok || (7 * 5 * time.Minute) * 7 / 5 + 48 * time.Hour != period {
+		t.Errorf("Unexpected warm-up period: %s", period)
+	}
+
+	start := time.Date(2015, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	// M5 candles close at minutes 5, 10, 15 and 20, the last one makes the second SMA of the SMA
+	for i := 0; i <= 20; i++ {
+		if a.IsWarm() {
+			t.Fatalf("Expected to be cold until the SMA of the SMA is ready, warm after %d minutes", i)
+		}
+
+		tick := ticks.NewRawTick("EURUSD", start.Add(time.Duration(i) * time.Minute), 1.2, 1.2002)
+
+		a.updateCharts(tick)
+		a.runIndicators(tick)
+		a.lastTick = tick
+	}
+
+	if !a.IsWarm() {
+		t.Errorf("Expected to be warm after 4 M5 candles")
+	}
+
+	a = newTestAlgorithm()
+	a.AddIndicatorOn("renko", Binding{Chart: "RENKO:10"}, func() indicators.Indicator { return moving_averages.NewSMA(3) })
+	a.Init(nil)
+
+	if _, ok := a.WarmUpPeriod(); ok {
+		t.Errorf("Expected no warm-up period for charts that don't go by time")
+	}
+}
+
+type stopWatcher struct {
+	BaseStrategy
+
+	modified []*orders.Order
+}
+
+func (sw *stopWatcher) OnStopModified(a *Algorithm, o *orders.Order) {
+	sw.modified = append(sw.modified, o)
+}
+
+// stops can be moved before there's been a tick, e.g. from OnStart
+func TestStopModifiedBeforeAnyTick(t *testing.T) {
+	sw := &stopWatcher{}
+	a := NewWithStrategy(nil, sw)
+
+	o := &orders.Order{}
+	a.StopModified(o)
+
+	if 1 != len(sw.modified) || o != sw.modified[0] {
+		t.Errorf("Expected the strategy to hear about the stops moving")
+	}
+}
@@ -0,0 +1,243 @@
+package algorithms
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+
+	"../accounts"
+	"../brokers"
+	"../candles"
+	"../circuit_breakers"
+	"../indicators"
+	"../schedulers"
+	"../ticks"
+)
+
+// ===== CHECKPOINTS ===============================================================================
+
+// Strategies that keep anything of their own between ticks save it in checkpoints. Whatever State
+// returns has to be gob.Registered, Restore gets the same type back.
+type StatefulStrategy interface {
+	State() interface{}
+	Restore(saved interface{})
+}
+
+// State is everything about an algorithm that changes during a run. The rest (currencies, charts,
+// indicators, strategies, breakers) comes from setting it up again the same way, see Restore.
+type State struct {
+	Account accounts.State
+
+	Charts map[string]map[string]candles.ChartState // symbol -> descriptor
+
+	FirstTick    bool
+	LastTick     *ticks.State
+	LatestTicks  map[string]*ticks.State
+	LeadingTicks map[string][]*ticks.State
+
+	// shared indicators are only saved for the first currency they're under
+	Indicators map[string]map[string]indicators.State // currency -> name
+	CrossBars  map[string][][]candles.CandleState
+
+	Members []MemberState
+
+	Breakers  *circuit_breakers.State
+	Rejection *brokers.RejectionState // a breaker's, see algorithmBroker
+
+	Day            time.Time
+	FirstTickAfter time.Time
+	Warm           bool
+	Draining       bool
+
+	Metadata map[string]interface{} // see ticks.KeepMetadata
+}
+
+type MemberState struct {
+	Weight    float64
+	Scheduler schedulers.State
+	Strategy  interface{} // nil unless it's a StatefulStrategy
+}
+
+// State is only safe to take while the algorithm's waiting for ticks, see Sync
+func (a *Algorithm) State() State {
+	s := State{
+		Account: a.Account.State(),
+
+		Charts: make(map[string]map[string]candles.ChartState),
+
+		FirstTick:    a.firstTick,
+		LastTick:     a.lastTick.State(),
+		LatestTicks:  make(map[string]*ticks.State),
+		LeadingTicks: make(map[string][]*ticks.State),
+
+		Indicators: make(map[string]map[string]indicators.State),
+		CrossBars:  make(map[string][][]candles.CandleState),
+
+		Day:            a.day,
+		FirstTickAfter: a.firstTickAfter,
+		Warm:           a.warm,
+		Draining:       a.draining,
+
+		Metadata: ticks.SaveMetadata(&a.Metadata),
+	}
+
+	for symbol, charts := range a.Charts {
+		s.Charts[symbol] = make(map[string]candles.ChartState)
+
+		for descriptor, chart := range charts {
+			s.Charts[symbol][descriptor] = chart.State()
+		}
+	}
+
+	for symbol, tick := range a.latestTicks {
+		s.LatestTicks[symbol] = tick.State()
+	}
+
+	for symbol, leading := range a.leadingTicks {
+		for el := leading.Front(); el != nil; el = el.Next() {
+			s.LeadingTicks[symbol] = append(s.LeadingTicks[symbol], el.Value.(*ticks.MarketTick).State())
+		}
+	}
+
+	saved := make(map[indicators.Indicator]bool)
+
+	for _, currency := range a.currencies {
+		s.Indicators[currency] = make(map[string]indicators.State)
+
+		for _, name := range a.indiOrder {
+			indi := a.indis[currency][name]
+			if saved[indi] {
+				continue
+			}
+			saved[indi] = true
+
+			s.Indicators[currency][name] = indicators.Save(indi)
+		}
+	}
+
+	for name, queues := range a.crossBars {
+		s.CrossBars[name] = make([][]candles.CandleState, len(queues))
+
+		for i, queue := range queues {
+			for _, candle := range queue {
+				s.CrossBars[name][i] = append(s.CrossBars[name][i], candle.State())
+			}
+		}
+	}
+
+	for _, m := range a.members {
+		ms := MemberState{Weight: m.weight, Scheduler: m.scheduler.State()}
+
+		if ss, ok := m.strategy.(StatefulStrategy); ok {
+			ms.Strategy = ss.State()
+		}
+
+		s.Members = append(s.Members, ms)
+	}
+
+	if nil != a.breakers {
+		bs := a.breakers.State()
+		s.Breakers = &bs
+	}
+
+	if ab, ok := a.Broker.(*algorithmBroker); ok && nil != ab.rejection {
+		rs := ab.rejection.State()
+		s.Rejection = &rs
+	}
+
+	return s
+}
+
+// Restore puts back what State saved over an algorithm set up the way the one that saved it was,
+// Init'd and Start'd so its strategies' timers are there to restore
+func (a *Algorithm) Restore(s State) {
+	if len(s.Members) != len(a.members) {
+		panic(fmt.Sprintf("the checkpoint has %d strategies, the algorithm has %d", len(s.Members), len(a.members)))
+	}
+
+	a.Account.Restore(s.Account)
+
+	for symbol, charts := range s.Charts {
+		for descriptor, cs := range charts {
+			chart, ok := a.Charts[symbol][descriptor]
+			if !ok {
+				panic("the checkpoint has a " + descriptor + " chart for " + symbol + ", the algorithm doesn't")
+			}
+
+			chart.Restore(cs)
+		}
+	}
+
+	a.firstTick = s.FirstTick
+	a.lastTick = s.LastTick.Tick()
+
+	a.latestTicks = make(map[string]*ticks.MarketTick)
+	for symbol, ts := range s.LatestTicks {
+		a.latestTicks[symbol] = ts.Tick()
+	}
+
+	a.leadingTicks = make(map[string]*list.List)
+	for _, leading := range s.LeadingTicks {
+		for _, ts := range leading {
+			a.recordLeadingTick(ts.Tick())
+		}
+	}
+
+	restored := make(map[indicators.Indicator]bool)
+
+	for _, currency := range a.currencies {
+		for _, name := range a.indiOrder {
+			indi := a.indis[currency][name]
+			if restored[indi] {
+				continue
+			}
+			restored[indi] = true
+
+			is, ok := s.Indicators[currency][name]
+			if !ok {
+				panic("the checkpoint doesn't have indicator " + name + " for " + currency)
+			}
+
+			indicators.Restore(indi, is)
+		}
+	}
+
+	for name, queues := range s.CrossBars {
+		for i, queue := range queues {
+			a.crossBars[name][i] = nil
+
+			for _, cs := range queue {
+				a.crossBars[name][i] = append(a.crossBars[name][i], cs.Restore())
+			}
+		}
+	}
+
+	for i, ms := range s.Members {
+		m := a.members[i]
+
+		m.weight = ms.Weight
+		m.scheduler.Restore(ms.Scheduler)
+
+		if ss, ok := m.strategy.(StatefulStrategy); ok {
+			ss.Restore(ms.Strategy)
+		}
+	}
+
+	if nil != s.Breakers {
+		a.breakers.Restore(*s.Breakers)
+	}
+
+	if nil != s.Rejection {
+		r := s.Rejection.Rejection(a.Account)
+		a.Broker.(*algorithmBroker).rejection = &r
+	}
+
+	a.day = s.Day
+	if !a.day.IsZero() {
+		a.day = a.day.In(candles.NEW_YORK) // gob only keeps the offset
+	}
+
+	a.firstTickAfter, a.warm, a.draining = s.FirstTickAfter, s.Warm, s.Draining
+
+	ticks.RestoreMetadata(&a.Metadata, s.Metadata)
+}
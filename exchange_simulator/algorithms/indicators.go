@@ -0,0 +1,402 @@
+package algorithms
+
+import (
+	"strings"
+	"time"
+
+	"../candles"
+	"../indicators"
+	"../loggers"
+	"../ticks"
+)
+
+// ===== INDICATORS ================================================================================
+
+// Which chart a bar or derived indicator is updated from
+type Binding struct {
+	Symbol string // "" for one indicator per currency, each on its own chart
+	Chart  string // descriptor, see candles.NewChart
+}
+
+// AddIndicator panics if name already exists, or if its inputs (see indicators.DerivedIndicator)
+// would make a cycle. Inputs can be added later, as long as they're all there by Init.
+//
+// Bar indicators run on each currency's M1 chart, derived indicators on the chart of their first
+// input. See AddIndicatorOn for anything else.
+func (a *Algorithm) AddIndicator(name string, indi func() indicators.Indicator) {
+	a.AddIndicatorOn(name, Binding{}, indi)
+}
+
+// AddIndicatorOn binds an indicator to a chart, e.g. Binding{Chart: "H4"} for a trend filter next
+// to an M5 entry trigger. With a Symbol, a single indicator on that symbol's chart is shared by
+// every currency. Cross indicators (see indicators.CrossIndicator) are always shared and ignore the
+// Symbol.
+func (a *Algorithm) AddIndicatorOn(name string, binding Binding, indi func() indicators.Indicator) {
+	if nil == a.indis {
+		a.indis = make(map[string]map[string]indicators.Indicator)
+		a.indiDeps = make(map[string][]string)
+		a.indiBindings = make(map[string]Binding)
+	}
+
+	if 0 == len(a.currencies) {
+		panic("subscribe to currencies before adding indicators")
+	}
+
+	if _, exists := a.indiDeps[name]; exists {
+		panic("attempted to add indicator \"" + name + "\" which already exists!")
+	}
+
+	if "" != binding.Symbol && !a.WantsCurrency(binding.Symbol) {
+		panic("indicators can only be bound to subscribed currencies, not " + binding.Symbol)
+	}
+
+	first := indi()
+	shared := "" != binding.Symbol
+
+	if ci, ok := first.(indicators.CrossIndicator); ok {
+		for _, symbol := range ci.Symbols() {
+			if !a.WantsCurrency(symbol) {
+				panic("cross indicator \"" + name + "\" needs unsubscribed currency: " + symbol)
+			}
+		}
+
+		binding.Symbol = ""
+		shared = true
+	}
+
+	created := make(map[string]indicators.Indicator)
+	for _, currency := range a.currencies {
+		if shared || currency == a.currencies[0] {
+			created[currency] = first
+		} else {
+			created[currency] = indi()
+		}
+	}
+
+	deps := []string{}
+	if di, ok := created[a.currencies[0]].(indicators.DerivedIndicator); ok {
+		for _, input := range di.Inputs() {
+			deps = append(deps, input.Indicator)
+		}
+	}
+
+	a.indiDeps[name] = deps
+	a.indiNames = append(a.indiNames, name)
+
+	order, cycle := a.sortIndicators()
+	if nil != cycle {
+		delete(a.indiDeps, name)
+		a.indiNames = a.indiNames[:len(a.indiNames) - 1]
+
+		panic("indicator dependency cycle: " + strings.Join(cycle, " -> "))
+	}
+
+	a.indiOrder = order
+	a.indiBindings[name] = binding
+
+	for currency, indi := range created {
+		if _, exists := a.indis[currency]; !exists {
+			a.indis[currency] = make(map[string]indicators.Indicator)
+		}
+
+		a.indis[currency][name] = indi
+	}
+}
+
+// sortIndicators orders indicators so everything comes after its inputs, or returns the first cycle
+// it finds. Inputs that haven't been added yet are left out, checkIndicatorInputs catches them.
+func (a *Algorithm) sortIndicators() ([]string, []string) {
+	const (
+		VISITING = 1
+		DONE     = 2
+	)
+
+	order := []string{}
+	state := make(map[string]int)
+
+	var visit func(name string, path []string) []string
+	visit = func(name string, path []string) []string {
+		path = append(path, name)
+
+		switch state[name] {
+		case VISITING:
+			return path
+		case DONE:
+			return nil
+		}
+
+		deps, known := a.indiDeps[name]
+		if !known {
+			return nil
+		}
+
+		state[name] = VISITING
+		for _, dep := range deps {
+			if cycle := visit(dep, path); nil != cycle {
+				return cycle
+			}
+		}
+		state[name] = DONE
+
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range a.indiNames {
+		if cycle := visit(name, nil); nil != cycle {
+			// trim the lead in so it starts where the cycle does
+			for i := range cycle {
+				if cycle[i] == cycle[len(cycle) - 1] {
+					return nil, cycle[i:]
+				}
+			}
+		}
+	}
+
+	return order, nil
+}
+
+// resolveBindings fills in default charts, which for derived indicators depends on their inputs
+func (a *Algorithm) resolveBindings() {
+	a.resolved = make(map[string]Binding)
+
+	for _, name := range a.indiOrder {
+		binding := a.indiBindings[name]
+
+		if "" == binding.Chart {
+			binding.Chart = "M1"
+
+			di, ok := a.indis[a.currencies[0]][name].(indicators.DerivedIndicator)
+			if ok && len(di.Inputs()) > 0 {
+				binding = a.resolved[di.Inputs()[0].Indicator]
+			}
+		}
+
+		a.resolved[name] = binding
+	}
+}
+
+func (a *Algorithm) bindingKey(currency, name string) (string, string, string) {
+	binding := a.resolved[name]
+
+	symbol := binding.Symbol
+	if "" == symbol {
+		symbol = currency
+	}
+
+	return symbol + " " + binding.Chart, symbol, binding.Chart
+}
+
+// watchChart makes sure the chart exists and its closed candles get recorded, see runBarIndicators
+func (a *Algorithm) watchChart(symbol, descriptor string) candles.Chart {
+	key := symbol + " " + descriptor
+
+	chart := a.chart(symbol, descriptor)
+
+	if !a.watched[key] {
+		a.watched[key] = true
+
+		chart.OnClose(func(candle *candles.Candle) {
+			a.closedBars = append(a.closedBars, closedBar{key: key, candle: candle})
+		})
+	}
+
+	return chart
+}
+
+// a candle some chart closed, by the chart's binding key
+type closedBar struct {
+	key    string
+	candle *candles.Candle
+}
+
+func (a *Algorithm) checkIndicatorInputs() {
+	for _, currency := range a.currencies {
+		for _, name := range a.indiOrder {
+			di, ok := a.indis[currency][name].(indicators.DerivedIndicator)
+			if !ok {
+				continue
+			}
+
+			for _, input := range di.Inputs() {
+				source, exists := a.indis[currency][input.Indicator]
+				if !exists {
+					panic("indicator \"" + name + "\" takes input from unknown indicator: " + input.Indicator)
+				}
+
+				if indicators.FLOAT != source.Outputs().Kind(input.Output) {
+					panic("indicator \"" + input.Indicator + "\" has no float output: " + input.Output)
+				}
+			}
+		}
+	}
+}
+
+func (a *Algorithm) ReadIndicator(currency, name string) indicators.Indicator {
+	indi, ok := a.indis[currency][name]
+	if !ok {
+		panic("Unknown indicator: " + name)
+	}
+
+	return indi
+}
+
+func (a *Algorithm) IndicatorReady(currency, name string) bool {
+	return a.ReadIndicator(currency, name).Ready()
+}
+
+// ReadFloat returns an indicator's float output as it was ago updates back, 0 being the latest
+func (a *Algorithm) ReadFloat(currency, name, output string, ago int64) float64 {
+	return a.ReadIndicator(currency, name).Outputs().Float(output).At(ago)
+}
+
+// ReadBool returns an indicator's bool output as it was ago updates back, 0 being the latest
+func (a *Algorithm) ReadBool(currency, name, output string, ago int64) bool {
+	return a.ReadIndicator(currency, name).Outputs().Bool(output).At(ago)
+}
+
+func (a *Algorithm) dumpIndis() {
+	if !a.log.Enabled(loggers.DEBUG) {
+		return
+	}
+
+	for _, currency := range a.currencies {
+		for key, value := range a.indis[currency] {
+			fields := []interface{}{"currency", currency, "indicator", key, "ready", value.Ready()}
+
+			outputs := value.Outputs()
+
+			for _, output := range outputs.Names() {
+				switch outputs.Kind(output) {
+				case indicators.FLOAT:
+					if series := outputs.Float(output); series.Len() > 0 {
+						fields = append(fields, output, series.Value())
+					}
+				case indicators.BOOL:
+					if series := outputs.Bool(output); series.Len() > 0 {
+						fields = append(fields, output, series.Value())
+					}
+				}
+			}
+
+			a.log.Debug("indicator", fields...)
+		}
+	}
+}
+
+func (a *Algorithm) runIndicators(tick *ticks.MarketTick) {
+	for _, currency := range a.currencies {
+		if currency != tick.Symbol {
+			continue
+		}
+
+		for _, name := range a.indiOrder {
+			a.indis[currency][name].OnTick(a, tick)
+		}
+	}
+
+	a.runBarIndicators()
+}
+
+// runBarIndicators updates the bar and derived indicators whose charts closed a candle on this
+// tick. Candles go in the order they closed and each runs the whole DAG, inputs first, so a derived
+// indicator never sees its input more than one candle on.
+func (a *Algorithm) runBarIndicators() {
+	if 0 == len(a.closedBars) {
+		return
+	}
+
+	for _, bar := range a.closedBars {
+		for _, name := range a.indiOrder {
+			if _, ok := a.crossBars[name]; ok {
+				a.runCrossIndicator(name, bar)
+				continue
+			}
+
+			for _, currency := range a.currencies {
+				if "" != a.resolved[name].Symbol && currency != a.currencies[0] {
+					continue // shared, see AddIndicatorOn
+				}
+
+				if key, _, _ := a.bindingKey(currency, name); key == bar.key {
+					a.runBarIndicator(currency, name, bar.candle)
+				}
+			}
+		}
+	}
+
+	a.closedBars = a.closedBars[:0]
+}
+
+func (a *Algorithm) runBarIndicator(currency, name string, candle *candles.Candle) {
+	switch indi := a.indis[currency][name].(type) {
+	case indicators.BarIndicator:
+		indi.OnBar(candle)
+	case indicators.DerivedIndicator:
+		inputs := indi.Inputs()
+		values := make([]float64, len(inputs))
+
+		for i, input := range inputs {
+			source := a.indis[currency][input.Indicator]
+			if !source.Ready() {
+				return
+			}
+
+			values[i] = source.Outputs().Float(input.Output).Value()
+		}
+
+		indi.OnInputs(values)
+	}
+}
+
+// runCrossIndicator queues each symbol's candles until every symbol has closed the same period.
+// Periods some symbols didn't trade in are dropped.
+func (a *Algorithm) runCrossIndicator(name string, bar closedBar) {
+	ci := a.indis[a.currencies[0]][name].(indicators.CrossIndicator)
+	queues := a.crossBars[name]
+
+	queued := false
+	for i, symbol := range ci.Symbols() {
+		if symbol + " " + a.resolved[name].Chart == bar.key {
+			queues[i] = append(queues[i], bar.candle)
+			queued = true
+		}
+	}
+
+	if !queued {
+		return
+	}
+
+	for {
+		latest := time.Time{}
+		for _, queue := range queues {
+			if 0 == len(queue) {
+				return
+			}
+
+			if queue[0].OpenTime.After(latest) {
+				latest = queue[0].OpenTime
+			}
+		}
+
+		aligned := true
+		for i, queue := range queues {
+			if queue[0].OpenTime.Before(latest) {
+				queues[i] = queue[1:]
+				aligned = false
+			}
+		}
+
+		if !aligned {
+			continue
+		}
+
+		bars := make([]*candles.Candle, len(queues))
+		for i, queue := range queues {
+			bars[i], queues[i] = queue[0], queue[1:]
+		}
+
+		ci.OnBars(bars)
+	}
+}
@@ -0,0 +1,206 @@
+package algorithms
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"../accounts"
+	"../loggers"
+	"../orders"
+	"../schedulers"
+	"../utils"
+
+	"../../ga/trading_decisions"
+)
+
+// ===== PORTFOLIOS ================================================================================
+
+// NewPortfolio is an algorithm for several strategies trading one account, e.g.
+//
+//   algo := algorithms.NewPortfolio(acc)
+//   algo.AddStrategy("trend", trend, 0.6)
+//   algo.AddStrategy("reversion", reversion, 0.4)
+//
+// They share the charts and indicators. Every order is tagged with the strategy that opened it
+// and its hooks go to that strategy, everything else goes to all of them in the order they were
+// added.
+func NewPortfolio(acc *accounts.Account) *Algorithm {
+	algo := Algorithm{Account: acc, portfolio: true}
+	algo.initLog()
+
+	return &algo
+}
+
+type member struct {
+	tag      string
+	strategy Strategy
+	weight   float64
+
+	scheduler *schedulers.Scheduler
+	decision  trading_decisions.TradingDecision
+
+	log *loggers.Logger
+}
+
+// AddStrategy adds s to a portfolio, weight is the share of the account it trades with: the lots
+// it asks for are scaled by it, see SetWeight
+func (a *Algorithm) AddStrategy(tag string, s Strategy, weight float64) {
+	if !a.portfolio {
+		panic("strategies can only be added to a portfolio, see NewPortfolio")
+	}
+
+	if "" == tag {
+		panic("portfolio strategies need a tag")
+	}
+
+	for _, m := range a.members {
+		if m.tag == tag {
+			panic("portfolio already has a strategy tagged " + tag)
+		}
+	}
+
+	checkWeight(weight)
+
+	m := &member{tag: tag, strategy: s, weight: weight, scheduler: schedulers.NewScheduler(), log: a.log.With("strategy", tag)}
+	a.members = append(a.members, m)
+
+	if 1 == len(a.members) {
+		a.Scheduler = m.scheduler
+	}
+}
+
+func checkWeight(weight float64) {
+	if weight <= 0.0 || weight > 1.0 {
+		panic(fmt.Sprintf("weight must be > 0.0 and <= 1.0 (got: %.2f)", weight))
+	}
+}
+
+// SetWeight rebalances a portfolio, from then on the strategy's orders are sized by weight. Open
+// orders are left alone.
+func (a *Algorithm) SetWeight(tag string, weight float64) {
+	checkWeight(weight)
+
+	a.member(tag).weight = weight
+}
+
+func (a *Algorithm) Weight(tag string) float64 {
+	return a.member(tag).weight
+}
+
+// Tags are the portfolio's strategies, in the order they were added
+func (a *Algorithm) Tags() []string {
+	tags := []string{}
+	for _, m := range a.members {
+		tags = append(tags, m.tag)
+	}
+
+	return tags
+}
+
+func (a *Algorithm) member(tag string) *member {
+	for _, m := range a.members {
+		if m.tag == tag {
+			return m
+		}
+	}
+
+	panic("no strategy tagged " + tag)
+}
+
+// owner is the member that opened o, the first one if it's not tagged
+func (a *Algorithm) owner(o *orders.Order) *member {
+	for _, m := range a.members {
+		if m.tag == o.Tag {
+			return m
+		}
+	}
+
+	return a.members[0]
+}
+
+// with runs f on m's strategy. In a portfolio m's Scheduler and TradingDecision are put in place
+// for it, and whatever was there put back after since hooks nest (an order filled from OnTick).
+func (a *Algorithm) with(m *member, f func(Strategy)) {
+	outer := a.active
+
+	if !a.portfolio {
+		a.active = m
+		f(m.strategy)
+		a.active = outer
+		return
+	}
+
+	scheduler := a.Scheduler
+
+	// the outer hook may have changed its decision before calling in
+	if nil != outer {
+		outer.decision = a.TradingDecision
+	}
+
+	a.active, a.Scheduler, a.TradingDecision = m, m.scheduler, m.decision
+
+	f(m.strategy)
+
+	m.decision = a.TradingDecision
+
+	a.active, a.Scheduler, a.TradingDecision = outer, scheduler, nil
+
+	if nil != outer {
+		a.TradingDecision = outer.decision
+	}
+}
+
+func (a *Algorithm) each(f func(Strategy)) {
+	for _, m := range a.members {
+		a.with(m, f)
+	}
+}
+
+// ----- summary -----------------------------------------------------------------------------------
+
+// printPortfolioSummary splits the account's results by strategy. Drawdowns are on closed trades,
+// so the portfolio's can be compared to the strategies' to see what diversification bought.
+func (a *Algorithm) printPortfolioSummary() {
+	fmt.Printf("***** PORTFOLIO *****\n\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Strategy\tWeight\tTrades\tWin %\tProfit\tMax DD\tMax lots\tIn market\t")
+
+	row := func(name, weight string, s accounts.TagStats) {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%.1f%%\t%s\t%s\t%.2f\t%.1f%%\t\n",
+			name,
+			weight,
+			utils.AddCommas(s.Trades),
+			s.WinPercentage(),
+			utils.FormatMoney(s.Profit),
+			utils.FormatMoney(s.MaxDrawdown),
+			s.MaxLots,
+			s.PercentInMarket(),
+		)
+	}
+
+	summed := 0.0
+
+	for _, m := range a.members {
+		s := a.Account.TagStats(m.tag)
+		summed += s.MaxDrawdown
+
+		row(m.tag, fmt.Sprintf("%.2f", m.weight), s)
+	}
+
+	portfolio := a.Account.TagStats(accounts.ALL_TAGS)
+	row("portfolio", "", portfolio)
+
+	w.Flush()
+
+	fmt.Printf("\nMax drawdown: %s for the portfolio, %s summed across strategies", utils.FormatMoney(portfolio.MaxDrawdown), utils.FormatMoney(summed))
+
+	if summed > 0.0 {
+		fmt.Printf(" (%.1f%% lower)", 100.0 * (summed - portfolio.MaxDrawdown) / summed)
+	}
+
+	fmt.Printf("\n\n")
+}
@@ -0,0 +1,164 @@
+package algorithms
+
+import (
+	"sort"
+	"time"
+
+	"../candles"
+	"../journals"
+	"../orders"
+	"../ticks"
+)
+
+// ===== STRATEGIES ================================================================================
+
+// Everything the algorithm tells a strategy about. OnTick and OnBarClose wait for the algorithm to
+// warm up (see IsWarm), the order hooks fire whenever the exchange fills, closes or changes an
+// order for the algorithm's account. Embed BaseStrategy to only implement some of them.
+type Strategy interface {
+	OnStart(a *Algorithm)
+	OnTick(a *Algorithm, tick *ticks.MarketTick)
+	OnBarClose(a *Algorithm, symbol, period string, candle *candles.Candle)
+
+	OnOrderFilled(a *Algorithm, o *orders.Order)
+	OnOrderClosed(a *Algorithm, o *orders.Order, reason orders.CloseReason)
+	OnStopModified(a *Algorithm, o *orders.Order)
+	OnMarginCall(a *Algorithm)
+
+	// days start at 17:00 New York time, like the forex trading day
+	OnDayStart(a *Algorithm, day time.Time)
+	OnDayEnd(a *Algorithm, day time.Time)
+
+	OnStop(a *Algorithm)
+}
+
+type BaseStrategy struct{}
+
+func (bs *BaseStrategy) OnStart(a *Algorithm)                                                    {}
+func (bs *BaseStrategy) OnTick(a *Algorithm, tick *ticks.MarketTick)                             {}
+func (bs *BaseStrategy) OnBarClose(a *Algorithm, symbol, period string, candle *candles.Candle)  {}
+func (bs *BaseStrategy) OnOrderFilled(a *Algorithm, o *orders.Order)                             {}
+func (bs *BaseStrategy) OnOrderClosed(a *Algorithm, o *orders.Order, reason orders.CloseReason)  {}
+func (bs *BaseStrategy) OnStopModified(a *Algorithm, o *orders.Order)                            {}
+func (bs *BaseStrategy) OnMarginCall(a *Algorithm)                                               {}
+func (bs *BaseStrategy) OnDayStart(a *Algorithm, day time.Time)                                  {}
+func (bs *BaseStrategy) OnDayEnd(a *Algorithm, day time.Time)                                    {}
+func (bs *BaseStrategy) OnStop(a *Algorithm)                                                     {}
+
+// FuncStrategy is a plain logic function, what NewWithDeets takes
+type FuncStrategy struct {
+	BaseStrategy
+
+	Logic func(*Algorithm, *ticks.MarketTick)
+}
+
+func (fs *FuncStrategy) OnTick(a *Algorithm, tick *ticks.MarketTick) {
+	fs.Logic(a, tick)
+}
+
+// LatestTick is the last tick seen for symbol, e.g. to close orders from a timer
+func (a *Algorithm) LatestTick(symbol string) *ticks.MarketTick {
+	return a.latestTicks[symbol]
+}
+
+// OpenOrders are the account's open orders opened by the strategy whose hook is running, so
+// strategies in a portfolio leave each other's alone. Outside a hook it's every open order.
+func (a *Algorithm) OpenOrders() []*orders.Order {
+	if nil == a.active {
+		return a.Account.OpenOrders()
+	}
+
+	res := []*orders.Order{}
+
+	for _, o := range a.Account.OpenOrders() {
+		if a.active.tag == o.Tag {
+			res = append(res, o)
+		}
+	}
+
+	return res
+}
+
+func (a *Algorithm) HasOpenOrders() bool {
+	return 0 != len(a.OpenOrders())
+}
+
+// Strategy is the first strategy, the only one unless it's a portfolio
+func (a *Algorithm) Strategy() Strategy {
+	return a.members[0].strategy
+}
+
+// OrderFilled, OrderClosed and StopModified are for the exchange to call, see Strategy. They go to
+// the strategy that opened the order.
+
+func (a *Algorithm) OrderFilled(o *orders.Order) {
+	if "" == o.Tag && nil != a.active {
+		o.Tag = a.active.tag
+	}
+
+	a.record(journals.Filled(o))
+
+	a.with(a.owner(o), func(s Strategy) { s.OnOrderFilled(a, o) })
+}
+
+func (a *Algorithm) OrderClosed(o *orders.Order) {
+	if nil != a.breakers {
+		profit := o.Profit() + o.Commission()
+		a.breakers.TradeClosed(profit, a.Account.GetEquity() - profit)
+	}
+
+	a.record(journals.Closed(o))
+
+	a.with(a.owner(o), func(s Strategy) { s.OnOrderClosed(a, o, o.CloseReason) })
+}
+
+func (a *Algorithm) StopModified(o *orders.Order) {
+	a.record(journals.StopsMoved(a.now(), o))
+
+	a.with(a.owner(o), func(s Strategy) { s.OnStopModified(a, o) })
+}
+
+type barClose struct {
+	symbol string
+	period string
+	candle *candles.Candle
+}
+
+// charts update in map order, this keeps bar closes in the same order run to run
+type byChart []barClose
+
+func (bc byChart) Len() int      { return len(bc) }
+func (bc byChart) Swap(i, j int) { bc[i], bc[j] = bc[j], bc[i] }
+
+func (bc byChart) Less(i, j int) bool {
+	if bc[i].symbol != bc[j].symbol {
+		return bc[i].symbol < bc[j].symbol
+	}
+
+	return bc[i].period < bc[j].period
+}
+
+// dispatch tells the strategies about the tick, after whatever closed or started because of it.
+// Each step goes to every strategy before the next.
+func (a *Algorithm) dispatch(tick *ticks.MarketTick) {
+	day := candles.TradingDay(tick.Time)
+
+	if !day.Equal(a.day) {
+		if !a.day.IsZero() {
+			a.each(func(s Strategy) { s.OnDayEnd(a, a.day) })
+		}
+
+		a.day = day
+		a.each(func(s Strategy) { s.OnDayStart(a, day) })
+	}
+
+	a.each(func(s Strategy) { a.Scheduler.Advance(tick.Time) })
+
+	sort.Sort(byChart(a.barCloses))
+
+	for _, bc := range a.barCloses {
+		a.each(func(s Strategy) { s.OnBarClose(a, bc.symbol, bc.period, bc.candle) })
+	}
+
+	a.each(func(s Strategy) { s.OnTick(a, tick) })
+}
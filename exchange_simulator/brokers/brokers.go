@@ -1,15 +1,64 @@
 package brokers
 
 import (
+	"time"
+
 	"../accounts"
 	"../orders"
 	"../stops"
 	"../ticks"
 )
 
+// Opening an order returns nil when the broker refuses it, a Rejecter can say why
 type Broker interface {
 	CloseOrder(*accounts.Account, *orders.Order, *ticks.MarketTick)
 	CloseAllOrders(*accounts.Account, map[string]*ticks.MarketTick)
 	OpenBuyOrder(*accounts.Account, string, *ticks.MarketTick, float64, stops.StopLoss, stops.TakeProfit) *orders.Order
 	OpenSellOrder(*accounts.Account, string, *ticks.MarketTick, float64, stops.StopLoss, stops.TakeProfit) *orders.Order
 }
+
+// ===== REJECTIONS ================================================================================
+
+type Rejection struct {
+	At        time.Time
+	Account   *accounts.Account
+	Symbol    string
+	Direction orders.TradeDirection
+	Lots      float64
+	Reason    string
+}
+
+type Rejecter interface {
+	LastRejection(*accounts.Account) (Rejection, bool)
+}
+
+// Brokers with something to add to the end of run report
+type Summarizer interface {
+	PrintSummary()
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+// Brokers that keep anything between orders save it in checkpoints, accounts are passed in the
+// order the run has them so the state can point at them by index
+type Stateful interface {
+	State(accts []*accounts.Account) interface{}
+	Restore(saved interface{}, accts []*accounts.Account)
+}
+
+// RejectionState is a Rejection without its account, whatever saves it knows which one it was
+type RejectionState struct {
+	At        time.Time
+	Symbol    string
+	Direction orders.TradeDirection
+	Lots      float64
+	Reason    string
+}
+
+func (r Rejection) State() RejectionState {
+	return RejectionState{At: r.At, Symbol: r.Symbol, Direction: r.Direction, Lots: r.Lots, Reason: r.Reason}
+}
+
+func (s RejectionState) Rejection(acc *accounts.Account) Rejection {
+	return Rejection{At: s.At, Account: acc, Symbol: s.Symbol, Direction: s.Direction, Lots: s.Lots, Reason: s.Reason}
+}
@@ -1,9 +1,13 @@
 package candles
 
 import (
-	"container/list"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
+	"../pips"
 	"../quotes"
 	"../ticks"
 )
@@ -19,6 +23,33 @@ const (
 	W1  = D1 * 7
 )
 
+// Forex trading days end at 17:00 New York time, so that's where daily bars should split
+var NEW_YORK *time.Location
+const NEW_YORK_CLOSE = 17 * time.Hour
+
+func init() {
+	var err error
+
+	NEW_YORK, err = time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TradingDay is when the forex trading day t is in started
+func TradingDay(t time.Time) time.Time {
+	local := t.In(NEW_YORK)
+
+	y, m, d := local.Date()
+	start := time.Date(y, m, d, int(NEW_YORK_CLOSE / time.Hour), 0, 0, 0, NEW_YORK) // not Add, it's 17:00 on DST days too
+
+	if start.After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+
+	return start
+}
+
 var validCharts = map[string]int64{
 	"M1":  M1,
 	"M5":  M5,
@@ -43,21 +74,421 @@ type Candle struct {
 	LowBid  float64
 	LowAsk  float64
 
-	Volume int64
+	Volume    int64
+	TickCount int64
+
+	OpenTime  time.Time
+	CloseTime time.Time
 
 	id int64
 }
 
+func (c *Candle) update(tick *ticks.MarketTick) {
+	if tick.HighBid > c.HighBid {
+		c.HighBid = tick.HighBid
+	}
+	if tick.HighAsk > c.HighAsk {
+		c.HighAsk = tick.HighAsk
+	}
+	if tick.LowBid < c.LowBid {
+		c.LowBid = tick.LowBid
+	}
+	if tick.LowAsk < c.LowAsk {
+		c.LowAsk = tick.LowAsk
+	}
+
+	c.CloseBid = tick.CloseBid
+	c.CloseAsk = tick.CloseAsk
+
+	c.Volume    += tick.Volume
+	c.TickCount += 1
+}
+
 // Note: can legitimately return negative values
 func (c *Candle) Spread() float64 {
 	// TODO: get rid of hardcoded currency
 	return float64(quotes.DifferenceInPips("EURUSD", c.OpenBid, c.OpenAsk))
 }
 
+// newCandle starts a candle from a single tick
+func newCandle(tick *ticks.MarketTick) *Candle {
+	return &Candle{
+		OpenBid:   tick.OpenBid,
+		OpenAsk:   tick.OpenAsk,
+		CloseBid:  tick.CloseBid,
+		CloseAsk:  tick.CloseAsk,
+		HighBid:   tick.HighBid,
+		HighAsk:   tick.HighAsk,
+		LowBid:    tick.LowBid,
+		LowAsk:    tick.LowAsk,
+		Volume:    tick.Volume,
+		TickCount: 1,
+		OpenTime:  tick.Time,
+		CloseTime: tick.Time,
+	}
+}
+
+// ===== CHART =====================================================================================
+
+// Anything that builds candles out of ticks. Time based charts are CandleCharts, see NewChart for
+// the rest.
+type Chart interface {
+	Update(current, last *ticks.MarketTick)
+
+	// GetCandles returns the n most recent finished candles, newest first
+	GetCandles(n int64) []*Candle
+	At(i int64) *Candle
+	Column(col int, n int64) []float64
+	Len() int
+	Finished() int64
+
+	// Keep makes sure the chart holds on to at least n finished candles. Call it before any close.
+	Keep(n int64)
+
+	OnClose(f func(*Candle))
+	Print()
+
+	// for checkpoints, Restore doesn't call OnClose for the candles it puts back
+	State() ChartState
+	Restore(ChartState)
+}
+
+// NewChart builds a chart from a descriptor:
+//
+//   M1 ... W1       time based, see validCharts
+//   HA:<period>     Heikin-Ashi candles on top of a time based chart, e.g. HA:M5
+//   RENKO:<pips>    Renko bricks of the given size
+//   RANGE:<pips>    a new candle whenever the bid range reaches the given size
+//   TICK:<count>    a new candle every count ticks
+//   VOLUME:<volume> a new candle every time volume reaches the given amount
+func NewChart(descriptor string, maxCandles int64) Chart {
+	return NewAlignedChart(descriptor, maxCandles, time.UTC, 0)
+}
+
+// PeriodOf is how long each candle of a descriptor's chart lasts, false for charts that don't go by
+// time like RENKO:10
+func PeriodOf(descriptor string) (time.Duration, bool) {
+	if strings.HasPrefix(strings.ToUpper(descriptor), "HA:") {
+		descriptor = descriptor[3:]
+	}
+
+	period, ok := validCharts[descriptor]
+
+	return time.Duration(period) * time.Second, ok
+}
+
+// NewAlignedChart is NewChart with time based charts aligned like NewAlignedCandleChart
+func NewAlignedChart(descriptor string, maxCandles int64, loc *time.Location, offset time.Duration) Chart {
+	parts := strings.SplitN(descriptor, ":", 2)
+	if 1 == len(parts) {
+		return NewAlignedCandleChart(descriptor, maxCandles, loc, offset)
+	}
+
+	kind, arg := strings.ToUpper(parts[0]), parts[1]
+
+	if "HA" == kind {
+		return NewHeikinAshiChart(NewAlignedCandleChart(arg, 2, loc, offset), maxCandles)
+	}
+
+	switch kind {
+	case "RENKO", "RANGE":
+		size, err := strconv.ParseFloat(arg, 64)
+		if err != nil || size <= 0.0 {
+			panic("bad chart size in descriptor: " + descriptor)
+		}
+
+		if "RENKO" == kind {
+			return NewRenkoChart(pips.Pip(size), maxCandles)
+		}
+
+		return NewRangeChart(pips.Pip(size), maxCandles)
+	case "TICK", "VOLUME":
+		// there's no such thing as half a tick, so don't round one down to 0
+		threshold, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || threshold <= 0 {
+			panic("bad chart size in descriptor, it must be a whole number: " + descriptor)
+		}
+
+		if "TICK" == kind {
+			return NewTickChart(threshold, maxCandles)
+		}
+
+		return NewVolumeChart(threshold, maxCandles)
+	}
+
+	panic("unknown chart type in descriptor: " + descriptor)
+}
+
+// ===== CANDLE STORE ==============================================================================
+
+// Columns for Chart.Column
+const (
+	OPEN_BID = iota
+	HIGH_BID
+	LOW_BID
+	CLOSE_BID
+	OPEN_ASK
+	HIGH_ASK
+	LOW_ASK
+	CLOSE_ASK
+	VOLUME
+
+	numColumns
+)
+
+func columnValue(c *Candle, col int) float64 {
+	switch col {
+	case OPEN_BID:
+		return c.OpenBid
+	case HIGH_BID:
+		return c.HighBid
+	case LOW_BID:
+		return c.LowBid
+	case CLOSE_BID:
+		return c.CloseBid
+	case OPEN_ASK:
+		return c.OpenAsk
+	case HIGH_ASK:
+		return c.HighAsk
+	case LOW_ASK:
+		return c.LowAsk
+	case CLOSE_ASK:
+		return c.CloseAsk
+	case VOLUME:
+		return float64(c.Volume)
+	}
+
+	panic(fmt.Sprintf("unknown candle column: %d", col))
+}
+
+// Keeps the candle that's forming plus up to maxCandles finished ones in a ring buffer. Everything
+// is written twice, at i and i + maxCandles, so the newest n finished candles (or any column of
+// them) are always one contiguous, newest first slice of the buffer and reads never allocate.
+type candleStore struct {
+	maxCandles int64
+
+	forming *Candle
+
+	head    int64 // where the newest finished candle is
+	count   int64 // finished candles
+	candles []*Candle
+	columns [][]float64
+
+	onClose []func(*Candle)
+}
+
+// OnClose registers f to be called with each candle once it's finished
+func (cs *candleStore) OnClose(f func(*Candle)) {
+	cs.onClose = append(cs.onClose, f)
+}
+
+func (cs *candleStore) current() *Candle {
+	return cs.forming
+}
+
+func (cs *candleStore) open(c *Candle) {
+	cs.forming = c
+}
+
+func (cs *candleStore) close() {
+	c := cs.forming
+	if nil == c {
+		return
+	}
+
+	cs.store(c)
+	cs.forming = nil
+
+	for _, f := range cs.onClose {
+		f(c)
+	}
+}
+
+// store puts c in front of the finished candles
+func (cs *candleStore) store(c *Candle) {
+	if nil == cs.candles {
+		if cs.maxCandles < 1 {
+			panic("charts must keep at least one candle")
+		}
+
+		cs.candles = make([]*Candle, 2 * cs.maxCandles)
+		cs.columns = make([][]float64, numColumns)
+		for col := range cs.columns {
+			cs.columns[col] = make([]float64, 2 * cs.maxCandles)
+		}
+		cs.head = 0
+	} else {
+		cs.head = (cs.head + cs.maxCandles - 1) % cs.maxCandles
+	}
+
+	cs.candles[cs.head] = c
+	cs.candles[cs.head + cs.maxCandles] = c
+
+	for col := range cs.columns {
+		val := columnValue(c, col)
+		cs.columns[col][cs.head] = val
+		cs.columns[col][cs.head + cs.maxCandles] = val
+	}
+
+	if cs.count < cs.maxCandles {
+		cs.count += 1
+	}
+}
+
+// Len counts the forming candle too, so it's one more than the most you can ask GetCandles for
+func (cs *candleStore) Len() int {
+	if nil != cs.forming {
+		return int(cs.count) + 1
+	}
+
+	return int(cs.count)
+}
+
+func (cs *candleStore) Finished() int64 {
+	return cs.count
+}
+
+func (cs *candleStore) Keep(n int64) {
+	if nil != cs.candles {
+		panic("too late to keep more candles, some have closed already")
+	}
+
+	if n > cs.maxCandles {
+		cs.maxCandles = n
+	}
+}
+
+// At returns the ith newest finished candle, At(0) being the one before the forming candle
+func (cs *candleStore) At(i int64) *Candle {
+	if i < 0 || i >= cs.count {
+		panic(fmt.Sprintf("candle index out of range! wanted: %d, have: %d", i, cs.count))
+	}
+
+	return cs.candles[cs.head + i]
+}
+
+func (cs *candleStore) checkAvailable(n int64) {
+	if n <= 0 {
+		panic("n must be >= 0")
+	}
+
+	if n > cs.count {
+		panic(fmt.Sprintf(
+			"not enough candles to fulfill request! wanted: %d, have: %d",
+			n,
+			cs.count,
+		))
+	}
+}
+
+// GetCandles returns a view of the buffer, don't hang on to it or modify it
+func (cs *candleStore) GetCandles(n int64) []*Candle {
+	cs.checkAvailable(n)
+
+	return cs.candles[cs.head : cs.head + n]
+}
+
+// Column returns col (e.g. CLOSE_BID) of the newest n finished candles, newest first. Like
+// GetCandles, it's a view of the buffer.
+func (cs *candleStore) Column(col int, n int64) []float64 {
+	cs.checkAvailable(n)
+
+	if col < 0 || col >= numColumns {
+		panic(fmt.Sprintf("unknown candle column: %d", col))
+	}
+
+	return cs.columns[col][cs.head : cs.head + n]
+}
+
+func (cs *candleStore) Print() {
+	if nil != cs.forming {
+		fmt.Printf("id: %d, val: %#v\n", cs.forming.id, cs.forming)
+	}
+
+	for i := int64(0); i < cs.count; i++ {
+		fmt.Printf("id: %d, val: %#v\n", cs.At(i).id, cs.At(i))
+	}
+}
+
+// ----- checkpoints -------------------------------------------------------------------------------
+
+// CandleState is a candle with the id charts tell the next one by
+type CandleState struct {
+	Candle
+
+	ID int64
+}
+
+func (c *Candle) State() CandleState {
+	return CandleState{Candle: *c, ID: c.id}
+}
+
+// Restore is a new candle from s
+func (s CandleState) Restore() *Candle {
+	c := s.Candle
+	c.id = s.ID
+
+	return &c
+}
+
+// ChartState is a chart's candles, plus whatever else the kind of chart keeps
+type ChartState struct {
+	Forming  *CandleState
+	Finished []CandleState // oldest first
+
+	// RenkoChart
+	Started bool
+	Top     float64
+	Bottom  float64
+
+	// HeikinAshiChart
+	Source *ChartState
+}
+
+func (cs *candleStore) State() ChartState {
+	s := ChartState{}
+
+	if nil != cs.forming {
+		forming := cs.forming.State()
+		s.Forming = &forming
+	}
+
+	for i := cs.count - 1; i >= 0; i-- {
+		s.Finished = append(s.Finished, cs.At(i).State())
+	}
+
+	return s
+}
+
+// Restore needs the chart to keep as many candles as the saved one did, set it up the same way
+func (cs *candleStore) Restore(s ChartState) {
+	if int64(len(s.Finished)) > cs.maxCandles {
+		panic(fmt.Sprintf("can't restore %d candles to a chart that keeps %d", len(s.Finished), cs.maxCandles))
+	}
+
+	cs.candles, cs.columns, cs.count, cs.forming = nil, nil, 0, nil
+
+	for _, c := range s.Finished {
+		cs.store(c.Restore())
+	}
+
+	if nil != s.Forming {
+		cs.forming = s.Forming.Restore()
+	}
+}
+
 // ===== CANDLE CHART ==============================================================================
 
 func NewCandleChart(period string, maxCandles int64) *CandleChart {
-	cc := CandleChart{maxCandles: maxCandles}
+	return NewAlignedCandleChart(period, maxCandles, time.UTC, 0)
+}
+
+// NewAlignedCandleChart splits candles at offset past midnight in loc instead of midnight UTC, e.g.
+// NEW_YORK and NEW_YORK_CLOSE for D1 candles that match the forex trading day. Weekly candles
+// always start on Sunday.
+func NewAlignedCandleChart(period string, maxCandles int64, loc *time.Location, offset time.Duration) *CandleChart {
+	cc := CandleChart{location: loc, offset: int64(offset / time.Second)}
+	cc.maxCandles = maxCandles
 
 	val, ok := validCharts[period]
 	if !ok {
@@ -65,88 +496,353 @@ func NewCandleChart(period string, maxCandles int64) *CandleChart {
 	}
 
 	cc.period = val
+	cc.fixed = 0 == H1 % val
+
+	// the unix epoch was a thursday, move it to sunday
+	if W1 == val {
+		cc.offset += 3 * D1
+	}
 
 	return &cc
 }
 
 type CandleChart struct {
-	list.List
+	candleStore
 
-	maxCandles int64
-	period     int64
-}
-
-func (cc *CandleChart) newCandleFromTick(id int64, tick *ticks.MarketTick) {
-		cs := Candle{
-			OpenBid:  tick.OpenBid,
-			OpenAsk:  tick.OpenAsk,
-			CloseBid: tick.CloseBid,
-			CloseAsk: tick.CloseAsk,
-			HighBid:  tick.HighBid,
-			HighAsk:  tick.HighAsk,
-			LowBid:   tick.LowBid,
-			LowAsk:   tick.LowAsk,
-			Volume:   tick.Volume,
-			id: id,
-		}
+	period int64
+	fixed  bool // the period divides an hour, so DST can't change how long candles are
 
-		cc.PushFront(&cs)
+	location *time.Location
+	offset   int64 // seconds
 }
 
-func (cc *CandleChart) GetCandles(n int64) []*Candle {
-	if n <= 0 {
-		panic("n must be >= 0")
+// ids count periods since the epoch in local time, minus the offset. For fixed periods only the
+// zone offset to within a period matters, so they're counted in absolute time instead and keep
+// going up through the hour the clocks repeat when DST ends.
+func (cc *CandleChart) idFor(t time.Time) (int64, int64) {
+	_, offset := t.In(cc.location).Zone()
+
+	zoneOffset := int64(offset)
+	if cc.fixed {
+		zoneOffset %= cc.period
+	}
+
+	shifted := t.Unix() + zoneOffset - cc.offset
+
+	id := shifted / cc.period
+	if shifted < 0 && 0 != shifted % cc.period {
+		id -= 1
 	}
 
-	c := []*Candle{}
+	return id, zoneOffset
+}
 
-	count := int64(0)
+// localTime is when the clocks in the chart's location read wall, seconds since the epoch
+func (cc *CandleChart) localTime(wall int64) time.Time {
+	w := time.Unix(wall, 0).UTC()
 
-	// we don't want the current candle, start from the previous one
-	e := cc.Front().Next()
+	return time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, cc.location).UTC()
+}
 
-	for ; count < n; e = e.Next() {
-		if nil == e {
-			panic(fmt.Sprintf(
-				"not enough candles to fulfill request! wanted: %d, have: %d",
-				n,
-				cc.Len() - 1, // don't count latest candle, see above
-			))
-		}
-		c = append(c, e.Value.(*Candle))
-		count++
+func (cc *CandleChart) newCandleFromTick(id, zoneOffset int64, tick *ticks.MarketTick) {
+	cs := newCandle(tick)
+	cs.id = id
+
+	if cc.fixed {
+		cs.OpenTime = time.Unix(id * cc.period + cc.offset - zoneOffset, 0).UTC()
+		cs.CloseTime = cs.OpenTime.Add(time.Duration(cc.period) * time.Second)
+	} else {
+		// an hour shorter or longer when the clocks change
+		cs.OpenTime = cc.localTime(id * cc.period + cc.offset)
+		cs.CloseTime = cc.localTime((id + 1) * cc.period + cc.offset)
 	}
 
-	return c
+	cc.open(cs)
 }
 
 func (cc *CandleChart) Print() {
-	fmt.Printf("===== %d chart =====\n")
+	fmt.Printf("===== %d chart =====\n", cc.period)
 
-	for e := cc.Front(); e != nil; e = e.Next() {
-		fmt.Printf("id: %d, val: %#v\n", e.Value.(*Candle).id, e.Value.(*Candle))
-	}
+	cc.candleStore.Print()
 }
 
 func (cc *CandleChart) Update(current, last *ticks.MarketTick) {
-	numCandles := int64(cc.Len())
-	id := int64(current.Time.Unix() / cc.period)
+	id, zoneOffset := cc.idFor(current.Time)
 
-	if 0 == numCandles {
-		cc.newCandleFromTick(id, current)
+	cs := cc.current()
+	if nil == cs {
+		cc.newCandleFromTick(id, zoneOffset, current)
 		return
 	}
 
-	cs := cc.Front().Value.(*Candle)
-
 	if id > cs.id {
-		cc.newCandleFromTick(id, current)
+		cc.close()
+		cc.newCandleFromTick(id, zoneOffset, current)
+		return
+	}
+
+	// still the same candle, even if the clocks went back to before it started
+	cs.update(current)
+}
+
+// ===== HEIKIN-ASHI ===============================================================================
+
+func NewHeikinAshiChart(source *CandleChart, maxCandles int64) *HeikinAshiChart {
+	ha := HeikinAshiChart{source: source}
+	ha.maxCandles = maxCandles
+
+	// the source only closes a candle when the next one starts, which is when ours is done too
+	source.OnClose(func(*Candle) {
+		ha.close()
+	})
+
+	return &ha
+}
+
+type HeikinAshiChart struct {
+	candleStore
+
+	source *CandleChart
+}
+
+func heikinAshi(open, high, low, close, prevOpen, prevClose float64) (float64, float64, float64, float64) {
+	haClose := (open + high + low + close) / 4.0
+	haOpen := (prevOpen + prevClose) / 2.0
+
+	return haOpen, math.Max(high, math.Max(haOpen, haClose)), math.Min(low, math.Min(haOpen, haClose)), haClose
+}
+
+func (ha *HeikinAshiChart) Update(current, last *ticks.MarketTick) {
+	ha.source.Update(current, last)
+
+	src := ha.source.current()
+
+	c := ha.current()
+	if nil == c {
+		c = &Candle{id: src.id}
+		ha.open(c)
+	}
+
+	// the very first candle has nothing before it, so seed it from its own open and close
+	prev := src
+	if ha.count > 0 {
+		prev = ha.At(0)
+	}
+
+	c.OpenBid, c.HighBid, c.LowBid, c.CloseBid = heikinAshi(src.OpenBid, src.HighBid, src.LowBid, src.CloseBid, prev.OpenBid, prev.CloseBid)
+	c.OpenAsk, c.HighAsk, c.LowAsk, c.CloseAsk = heikinAshi(src.OpenAsk, src.HighAsk, src.LowAsk, src.CloseAsk, prev.OpenAsk, prev.CloseAsk)
+
+	c.Volume    = src.Volume
+	c.TickCount = src.TickCount
+	c.OpenTime  = src.OpenTime
+	c.CloseTime = src.CloseTime
+}
+
+func (ha *HeikinAshiChart) State() ChartState {
+	s := ha.candleStore.State()
+
+	source := ha.source.State()
+	s.Source = &source
+
+	return s
+}
+
+func (ha *HeikinAshiChart) Restore(s ChartState) {
+	ha.candleStore.Restore(s)
+	ha.source.Restore(*s.Source)
+}
+
+func (ha *HeikinAshiChart) Print() {
+	fmt.Printf("===== HA:%d chart =====\n", ha.source.period)
+
+	ha.candleStore.Print()
+}
+
+// ===== RENKO =====================================================================================
+
+func NewRenkoChart(brickSize pips.Pip, maxCandles int64) *RenkoChart {
+	rc := RenkoChart{BrickSize: brickSize}
+	rc.maxCandles = maxCandles
+
+	return &rc
+}
+
+// Bricks are BrickSize pips of bid movement. A reversal takes two bricks worth of movement, as
+// usual. Bricks carry the volume and tick count seen while they formed.
+type RenkoChart struct {
+	candleStore
+
+	BrickSize pips.Pip
+
+	started bool
+	top     float64 // of the last brick
+	bottom  float64
+}
+
+func shiftByPips(symbol string, price float64, p pips.Pip) float64 {
+	q := quotes.NewQuote(symbol, price)
+	q.AddPips(p)
+
+	return q.ToFloat64()
+}
+
+func (rc *RenkoChart) brick(tick *ticks.MarketTick, open, close float64) {
+	c := rc.current()
+	if nil == c {
+		// more than one brick out of a single tick
+		c = newCandle(tick)
+		c.Volume = 0
+		c.TickCount = 0
+		rc.open(c)
+	}
+
+	spread := tick.CloseAsk - tick.CloseBid
+
+	c.OpenBid  = open
+	c.CloseBid = close
+	c.HighBid  = math.Max(open, close)
+	c.LowBid   = math.Min(open, close)
+	c.OpenAsk  = c.OpenBid + spread
+	c.CloseAsk = c.CloseBid + spread
+	c.HighAsk  = c.HighBid + spread
+	c.LowAsk   = c.LowBid + spread
+
+	c.CloseTime = tick.Time
 
-		if numCandles >= cc.maxCandles {
-			cc.Remove(cc.Back())
+	rc.close()
+}
+
+func (rc *RenkoChart) Update(current, last *ticks.MarketTick) {
+	price := current.CloseBid
+
+	if !rc.started {
+		rc.top, rc.bottom = price, price
+		rc.started = true
+	}
+
+	if c := rc.current(); nil != c {
+		c.update(current)
+	} else {
+		rc.open(newCandle(current))
+	}
+
+	for {
+		up := shiftByPips(current.Symbol, rc.top, rc.BrickSize)
+		down := shiftByPips(current.Symbol, rc.bottom, -rc.BrickSize)
+
+		if price >= up {
+			rc.brick(current, rc.top, up)
+			rc.bottom, rc.top = rc.top, up
+		} else if down > 0.0 && price <= down {
+			rc.brick(current, rc.bottom, down)
+			rc.top, rc.bottom = rc.bottom, down
+		} else {
+			break
 		}
+	}
+}
 
-		return
+func (rc *RenkoChart) State() ChartState {
+	s := rc.candleStore.State()
+	s.Started, s.Top, s.Bottom = rc.started, rc.top, rc.bottom
+
+	return s
+}
+
+func (rc *RenkoChart) Restore(s ChartState) {
+	rc.candleStore.Restore(s)
+	rc.started, rc.top, rc.bottom = s.Started, s.Top, s.Bottom
+}
+
+func (rc *RenkoChart) Print() {
+	fmt.Printf("===== RENKO:%.1f chart =====\n", rc.BrickSize)
+
+	rc.candleStore.Print()
+}
+
+// ===== RANGE =====================================================================================
+
+func NewRangeChart(size pips.Pip, maxCandles int64) *RangeChart {
+	rc := RangeChart{Size: size}
+	rc.maxCandles = maxCandles
+
+	return &rc
+}
+
+// A candle closes as soon as its bid high-low range reaches Size pips
+type RangeChart struct {
+	candleStore
+
+	Size pips.Pip
+}
+
+func (rc *RangeChart) Update(current, last *ticks.MarketTick) {
+	c := rc.current()
+	if nil == c {
+		c = newCandle(current)
+		rc.open(c)
+	} else {
+		c.update(current)
+	}
+
+	c.CloseTime = current.Time
+
+	if quotes.DifferenceInPips(current.Symbol, c.LowBid, c.HighBid) >= rc.Size {
+		rc.close()
+	}
+}
+
+func (rc *RangeChart) Print() {
+	fmt.Printf("===== RANGE:%.1f chart =====\n", rc.Size)
+
+	rc.candleStore.Print()
+}
+
+// ===== TICK & VOLUME =============================================================================
+
+func NewTickChart(count, maxCandles int64) *ThresholdChart {
+	return newThresholdChart("TICK", count, maxCandles, func(c *Candle) int64 { return c.TickCount })
+}
+
+func NewVolumeChart(volume, maxCandles int64) *ThresholdChart {
+	return newThresholdChart("VOLUME", volume, maxCandles, func(c *Candle) int64 { return c.Volume })
+}
+
+func newThresholdChart(name string, threshold, maxCandles int64, measure func(*Candle) int64) *ThresholdChart {
+	tc := ThresholdChart{Name: name, Threshold: threshold, measure: measure}
+	tc.maxCandles = maxCandles
+
+	return &tc
+}
+
+// A candle closes once measure (tick count, volume) reaches Threshold
+type ThresholdChart struct {
+	candleStore
+
+	Name      string
+	Threshold int64
+
+	measure func(*Candle) int64
+}
+
+func (tc *ThresholdChart) Update(current, last *ticks.MarketTick) {
+	c := tc.current()
+	if nil == c {
+		c = newCandle(current)
+		tc.open(c)
+	} else {
+		c.update(current)
+	}
+
+	c.CloseTime = current.Time
+
+	if tc.measure(c) >= tc.Threshold {
+		tc.close()
 	}
 }
 
+func (tc *ThresholdChart) Print() {
+	fmt.Printf("===== %s:%d chart =====\n", tc.Name, tc.Threshold)
+
+	tc.candleStore.Print()
+}
@@ -0,0 +1,426 @@
+package candles
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"../ticks"
+)
+
+func tick(at time.Time, bid float64) *ticks.MarketTick {
+	return ticks.NewRawTick("EURUSD", at, bid, bid + 0.0002)
+}
+
+func feed(c Chart, ticks ...*ticks.MarketTick) {
+	for i, t := range ticks {
+		if i > 0 {
+			c.Update(t, ticks[i - 1])
+		} else {
+			c.Update(t, nil)
+		}
+	}
+}
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a - b) < 1e-9
+}
+
+// finished returns every finished candle, oldest first
+func finished(c Chart) []*Candle {
+	res := []*Candle{}
+	for i := c.Finished() - 1; i >= 0; i-- {
+		res = append(res, c.At(i))
+	}
+
+	return res
+}
+
+func expectPanic(t *testing.T, what string, f func()) {
+	defer func() {
+		if nil == recover() {
+			t.Errorf("expected %s to panic", what)
+		}
+	}()
+
+	f()
+}
+
+// ===== CANDLE CHART ==============================================================================
+
+func TestCandleChart(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	cc := NewCandleChart("M5", 10)
+
+	closed := []*Candle{}
+	cc.OnClose(func(c *Candle) { closed = append(closed, c) })
+
+	feed(cc,
+		tick(at.Add(10 * time.Second), 1.3700),
+		tick(at.Add(1 * time.Minute), 1.3720),
+		tick(at.Add(3 * time.Minute), 1.3690),
+		tick(at.Add(5 * time.Minute - time.Second), 1.3705),
+		tick(at.Add(5 * time.Minute), 1.3710),
+	)
+
+	if 1 != len(closed) || 1 != cc.Finished() || 2 != cc.Len() {
+		t.Fatalf("expected 1 candle closed and 1 forming, got %d and %d", len(closed), cc.Len())
+	}
+
+	c := cc.At(0)
+	if c != closed[0] || 1.3700 != c.OpenBid || 1.3720 != c.HighBid || 1.3690 != c.LowBid || 1.3705 != c.CloseBid || 1.3722 != c.HighAsk {
+		t.Errorf("expected 1.3700 1.3720 1.3690 1.3705, got %+v", c)
+	}
+
+	if 4 != c.Volume || 4 != c.TickCount || !at.Equal(c.OpenTime) || !at.Add(5 * time.Minute).Equal(c.CloseTime) {
+		t.Errorf("expected 4 ticks from %s to %s, got %d from %s to %s", at, at.Add(5 * time.Minute), c.TickCount, c.OpenTime, c.CloseTime)
+	}
+
+	// nothing for the next 10 minutes
+	feed(cc, tick(at.Add(20 * time.Minute), 1.3730))
+
+	if 2 != cc.Finished() || !at.Add(5 * time.Minute).Equal(cc.At(0).OpenTime) || !at.Add(20 * time.Minute).Equal(cc.current().OpenTime) {
+		t.Errorf("expected the candle after the gap to open at %s, got %s", at.Add(20 * time.Minute), cc.current().OpenTime)
+	}
+}
+
+// New York falls back from 02:00 EDT to 01:00 EST at 06:00 UTC on November 2nd 2014
+func TestFallBack(t *testing.T) {
+	start := time.Date(2014, 11, 2, 5, 0, 0, 0, time.UTC)
+
+	m1 := NewAlignedCandleChart("M1", 200, NEW_YORK, 0)
+	h1 := NewAlignedCandleChart("H1", 10, NEW_YORK, 0)
+
+	minutes := []*ticks.MarketTick{}
+	for i := 0; i <= 120; i++ {
+		minutes = append(minutes, tick(start.Add(time.Duration(i) * time.Minute), 1.3700))
+	}
+
+	feed(m1, minutes...)
+	feed(h1, minutes...)
+
+	// a candle for every minute of both 1 o'clocks
+	candles := finished(m1)
+	if 120 != len(candles) {
+		t.Fatalf("expected 120 M1 candles, got %d", len(candles))
+	}
+
+	for i, c := range candles {
+		if !minutes[i].Time.Equal(c.OpenTime) || 1 != c.TickCount || !c.OpenTime.Add(time.Minute).Equal(c.CloseTime) {
+			t.Errorf("expected M1 candle %d to be the tick at %s, got %d ticks at %s", i, minutes[i].Time, c.TickCount, c.OpenTime)
+		}
+	}
+
+	// and an hour each
+	candles = finished(h1)
+	if 2 != len(candles) {
+		t.Fatalf("expected 2 H1 candles, got %d", len(candles))
+	}
+
+	for i, c := range candles {
+		open := start.Add(time.Duration(i) * time.Hour)
+
+		if !open.Equal(c.OpenTime) || !open.Add(time.Hour).Equal(c.CloseTime) || 60 != c.TickCount {
+			t.Errorf("expected H1 candle %d from %s with 60 ticks, got %s to %s with %d", i, open, c.OpenTime, c.CloseTime, c.TickCount)
+		}
+	}
+}
+
+// candles which start in the hour that repeats run from the first time the clocks get there
+func TestFallBackLongCandle(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2014, 11, 2, h, m, 0, 0, time.UTC) }
+
+	// 01:30, 05:30, ... New York time
+	h4 := NewAlignedCandleChart("H4", 10, NEW_YORK, 90 * time.Minute)
+
+	feed(h4,
+		tick(at(5, 29), 1.3700), // 01:29 EDT
+		tick(at(5, 30), 1.3710), // 01:30 EDT
+		tick(at(6, 10), 1.3720), // 01:10 EST
+		tick(at(6, 40), 1.3690), // 01:40 EST
+		tick(at(9, 30), 1.3700), // 04:30 EST
+		tick(at(10, 30), 1.3705), // 05:30 EST
+	)
+
+	candles := finished(h4)
+	if 2 != len(candles) {
+		t.Fatalf("expected 2 H4 candles, got %d", len(candles))
+	}
+
+	c := candles[1]
+	if !at(5, 30).Equal(c.OpenTime) || !at(10, 30).Equal(c.CloseTime) || 4 != c.TickCount || 1.3720 != c.HighBid || 1.3690 != c.LowBid {
+		t.Errorf("expected 4 ticks from 05:30 to 10:30 UTC, got %d from %s to %s", c.TickCount, c.OpenTime, c.CloseTime)
+	}
+}
+
+// trading days are 23 and 25 hours long when the clocks change
+func TestTradingDayLength(t *testing.T) {
+	cases := []struct {
+		first, last, next time.Time
+		open, close       time.Time
+	}{
+		{
+			time.Date(2014, 3, 8, 22, 30, 0, 0, time.UTC),
+			time.Date(2014, 3, 9, 20, 30, 0, 0, time.UTC),
+			time.Date(2014, 3, 9, 21, 30, 0, 0, time.UTC),
+			time.Date(2014, 3, 8, 22, 0, 0, 0, time.UTC),
+			time.Date(2014, 3, 9, 21, 0, 0, 0, time.UTC),
+		},
+		{
+			time.Date(2014, 11, 1, 21, 30, 0, 0, time.UTC),
+			time.Date(2014, 11, 2, 21, 30, 0, 0, time.UTC),
+			time.Date(2014, 11, 2, 22, 30, 0, 0, time.UTC),
+			time.Date(2014, 11, 1, 21, 0, 0, 0, time.UTC),
+			time.Date(2014, 11, 2, 22, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		d1 := NewAlignedCandleChart("D1", 10, NEW_YORK, NEW_YORK_CLOSE)
+		feed(d1, tick(c.first, 1.37), tick(c.last, 1.37), tick(c.next, 1.37))
+
+		day := d1.At(0)
+		if 1 != d1.Finished() || 2 != day.TickCount || !c.open.Equal(day.OpenTime) || !c.close.Equal(day.CloseTime) {
+			t.Errorf("expected a day from %s to %s with 2 ticks, got %s to %s with %d", c.open, c.close, day.OpenTime, day.CloseTime, day.TickCount)
+		}
+
+		if !c.open.Equal(TradingDay(c.last)) || !c.close.Equal(d1.current().OpenTime) {
+			t.Errorf("expected the trading day to start at %s and the next at %s", c.open, c.close)
+		}
+	}
+}
+
+// ===== OTHER CHARTS ==============================================================================
+
+func TestHeikinAshiChart(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	ha := NewChart("HA:M1", 10)
+
+	feed(ha,
+		tick(at, 1.3700),
+		tick(at.Add(10 * time.Second), 1.3720),
+		tick(at.Add(20 * time.Second), 1.3690),
+		tick(at.Add(30 * time.Second), 1.3710),
+		tick(at.Add(1 * time.Minute), 1.3710),
+		tick(at.Add(70 * time.Second), 1.3730),
+		tick(at.Add(80 * time.Second), 1.3700),
+		tick(at.Add(90 * time.Second), 1.3725),
+		tick(at.Add(2 * time.Minute), 1.3725),
+	)
+
+	// open, high, low, close, the first seeded from its own open and close
+	expected := [][]float64{
+		{1.3705, 1.3720, 1.3690, 1.3705},
+		{1.3705, 1.3730, 1.3700, 1.371625},
+	}
+
+	candles := finished(ha)
+	if len(expected) != len(candles) {
+		t.Fatalf("expected %d candles, got %d", len(expected), len(candles))
+	}
+
+	for i, e := range expected {
+		c := candles[i]
+
+		if !closeEnough(e[0], c.OpenBid) || !closeEnough(e[1], c.HighBid) || !closeEnough(e[2], c.LowBid) || !closeEnough(e[3], c.CloseBid) {
+			t.Errorf("candle %d: expected %v, got %.6f %.6f %.6f %.6f", i, e, c.OpenBid, c.HighBid, c.LowBid, c.CloseBid)
+		}
+
+		if 4 != c.TickCount || !at.Add(time.Duration(i) * time.Minute).Equal(c.OpenTime) {
+			t.Errorf("candle %d: expected 4 ticks at %s, got %d at %s", i, at.Add(time.Duration(i) * time.Minute), c.TickCount, c.OpenTime)
+		}
+	}
+}
+
+func TestRenkoChart(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	rc := NewChart("RENKO:10", 10)
+
+	prices := []float64{1.3700, 1.3705, 1.3712, 1.3735, 1.3715, 1.3705}
+	for i, p := range prices {
+		feed(rc, tick(at.Add(time.Duration(i) * time.Second), p))
+	}
+
+	// two bricks out of the jump to 1.3735, then a reversal needs to get back below the brick before
+	expected := []struct {
+		open, close float64
+		ticks       int64
+	}{
+		{1.3700, 1.3710, 3},
+		{1.3710, 1.3720, 1},
+		{1.3720, 1.3730, 0},
+		{1.3720, 1.3710, 2},
+	}
+
+	bricks := finished(rc)
+	if len(expected) != len(bricks) {
+		t.Fatalf("expected %d bricks, got %d", len(expected), len(bricks))
+	}
+
+	for i, e := range expected {
+		b := bricks[i]
+
+		if !closeEnough(e.open, b.OpenBid) || !closeEnough(e.close, b.CloseBid) || e.ticks != b.TickCount {
+			t.Errorf("brick %d: expected %.4f to %.4f from %d ticks, got %.4f to %.4f from %d", i, e.open, e.close, e.ticks, b.OpenBid, b.CloseBid, b.TickCount)
+		}
+
+		if !closeEnough(math.Max(e.open, e.close), b.HighBid) || !closeEnough(b.HighBid + 0.0002, b.HighAsk) {
+			t.Errorf("brick %d: expected a high of %.4f with the tick's spread, got %+v", i, math.Max(e.open, e.close), b)
+		}
+	}
+}
+
+func TestRangeChart(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	rc := NewChart("RANGE:10", 10)
+
+	prices := []float64{1.3700, 1.3705, 1.3711, 1.3708, 1.3702, 1.3697, 1.3700}
+	for i, p := range prices {
+		feed(rc, tick(at.Add(time.Duration(i) * time.Second), p))
+	}
+
+	candles := finished(rc)
+	if 2 != len(candles) {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+
+	first, second := candles[0], candles[1]
+	if 3 != first.TickCount || 1.3711 != first.HighBid || 1.3700 != first.LowBid || !at.Add(2 * time.Second).Equal(first.CloseTime) {
+		t.Errorf("expected the first candle to close on the 3rd tick, got %+v", first)
+	}
+
+	if 3 != second.TickCount || 1.3708 != second.OpenBid || 1.3697 != second.LowBid || 1.3697 != second.CloseBid {
+		t.Errorf("expected the second candle to close at 1.3697, got %+v", second)
+	}
+}
+
+func TestThresholdCharts(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	tc, vc := NewChart("TICK:3", 10), NewChart("VOLUME:5", 10)
+
+	for i := 0; i < 7; i++ {
+		tick := tick(at.Add(time.Duration(i) * time.Second), 1.3700 + float64(i) * 0.0001)
+		tick.Volume = 2
+
+		feed(tc, tick)
+		feed(vc, tick)
+	}
+
+	if 2 != tc.Finished() || 3 != tc.Len() || 3 != tc.At(0).TickCount || !closeEnough(1.3705, tc.At(0).CloseBid) {
+		t.Errorf("expected 2 candles of 3 ticks and 1 forming, got %d and %d", tc.Finished(), tc.Len())
+	}
+
+	if 2 != vc.Finished() || 6 != vc.At(0).Volume || 3 != vc.At(0).TickCount {
+		t.Errorf("expected 2 candles of 6 volume, got %d", vc.Finished())
+	}
+}
+
+func TestNewChart(t *testing.T) {
+	for _, descriptor := range []string{"M7", "TICK:0.5", "TICK:0", "VOLUME:2.0", "VOLUME:-5", "RENKO:x", "RANGE:0", "FOO:1"} {
+		expectPanic(t, descriptor, func() { NewChart(descriptor, 10) })
+	}
+
+	if rc, ok := NewChart("RANGE:2.5", 10).(*RangeChart); !ok || 2.5 != rc.Size {
+		t.Errorf("expected a 2.5 pip range chart")
+	}
+
+	if tc, ok := NewChart("TICK:100", 10).(*ThresholdChart); !ok || 100 != tc.Threshold || "TICK" != tc.Name {
+		t.Errorf("expected a 100 tick chart")
+	}
+}
+
+// ===== CANDLE STORE ==============================================================================
+
+func TestRingBuffer(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+	cc := NewCandleChart("M1", 3)
+	cc.Keep(2) // less than it has already
+	cc.Keep(4)
+
+	price := func(minute int) float64 { return 1.3700 + float64(minute) * 0.0001 }
+
+	for minute := 0; minute < 10; minute++ {
+		feed(cc, tick(at.Add(time.Duration(minute) * time.Minute), price(minute)))
+
+		if 2 == minute {
+			// before it's gone round
+			if 2 != cc.Finished() || 1 != cc.At(0).OpenTime.Minute() || 0 != cc.At(1).OpenTime.Minute() {
+				t.Errorf("expected minutes 1 and 0 finished, got %d", cc.Finished())
+			}
+		}
+	}
+
+	// 9 finished, the newest 4 kept
+	if 4 != cc.Finished() || 5 != cc.Len() {
+		t.Fatalf("expected 4 finished candles, got %d", cc.Finished())
+	}
+
+	candles := cc.GetCandles(4)
+	closes := cc.Column(CLOSE_BID, 4)
+	volumes := cc.Column(VOLUME, 3)
+
+	for i := int64(0); i < 4; i++ {
+		minute := 8 - int(i)
+
+		if c := cc.At(i); minute != c.OpenTime.Minute() || c != candles[i] || !closeEnough(price(minute), closes[i]) {
+			t.Errorf("candle %d: expected minute %d closing at %.4f, got minute %d at %.4f", i, minute, price(minute), c.OpenTime.Minute(), closes[i])
+		}
+	}
+
+	if 3 != len(volumes) || 1.0 != volumes[2] {
+		t.Errorf("expected 3 volumes of 1, got %v", volumes)
+	}
+
+	expectPanic(t, "At past the oldest candle", func() { cc.At(4) })
+	expectPanic(t, "At(-1)", func() { cc.At(-1) })
+	expectPanic(t, "more candles than are kept", func() { cc.GetCandles(5) })
+	expectPanic(t, "an unknown column", func() { cc.Column(numColumns, 1) })
+	expectPanic(t, "keeping more after candles have closed", func() { cc.Keep(10) })
+}
+
+// a chart restored part way through carries on as if it had seen everything
+func TestRestore(t *testing.T) {
+	at := time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	prices := []float64{}
+	for i := 0; i < 120; i++ {
+		prices = append(prices, 1.3700 + 0.0030 * math.Sin(float64(i) / 7.0))
+	}
+
+	for _, descriptor := range []string{"M1", "HA:M1", "RENKO:5"} {
+		full, half := NewChart(descriptor, 5), NewChart(descriptor, 5)
+
+		for i, p := range prices {
+			feed(full, tick(at.Add(time.Duration(i) * 20 * time.Second), p))
+
+			if i < len(prices) / 2 {
+				feed(half, tick(at.Add(time.Duration(i) * 20 * time.Second), p))
+			}
+		}
+
+		closes := 0
+
+		restored := NewChart(descriptor, 5)
+		restored.OnClose(func(*Candle) { closes += 1 })
+		restored.Restore(half.State())
+
+		if 0 != closes || half.Len() != restored.Len() {
+			t.Errorf("%s: expected %d candles back without any closing, got %d and %d closes", descriptor, half.Len(), restored.Len(), closes)
+		}
+
+		for i := len(prices) / 2; i < len(prices); i++ {
+			feed(restored, tick(at.Add(time.Duration(i) * 20 * time.Second), prices[i]))
+		}
+
+		expected, got := finished(full), finished(restored)
+		if len(expected) != len(got) || full.Len() != restored.Len() {
+			t.Fatalf("%s: expected %d candles, got %d", descriptor, len(expected), len(got))
+		}
+
+		for i := range expected {
+			if *expected[i] != *got[i] {
+				t.Errorf("%s: candle %d: expected %+v, got %+v", descriptor, i, expected[i], got[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package checkpoints
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"../algorithms"
+	"../journals"
+	"../ticks"
+	"../validators"
+)
+
+// ===== CHECKPOINTS ===============================================================================
+
+// VERSION goes up whenever what's saved changes, older checkpoints can't be resumed
+const VERSION = 1
+
+// A Checkpoint is a run's state part way through, so a later run can pick it up, e.g.
+//
+//   e.SetCheckpoint("run.ckpt", 30)
+//   ...
+//   e.Resume(checkpoints.Read("run.ckpt"))
+//
+// Each part of the run saves its own State, only what changes as ticks go through. Everything else
+// (strategies' settings, callbacks, the tick source) comes from setting the run up again the same
+// way, see exchanges.Exchange.Resume.
+type Checkpoint struct {
+	Version int64
+
+	At     time.Time // the first tick that hadn't been dealt with
+	Ticks  int64     // read from the tick source before it
+	Every  int64     // days between checkpoints
+	Config []byte    // see exchanges.Exchange.RecordConfig
+
+	Saved time.Time
+
+	// ----- the exchange -----
+
+	TicksRead            int64
+	TickCount            int64
+	TotalOrdersProcessed int64
+	TotalTicksProcessed  int64
+	WarmUpTicksProcessed int64
+
+	FirstTick      *ticks.State
+	LastTick       *ticks.State
+	LastCheckpoint time.Time
+
+	Validator validators.State
+	Broker    interface{} // nil unless it's a brokers.Stateful
+	Journal   *journals.State
+
+	Algorithms []algorithms.State
+}
+
+// Write saves c to path, replacing whatever's there only once it's all written
+func (c *Checkpoint) Write(path string) {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	z := gzip.NewWriter(f)
+
+	if err := gob.NewEncoder(z).Encode(c); err != nil {
+		log.Fatalln("can't write checkpoint " + path + ": " + err.Error())
+	}
+
+	if err := z.Close(); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := f.Close(); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func Read(path string) *Checkpoint {
+	c, err := read(path)
+	if err != nil {
+		log.Fatalln("bad checkpoint " + path + ": " + err.Error())
+	}
+
+	return c
+}
+
+func read(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checkpoint{}
+	if err := gob.NewDecoder(z).Decode(c); err != nil {
+		return nil, err
+	}
+
+	if VERSION != c.Version {
+		return nil, fmt.Errorf("saved as version %d, this build reads version %d", c.Version, VERSION)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,124 @@
+package checkpoints
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"../algorithms"
+	"../journals"
+	"../orders"
+	"../ticks"
+	"../validators"
+)
+
+type brokerState struct {
+	Fills int64
+}
+
+func init() {
+	gob.Register(brokerState{})
+}
+
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "test.ckpt"), func() { os.RemoveAll(dir) }
+}
+
+func TestRoundTrip(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	at := time.Date(2014, 3, 3, 22, 0, 0, 0, time.UTC)
+	tick := &ticks.MarketTick{Symbol: "EURUSD", Time: at, OpenBid: 1.3801, OpenAsk: 1.3803}
+	tick.Metadata.Set("percent_to_tp", 0.5)
+
+	o := &orders.Order{ID: 7, Symbol: "EURUSD", OpenedAt: at, OpenPrice: 1.3803, LotSize: 0.25}
+	o.Ticks.PushBack(tick)
+
+	v := validators.Default()
+	v.Validate(tick)
+
+	saved := &Checkpoint{
+		Version:    VERSION,
+		At:         at,
+		Ticks:      1234,
+		Every:      30,
+		Config:     []byte(`{"symbols":["EURUSD"]}`),
+		Saved:      time.Now(),
+		TicksRead:  1234,
+		FirstTick:  tick.State(),
+		LastTick:   tick.State(),
+		Validator:  v.State(),
+		Broker:     brokerState{Fills: 3},
+		Journal:    &journals.State{Path: "run.jsonl", Seq: 12, Written: 2048},
+		Algorithms: []algorithms.State{{FirstTick: true, LastTick: tick.State()}},
+	}
+	saved.Algorithms[0].Account.Orders = []orders.State{o.State()}
+
+	saved.Write(path)
+	c := Read(path)
+
+	if 1234 != c.Ticks || 30 != c.Every || string(saved.Config) != string(c.Config) || !c.At.Equal(at) {
+		t.Errorf("expected the run's position to be read back, got %+v", c)
+	}
+
+	if last := c.LastTick.Tick(); "EURUSD" != last.Symbol || 1.3803 != last.OpenAsk || !last.Time.Equal(at) {
+		t.Errorf("expected the last tick to be read back, got %+v", last)
+	}
+
+	if 3 != c.Broker.(brokerState).Fills {
+		t.Errorf("expected the broker's state to be read back, got %v", c.Broker)
+	}
+
+	if 12 != c.Journal.Seq || 2048 != c.Journal.Written {
+		t.Errorf("expected the journal's state to be read back, got %+v", c.Journal)
+	}
+
+	// the same tick again is a duplicate, if the validator remembers it
+	restored := validators.Default()
+	restored.Restore(c.Validator)
+	restored.Validate(tick)
+	if 1 != restored.Report.Count(validators.DUPLICATE_TIMESTAMP, "EURUSD") {
+		t.Errorf("expected the validator's last tick to be read back")
+	}
+
+	order := c.Algorithms[0].Account.Orders[0].Order()
+	if 7 != order.ID || 0.25 != order.LotSize || 1 != order.Ticks.Len() {
+		t.Errorf("expected the order to be read back, got %+v", order)
+	}
+
+	pttp, _ := order.Ticks.Front().Value.(*ticks.MarketTick).Metadata.Get("percent_to_tp")
+	if 0.5 != pttp {
+		t.Errorf("expected the order's tick to keep its metadata, got %v", pttp)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := gzip.NewWriter(f)
+	if err := gob.NewEncoder(z).Encode(&Checkpoint{Version: VERSION - 1}); err != nil {
+		t.Fatal(err)
+	}
+	z.Close()
+	f.Close()
+
+	if _, err := read(path); nil == err {
+		t.Errorf("expected a checkpoint from version %d not to be read", VERSION - 1)
+	}
+}
@@ -0,0 +1,421 @@
+package circuit_breakers
+
+import (
+	"fmt"
+	"time"
+
+	"../candles"
+	"../utils"
+)
+
+// ===== COOL-OFFS =================================================================================
+
+// CoolOff is when a tripped breaker lets trading start again
+type CoolOff struct {
+	kind     string
+	recovery float64
+}
+
+// NextDay resumes at the start of the next trading day, 17:00 New York
+func NextDay() CoolOff {
+	return CoolOff{kind: "next day"}
+}
+
+// NextWeek resumes at the start of the next trading week, Sunday 17:00 New York
+func NextWeek() CoolOff {
+	return CoolOff{kind: "next week"}
+}
+
+// Recovery resumes once equity wins back percent of what was lost, from the breaker's reference
+// (the day's or week's starting equity, the equity high, or where the losing streak started)
+func Recovery(percent float64) CoolOff {
+	if percent <= 0.0 || percent > 100.0 {
+		panic(fmt.Sprintf("recovery must be between 0.0 and 100.0 (got: %.1f)", percent))
+	}
+
+	return CoolOff{kind: "recovery", recovery: percent}
+}
+
+// Never stays tripped for the rest of the run
+func Never() CoolOff {
+	return CoolOff{kind: "never"}
+}
+
+func (co CoolOff) String() string {
+	if "recovery" == co.kind {
+		return fmt.Sprintf("%.0f%% recovery", co.recovery)
+	}
+
+	return co.kind
+}
+
+// WeekStart is when the forex trading week t is in started, Sunday 17:00 New York
+func WeekStart(t time.Time) time.Time {
+	day := candles.TradingDay(t)
+
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
+
+// ===== BREAKERS ==================================================================================
+
+const (
+	DAILY_LOSS         = "daily loss"
+	WEEKLY_LOSS        = "weekly loss"
+	TRAILING_DRAWDOWN  = "trailing drawdown"
+	CONSECUTIVE_LOSSES = "consecutive losses"
+)
+
+// NewDailyLoss trips when equity is down percent from the start of the trading day, or from where
+// it resumed if it's recovered since
+func NewDailyLoss(percent float64, coolOff CoolOff) *Breaker {
+	return newBreaker(DAILY_LOSS, percent, coolOff)
+}
+
+// NewWeeklyLoss trips when equity is down percent from the start of the trading week, or from
+// where it resumed if it's recovered since
+func NewWeeklyLoss(percent float64, coolOff CoolOff) *Breaker {
+	return newBreaker(WEEKLY_LOSS, percent, coolOff)
+}
+
+// NewTrailingDrawdown trips when equity is down percent from its high. The high starts again from
+// wherever equity is when it resumes.
+func NewTrailingDrawdown(percent float64, coolOff CoolOff) *Breaker {
+	return newBreaker(TRAILING_DRAWDOWN, percent, coolOff)
+}
+
+// NewConsecutiveLosses trips after n losing trades in a row
+func NewConsecutiveLosses(n int64, coolOff CoolOff) *Breaker {
+	if n < 1 {
+		panic(fmt.Sprintf("consecutive losses must be >= 1, got %d", n))
+	}
+
+	return newBreaker(CONSECUTIVE_LOSSES, float64(n), coolOff)
+}
+
+func newBreaker(kind string, limit float64, coolOff CoolOff) *Breaker {
+	if kind != CONSECUTIVE_LOSSES && (limit <= 0.0 || limit >= 100.0) {
+		panic(fmt.Sprintf("%s limit must be between 0.0 and 100.0 (got: %.1f)", kind, limit))
+	}
+
+	return &Breaker{Kind: kind, Limit: limit, CoolOff: coolOff}
+}
+
+type Breaker struct {
+	Kind    string
+	Limit   float64 // % for the losses and drawdown, a count for consecutive losses
+	CoolOff CoolOff
+
+	flatten bool
+
+	// the period the loss is measured over, and the equity at its start or high
+	period    time.Time
+	reference float64
+	losses    int64
+
+	tripped      bool
+	resumeAt     time.Time // for the next day/week cool-offs
+	resumeEquity float64   // for recovery
+}
+
+// Flatten makes the breaker close every open order when it trips, rather than only stopping new
+// ones
+func (b *Breaker) Flatten() *Breaker {
+	b.flatten = true
+	return b
+}
+
+func (b *Breaker) Flattens() bool {
+	return b.flatten
+}
+
+func (b *Breaker) Tripped() bool {
+	return b.tripped
+}
+
+func (b *Breaker) String() string {
+	if CONSECUTIVE_LOSSES == b.Kind {
+		return fmt.Sprintf("%s (%.0f)", b.Kind, b.Limit)
+	}
+
+	return fmt.Sprintf("%s (%.1f%%)", b.Kind, b.Limit)
+}
+
+// measure moves the breaker's reference along and returns how far past it equity is, "" if it
+// isn't
+func (b *Breaker) measure(now time.Time, equity float64) string {
+	switch b.Kind {
+	case DAILY_LOSS, WEEKLY_LOSS:
+		period := candles.TradingDay(now)
+		if WEEKLY_LOSS == b.Kind {
+			period = WeekStart(now)
+		}
+
+		if !period.Equal(b.period) {
+			b.period = period
+			b.reference = equity
+		}
+
+		loss := 100.0 * (b.reference - equity) / b.reference
+		if loss >= b.Limit {
+			return fmt.Sprintf("down %.2f%% from %s", loss, utils.FormatMoney(b.reference))
+		}
+	case TRAILING_DRAWDOWN:
+		if equity > b.reference {
+			b.reference = equity
+		}
+
+		drawdown := 100.0 * (b.reference - equity) / b.reference
+		if drawdown >= b.Limit {
+			return fmt.Sprintf("down %.2f%% from the high of %s", drawdown, utils.FormatMoney(b.reference))
+		}
+	case CONSECUTIVE_LOSSES:
+		if float64(b.losses) >= b.Limit {
+			return fmt.Sprintf("%d losing trades in a row since %s", b.losses, utils.FormatMoney(b.reference))
+		}
+	}
+
+	return ""
+}
+
+func (b *Breaker) trip(now time.Time, equity float64) {
+	b.tripped = true
+
+	switch b.CoolOff.kind {
+	case "next day":
+		b.resumeAt = candles.TradingDay(now).AddDate(0, 0, 1)
+	case "next week":
+		b.resumeAt = WeekStart(now).AddDate(0, 0, 7)
+	case "recovery":
+		b.resumeEquity = equity + (b.reference - equity) * b.CoolOff.recovery / 100.0
+	}
+}
+
+// canResume says why the breaker can reset, "" if it can't yet
+func (b *Breaker) canResume(now time.Time, equity float64) string {
+	switch b.CoolOff.kind {
+	case "next day", "next week":
+		if !now.Before(b.resumeAt) {
+			return "cooled off until " + b.resumeAt.Format("2006-01-02 15:04 MST")
+		}
+	case "recovery":
+		if equity >= b.resumeEquity {
+			return "equity recovered to " + utils.FormatMoney(equity)
+		}
+	}
+
+	return ""
+}
+
+// reset measures from equity again, anything else would trip straight back if a recovery didn't
+// win back more than the limit
+func (b *Breaker) reset(equity float64) {
+	b.tripped = false
+
+	switch b.Kind {
+	case DAILY_LOSS, WEEKLY_LOSS, TRAILING_DRAWDOWN:
+		b.reference = equity
+	case CONSECUTIVE_LOSSES:
+		b.losses = 0
+	}
+}
+
+// ===== PANEL =====================================================================================
+
+// NewPanel is the set of breakers for one account, see Algorithm.AddCircuitBreaker
+func NewPanel() *Panel {
+	return &Panel{}
+}
+
+type Panel struct {
+	breakers []*Breaker
+
+	Events []Event
+}
+
+// An Event is a breaker tripping or resetting
+type Event struct {
+	At      time.Time
+	Breaker *Breaker
+	Tripped bool
+	Equity  float64
+	Detail  string
+}
+
+func (e Event) String() string {
+	what := "reset"
+	if e.Tripped {
+		what = "tripped"
+	}
+
+	return fmt.Sprintf(
+		"%s %s %s at %s equity, %s",
+		e.At.Format("2006-01-02 15:04"),
+		e.Breaker,
+		what,
+		utils.FormatMoney(e.Equity),
+		e.Detail,
+	)
+}
+
+func (p *Panel) Add(b *Breaker) {
+	p.breakers = append(p.breakers, b)
+}
+
+func (p *Panel) Empty() bool {
+	return 0 == len(p.breakers)
+}
+
+// Update checks every breaker against the account's equity, returning the ones that just tripped
+func (p *Panel) Update(now time.Time, equity float64) []*Breaker {
+	tripped := []*Breaker{}
+
+	for _, b := range p.breakers {
+		if b.tripped {
+			if why := b.canResume(now, equity); "" != why {
+				b.reset(equity)
+				p.Events = append(p.Events, Event{now, b, false, equity, why})
+			} else {
+				continue
+			}
+		}
+
+		if why := b.measure(now, equity); "" != why {
+			b.trip(now, equity)
+			p.Events = append(p.Events, Event{now, b, true, equity, why + ", cool-off: " + b.CoolOff.String()})
+
+			tripped = append(tripped, b)
+		}
+	}
+
+	return tripped
+}
+
+// TradeClosed counts losing streaks, equity is the account's before the trade's profit
+func (p *Panel) TradeClosed(profit, equity float64) {
+	for _, b := range p.breakers {
+		if CONSECUTIVE_LOSSES != b.Kind || b.tripped {
+			continue
+		}
+
+		if profit >= 0.0 {
+			b.losses = 0
+			continue
+		}
+
+		if 0 == b.losses {
+			b.reference = equity
+		}
+
+		b.losses += 1
+	}
+}
+
+// Tripped is the first breaker that's stopping trading, if any are
+func (p *Panel) Tripped() (*Breaker, bool) {
+	for _, b := range p.breakers {
+		if b.tripped {
+			return b, true
+		}
+	}
+
+	return nil, false
+}
+
+func (p *Panel) PrintEvents() {
+	fmt.Printf("***** CIRCUIT BREAKERS *****\n\n")
+
+	if 0 == len(p.Events) {
+		fmt.Printf("None tripped\n\n")
+		return
+	}
+
+	for _, e := range p.Events {
+		fmt.Println(e)
+	}
+
+	fmt.Println("")
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+// A panel's state only works on one with the same breakers added in the same order, events point
+// at breakers by index
+type State struct {
+	Breakers []BreakerState
+	Events   []EventState
+}
+
+type BreakerState struct {
+	Period       time.Time
+	Reference    float64
+	Losses       int64
+	Tripped      bool
+	ResumeAt     time.Time
+	ResumeEquity float64
+}
+
+type EventState struct {
+	At      time.Time
+	Breaker int64
+	Tripped bool
+	Equity  float64
+	Detail  string
+}
+
+func (p *Panel) State() State {
+	s := State{}
+
+	for _, b := range p.breakers {
+		s.Breakers = append(s.Breakers, BreakerState{
+			Period:       b.period,
+			Reference:    b.reference,
+			Losses:       b.losses,
+			Tripped:      b.tripped,
+			ResumeAt:     b.resumeAt,
+			ResumeEquity: b.resumeEquity,
+		})
+	}
+
+	for _, e := range p.Events {
+		s.Events = append(s.Events, EventState{e.At, p.index(e.Breaker), e.Tripped, e.Equity, e.Detail})
+	}
+
+	return s
+}
+
+func (p *Panel) Restore(s State) {
+	if len(s.Breakers) != len(p.breakers) {
+		panic(fmt.Sprintf("can't restore %d circuit breakers to a panel of %d", len(s.Breakers), len(p.breakers)))
+	}
+
+	for i, bs := range s.Breakers {
+		b := p.breakers[i]
+
+		b.period, b.reference, b.losses = newYork(bs.Period), bs.Reference, bs.Losses
+		b.tripped, b.resumeAt, b.resumeEquity = bs.Tripped, newYork(bs.ResumeAt), bs.ResumeEquity
+	}
+
+	p.Events = nil
+	for _, es := range s.Events {
+		p.Events = append(p.Events, Event{es.At, p.breakers[es.Breaker], es.Tripped, es.Equity, es.Detail})
+	}
+}
+
+func (p *Panel) index(b *Breaker) int64 {
+	for i, pb := range p.breakers {
+		if pb == b {
+			return int64(i)
+		}
+	}
+
+	panic(fmt.Sprintf("%s isn't on the panel", b))
+}
+
+// gob only keeps a time's offset, the trading days are printed with New York's zone name
+func newYork(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	return t.In(candles.NEW_YORK)
+}
@@ -0,0 +1,153 @@
+package circuit_breakers
+
+import (
+	"testing"
+	"time"
+
+	"../candles"
+)
+
+// at is New York time
+func at(day, hour int) time.Time {
+	return time.Date(2014, 3, day, hour, 0, 0, 0, candles.NEW_YORK)
+}
+
+func expectTripped(t *testing.T, what string, b *Breaker, expected bool) {
+	if b.Tripped() != expected {
+		t.Errorf("%s: expected tripped to be %v", what, expected)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	sunday := at(2, 17)
+
+	tests := []time.Time{at(2, 17), at(3, 10), at(6, 20), at(7, 16)}
+	for _, now := range tests {
+		if s := WeekStart(now); !s.Equal(sunday) {
+			t.Errorf("expected %s to be in the week from %s, got %s", now, sunday, s)
+		}
+	}
+
+	if s := WeekStart(at(9, 17)); !s.Equal(at(9, 17)) {
+		t.Errorf("expected the next week to start on the 9th, got %s", s)
+	}
+}
+
+func TestDailyLoss(t *testing.T) {
+	p := NewPanel()
+	b := NewDailyLoss(2.0, NextDay())
+	p.Add(b)
+
+	p.Update(at(3, 9), 10000.0)
+	p.Update(at(3, 11), 9850.0)
+	expectTripped(t, "down 1.5%", b, false)
+
+	if tripped := p.Update(at(3, 12), 9790.0); 1 != len(tripped) || tripped[0] != b {
+		t.Errorf("expected the breaker to trip at 2.1%% down, got %v", tripped)
+	}
+
+	p.Update(at(3, 15), 10100.0)
+	expectTripped(t, "recovered in the same day", b, true)
+
+	// the next trading day starts at 17:00 and measures from there
+	p.Update(at(3, 17), 9790.0)
+	expectTripped(t, "next day", b, false)
+
+	p.Update(at(4, 9), 9650.0)
+	expectTripped(t, "down 1.4% on the new day", b, false)
+
+	if 2 != len(p.Events) || !p.Events[0].Tripped || p.Events[1].Tripped {
+		t.Errorf("expected a trip and a reset, got %v", p.Events)
+	}
+}
+
+func TestWeeklyLossRecovery(t *testing.T) {
+	p := NewPanel()
+	b := NewWeeklyLoss(5.0, Recovery(50.0))
+	p.Add(b)
+
+	p.Update(at(3, 9), 10000.0)
+	p.Update(at(5, 9), 9400.0)
+	expectTripped(t, "down 6%", b, true)
+
+	// half of the 600 lost has to be won back, the new week doesn't matter
+	p.Update(at(10, 9), 9650.0)
+	expectTripped(t, "won back 250", b, true)
+
+	p.Update(at(10, 10), 9700.0)
+	expectTripped(t, "won back 300", b, false)
+}
+
+// a recovery that leaves equity past the limit mustn't trip again on the tick it resets
+func TestDailyLossRecovery(t *testing.T) {
+	p := NewPanel()
+	b := NewDailyLoss(3.0, Recovery(10.0))
+	p.Add(b)
+
+	p.Update(at(3, 9), 10000.0)
+	p.Update(at(3, 10), 9600.0)
+	expectTripped(t, "down 4%", b, true)
+
+	// 40 of the 400 won back, still 3.5% down on the day
+	if tripped := p.Update(at(3, 11), 9650.0); 0 != len(tripped) {
+		t.Errorf("expected the recovery to stick, got %v tripped", tripped)
+	}
+	expectTripped(t, "won back 50", b, false)
+
+	p.Update(at(3, 12), 9400.0)
+	expectTripped(t, "down 2.6% from where it resumed", b, false)
+
+	p.Update(at(3, 13), 9350.0)
+	expectTripped(t, "down 3.1% from where it resumed", b, true)
+
+	if 3 != len(p.Events) || !p.Events[0].Tripped || p.Events[1].Tripped || !p.Events[2].Tripped {
+		t.Errorf("expected a trip, a reset and a trip, got %v", p.Events)
+	}
+}
+
+func TestTrailingDrawdown(t *testing.T) {
+	p := NewPanel()
+	b := NewTrailingDrawdown(10.0, NextWeek())
+	p.Add(b)
+
+	p.Update(at(3, 9), 10000.0)
+	p.Update(at(4, 9), 12000.0)
+	p.Update(at(5, 9), 10900.0)
+	expectTripped(t, "down 9.2% from the high", b, false)
+
+	p.Update(at(5, 10), 10700.0)
+	expectTripped(t, "down 10.8% from the high", b, true)
+
+	p.Update(at(9, 16), 12000.0)
+	expectTripped(t, "before the week's out", b, true)
+
+	// the high starts again from where it resumes
+	p.Update(at(9, 17), 10500.0)
+	p.Update(at(10, 9), 9500.0)
+	expectTripped(t, "down 9.5% from the resumed high", b, false)
+}
+
+func TestConsecutiveLosses(t *testing.T) {
+	p := NewPanel()
+	b := NewConsecutiveLosses(3, Never())
+	p.Add(b)
+
+	p.TradeClosed(-10.0, 10000.0)
+	p.TradeClosed(-10.0, 9990.0)
+	p.TradeClosed(5.0, 9980.0)
+	p.TradeClosed(-10.0, 9985.0)
+	p.TradeClosed(-10.0, 9975.0)
+	p.Update(at(3, 9), 9965.0)
+	expectTripped(t, "a win in between", b, false)
+
+	p.TradeClosed(-10.0, 9965.0)
+	p.Update(at(3, 10), 9955.0)
+	expectTripped(t, "three in a row", b, true)
+
+	p.Update(at(24, 10), 20000.0)
+	expectTripped(t, "never", b, true)
+
+	if tripped, ok := p.Tripped(); !ok || tripped != b {
+		t.Errorf("expected the panel to be tripped by %s", b)
+	}
+}
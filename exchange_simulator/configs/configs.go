@@ -0,0 +1,489 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"../accounts"
+	"../algorithms"
+	"../candles"
+	"../circuit_breakers"
+	"../exchanges"
+	"../importers"
+	"../indicators"
+	"../registry"
+	"../ticks"
+)
+
+// ===== CONFIG ====================================================================================
+
+// Everything simulator needs to run a strategy, e.g.
+//
+//   {
+//     "name": "steve's algorithm 2",
+//     "account": {"deposit": 10000, "leverage": 1, "risk_per_trade": 1.0},
+//     "symbols": ["EURUSD"],
+//     "charts": ["M1"],
+//     "history": {"M1": 60},
+//     "indicators": [
+//       {"name": "STD", "type": "std", "params": {"lower": 0.9969, "upper": 0.9975}},
+//       {"name": "h4_trend", "type": "ema", "chart": "H4", "params": {"periods": 50}}
+//     ],
+//     "strategy": {"name": "steveorithm2"},
+//     "data": {"format": "fxcm-m1", "paths": ["EURUSD.csv"]},
+//     "start": "2014-03-03",
+//     "end": "2014-09-01"
+//   }
+//
+// Several strategies can trade the account as a portfolio instead, see algorithms.NewPortfolio:
+//
+//   "strategies": [
+//     {"tag": "steve", "name": "steveorithm2", "weight": 0.5},
+//     {"tag": "steve_cautious", "name": "steveorithm2", "weight": 0.25, "params": {"decision": "noop"}}
+//   ]
+//
+// and circuit breakers can stop it trading for a while, see algorithms.AddCircuitBreaker:
+//
+//   "circuit_breakers": [
+//     {"type": "daily_loss", "limit": 2.0, "cool_off": "next_day"},
+//     {"type": "trailing_drawdown", "limit": 10.0, "cool_off": "recovery", "recovery": 50.0, "flatten": true}
+//   ]
+type Config struct {
+	Name string `json:"name"`
+
+	Account    Account          `json:"account"`
+	Symbols    []string         `json:"symbols"`
+	Charts     []string         `json:"charts"`
+	History    map[string]int64 `json:"history"` // see Algorithm.RequireHistory
+	Indicators []Indicator      `json:"indicators"`
+	Strategy   *Strategy        `json:"strategy,omitempty"`
+	Strategies []Strategy       `json:"strategies,omitempty"`
+	Data       Data             `json:"data"`
+
+	CircuitBreakers []CircuitBreaker `json:"circuit_breakers,omitempty"`
+
+	Start string `json:"start"` // YYYY-MM-DD, see Exchange.SetStartDate
+	End   string `json:"end"`   // YYYY-MM-DD, not included
+}
+
+type Account struct {
+	Name          string  `json:"name"`
+	Deposit       float64 `json:"deposit"`
+	Leverage      int64   `json:"leverage"`
+	RiskPerTrade  float64 `json:"risk_per_trade"` // % of the account, see Account.SetMaxRiskPerTrade
+	DrawdownLimit float64 `json:"drawdown_limit"` // %, 0 for none
+	ShowOrders    bool    `json:"show_orders"`
+}
+
+type Indicator struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"` // see registry.RegisterIndicator
+	Params registry.Params `json:"params"`
+
+	// see algorithms.Binding
+	Symbol string `json:"symbol"`
+	Chart  string `json:"chart"`
+
+	// feeds another indicator's output in instead of the candles, see indicators.NewComposite
+	Input *Input `json:"input"`
+}
+
+type Input struct {
+	Indicator string `json:"indicator"`
+	Output    string `json:"output"`
+}
+
+type Strategy struct {
+	Name   string          `json:"name"` // see registry.RegisterStrategy
+	Params registry.Params `json:"params"`
+
+	// for portfolios
+	Tag    string  `json:"tag,omitempty"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+type CircuitBreaker struct {
+	Type     string  `json:"type"`               // daily_loss, weekly_loss, trailing_drawdown or consecutive_losses
+	Limit    float64 `json:"limit"`              // %, or a count for consecutive_losses
+	CoolOff  string  `json:"cool_off"`           // next_day, next_week, recovery or never
+	Recovery float64 `json:"recovery,omitempty"` // % of the loss won back, for the recovery cool-off
+	Flatten  bool    `json:"flatten,omitempty"`  // close open orders when it trips
+}
+
+type Data struct {
+	Format     string   `json:"format"` // see importers.Formats
+	Paths      []string `json:"paths"`  // merged in time order
+	Symbol     string   `json:"symbol"`
+	TimeZone   string   `json:"time_zone"`
+	SpreadPips float64  `json:"spread_pips"` // required for bid only formats
+	Mapping    string   `json:"mapping"`
+	Resample   string   `json:"resample"` // a duration, e.g. "1m"
+}
+
+func Load(path string) *Config {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return Parse(raw, path)
+}
+
+// Parse reads a config, name is only for errors
+func Parse(raw []byte, name string) *Config {
+	c := &Config{
+		Account: Account{Deposit: 10000.0, Leverage: 1, RiskPerTrade: 1.0},
+		Data:    Data{Format: "fxcm-m1"},
+	}
+
+	if err := json.Unmarshal(raw, c); err != nil {
+		log.Fatalln("bad config in " + name + ": " + err.Error())
+	}
+
+	if "" == c.Account.Name {
+		c.Account.Name = c.Name
+	}
+
+	return c
+}
+
+// JSON is the config as it stands, including any changes since it was read
+func (c *Config) JSON() []byte {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return raw
+}
+
+// Problems lists everything wrong with the config that can be found without running it
+func (c *Config) Problems() []string {
+	problems := []string{}
+	complain := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Account.Deposit <= 0.0 {
+		complain("account deposit must be > 0")
+	}
+
+	if c.Account.Leverage < 1 {
+		complain("account leverage must be >= 1")
+	}
+
+	if 0 == len(c.Symbols) {
+		complain("no symbols")
+	}
+
+	for _, descriptor := range c.Charts {
+		if !validChart(descriptor) {
+			complain("unknown chart: %s", descriptor)
+		}
+	}
+
+	for descriptor := range c.History {
+		if !validChart(descriptor) {
+			complain("unknown chart in history: %s", descriptor)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, indi := range c.Indicators {
+		if "" == indi.Name {
+			complain("indicator with no name")
+		} else if names[indi.Name] {
+			complain("indicator %s is there twice", indi.Name)
+		}
+		names[indi.Name] = true
+
+		if component, ok := registry.Indicator(indi.Type); !ok {
+			complain("indicator %s has unknown type %q", indi.Name, indi.Type)
+		} else {
+			for _, problem := range component.Problems(indi.Params) {
+				complain("indicator %s: %s", indi.Name, problem)
+			}
+		}
+
+		if "" != indi.Chart && !validChart(indi.Chart) {
+			complain("indicator %s has unknown chart: %s", indi.Name, indi.Chart)
+		}
+	}
+
+	for _, indi := range c.Indicators {
+		if nil != indi.Input && !names[indi.Input.Indicator] {
+			complain("indicator %s takes input from unknown indicator: %s", indi.Name, indi.Input.Indicator)
+		}
+	}
+
+	switch {
+	case nil == c.Strategy && 0 == len(c.Strategies):
+		complain("no strategy")
+	case nil != c.Strategy && len(c.Strategies) > 0:
+		complain("either a strategy or strategies, not both")
+	case nil != c.Strategy:
+		c.Strategy.problems(complain)
+	default:
+		tags := make(map[string]bool)
+
+		for _, s := range c.Strategies {
+			if "" == s.Tag {
+				complain("portfolio strategy %s has no tag", s.Name)
+			} else if tags[s.Tag] {
+				complain("portfolio strategy tag %s is there twice", s.Tag)
+			}
+			tags[s.Tag] = true
+
+			if s.Weight <= 0.0 || s.Weight > 1.0 {
+				complain("portfolio strategy %s needs a weight > 0 and <= 1", s.Tag)
+			}
+
+			s.problems(complain)
+		}
+	}
+
+	for _, cb := range c.CircuitBreakers {
+		cb.problems(complain)
+	}
+
+	if 0 == len(c.Data.Paths) {
+		complain("no data paths")
+	}
+
+	if !contains(importers.Formats, c.Data.Format) {
+		complain("unknown data format %q", c.Data.Format)
+	}
+
+	if contains(importers.BidOnlyFormats, c.Data.Format) && c.Data.SpreadPips <= 0 {
+		complain("%s data is bid only, spread_pips must be > 0", c.Data.Format)
+	}
+
+	if "" != c.Data.Resample {
+		if _, err := time.ParseDuration(c.Data.Resample); err != nil {
+			complain("bad resample duration: %s", c.Data.Resample)
+		}
+	}
+
+	start, startErr := parseDate(c.Start)
+	if nil != startErr {
+		complain("bad start date, use YYYY-MM-DD: %s", c.Start)
+	}
+
+	end, endErr := parseDate(c.End)
+	if nil != endErr {
+		complain("bad end date, use YYYY-MM-DD: %s", c.End)
+	}
+
+	if nil == startErr && nil == endErr && !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		complain("start (%s) must be before end (%s)", c.Start, c.End)
+	}
+
+	return problems
+}
+
+func (s *Strategy) problems(complain func(string, ...interface{})) {
+	component, ok := registry.Strategy(s.Name)
+	if !ok {
+		complain("unknown strategy %q", s.Name)
+		return
+	}
+
+	for _, problem := range component.Problems(s.Params) {
+		complain("strategy %s: %s", s.Name, problem)
+	}
+}
+
+func (cb CircuitBreaker) problems(complain func(string, ...interface{})) {
+	switch cb.Type {
+	case "daily_loss", "weekly_loss", "trailing_drawdown":
+		if cb.Limit <= 0.0 || cb.Limit >= 100.0 {
+			complain("circuit breaker %s needs a limit > 0 and < 100", cb.Type)
+		}
+	case "consecutive_losses":
+		if cb.Limit < 1.0 || cb.Limit != float64(int64(cb.Limit)) {
+			complain("circuit breaker %s needs a whole number limit >= 1", cb.Type)
+		}
+	default:
+		complain("unknown circuit breaker type %q", cb.Type)
+	}
+
+	switch cb.CoolOff {
+	case "next_day", "next_week", "never":
+	case "recovery":
+		if cb.Recovery <= 0.0 || cb.Recovery > 100.0 {
+			complain("circuit breaker %s needs a recovery > 0 and <= 100", cb.Type)
+		}
+	default:
+		complain("circuit breaker %s has unknown cool-off %q", cb.Type, cb.CoolOff)
+	}
+}
+
+func validChart(descriptor string) (ok bool) {
+	defer func() {
+		if nil != recover() {
+			ok = false
+		}
+	}()
+
+	candles.NewChart(descriptor, 1)
+
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDate returns the zero time for ""
+func parseDate(s string) (time.Time, error) {
+	if "" == s {
+		return time.Time{}, nil
+	}
+
+	return time.Parse("2006-01-02", s)
+}
+
+// ===== BUILDING ==================================================================================
+
+// Build sets up the exchange and algorithm the config describes, ready to Run. It panics if there
+// are any Problems.
+func (c *Config) Build() (*exchanges.Exchange, *algorithms.Algorithm) {
+	if problems := c.Problems(); len(problems) > 0 {
+		panic("bad config:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	opts := importers.Options{
+		Symbol:     c.Data.Symbol,
+		TimeZone:   c.Data.TimeZone,
+		SpreadPips: c.Data.SpreadPips,
+		Mapping:    c.Data.Mapping,
+	}
+
+	source := importers.Open(c.Data.Format, strings.Join(c.Data.Paths, ","), opts)
+
+	if "" != c.Data.Resample {
+		resample, _ := time.ParseDuration(c.Data.Resample)
+		source = ticks.NewResampler(source, resample)
+	}
+
+	e := exchanges.NewWithDeets(source)
+
+	if start, _ := parseDate(c.Start); !start.IsZero() {
+		e.SetStartDate(start)
+	}
+
+	if end, _ := parseDate(c.End); !end.IsZero() {
+		e.SetEndDate(end)
+	}
+
+	e.RecordConfig(c.JSON())
+
+	acc := accounts.NewWithDeets(c.Account.Name, c.Account.Deposit)
+	acc.SetMargin(c.Account.Leverage)
+	acc.SetMaxRiskPerTrade(c.Account.RiskPerTrade)
+
+	if c.Account.DrawdownLimit > 0.0 {
+		acc.SetDrawdownLimit(c.Account.DrawdownLimit)
+	}
+
+	if c.Account.ShowOrders {
+		acc.ShowOrders()
+	}
+
+	var algo *algorithms.Algorithm
+
+	if nil != c.Strategy {
+		algo = algorithms.NewWithStrategy(acc, registry.NewStrategy(c.Strategy.Name, c.Strategy.Params))
+	} else {
+		algo = algorithms.NewPortfolio(acc)
+
+		for _, s := range c.Strategies {
+			algo.AddStrategy(s.Tag, registry.NewStrategy(s.Name, s.Params), s.Weight)
+		}
+	}
+	algo.AddCurrency(c.Symbols...)
+
+	if len(c.Charts) > 0 {
+		algo.AttachCharts(c.Charts...)
+	}
+
+	for descriptor, n := range c.History {
+		algo.RequireHistory(descriptor, n)
+	}
+
+	for _, indi := range c.Indicators {
+		algo.AddIndicatorOn(indi.Name, algorithms.Binding{Symbol: indi.Symbol, Chart: indi.Chart}, indi.factory())
+	}
+
+	for _, cb := range c.CircuitBreakers {
+		algo.AddCircuitBreaker(cb.breaker())
+	}
+
+	e.AddAlgorithm(algo)
+
+	return e, algo
+}
+
+func (cb CircuitBreaker) breaker() *circuit_breakers.Breaker {
+	var coolOff circuit_breakers.CoolOff
+
+	switch cb.CoolOff {
+	case "next_day":
+		coolOff = circuit_breakers.NextDay()
+	case "next_week":
+		coolOff = circuit_breakers.NextWeek()
+	case "recovery":
+		coolOff = circuit_breakers.Recovery(cb.Recovery)
+	default:
+		coolOff = circuit_breakers.Never()
+	}
+
+	var b *circuit_breakers.Breaker
+
+	switch cb.Type {
+	case "daily_loss":
+		b = circuit_breakers.NewDailyLoss(cb.Limit, coolOff)
+	case "weekly_loss":
+		b = circuit_breakers.NewWeeklyLoss(cb.Limit, coolOff)
+	case "trailing_drawdown":
+		b = circuit_breakers.NewTrailingDrawdown(cb.Limit, coolOff)
+	default:
+		b = circuit_breakers.NewConsecutiveLosses(int64(cb.Limit), coolOff)
+	}
+
+	if cb.Flatten {
+		b.Flatten()
+	}
+
+	return b
+}
+
+func (indi Indicator) factory() func() indicators.Indicator {
+	if nil == indi.Input {
+		return func() indicators.Indicator { return registry.NewIndicator(indi.Type, indi.Params) }
+	}
+
+	return func() indicators.Indicator {
+		adder, ok := registry.NewIndicator(indi.Type, indi.Params).(indicators.Adder)
+		if !ok {
+			panic("indicator " + indi.Name + " (" + indi.Type + ") can't take input from another indicator")
+		}
+
+		output := indi.Input.Output
+		if "" == output {
+			output = "value"
+		}
+
+		return indicators.NewComposite(adder, indi.Input.Indicator, output)
+	}
+}
@@ -0,0 +1,218 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"../algorithms"
+	"../registry"
+	"../ticks"
+)
+
+type countingStrategy struct {
+	algorithms.BaseStrategy
+
+	ticks int
+	last  time.Time
+}
+
+func (cs *countingStrategy) OnTick(a *algorithms.Algorithm, tick *ticks.MarketTick) {
+	cs.ticks += 1
+	cs.last = tick.Time
+}
+
+var counter *countingStrategy
+
+func init() {
+	registry.RegisterStrategy("counting", "counts ticks", registry.Schema{}, func(p registry.Params) algorithms.Strategy {
+		counter = &countingStrategy{}
+		return counter
+	})
+}
+
+func TestParse(t *testing.T) {
+	c := Parse([]byte(`{
+		"name": "test",
+		"symbols": ["EURUSD", "GBPUSD"],
+		"indicators": [{"name": "fast", "type": "ema", "chart": "H1", "params": {"periods": 10}}],
+		"strategy": {"name": "counting", "params": {"lots": 0.1}}
+	}`), "test")
+
+	if "test" != c.Account.Name || 10000.0 != c.Account.Deposit || 1 != c.Account.Leverage {
+		t.Errorf("account defaults not applied: %+v", c.Account)
+	}
+
+	if "fxcm-m1" != c.Data.Format {
+		t.Errorf("expected fxcm-m1 format by default, got %s", c.Data.Format)
+	}
+
+	if 2 != len(c.Symbols) || "H1" != c.Indicators[0].Chart || 10.0 != c.Indicators[0].Params["periods"] {
+		t.Errorf("config not parsed: %+v", c)
+	}
+
+	if 0.1 != c.Strategy.Params["lots"] {
+		t.Errorf("strategy params not parsed: %+v", c.Strategy)
+	}
+
+	again := Parse(c.JSON(), "JSON")
+	if string(c.JSON()) != string(again.JSON()) {
+		t.Errorf("JSON doesn't read back the same:\n%s\n%s", c.JSON(), again.JSON())
+	}
+}
+
+func TestProblems(t *testing.T) {
+	c := Parse([]byte(`{
+		"account": {"deposit": -1},
+		"charts": ["M7"],
+		"indicators": [
+			{"name": "a", "type": "ema", "params": {"periods": 2.5, "smoothing": 2}},
+			{"name": "a", "type": "nope"},
+			{"name": "b", "type": "rsi", "input": {"indicator": "c"}}
+		],
+		"strategy": {"name": "nope"},
+		"data": {"format": "excel"},
+		"start": "2014-03-03",
+		"end": "2014-03-01"
+	}`), "test")
+
+	problems := strings.Join(c.Problems(), "\n")
+
+	expected := []string{
+		"account deposit must be > 0",
+		"no symbols",
+		"unknown chart: M7",
+		"indicator a is there twice",
+		"indicator a: parameter periods should be a whole number, got 2.5",
+		"indicator a: unknown parameter: smoothing",
+		`indicator a has unknown type "nope"`,
+		"indicator b takes input from unknown indicator: c",
+		`unknown strategy "nope"`,
+		"no data paths",
+		`unknown data format "excel"`,
+		"start (2014-03-03) must be before end (2014-03-01)",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(problems, e) {
+			t.Errorf("expected problem %q in:\n%s", e, problems)
+		}
+	}
+}
+
+func TestPortfolioProblems(t *testing.T) {
+	c := Parse([]byte(`{
+		"symbols": ["EURUSD"],
+		"strategies": [
+			{"tag": "a", "name": "counting", "weight": 0.5},
+			{"tag": "a", "name": "counting", "weight": 1.5},
+			{"name": "counting", "weight": 0.5}
+		],
+		"data": {"paths": ["EURUSD.csv"]}
+	}`), "test")
+
+	expected := []string{
+		"portfolio strategy tag a is there twice",
+		"portfolio strategy a needs a weight > 0 and <= 1",
+		"portfolio strategy counting has no tag",
+	}
+
+	if e, g := strings.Join(expected, "\n"), strings.Join(c.Problems(), "\n"); e != g {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", e, g)
+	}
+
+	c.Strategy = &Strategy{Name: "counting"}
+
+	if problems := c.Problems(); "either a strategy or strategies, not both" != problems[len(problems) - 1] {
+		t.Errorf("expected a complaint about both, got %v", problems)
+	}
+}
+
+func TestCircuitBreakerProblems(t *testing.T) {
+	c := Parse([]byte(`{
+		"symbols": ["EURUSD"],
+		"strategy": {"name": "counting"},
+		"circuit_breakers": [
+			{"type": "daily_loss", "limit": 2.0, "cool_off": "next_day"},
+			{"type": "weekly_loss", "limit": 100.0, "cool_off": "tomorrow"},
+			{"type": "consecutive_losses", "limit": 2.5, "cool_off": "recovery"},
+			{"type": "max_loss", "limit": 5.0, "cool_off": "never"}
+		],
+		"data": {"paths": ["EURUSD.csv"]}
+	}`), "test")
+
+	expected := []string{
+		"circuit breaker weekly_loss needs a limit > 0 and < 100",
+		"circuit breaker weekly_loss has unknown cool-off \"tomorrow\"",
+		"circuit breaker consecutive_losses needs a whole number limit >= 1",
+		"circuit breaker consecutive_losses needs a recovery > 0 and <= 100",
+		"unknown circuit breaker type \"max_loss\"",
+	}
+
+	if e, g := strings.Join(expected, "\n"), strings.Join(c.Problems(), "\n"); e != g {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", e, g)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csv := []string{`"Symbol","Date","Time","OpenBid","HighBid","LowBid","CloseBid","OpenAsk","HighAsk","LowAsk","CloseAsk","Total Ticks"`}
+
+	start := time.Date(2014, 3, 3, 23, 50, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		bid := 1.3700 + float64(i%5) * 0.0001
+		csv = append(csv, fmt.Sprintf(
+			"EURUSD,%s,%.5f,%.5f,%.5f,%.5f,%.5f,%.5f,%.5f,%.5f,100",
+			start.Add(time.Duration(i) * time.Minute).Format("01/02/2006,15:04:05"),
+			bid, bid + 0.0002, bid - 0.0002, bid, bid + 0.0002, bid + 0.0004, bid, bid + 0.0002,
+		))
+	}
+
+	path := filepath.Join(dir, "EURUSD.csv")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(csv, "\n") + "\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Parse([]byte(`{
+		"name": "build test",
+		"account": {"deposit": 5000, "leverage": 10},
+		"symbols": ["EURUSD"],
+		"indicators": [
+			{"name": "sma", "type": "sma", "params": {"periods": 3}},
+			{"name": "rsi_of_sma", "type": "rsi", "params": {"periods": 2}, "input": {"indicator": "sma"}}
+		],
+		"strategy": {"name": "counting"},
+		"data": {"paths": ["` + path + `"]},
+		"end": "2014-03-04"
+	}`), "test")
+
+	e, algo := c.Build()
+
+	if 10 * 100.0 != algo.Account.MarginRequirementPerLot() {
+		t.Errorf("account not set up from config")
+	}
+
+	e.Run()
+
+	if !algo.IndicatorReady("EURUSD", "rsi_of_sma") {
+		t.Errorf("expected rsi_of_sma to be ready")
+	}
+
+	// ticks from 2014-03-04 on are after the end
+	if counter.last.After(time.Date(2014, 3, 3, 23, 59, 0, 0, time.UTC)) {
+		t.Errorf("got a tick after the end date: %s", counter.last)
+	}
+
+	if 0 == counter.ticks {
+		t.Errorf("strategy got no ticks")
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"../candles"
+	"../quotes"
+	"../ticks"
+	"../utils"
+)
+
+// Scans FXCM M1 CSVs (the format ticks.FXCMM1CsvReader reads) for problems before we backtest on
+// them, and optionally writes out a cleaned copy.
+
+// ===== ISSUES ====================================================================================
+
+const (
+	MALFORMED_ROW     = "malformed_row"
+	MISSING_MINUTES   = "missing_minutes"
+	DUPLICATE_BAR     = "duplicate_bar"
+	OUT_OF_ORDER      = "out_of_order"
+	WEEKEND_BAR       = "weekend_bar"
+	UNALIGNED_TIME    = "unaligned_timestamp"
+	OHLC_INCONSISTENT = "ohlc_inconsistent"
+	NEGATIVE_SPREAD   = "negative_spread"
+	SPREAD_OUTLIER    = "spread_outlier"
+	ZERO_VOLUME       = "zero_volume"
+)
+
+// bars with these problems are dropped from the cleaned copy. Malformed rows aren't here as they
+// have no time to put them in a week, they're reported for the whole run.
+var fatalIssues = []string{
+	DUPLICATE_BAR,
+	OUT_OF_ORDER,
+	WEEKEND_BAR,
+	UNALIGNED_TIME,
+	OHLC_INCONSISTENT,
+	NEGATIVE_SPREAD,
+}
+
+// ===== TRADING HOURS =============================================================================
+
+// The market's shut from Friday 17:00 to Sunday 17:00 New York, which is 21:00 or 22:00 UTC
+// depending on DST. utils.IsWeekend uses the wider of the two, which is no good for spotting bars
+// that shouldn't be there.
+func isWeekend(t time.Time) bool {
+	switch candles.TradingDay(t).Weekday() {
+	case time.Friday, time.Saturday:
+		return true
+	}
+
+	return false
+}
+
+// tradingDuration is the time between from and to, minus any weekends in between
+func tradingDuration(from, to time.Time) time.Duration {
+	total := time.Duration(0)
+
+	for day := candles.TradingDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if isWeekend(day) {
+			continue
+		}
+
+		start, end := day, day.AddDate(0, 0, 1)
+		if from.After(start) {
+			start = from
+		}
+
+		if to.Before(end) {
+			end = to
+		}
+
+		total += end.Sub(start)
+	}
+
+	return total
+}
+
+// ===== WEEK ======================================================================================
+
+type Week struct {
+	Start time.Time
+
+	Bars    int64
+	Missing int64
+	Issues  map[string]int64
+
+	audit *SymbolAudit
+}
+
+func weekStart(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	return day.Add(-time.Duration(day.Weekday()) * utils.Day)
+}
+
+// ExpectedBars is how many M1 bars there should be in the week's trading hours, Sunday 17:00 to
+// Friday 17:00 New York, cut short where the data for the symbol starts or ends
+func (w *Week) ExpectedBars() int64 {
+	if 0 == w.Bars {
+		return 0
+	}
+
+	y, m, d := w.Start.Date()
+
+	from := time.Date(y, m, d, int(candles.NEW_YORK_CLOSE / time.Hour), 0, 0, 0, candles.NEW_YORK)
+	to := from.AddDate(0, 0, 5)
+
+	if w.audit.first.After(from) {
+		from = w.audit.first
+	}
+
+	if end := w.audit.last.Time.Add(time.Minute); end.Before(to) {
+		to = end
+	}
+
+	return int64(tradingDuration(from, to) / time.Minute)
+}
+
+func (w *Week) Coverage() float64 {
+	if 0 == w.Bars {
+		return 0.0
+	}
+
+	return math.Min(100.0, (float64(w.Bars) / float64(w.ExpectedBars())) * 100.0)
+}
+
+func (w *Week) IsSafe() bool {
+	if w.Coverage() < minCoverage {
+		return false
+	}
+
+	for _, issue := range fatalIssues {
+		if w.Issues[issue] > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ===== SYMBOL AUDIT ==============================================================================
+
+type SymbolAudit struct {
+	Symbol string
+
+	Bars   int64
+	Issues map[string]int64
+	Weeks  map[int64]*Week
+
+	first time.Time // of the bars we kept
+	last  *ticks.MarketTick
+	seen  map[int64]bool
+
+	// running spread stats (Welford)
+	spreadCount int64
+	spreadMean  float64
+	spreadM2    float64
+}
+
+func NewSymbolAudit(symbol string) *SymbolAudit {
+	return &SymbolAudit{
+		Symbol: symbol,
+		Issues: make(map[string]int64),
+		Weeks:  make(map[int64]*Week),
+		seen:   make(map[int64]bool),
+	}
+}
+
+func (sa *SymbolAudit) week(t time.Time) *Week {
+	ws := weekStart(t)
+
+	w, ok := sa.Weeks[ws.Unix()]
+	if !ok {
+		w = &Week{Start: ws, Issues: make(map[string]int64), audit: sa}
+		sa.Weeks[ws.Unix()] = w
+	}
+
+	return w
+}
+
+func (sa *SymbolAudit) record(w *Week, issue string, count int64) {
+	sa.Issues[issue] += count
+	w.Issues[issue] += count
+}
+
+func (sa *SymbolAudit) spreadStdDev() float64 {
+	if sa.spreadCount < 2 {
+		return 0.0
+	}
+
+	return math.Sqrt(sa.spreadM2 / float64(sa.spreadCount - 1))
+}
+
+func (sa *SymbolAudit) recordSpread(spread float64) {
+	sa.spreadCount += 1
+	delta := spread - sa.spreadMean
+	sa.spreadMean += delta / float64(sa.spreadCount)
+	sa.spreadM2 += delta * (spread - sa.spreadMean)
+}
+
+func ohlcInconsistent(open, high, low, close float64) bool {
+	return high < low || open > high || open < low || close > high || close < low
+}
+
+// Audit checks one bar and reports whether it belongs in the cleaned copy
+func (sa *SymbolAudit) Audit(tick *ticks.MarketTick) bool {
+	sa.Bars += 1
+
+	w := sa.week(tick.Time)
+
+	keep := true
+
+	// ----- TIMESTAMPS --------------------------------------------------------------------
+
+	if sa.seen[tick.Time.Unix()] {
+		sa.record(w, DUPLICATE_BAR, 1)
+		keep = false
+	} else if nil != sa.last && tick.Time.Before(sa.last.Time) {
+		sa.record(w, OUT_OF_ORDER, 1)
+		keep = false
+	}
+	sa.seen[tick.Time.Unix()] = true
+
+	if 0 != tick.Time.Second() || 0 != tick.Time.Nanosecond() {
+		sa.record(w, UNALIGNED_TIME, 1)
+		keep = false
+	}
+
+	if isWeekend(tick.Time) {
+		sa.record(w, WEEKEND_BAR, 1)
+		keep = false
+	}
+
+	// ----- PRICES ------------------------------------------------------------------------
+
+	if ohlcInconsistent(tick.OpenBid, tick.HighBid, tick.LowBid, tick.CloseBid) ||
+		ohlcInconsistent(tick.OpenAsk, tick.HighAsk, tick.LowAsk, tick.CloseAsk) {
+		sa.record(w, OHLC_INCONSISTENT, 1)
+		keep = false
+	}
+
+	spread := float64(quotes.DifferenceInPips(tick.Symbol, tick.OpenBid, tick.OpenAsk))
+
+	if spread < 0.0 {
+		sa.record(w, NEGATIVE_SPREAD, 1)
+		keep = false
+	} else {
+		stddev := sa.spreadStdDev()
+
+		if sa.spreadCount >= SPREAD_WARMUP && spread > sa.spreadMean + (spreadSigmas * stddev) {
+			sa.record(w, SPREAD_OUTLIER, 1)
+		} else {
+			// keep outliers out of the baseline so one bad week doesn't hide the next
+			sa.recordSpread(spread)
+		}
+	}
+
+	if 0 == tick.Volume {
+		sa.record(w, ZERO_VOLUME, 1)
+	}
+
+	if keep {
+		if nil != sa.last {
+			missing := int64(tradingDuration(sa.last.Time, tick.Time) / time.Minute) - 1
+			if missing > 0 {
+				sa.record(w, MISSING_MINUTES, missing)
+				w.Missing += missing
+			}
+		}
+
+		if nil == sa.last {
+			sa.first = tick.Time
+		}
+
+		sa.last = tick
+		w.Bars += 1
+	}
+
+	return keep
+}
+
+func (sa *SymbolAudit) Print() {
+	fmt.Printf("===== %s =====\n", sa.Symbol)
+	fmt.Printf(
+		"Bars: %s, Mean spread: %.1f pips (stddev: %.1f)\n",
+		utils.AddCommas(sa.Bars),
+		sa.spreadMean,
+		sa.spreadStdDev(),
+	)
+
+	issues := []string{}
+	for issue := range sa.Issues {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+
+	for _, issue := range issues {
+		fmt.Printf("%-20s %s\n", issue, utils.AddCommas(sa.Issues[issue]))
+	}
+
+	fmt.Println("")
+
+	weeks := []int64{}
+	for key := range sa.Weeks {
+		weeks = append(weeks, key)
+	}
+	sort.Sort(int64Slice(weeks))
+
+	safe := int64(0)
+
+	for _, key := range weeks {
+		w := sa.Weeks[key]
+
+		verdict := "UNSAFE"
+		if w.IsSafe() {
+			verdict = "ok"
+			safe += 1
+		}
+
+		problems := []string{}
+		for _, issue := range issues {
+			if w.Issues[issue] > 0 && MISSING_MINUTES != issue {
+				problems = append(problems, fmt.Sprintf("%s: %d", issue, w.Issues[issue]))
+			}
+		}
+
+		fmt.Printf(
+			"Week of %s: Bars: %6s, Coverage: %5.1f%%, Missing: %5d [%6s] %s\n",
+			w.Start.Format("2006-01-02"),
+			utils.AddCommas(w.Bars),
+			w.Coverage(),
+			w.Missing,
+			verdict,
+			strings.Join(problems, ", "),
+		)
+	}
+
+	fmt.Printf("\nSafe weeks: %d/%d\n\n", safe, len(weeks))
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+
+// ===== CSV =======================================================================================
+
+// auditCSV audits every bar in the file, writing the ones worth keeping to writer if there is one,
+// and returns how many rows were malformed
+func auditCSV(csvPath string, audits map[string]*SymbolAudit, writer *csv.Writer) int64 {
+	malformed := int64(0)
+
+	csvfile, err := os.Open(csvPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer csvfile.Close()
+
+	reader := csv.NewReader(csvfile)
+	reader.FieldsPerRecord = -1 // count bad rows instead of bailing
+
+	first := true // used to skip header row in CSV
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			malformed += 1
+			continue
+		}
+
+		if first {
+			first = false
+			continue
+		}
+
+		tick, err := ticks.ParseFXCMM1Record(record)
+		if err != nil {
+			malformed += 1
+			continue
+		}
+
+		audit, ok := audits[tick.Symbol]
+		if !ok {
+			audit = NewSymbolAudit(tick.Symbol)
+			audits[tick.Symbol] = audit
+		}
+
+		if audit.Audit(tick) && nil != writer {
+			writer.Write(ticks.FXCMM1Record(tick))
+		}
+	}
+
+	return malformed
+}
+
+// ===== PROGRAM ENTRYPOINT ========================================================================
+
+const SPREAD_WARMUP = 1000 // bars to see before we trust the spread baseline
+
+var path string
+var output string
+var minCoverage float64
+var spreadSigmas float64
+
+func parseFlags() {
+	flag.StringVar(&path, "path", "", "path to CSV file(s) to audit, comma separated")
+	flag.StringVar(&output, "output", "", "write a cleaned copy of the data here (optional)")
+	flag.Float64Var(&minCoverage, "min-coverage", 95.0, "minimum % of minutes present for a week to be safe")
+	flag.Float64Var(&spreadSigmas, "spread-sigmas", 6.0, "standard deviations above the mean before a spread is an outlier")
+	flag.Parse()
+
+	if "" == path {
+		panic("must supply --path")
+	}
+}
+
+func main() {
+	parseFlags()
+
+	startTime := time.Now()
+
+	audits := make(map[string]*SymbolAudit)
+	malformed := int64(0)
+
+	var writer *csv.Writer
+
+	if "" != output {
+		outfile, err := os.Create(output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer outfile.Close()
+
+		writer = csv.NewWriter(outfile)
+		writer.Write(ticks.FXCMM1Header)
+	}
+
+	for _, csvPath := range strings.Split(path, ",") {
+		log.Println("Auditing", csvPath)
+
+		malformed += auditCSV(csvPath, audits, writer)
+	}
+
+	if nil != writer {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	// ----- REPORT ------------------------------------------------------------------------
+
+	fmt.Println("")
+	fmt.Println("***** DATA AUDIT *****")
+	fmt.Println("")
+
+	if malformed > 0 {
+		fmt.Printf("%s: %s\n\n", MALFORMED_ROW, utils.AddCommas(malformed))
+	}
+
+	symbols := []string{}
+	for symbol := range audits {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		audits[symbol].Print()
+	}
+
+	if "" != output {
+		fmt.Println("Cleaned copy written to", output)
+	}
+
+	fmt.Printf("Audited %d symbols in %.2fs\n", len(symbols), time.Since(startTime).Seconds())
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"../ticks"
+)
+
+// a week of trading hours, Sunday 17:00 to Friday 17:00 New York
+const FULL_WEEK = 5 * 24 * 60
+
+// New York's on EST until DST starts on March 9th
+var sunday = time.Date(2014, 3, 2, 0, 0, 0, 0, time.UTC)
+var nextSunday = sunday.Add(7 * 24 * time.Hour)
+
+// bars feeds sa a bar a minute from from until to, weekends and all, and returns how many it kept
+func bars(sa *SymbolAudit, from, to time.Time) int64 {
+	kept := int64(0)
+
+	for t := from; t.Before(to); t = t.Add(time.Minute) {
+		if sa.Audit(ticks.NewRawTick("EURUSD", t, 1.37000, 1.37020)) {
+			kept += 1
+		}
+	}
+
+	return kept
+}
+
+func at(day time.Time, hours int) time.Time {
+	return day.Add(time.Duration(hours) * time.Hour)
+}
+
+func expectWeek(t *testing.T, sa *SymbolAudit, start time.Time, bars, expected int64, safe bool) {
+	w, ok := sa.Weeks[start.Unix()]
+	if !ok {
+		t.Fatalf("no week of %s", start)
+	}
+
+	if bars != w.Bars || expected != w.ExpectedBars() {
+		t.Errorf("week of %s: expected %d/%d bars, got %d/%d", start, bars, expected, w.Bars, w.ExpectedBars())
+	}
+
+	coverage := 100.0 * float64(bars) / float64(expected)
+	if math.Abs(coverage - w.Coverage()) > 0.001 || safe != w.IsSafe() {
+		t.Errorf("week of %s: expected %.1f%% coverage, safe: %v, got %.1f%%", start, coverage, safe, w.Coverage())
+	}
+}
+
+// the data starting Wednesday and stopping Thursday doesn't count against the week
+func TestPartialWeek(t *testing.T) {
+	minCoverage = 95.0
+
+	wednesday := sunday.Add(3 * 24 * time.Hour)
+
+	sa := NewSymbolAudit("EURUSD")
+	bars(sa, at(wednesday, 12), at(wednesday, 36))
+
+	expectWeek(t, sa, sunday, 24 * 60, 24 * 60, true)
+}
+
+// a week that stops early is missing the rest of it, even though what's there is complete
+func TestGappyWeek(t *testing.T) {
+	minCoverage = 95.0
+
+	sa := NewSymbolAudit("EURUSD")
+
+	// 22:00 UTC on EST, 21:00 once DST starts
+	bars(sa, at(sunday, 22), at(sunday, 3 * 24))
+	bars(sa, at(nextSunday, 21), at(nextSunday, 5 * 24 + 21))
+
+	// Sunday 22:00 to Wednesday 00:00
+	expectWeek(t, sa, sunday, 2 * 24 * 60 + 120, FULL_WEEK, false)
+	expectWeek(t, sa, nextSunday, FULL_WEEK, FULL_WEEK, true)
+
+	// Wednesday 00:00 to Friday 22:00
+	if 2 * 24 * 60 + 22 * 60 != sa.Weeks[nextSunday.Unix()].Missing {
+		t.Errorf("expected Wednesday to Friday to be missing, got %d minutes", sa.Weeks[nextSunday.Unix()].Missing)
+	}
+}
+
+// the weekend moves an hour in UTC when New York changes clocks, between the Friday and Sunday
+// for the 2014 starts and ends of DST
+func TestWeekendAcrossDST(t *testing.T) {
+	tests := []struct {
+		friday time.Time
+		close  int // UTC hours
+		open   int
+	}{
+		{time.Date(2014, 3, 7, 0, 0, 0, 0, time.UTC), 22, 2 * 24 + 21},
+		{time.Date(2014, 10, 31, 0, 0, 0, 0, time.UTC), 21, 2 * 24 + 22},
+	}
+
+	for _, test := range tests {
+		sa := NewSymbolAudit("EURUSD")
+
+		from, to := at(test.friday, 20), at(test.friday, 2 * 24 + 23)
+		total := int64(to.Sub(from) / time.Minute)
+
+		kept := bars(sa, from, to)
+
+		trading := int64((test.close - 20) * 60 + (2 * 24 + 23 - test.open) * 60)
+		if trading != kept {
+			t.Errorf("weekend of %s: expected to keep %d bars, kept %d", test.friday.Format("2006-01-02"), trading, kept)
+		}
+
+		if total - trading != sa.Issues[WEEKEND_BAR] {
+			t.Errorf("weekend of %s: expected %d weekend bars, got %d", test.friday.Format("2006-01-02"), total - trading, sa.Issues[WEEKEND_BAR])
+		}
+
+		if 0 != sa.Issues[MISSING_MINUTES] {
+			t.Errorf("weekend of %s: expected no missing minutes, got %d", test.friday.Format("2006-01-02"), sa.Issues[MISSING_MINUTES])
+		}
+	}
+}
+
+func TestBadTimestamps(t *testing.T) {
+	monday := at(sunday, 24 + 12)
+
+	offsets := []time.Duration{0, 1 * time.Minute, 1 * time.Minute, 3 * time.Minute, 2 * time.Minute, 4 * time.Minute + 30 * time.Second, 5 * time.Minute}
+	expected := []bool{true, true, false, true, false, false, true}
+
+	sa := NewSymbolAudit("EURUSD")
+
+	for i, offset := range offsets {
+		if kept := sa.Audit(ticks.NewRawTick("EURUSD", monday.Add(offset), 1.37000, 1.37020)); expected[i] != kept {
+			t.Errorf("bar %d: expected kept to be %v", i, expected[i])
+		}
+	}
+
+	issues := map[string]int64{DUPLICATE_BAR: 1, OUT_OF_ORDER: 1, UNALIGNED_TIME: 1, MISSING_MINUTES: 2}
+	for issue, count := range issues {
+		if count != sa.Issues[issue] {
+			t.Errorf("expected %d %s, got %d", count, issue, sa.Issues[issue])
+		}
+	}
+
+	if sa.Weeks[sunday.Unix()].IsSafe() {
+		t.Errorf("expected the week to be unsafe")
+	}
+}
+
+func TestBadPrices(t *testing.T) {
+	monday := at(sunday, 24 + 12)
+
+	highBelowLow := ticks.NewRawTick("EURUSD", monday, 1.37000, 1.37020)
+	highBelowLow.HighBid = 1.36990
+
+	closeAboveHigh := ticks.NewRawTick("EURUSD", monday.Add(time.Minute), 1.37000, 1.37020)
+	closeAboveHigh.CloseAsk = 1.37030
+
+	negative := ticks.NewRawTick("EURUSD", monday.Add(2 * time.Minute), 1.37020, 1.37000)
+
+	sa := NewSymbolAudit("EURUSD")
+
+	for i, tick := range []*ticks.MarketTick{highBelowLow, closeAboveHigh, negative} {
+		if sa.Audit(tick) {
+			t.Errorf("bar %d: expected it to be dropped", i)
+		}
+	}
+
+	if 2 != sa.Issues[OHLC_INCONSISTENT] || 1 != sa.Issues[NEGATIVE_SPREAD] {
+		t.Errorf("expected 2 %s and 1 %s, got %v", OHLC_INCONSISTENT, NEGATIVE_SPREAD, sa.Issues)
+	}
+}
+
+// outliers are reported but kept, and left out of the baseline
+func TestSpreadOutliers(t *testing.T) {
+	spreadSigmas = 6.0
+
+	monday := at(sunday, 24)
+
+	sa := NewSymbolAudit("EURUSD")
+
+	// 1.0 and 1.4 pips, a mean of 1.2 and a standard deviation of 0.2
+	for i := 0; i < SPREAD_WARMUP; i++ {
+		spread := 0.00010 + 0.00004 * float64(i % 2)
+		sa.Audit(ticks.NewRawTick("EURUSD", monday.Add(time.Duration(i) * time.Minute), 1.37000, 1.37000 + spread))
+	}
+
+	mean := sa.spreadMean
+	next := monday.Add(SPREAD_WARMUP * time.Minute)
+
+	if !sa.Audit(ticks.NewRawTick("EURUSD", next, 1.37000, 1.37030)) {
+		t.Errorf("expected a 3 pip spread to be kept")
+	}
+
+	if 1 != sa.Issues[SPREAD_OUTLIER] || mean != sa.spreadMean {
+		t.Errorf("expected a 3 pip spread to be an outlier left out of the mean, got %d and %.4f", sa.Issues[SPREAD_OUTLIER], sa.spreadMean)
+	}
+
+	sa.Audit(ticks.NewRawTick("EURUSD", next.Add(time.Minute), 1.37000, 1.37020))
+
+	if 1 != sa.Issues[SPREAD_OUTLIER] {
+		t.Errorf("expected a 2 pip spread not to be an outlier")
+	}
+}
+
+func TestCleanedCopy(t *testing.T) {
+	good := []string{"EURUSD", "2014-03-03", "12:00:00", "1.37", "1.371", "1.369", "1.3705", "1.3702", "1.3712", "1.3692", "1.3707", "42"}
+	later := []string{"EURUSD", "2014-03-03", "12:01:00", "1.3705", "1.371", "1.37", "1.3708", "1.3707", "1.3712", "1.3702", "1.371", "17"}
+	saturday := []string{"EURUSD", "2014-03-08", "12:00:00", "1.37", "1.371", "1.369", "1.3705", "1.3702", "1.3712", "1.3692", "1.3707", "3"}
+
+	rows := [][]string{
+		ticks.FXCMM1Header,
+		good,
+		good, // duplicate
+		{"EURUSD", "2014-03-03", "12:00:30"},
+		{"EURUSD", "2014-03-03", "12:00:45", "1.37", "x", "1.369", "1.3705", "1.3702", "1.3712", "1.3692", "1.3707", "1"},
+		later,
+		saturday,
+	}
+
+	in, err := ioutil.TempFile("", "data_auditor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(in.Name())
+
+	w := csv.NewWriter(in)
+	w.WriteAll(rows)
+	in.Close()
+
+	out := &bytes.Buffer{}
+	writer := csv.NewWriter(out)
+
+	audits := make(map[string]*SymbolAudit)
+	malformed := auditCSV(in.Name(), audits, writer)
+	writer.Flush()
+
+	if 2 != malformed {
+		t.Errorf("expected 2 malformed rows, got %d", malformed)
+	}
+
+	sa := audits["EURUSD"]
+	if 4 != sa.Bars || 1 != sa.Issues[DUPLICATE_BAR] || 1 != sa.Issues[WEEKEND_BAR] {
+		t.Errorf("expected 4 bars with a duplicate and a weekend bar, got %d: %v", sa.Bars, sa.Issues)
+	}
+
+	expected := []string{}
+	for _, row := range [][]string{good, later} {
+		tick, _ := ticks.ParseFXCMM1Record(row)
+		expected = append(expected, strings.Join(ticks.FXCMM1Record(tick), ","))
+	}
+
+	if cleaned := strings.TrimSpace(out.String()); strings.Join(expected, "\n") != cleaned {
+		t.Errorf("expected only the good bars in the cleaned copy, got:\n%s", cleaned)
+	}
+}
@@ -0,0 +1,178 @@
+package exchanges
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"../accounts"
+	"../algorithms"
+	"../brokers"
+	"../candles"
+	"../checkpoints"
+	"../journals"
+	"../ticks"
+)
+
+// ===== CHECKPOINTS ===============================================================================
+
+// SetCheckpoint saves the run to path every days simulated days (0 for only when it's interrupted,
+// e.g. with ctrl-c), overwriting the last one. See Resume.
+func (e *Exchange) SetCheckpoint(path string, days int64) {
+	e.checkpointPath = path
+	e.checkpointDays = days
+}
+
+// Interrupt stops the run before the next tick, saving a checkpoint instead of the results if
+// there's a path for one. SIGINT does the same once there is.
+func (e *Exchange) Interrupt() {
+	select {
+	case e.interrupts <- os.Interrupt:
+	default:
+	}
+}
+
+func (e *Exchange) interrupted() bool {
+	select {
+	case <- e.interrupts:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkpointDue is true on the first tick of the trading day the next checkpoint's due
+func (e *Exchange) checkpointDue(tick *ticks.MarketTick) bool {
+	if 0 == e.checkpointDays {
+		return false
+	}
+
+	day := candles.TradingDay(tick.Time)
+
+	if e.lastCheckpoint.IsZero() {
+		e.lastCheckpoint = day
+		return false
+	}
+
+	return !day.Before(e.lastCheckpoint.AddDate(0, 0, int(e.checkpointDays)))
+}
+
+// checkpoint saves everything up to tick, once the algorithms have caught up
+func (e *Exchange) checkpoint(tick *ticks.MarketTick) {
+	algos := []*algorithms.Algorithm{}
+	accts := []*accounts.Account{}
+
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		a := algo.Value.(*algorithms.Algorithm)
+		a.Sync()
+
+		algos = append(algos, a)
+		accts = append(accts, a.Account)
+	}
+
+	if 0 != e.checkpointDays {
+		e.lastCheckpoint = candles.TradingDay(tick.Time)
+	}
+
+	c := &checkpoints.Checkpoint{
+		Version: checkpoints.VERSION,
+
+		At:     tick.Time,
+		Ticks:  e.ticksRead,
+		Every:  e.checkpointDays,
+		Config: e.config,
+
+		Saved: time.Now(),
+
+		TicksRead:            e.ticksRead,
+		TickCount:            e.tickCount,
+		TotalOrdersProcessed: e.totalOrdersProcessed,
+		TotalTicksProcessed:  e.totalTicksProcessed,
+		WarmUpTicksProcessed: e.warmUpTicksProcessed,
+
+		FirstTick:      e.firstTick.State(),
+		LastTick:       e.lastTick.State(),
+		LastCheckpoint: e.lastCheckpoint,
+
+		Validator: e.validator.State(),
+	}
+
+	if b, ok := e.broker.(brokers.Stateful); ok {
+		c.Broker = b.State(accts)
+	}
+
+	if nil != e.journal {
+		js := e.journal.State()
+		c.Journal = &js
+	}
+
+	for _, a := range algos {
+		c.Algorithms = append(c.Algorithms, a.State())
+	}
+
+	c.Write(e.checkpointPath)
+
+	log.At(tick.Time).Debug("saved a checkpoint", "path", e.checkpointPath, "ticks", e.ticksRead)
+}
+
+// Resume picks the run up from a checkpoint instead of the start. The exchange has to be set up
+// the way the one that saved it was: the same algorithms, strategies, data and dates (see
+// configs.Config.Build). Strategies are started first, to set their timers up again.
+func (e *Exchange) Resume(c *checkpoints.Checkpoint) {
+	algos := []*algorithms.Algorithm{}
+	accts := []*accounts.Account{}
+
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		a := algo.Value.(*algorithms.Algorithm)
+		a.Start()
+
+		algos = append(algos, a)
+		accts = append(accts, a.Account)
+	}
+
+	if len(c.Algorithms) != len(algos) {
+		panic(fmt.Sprintf("the checkpoint has %d algorithms, the exchange has %d", len(c.Algorithms), len(algos)))
+	}
+
+	e.ticksRead = c.TicksRead
+	e.tickCount = c.TickCount
+	e.totalOrdersProcessed = c.TotalOrdersProcessed
+	e.totalTicksProcessed = c.TotalTicksProcessed
+	e.warmUpTicksProcessed = c.WarmUpTicksProcessed
+
+	e.firstTick = c.FirstTick.Tick()
+	e.lastTick = c.LastTick.Tick()
+
+	e.lastCheckpoint = c.LastCheckpoint
+	if !e.lastCheckpoint.IsZero() {
+		e.lastCheckpoint = e.lastCheckpoint.In(candles.NEW_YORK) // gob only keeps the offset
+	}
+
+	e.validator.Restore(c.Validator)
+
+	for i, a := range algos {
+		a.Restore(c.Algorithms[i])
+
+		// the exchange is what tells algorithms about their orders' stops changing
+		for _, o := range a.Account.OpenOrders() {
+			o.OnStopsModified(a.StopModified)
+		}
+	}
+
+	if b, ok := e.broker.(brokers.Stateful); ok && nil != c.Broker {
+		b.Restore(c.Broker, accts)
+	}
+
+	// the journal, if the run kept one, carries on where the checkpoint left it
+	if nil != c.Journal {
+		if nil == e.journal && "" != c.Journal.Path {
+			e.SetJournal(journals.NewFile(c.Journal.Path))
+		}
+
+		if nil != e.journal {
+			e.journal.Restore(*c.Journal)
+		}
+	}
+
+	e.resumeFrom = c
+}
@@ -3,21 +3,31 @@ package exchanges
 import (
 	"container/list"
 	"fmt"
-	"log"
-	"math"
+	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"../accounts"
 	"../algorithms"
+	"../brokers"
+	"../checkpoints"
+	"../journals"
+	"../loggers"
 	"../orders"
 	"../stops"
 	"../ticks"
 	"../utils"
+	"../validators"
 )
 
+var log = loggers.Component("exchanges")
+
 func NewWithDeets(mt ticks.MarketTicker) *Exchange {
-	e := Exchange{tickSource: mt}
+	e := Exchange{tickSource: mt, validator: validators.Default(), interrupts: make(chan os.Signal, 1)}
+	e.broker = &e
 
 	return &e
 }
@@ -25,19 +35,96 @@ func NewWithDeets(mt ticks.MarketTicker) *Exchange {
 type Exchange struct {
 	algorithms list.List
 	tickSource ticks.MarketTicker
+	validator  *validators.Validator
+	broker     brokers.Broker
 
 	totalOrdersProcessed int64
 	totalTicksProcessed  int64
+	warmUpTicksProcessed int64
+	ticksRead            int64 // from the tick source
+	tickCount            int64 // after the start date
+
+	startDate time.Time
+	endDate   time.Time
+
+	config []byte
 
 	firstTick *ticks.MarketTick
 	lastTick  *ticks.MarketTick
 
 	runStartedAt time.Time
+
+	checkpointPath string
+	checkpointDays int64
+	lastCheckpoint time.Time // trading day
+	resumeFrom     *checkpoints.Checkpoint
+	interrupts     chan os.Signal
+
+	journal *journals.Journal
+}
+
+func (e *Exchange) SetValidator(v *validators.Validator) {
+	e.validator = v
+}
+
+// SetBroker puts b between the algorithms added after it and the exchange, e.g. a
+// risk_managers.RiskManager wrapping the exchange
+func (e *Exchange) SetBroker(b brokers.Broker) {
+	e.broker = b
+}
+
+// SetStartDate starts the results at t. Ticks from before it only warm the algorithms up, going as
+// far back as the longest Algorithm.WarmUpPeriod, or all the way if any of them can't tell.
+func (e *Exchange) SetStartDate(t time.Time) {
+	e.startDate = t
+}
+
+// SetEndDate stops the run at the first tick on or after t
+func (e *Exchange) SetEndDate(t time.Time) {
+	e.endDate = t
+}
+
+// SetJournal records what happens to every algorithm's account in j, see journals.Replay
+func (e *Exchange) SetJournal(j *journals.Journal) {
+	e.journal = j
+
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		algo.Value.(*algorithms.Algorithm).SetJournal(j)
+	}
+}
+
+// RecordConfig keeps the config the run was set up from, to print with the results
+func (e *Exchange) RecordConfig(raw []byte) {
+	e.config = raw
+}
+
+// preloadFrom is when warm-up ticks should start
+func (e *Exchange) preloadFrom() time.Time {
+	from := e.startDate
+
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		a := algo.Value.(*algorithms.Algorithm)
+
+		period, ok := a.WarmUpPeriod()
+		if !ok {
+			return time.Time{}
+		}
+
+		if e.startDate.Add(-period).Before(from) {
+			from = e.startDate.Add(-period)
+		}
+	}
+
+	return from
 }
 
 func (e *Exchange) AddAlgorithm(a *algorithms.Algorithm) {
 	e.algorithms.PushBack(a)
-	a.Init(e)
+	a.Init(e.broker)
+
+	if nil != e.journal {
+		a.SetJournal(e.journal)
+	}
 }
 
 func (e *Exchange) CloseAllOrders(a *accounts.Account, finalTicks map[string]*ticks.MarketTick) {
@@ -68,11 +155,41 @@ func bidOrAskToOpen(isBuy bool, t *ticks.MarketTick) float64 {
 	}
 }
 
-func (e Exchange) CloseOrder(a *accounts.Account, o *orders.Order, tick *ticks.MarketTick) {
+// algorithmFor finds the algorithm trading with an account, if there is one
+func (e *Exchange) algorithmFor(a *accounts.Account) *algorithms.Algorithm {
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		currAlgo := algo.Value.(*algorithms.Algorithm)
+
+		if currAlgo.Account == a {
+			return currAlgo
+		}
+	}
+
+	return nil
+}
+
+// orderFilled tells the account's algorithm about the order, and about any stop changes after
+func (e *Exchange) orderFilled(a *accounts.Account, o *orders.Order) *orders.Order {
+	algo := e.algorithmFor(a)
+	if nil == algo {
+		return o
+	}
+
+	o.OnStopsModified(algo.StopModified)
+	algo.OrderFilled(o)
+
+	return o
+}
+
+func (e *Exchange) CloseOrder(a *accounts.Account, o *orders.Order, tick *ticks.MarketTick) {
 	if o.IsClosed() {
 		panic("can't close already closed order")
 	}
 
+	if "" == o.CloseReason {
+		o.CloseReason = orders.CLOSED_BY_STRATEGY
+	}
+
 	closePrice := bidOrAskToClose(o.IsBuy(), tick)
 
 	// log.Printf("Closing @ %.5f\n", closePrice)
@@ -91,6 +208,10 @@ func (e Exchange) CloseOrder(a *accounts.Account, o *orders.Order, tick *ticks.M
 	o.EquityAtClose = a.GetEquity()
 
 	o.DrawdownAtClose = a.CurrentDrawdown()
+
+	if algo := e.algorithmFor(a); nil != algo {
+		algo.OrderClosed(o)
+	}
 }
 
 func openOrder(direction orders.TradeDirection, a *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
@@ -145,13 +266,17 @@ func openOrder(direction orders.TradeDirection, a *accounts.Account, symbol stri
 }
 
 func (e *Exchange) OpenBuyOrder(a *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
-	e.totalOrdersProcessed += 1
-	return openOrder(orders.BUY, a, symbol, tick, lots, sl, tp)
+	o := openOrder(orders.BUY, a, symbol, tick, lots, sl, tp)
+	o.ID = atomic.AddInt64(&e.totalOrdersProcessed, 1)
+
+	return e.orderFilled(a, o)
 }
 
 func (e *Exchange) OpenSellOrder(a *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
-	e.totalOrdersProcessed += 1
-	return openOrder(orders.SELL, a, symbol, tick, lots, sl, tp)
+	o := openOrder(orders.SELL, a, symbol, tick, lots, sl, tp)
+	o.ID = atomic.AddInt64(&e.totalOrdersProcessed, 1)
+
+	return e.orderFilled(a, o)
 }
 
 func (e *Exchange) Run() {
@@ -169,54 +294,134 @@ func (e *Exchange) Run() {
 	fmt.Printf("Simulating exchange for %d algorithms\n", numAlgos)
 	fmt.Println("")
 
-	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
-		go algo.Value.(*algorithms.Algorithm).TickReceiverLoop()
+	preloadFrom := e.preloadFrom()
+
+	if nil != e.resumeFrom {
+		fmt.Printf("Resuming from %s\n\n", e.resumeFrom.At.Format("2006-01-02 15:04 MST"))
+	} else if !e.startDate.IsZero() {
+		fmt.Printf("Warming up from %s\n\n", yearMonthDayFromTime(preloadFrom))
 	}
 
-	symbolsSeen := make(map[string]bool)
-	tickDeviance := make(map[string]*ticks.MarketTick)
-	tickCount := int64(0)
+	if nil != e.journal {
+		defer e.journal.Close()
+	}
+
+	if "" != e.checkpointPath {
+		signal.Notify(e.interrupts, os.Interrupt)
+		defer signal.Stop(e.interrupts)
+	}
+
+	for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+		a := algo.Value.(*algorithms.Algorithm)
+
+		a.SetStartDate(e.startDate)
+		go a.TickReceiverLoop()
+	}
 
-	first := true
+	skip := int64(0)
+	if nil != e.resumeFrom {
+		skip = e.resumeFrom.Ticks
+	}
 
 	// FXCM switched to an ECN model with lower spreads the week of 2014/10/05
 	ecnChangeoverCutoff := time.Date(2014, time.October, 5, 0, 0, 0, 0, time.UTC)
 
+	checkpointed := false // interrupted, so there's a checkpoint instead of results
+
 	for tick := range e.tickSource.Ticks() {
+		// already dealt with before the checkpoint
+		if skip > 0 {
+			skip -= 1
+			continue
+		}
+
+		if e.checkpointDue(tick) {
+			e.checkpoint(tick)
+		}
+
+		if e.interrupted() {
+			if "" == e.checkpointPath {
+				fmt.Println("Interrupted, stopping early")
+				fmt.Println("")
+				break
+			}
+
+			e.checkpoint(tick)
+			checkpointed = true
+			break
+		}
+
+		e.ticksRead += 1
+
 		if !tick.Time.Before(ecnChangeoverCutoff) {
 			continue
 		}
 
-		if first {
-			first = false
-			e.firstTick = tick
+		if tick.Time.Before(preloadFrom) {
+			continue
 		}
 
-		_, ok := symbolsSeen[tick.Symbol]
-		if !ok {
-			symbolsSeen[tick.Symbol] = true
-			tickDeviance[tick.Symbol] = tick
+		if !e.endDate.IsZero() && !tick.Time.Before(e.endDate) {
+			break
 		}
 
-		previousTick, ok := tickDeviance[tick.Symbol]
+		validated, ok := e.validator.Validate(tick)
 		if !ok {
-			panic("could not find previous tick for " + tick.Symbol)
+			failure, _ := e.validator.Report.Failure()
+			fmt.Println(failure)
+			fmt.Println("Stopping simulation early due to bad data")
+			fmt.Println("")
+			break
 		}
 
-		validateTick(tick, previousTick)
+		for _, vt := range validated {
+			warmUp := vt.Time.Before(e.startDate)
 
-		for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
-			currAlgo := algo.Value.(*algorithms.Algorithm)
+			if nil == e.firstTick && !warmUp {
+				e.firstTick = vt
+			}
+
+			for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+				currAlgo := algo.Value.(*algorithms.Algorithm)
+
+				if !currAlgo.WantsCurrency(vt.Symbol) {
+					continue
+				}
+
+				currAlgo.SendTick(vt)
 
-			if currAlgo.WantsCurrency(tick.Symbol) {
-				currAlgo.SendTick(tick)
-				e.totalTicksProcessed += 1
+				if warmUp {
+					e.warmUpTicksProcessed += 1
+				} else {
+					e.totalTicksProcessed += 1
+				}
 			}
+
+			if warmUp {
+				continue
+			}
+
+			e.lastTick = vt
+			e.tickCount += 1
+		}
+	}
+
+	if checkpointed {
+		for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+			algo.Value.(*algorithms.Algorithm).StopReceiverLoop()
 		}
 
-		e.lastTick = tick
-		tickDeviance[tick.Symbol] = tick
-		tickCount += 1
+		fmt.Printf("Interrupted, saved a checkpoint to resume from: %s\n", e.checkpointPath)
+		return
+	}
+
+	if nil == e.firstTick {
+		for algo := e.algorithms.Front(); algo != nil; algo = algo.Next() {
+			algo.Value.(*algorithms.Algorithm).StopReceiverLoop()
+		}
+
+		fmt.Println("No ticks on or after the start date, so nothing to report")
+		return
 	}
 
 	// TODO: Move this outta here!
@@ -228,6 +433,16 @@ func (e *Exchange) Run() {
 		a.Account.PrintWeeklyStats(e.firstTick.Time, e.lastTick.Time)
 	}
 
+	e.validator.Report.Print()
+
+	if s, ok := e.broker.(brokers.Summarizer); ok {
+		s.PrintSummary()
+	}
+
+	if nil != e.config {
+		fmt.Printf("***** CONFIG *****\n\n%s\n\n", strings.TrimSpace(string(e.config)))
+	}
+
 	seconds := time.Since(e.runStartedAt).Seconds()
 
 	fmt.Printf(
@@ -237,14 +452,16 @@ func (e *Exchange) Run() {
 		"Ticks in dataset: %s\n" +
 		"Total orders executed: %s\n" +
 		"Total ticks processed: %s\n" +
+		"Warm-up ticks processed: %s\n" +
 		"Ticks processed per second: %s\n" +
 		"Execution time: %.0f seconds\n\n",
 		utils.AddCommas(numAlgos),
 		yearMonthDayFromTime(e.firstTick.Time),
 		yearMonthDayFromTime(e.lastTick.Time),
-		utils.AddCommas(tickCount),
+		utils.AddCommas(e.tickCount),
 		utils.AddCommas(e.totalOrdersProcessed),
 		utils.AddCommas(e.totalTicksProcessed),
+		utils.AddCommas(e.warmUpTicksProcessed),
 		utils.AddCommas(int64(float64(e.totalTicksProcessed) / (float64(seconds) + 0.00001))),
 		seconds,
 	)
@@ -255,48 +472,3 @@ func yearMonthDayFromTime(t time.Time) string {
 
 	return fmt.Sprintf("%d/%d/%d", y, m, d)
 }
-
-// ===== TICKS =====================================================================================
-
-const BID_ASK_DEVIATION_TOLERANCE = float64(0.005)
-
-func validateTick(current *ticks.MarketTick, last *ticks.MarketTick) {
-	sameDay := current.Time.Day() == last.Time.Day()
-
-	bidDiff := last.OpenBid * BID_ASK_DEVIATION_TOLERANCE
-	if !sameDay && (current.OpenBid >= (last.OpenBid + bidDiff) || current.OpenBid <= (last.OpenBid - bidDiff)) {
-		fmt.Printf(
-			"BAD DATA: bid has deviated over %.1f%% (%.1f%%) from last Bid (curr: %.5f, last: %.5f)\n",
-			BID_ASK_DEVIATION_TOLERANCE * 100,
-			math.Abs(((current.OpenBid - last.OpenBid) / current.OpenBid) * 100),
-			current.OpenBid,
-			last.OpenBid,
-		)
-		fmt.Printf("Last:    %s - %#v\n", last.Time, last)
-		fmt.Printf("Current: %s - %#v\n", current.Time, current)
-		log.Fatalln("")
-	}
-
-	askDiff := last.OpenAsk * BID_ASK_DEVIATION_TOLERANCE
-	if !sameDay && (current.OpenAsk >= (last.OpenAsk + askDiff) || current.OpenAsk <= (last.OpenAsk - askDiff)) {
-		fmt.Printf(
-			"BAD DATA: ask has deviated over %.1f%% (%.1f%%) from last ask (curr: %.5f, last: %.5f)\n",
-			BID_ASK_DEVIATION_TOLERANCE * 100,
-			math.Abs(((current.OpenAsk - last.OpenAsk) / current.OpenAsk) * 100),
-			current.OpenAsk,
-			last.OpenAsk,
-		)
-		fmt.Printf("Last:    %s - %#v\n", last.Time, last)
-		fmt.Printf("Current: %s - %#v\n", current.Time, current)
-		log.Fatalln("")
-	}
-
-	if last.Time.Unix() > current.Time.Unix() {
-		log.Fatalf(
-			"BAD DATA: current tick is newer than last tick (curr: %#v, last: %#v)\n",
-			current.Time,
-			last.Time,
-		)
-	}
-}
-
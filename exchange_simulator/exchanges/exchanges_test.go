@@ -0,0 +1,413 @@
+package exchanges
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"../accounts"
+	"../algorithms"
+	"../candles"
+	"../checkpoints"
+	"../circuit_breakers"
+	"../indicators"
+	"../journals"
+	"../indicators/moving_averages"
+	"../orders"
+	"../stops"
+	"../synthetic"
+	"../ticks"
+)
+
+// recorder is for test strategies to keep track of what they're told, one event a line
+type recorder struct {
+	algorithms.BaseStrategy
+
+	events []string
+}
+
+func (r *recorder) record(format string, args ...interface{}) {
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) expect(t *testing.T, what string, expected []string) {
+	if e, g := strings.Join(expected, "\n"), strings.Join(r.events, "\n"); e != g {
+		t.Errorf("%s: expected:\n%s\n\nGot:\n%s", what, e, g)
+	}
+}
+
+type recordingStrategy struct {
+	recorder
+
+	ticks int
+}
+
+func (rs *recordingStrategy) OnStart(a *algorithms.Algorithm) {
+	rs.record("start")
+
+	a.Scheduler.At("timer", time.Date(2014, 3, 3, 21, 30, 0, 0, time.UTC), func(due time.Time) {
+		rs.record("timer %s, bid %.4f", due.Format("15:04"), a.LatestTick("EURUSD").OpenBid)
+	})
+}
+
+func (rs *recordingStrategy) OnTick(a *algorithms.Algorithm, tick *ticks.MarketTick) {
+	rs.ticks += 1
+
+	if 1 == rs.ticks {
+		o := a.Broker.OpenBuyOrder(a.Account, tick.Symbol, tick, 0.01, stops.NoStopLoss(), stops.NoTakeProfit())
+		o.SetStopLoss(10)
+	}
+}
+
+func (rs *recordingStrategy) OnBarClose(a *algorithms.Algorithm, symbol, period string, candle *candles.Candle) {
+	rs.record("bar %s %s %02d:00", symbol, period, candle.OpenTime.Hour())
+}
+
+func (rs *recordingStrategy) OnOrderFilled(a *algorithms.Algorithm, o *orders.Order) {
+	rs.record("filled")
+}
+
+func (rs *recordingStrategy) OnOrderClosed(a *algorithms.Algorithm, o *orders.Order, reason orders.CloseReason) {
+	rs.record("closed %s", reason)
+}
+
+func (rs *recordingStrategy) OnStopModified(a *algorithms.Algorithm, o *orders.Order) {
+	rs.record("stop %.0f", float64(o.GetStopLoss().Pips))
+}
+
+func (rs *recordingStrategy) OnDayStart(a *algorithms.Algorithm, day time.Time) {
+	rs.record("day start %s", day.Format("Jan 2 15:04"))
+}
+
+func (rs *recordingStrategy) OnDayEnd(a *algorithms.Algorithm, day time.Time) {
+	rs.record("day end %s", day.Format("Jan 2 15:04"))
+}
+
+func (rs *recordingStrategy) OnStop(a *algorithms.Algorithm) {
+	rs.record("stop")
+}
+
+// what recordingStrategy sees of the hourly run in TestStrategyHooks, alone or in a portfolio
+var hookEvents = []string{
+	"start",
+	"day start Mar 2 17:00",
+	"filled",
+	"stop 10",
+	"bar EURUSD H1 20:00",
+	"day end Mar 2 17:00",
+	"day start Mar 3 17:00",
+	"timer 21:30, bid 1.3520",
+	"bar EURUSD H1 21:00",
+	"closed stop_loss",
+	"bar EURUSD H1 22:00",
+	"bar EURUSD H1 23:00",
+	"stop",
+}
+
+func TestStrategyHooks(t *testing.T) {
+	// hourly from 20:00 UTC, the trading day rolls over at 22:00 UTC (17:00 New York)
+	start := time.Date(2014, 3, 3, 20, 0, 0, 0, time.UTC)
+	e := NewWithDeets(synthetic.NewScripted("EURUSD", start, time.Hour, 1, 1.3500, 1.3500, 1.3520, 1.3480, 1.3490))
+
+	rs := &recordingStrategy{}
+
+	algo := algorithms.NewWithStrategy(accounts.NewWithDeets("hooks", 10000.0), rs)
+	algo.AddCurrency("EURUSD")
+	algo.AttachCharts("H1")
+
+	e.AddAlgorithm(algo)
+	e.Run()
+
+	rs.expect(t, "hooks", hookEvents)
+}
+
+// opens an order whenever it has none of its own, and records what it's told about
+type sizedStrategy struct {
+	recorder
+
+	direction orders.TradeDirection
+	lots      float64
+}
+
+func (ss *sizedStrategy) OnTick(a *algorithms.Algorithm, tick *ticks.MarketTick) {
+	if a.HasOpenOrders() {
+		return
+	}
+
+	if orders.BUY == ss.direction {
+		a.Broker.OpenBuyOrder(a.Account, tick.Symbol, tick, ss.lots, stops.NoStopLoss(), stops.NoTakeProfit())
+	} else {
+		a.Broker.OpenSellOrder(a.Account, tick.Symbol, tick, ss.lots, stops.NoStopLoss(), stops.NoTakeProfit())
+	}
+}
+
+func (ss *sizedStrategy) OnOrderFilled(a *algorithms.Algorithm, o *orders.Order) {
+	ss.record("filled %s %.2f", o.Tag, o.LotSize)
+}
+
+func (ss *sizedStrategy) OnOrderClosed(a *algorithms.Algorithm, o *orders.Order, reason orders.CloseReason) {
+	ss.record("closed %s %s", o.Tag, reason)
+}
+
+func TestPortfolio(t *testing.T) {
+	start := time.Date(2014, 3, 3, 20, 0, 0, 0, time.UTC)
+	e := NewWithDeets(synthetic.NewScripted("EURUSD", start, time.Hour, 1, 1.3500, 1.3500, 1.3520, 1.3480, 1.3490))
+
+	rs := &recordingStrategy{}
+	seller := &sizedStrategy{direction: orders.SELL, lots: 1.0}
+	buyer := &sizedStrategy{direction: orders.BUY, lots: 1.0}
+
+	acc := accounts.NewWithDeets("portfolio", 10000.0)
+
+	algo := algorithms.NewPortfolio(acc)
+	algo.AddStrategy("hooks", rs, 1.0)
+	algo.AddStrategy("seller", seller, 0.3)
+	algo.AddStrategy("buyer", buyer, 0.5)
+	algo.SetWeight("buyer", 0.25)
+	algo.AddCurrency("EURUSD")
+	algo.AttachCharts("H1")
+
+	e.AddAlgorithm(algo)
+	e.Run()
+
+	// the other strategies' orders and timers don't show up, and they don't see each other's
+	rs.expect(t, "hooks", hookEvents)
+	seller.expect(t, "seller", []string{"filled seller 0.30", "closed seller end_of_run"})
+	buyer.expect(t, "buyer", []string{"filled buyer 0.25", "closed buyer end_of_run"})
+
+	total := int64(0)
+	for _, tag := range algo.Tags() {
+		stats := acc.TagStats(tag)
+		total += stats.Trades
+
+		if 1 != stats.Trades {
+			t.Errorf("Expected 1 trade for %s, got %d", tag, stats.Trades)
+		}
+	}
+
+	if all := acc.TagStats(accounts.ALL_TAGS); total != all.Trades || "0.56" != fmt.Sprintf("%.2f", all.MaxLots) {
+		t.Errorf("Expected %d trades and 0.56 lots open at once for the portfolio, got %+v", total, all)
+	}
+}
+
+// buys whenever it's flat, and records what happens
+type flatBuyer struct {
+	recorder
+}
+
+func (fb *flatBuyer) OnTick(a *algorithms.Algorithm, tick *ticks.MarketTick) {
+	if a.Account.HasOpenOrders() {
+		return
+	}
+
+	if nil == a.Broker.OpenBuyOrder(a.Account, tick.Symbol, tick, 1.0, stops.NoStopLoss(), stops.NoTakeProfit()) {
+		r, _ := a.LastRejection()
+		fb.record("%s %s", tick.Time.Format("15:04"), r.Reason)
+	}
+}
+
+func (fb *flatBuyer) OnOrderFilled(a *algorithms.Algorithm, o *orders.Order) {
+	fb.record("%s filled", o.OpenedAt.Format("15:04"))
+}
+
+func (fb *flatBuyer) OnOrderClosed(a *algorithms.Algorithm, o *orders.Order, reason orders.CloseReason) {
+	fb.record("closed %s", reason)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	// 15:00 New York, the trading day ends at 17:00
+	start := time.Date(2014, 3, 3, 20, 0, 0, 0, time.UTC)
+	e := NewWithDeets(synthetic.NewScripted("EURUSD", start, 30 * time.Minute, 1, 1.3500, 1.3440, 1.3450, 1.3450, 1.3460))
+
+	fb := &flatBuyer{}
+
+	algo := algorithms.NewWithStrategy(accounts.NewWithDeets("breaker", 10000.0), fb)
+	algo.AddCurrency("EURUSD")
+	algo.AddCircuitBreaker(circuit_breakers.NewDailyLoss(5.0, circuit_breakers.NextDay()).Flatten())
+
+	e.AddAlgorithm(algo)
+	e.Run()
+
+	fb.expect(t, "breaker", []string{
+		"20:00 filled",
+		"closed circuit_breaker",
+		"20:30 circuit breaker tripped: daily loss (5.0%)",
+		"21:00 circuit breaker tripped: daily loss (5.0%)",
+		"21:30 circuit breaker tripped: daily loss (5.0%)",
+		"22:00 filled",
+		"closed end_of_run",
+	})
+
+	if events := algo.CircuitBreakers().Events; 2 != len(events) || !events[0].Tripped || events[1].Tripped {
+		t.Errorf("Expected the breaker to trip and reset, got %v", events)
+	}
+}
+
+// buys when an M15 bar closes above its EMA, with an hourly timer
+type crosser struct {
+	recorder
+
+	hours   int64
+	stopped bool
+}
+
+func init() {
+	gob.Register(crosserState{})
+}
+
+// what a checkpoint keeps of it, see algorithms.StatefulStrategy
+type crosserState struct {
+	Events []string
+	Hours  int64
+}
+
+func (c *crosser) State() interface{} {
+	return crosserState{Events: append([]string{}, c.events...), Hours: c.hours}
+}
+
+func (c *crosser) Restore(saved interface{}) {
+	s := saved.(crosserState)
+	c.events, c.hours = s.Events, s.Hours
+}
+
+func (c *crosser) OnStart(a *algorithms.Algorithm) {
+	a.Scheduler.Every("hourly", "0 * * * *", time.UTC, func(due time.Time) { c.hours += 1 })
+}
+
+func (c *crosser) OnBarClose(a *algorithms.Algorithm, symbol, period string, candle *candles.Candle) {
+	if a.Account.HasOpenOrders() || candle.CloseBid <= a.ReadFloat(symbol, "ema", "value", 0) {
+		return
+	}
+
+	tick := a.LatestTick(symbol)
+	a.Broker.OpenBuyOrder(a.Account, symbol, tick, 0.1, stops.NewStopLoss(10), stops.NewTakeProfit(10))
+}
+
+func (c *crosser) OnStop(a *algorithms.Algorithm) {
+	c.stopped = true
+}
+
+func (c *crosser) OnOrderClosed(a *algorithms.Algorithm, o *orders.Order, reason orders.CloseReason) {
+	c.record("%s %s %.2f after %d hours", o.ClosedAt.Format("Jan 2 15:04"), reason, o.Profit(), c.hours)
+}
+
+func crossing() (*Exchange, *crosser) {
+	start := time.Date(2014, 3, 3, 0, 0, 0, 0, time.UTC)
+	e := NewWithDeets(synthetic.NewWithDeets("EURUSD", start, start.AddDate(0, 0, 5), 7, 1.3500, &synthetic.GBM{Volatility: 0.1}))
+
+	c := &crosser{}
+
+	algo := algorithms.NewWithStrategy(accounts.NewWithDeets("crossing", 10000.0), c)
+	algo.AddCurrency("EURUSD")
+	algo.AttachCharts("M15")
+	algo.AddIndicatorOn("ema", algorithms.Binding{Chart: "M15"}, func() indicators.Indicator { return moving_averages.NewEMA(8) })
+
+	e.AddAlgorithm(algo)
+
+	return e, c
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "every.ckpt")
+
+	full, expected := crossing()
+	full.SetCheckpoint(path, 2)
+	full.Run()
+
+	if len(expected.events) < 10 {
+		t.Fatalf("expected a decent number of trades, got %d", len(expected.events))
+	}
+
+	check := func(what string, e *Exchange, c *crosser) {
+		c.expect(t, what, expected.events)
+
+		if expected.hours != c.hours || full.tickCount != e.tickCount || full.totalOrdersProcessed != e.totalOrdersProcessed {
+			t.Errorf("%s: expected the same hours, ticks and orders as the full run", what)
+		}
+	}
+
+	// the last one, at the start of the trading day 17:00 New York on the 6th
+	cp := checkpoints.Read(path)
+	if cp.Ticks <= 0 || cp.Ticks >= full.ticksRead || 6 != cp.At.Day() {
+		t.Errorf("expected a checkpoint part way through on the 6th, got one at %s after %d ticks", cp.At, cp.Ticks)
+	}
+
+	resumed, c := crossing()
+	resumed.Resume(cp)
+	resumed.Run()
+
+	check("every 2 days", resumed, c)
+
+	// straight away, before the strategy's been started
+	path = filepath.Join(dir, "interrupted.ckpt")
+
+	interrupted, stopped := crossing()
+	interrupted.SetCheckpoint(path, 0)
+	interrupted.Interrupt()
+	interrupted.Run()
+
+	if !stopped.stopped {
+		t.Errorf("expected the interrupted run to stop its algorithms")
+	}
+
+	resumed, c = crossing()
+	resumed.Resume(checkpoints.Read(path))
+	resumed.Run()
+
+	check("interrupted", resumed, c)
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "run.jsonl")
+
+	full, expected := crossing()
+	full.SetJournal(journals.NewFile(path))
+	full.SetCheckpoint(filepath.Join(dir, "run.ckpt"), 2)
+	full.Run()
+
+	rp := journals.ReplayFile(path)
+	if !rp.Verified() {
+		t.Fatalf("expected the journal to replay cleanly, got %v", rp.Problems)
+	}
+
+	acc, _ := rp.Account("crossing")
+	if acc.Trades != full.totalOrdersProcessed || int64(len(expected.events)) != acc.Events[journals.ORDER_CLOSED] {
+		t.Errorf("expected %d trades, replayed %d", full.totalOrdersProcessed, acc.Trades)
+	}
+
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the resumed run picks the journal up from the checkpoint without being told about it
+	resumed, _ := crossing()
+	resumed.Resume(checkpoints.Read(filepath.Join(dir, "run.ckpt")))
+	resumed.Run()
+
+	rewritten, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(written) != string(rewritten) {
+		t.Errorf("expected the resumed run to journal the same as the full run")
+	}
+}
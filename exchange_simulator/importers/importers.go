@@ -0,0 +1,506 @@
+package importers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"../pips"
+	"../quotes"
+	"../tick_cache"
+	"../ticks"
+	"../utils"
+)
+
+// MarketTickers for market data from vendors other than FXCM. Every importer parses timestamps in
+// its Location (the time zone the vendor exports in) and normalizes them to UTC, which is what
+// the rest of the simulator assumes.
+
+// HistData exports in EST year-round, no DST
+var EST = time.FixedZone("EST", -5 * 60 * 60)
+
+// ===== HELPERS ===================================================================================
+
+func readCsv(path string, delimiter rune, skipHeader bool, f func([]string)) {
+	csvfile, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	defer csvfile.Close()
+
+	reader := csv.NewReader(csvfile)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+
+	first := true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatalln(err)
+		}
+
+		if first && skipHeader {
+			first = false
+			continue
+		}
+
+		first = false
+
+		f(record)
+	}
+}
+
+func parseTime(layout, value string, loc *time.Location) time.Time {
+	if nil == loc {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(layout, strings.TrimSpace(value), loc)
+	if err != nil {
+		panic("time.Parse: " + err.Error())
+	}
+
+	return t.UTC()
+}
+
+func parsePrice(s string) float64 {
+	return utils.StringToFloat(strings.TrimSpace(s))
+}
+
+func withSpread(symbol string, bid float64, spread pips.Pip) float64 {
+	q := quotes.NewQuote(symbol, bid)
+	q.AddPips(spread)
+
+	return q.ToFloat64()
+}
+
+// bid only data says nothing about the spread, so one must be given rather than quietly being 0
+func needsSpread(format string, spread pips.Pip) {
+	if spread <= 0 {
+		panic(format + " data is bid only, a spread in pips > 0 must be supplied for the ask")
+	}
+}
+
+// bidBar builds a bar from bid-only data, synthesizing the ask side with a fixed spread
+func bidBar(symbol string, t time.Time, open, high, low, close float64, volume int64, spread pips.Pip) *ticks.MarketTick {
+	return &ticks.MarketTick{
+		Symbol:   symbol,
+		Time:     t,
+		OpenBid:  open,
+		HighBid:  high,
+		LowBid:   low,
+		CloseBid: close,
+		OpenAsk:  withSpread(symbol, open, spread),
+		HighAsk:  withSpread(symbol, high, spread),
+		LowAsk:   withSpread(symbol, low, spread),
+		CloseAsk: withSpread(symbol, close, spread),
+		Volume:   volume,
+	}
+}
+
+// ===== DUKASCOPY =================================================================================
+
+// Dukascopy (JForex) tick exports:
+// Gmt time,Ask,Bid,AskVolume,BidVolume
+// 01.01.2014 22:00:00.123,1.37612,1.37605,1.5,2.25
+//
+// The files don't name the symbol, so it has to be supplied. Volume is the tick count (1).
+const DUKASCOPY_TIME_LAYOUT = "02.01.2006 15:04:05.000"
+
+type DukascopyTickReader struct {
+	Path       string
+	Symbol     string
+	Location   *time.Location // UTC if nil
+	TimeLayout string         // DUKASCOPY_TIME_LAYOUT if blank
+}
+
+func (dtr *DukascopyTickReader) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(dtr)
+}
+
+func (dtr *DukascopyTickReader) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	layout := dtr.TimeLayout
+	if "" == layout {
+		layout = DUKASCOPY_TIME_LAYOUT
+	}
+
+	go func() {
+		readCsv(dtr.Path, ',', true, func(record []string) {
+			c <- ticks.NewRawTick(
+				dtr.Symbol,
+				parseTime(layout, record[0], dtr.Location),
+				parsePrice(record[2]),
+				parsePrice(record[1]),
+			)
+		})
+
+		close(c)
+	}()
+
+	return c
+}
+
+// ===== HISTDATA ==================================================================================
+
+// HistData.com "Generic ASCII" M1 bars, bid only, EST without DST, no header:
+// 20120201 000000;1.306600;1.306600;1.306560;1.306560;0
+const HISTDATA_TIME_LAYOUT = "20060102 150405"
+
+type HistDataM1Reader struct {
+	Path       string
+	Symbol     string
+	Location   *time.Location // EST if nil
+	SpreadPips pips.Pip       // synthetic spread added to the bid to get the ask, required
+}
+
+func (hdr *HistDataM1Reader) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(hdr)
+}
+
+func (hdr *HistDataM1Reader) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	needsSpread("histdata", hdr.SpreadPips)
+
+	loc := hdr.Location
+	if nil == loc {
+		loc = EST
+	}
+
+	go func() {
+		readCsv(hdr.Path, ';', false, func(record []string) {
+			c <- bidBar(
+				hdr.Symbol,
+				parseTime(HISTDATA_TIME_LAYOUT, record[0], loc),
+				parsePrice(record[1]),
+				parsePrice(record[2]),
+				parsePrice(record[3]),
+				parsePrice(record[4]),
+				utils.StringToInt(strings.TrimSpace(record[5])),
+				hdr.SpreadPips,
+			)
+		})
+
+		close(c)
+	}()
+
+	return c
+}
+
+// ===== METATRADER ================================================================================
+
+// MetaTrader history center CSV exports, bid only, in the broker's server time, no header:
+// 2014.01.02,00:00,1.37500,1.37520,1.37480,1.37510,123
+const METATRADER_TIME_LAYOUT = "2006.01.02 15:04"
+
+type MetaTraderCsvReader struct {
+	Path       string
+	Symbol     string
+	Location   *time.Location // the broker's server time zone, UTC if nil
+	SpreadPips pips.Pip       // required, as for HistData
+}
+
+func (mtr *MetaTraderCsvReader) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(mtr)
+}
+
+func (mtr *MetaTraderCsvReader) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	needsSpread("metatrader", mtr.SpreadPips)
+
+	go func() {
+		readCsv(mtr.Path, ',', false, func(record []string) {
+			c <- bidBar(
+				mtr.Symbol,
+				parseTime(METATRADER_TIME_LAYOUT, record[0] + " " + record[1], mtr.Location),
+				parsePrice(record[2]),
+				parsePrice(record[3]),
+				parsePrice(record[4]),
+				parsePrice(record[5]),
+				utils.StringToInt(strings.TrimSpace(record[6])),
+				mtr.SpreadPips,
+			)
+		})
+
+		close(c)
+	}()
+
+	return c
+}
+
+// ===== GENERIC ===================================================================================
+
+// Describes an arbitrary CSV layout. Columns maps field names to zero-based column indexes:
+//
+//   symbol                              (or set Symbol for single-symbol files)
+//   datetime, or date + time            (joined with a space before parsing with TimeLayout)
+//   bid, ask                            (raw ticks)
+//   open_bid, high_bid, low_bid, close_bid, open_ask, high_ask, low_ask, close_ask (bars)
+//   volume                              (optional, defaults to 1)
+//
+// Any ask field that isn't mapped is synthesized from the bid using SpreadPips, which must then be
+// given.
+type ColumnMapping struct {
+	Symbol     string         `json:"symbol"`
+	Delimiter  string         `json:"delimiter"`
+	SkipHeader bool           `json:"skip_header"`
+	TimeLayout string         `json:"time_layout"`
+	TimeZone   string         `json:"time_zone"` // IANA name, e.g. "America/New_York"
+	SpreadPips float64        `json:"spread_pips"`
+	Columns    map[string]int `json:"columns"`
+}
+
+func LoadColumnMapping(path string) ColumnMapping {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	cm := ColumnMapping{}
+	if err := json.Unmarshal(raw, &cm); err != nil {
+		log.Fatalln("bad column mapping in " + path + ": " + err.Error())
+	}
+
+	return cm
+}
+
+func (cm *ColumnMapping) has(field string) bool {
+	_, ok := cm.Columns[field]
+	return ok
+}
+
+func (cm *ColumnMapping) get(record []string, field string) string {
+	return record[cm.Columns[field]]
+}
+
+func (cm *ColumnMapping) validate() {
+	if "" == cm.TimeLayout {
+		panic("column mapping needs a time_layout")
+	}
+
+	if !cm.has("datetime") && !(cm.has("date") && cm.has("time")) {
+		panic("column mapping needs a datetime column, or date and time columns")
+	}
+
+	if !cm.has("bid") && !cm.has("open_bid") {
+		panic("column mapping needs a bid or open_bid column")
+	}
+
+	if "" == cm.Symbol && !cm.has("symbol") {
+		panic("column mapping needs a symbol or a symbol column")
+	}
+
+	if cm.has("bid") && !cm.has("ask") {
+		needsSpread("generic", pips.Pip(cm.SpreadPips))
+	}
+
+	if cm.has("open_bid") && !(cm.has("open_ask") && cm.has("high_ask") && cm.has("low_ask") && cm.has("close_ask")) {
+		needsSpread("generic", pips.Pip(cm.SpreadPips))
+	}
+}
+
+type GenericCsvReader struct {
+	Path    string
+	Mapping ColumnMapping
+}
+
+func (gr *GenericCsvReader) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(gr)
+}
+
+func (gr *GenericCsvReader) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	cm := gr.Mapping
+	cm.validate()
+
+	loc := time.UTC
+	if "" != cm.TimeZone {
+		var err error
+		loc, err = time.LoadLocation(cm.TimeZone)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	delimiter := ','
+	if "" != cm.Delimiter {
+		delimiter = []rune(cm.Delimiter)[0]
+	}
+
+	spread := pips.Pip(cm.SpreadPips)
+
+	go func() {
+		readCsv(gr.Path, delimiter, cm.SkipHeader, func(record []string) {
+			symbol := cm.Symbol
+			if cm.has("symbol") {
+				symbol = strings.TrimSpace(cm.get(record, "symbol"))
+			}
+
+			var t time.Time
+			if cm.has("datetime") {
+				t = parseTime(cm.TimeLayout, cm.get(record, "datetime"), loc)
+			} else {
+				t = parseTime(cm.TimeLayout, cm.get(record, "date") + " " + cm.get(record, "time"), loc)
+			}
+
+			volume := int64(1)
+			if cm.has("volume") {
+				volume = int64(parsePrice(cm.get(record, "volume")))
+			}
+
+			// raw ticks
+			if cm.has("bid") {
+				bid := parsePrice(cm.get(record, "bid"))
+
+				ask := withSpread(symbol, bid, spread)
+				if cm.has("ask") {
+					ask = parsePrice(cm.get(record, "ask"))
+				}
+
+				tick := ticks.NewRawTick(symbol, t, bid, ask)
+				tick.Volume = volume
+
+				c <- tick
+				return
+			}
+
+			// bars
+			tick := bidBar(
+				symbol,
+				t,
+				parsePrice(cm.get(record, "open_bid")),
+				parsePrice(cm.get(record, "high_bid")),
+				parsePrice(cm.get(record, "low_bid")),
+				parsePrice(cm.get(record, "close_bid")),
+				volume,
+				spread,
+			)
+
+			for field, price := range map[string]*float64{
+				"open_ask":  &tick.OpenAsk,
+				"high_ask":  &tick.HighAsk,
+				"low_ask":   &tick.LowAsk,
+				"close_ask": &tick.CloseAsk,
+			} {
+				if cm.has(field) {
+					*price = parsePrice(cm.get(record, field))
+				}
+			}
+
+			c <- tick
+		})
+
+		close(c)
+	}()
+
+	return c
+}
+
+// ===== OPEN ======================================================================================
+
+var Formats = []string{"fxcm-m1", "ticks", "cache", "dukascopy", "histdata", "metatrader", "generic"}
+
+// the formats which need a SpreadPips
+var BidOnlyFormats = []string{"histdata", "metatrader"}
+
+// What the vendor formats need that isn't in the files themselves
+type Options struct {
+	Symbol     string
+	TimeZone   string  // IANA name, defaults depend on the format
+	SpreadPips float64 // required for BidOnlyFormats
+	Mapping    string  // path to a ColumnMapping JSON file for "generic"
+}
+
+func (o Options) location(def *time.Location) *time.Location {
+	if "" == o.TimeZone {
+		return def
+	}
+
+	loc, err := time.LoadLocation(o.TimeZone)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return loc
+}
+
+// Open returns a MarketTicker for path in the given format. Several comma separated paths are
+// merged into one time ordered stream, e.g. one HistData file per year.
+func Open(format, path string, o Options) ticks.MarketTicker {
+	paths := strings.Split(path, ",")
+
+	if len(paths) > 1 {
+		sources := []ticks.MarketTicker{}
+		for _, p := range paths {
+			sources = append(sources, Open(format, p, o))
+		}
+
+		return ticks.NewMerger(sources...)
+	}
+
+	needsSymbol := func() {
+		if "" == o.Symbol {
+			panic(format + " files don't include the symbol, it must be supplied")
+		}
+	}
+
+	switch format {
+	case "fxcm-m1":
+		return &ticks.FXCMM1CsvReader{Path: path}
+	case "ticks":
+		return &ticks.BidAskCsvReader{Path: path, Symbol: o.Symbol}
+	case "cache":
+		return tick_cache.NewReader(path)
+	case "dukascopy":
+		needsSymbol()
+		return &DukascopyTickReader{Path: path, Symbol: o.Symbol, Location: o.location(time.UTC)}
+	case "histdata":
+		needsSymbol()
+		return &HistDataM1Reader{
+			Path:       path,
+			Symbol:     o.Symbol,
+			Location:   o.location(EST),
+			SpreadPips: pips.Pip(o.SpreadPips),
+		}
+	case "metatrader":
+		needsSymbol()
+		return &MetaTraderCsvReader{
+			Path:       path,
+			Symbol:     o.Symbol,
+			Location:   o.location(time.UTC),
+			SpreadPips: pips.Pip(o.SpreadPips),
+		}
+	case "generic":
+		if "" == o.Mapping {
+			panic("generic CSVs need a column mapping file")
+		}
+
+		cm := LoadColumnMapping(o.Mapping)
+		if "" != o.Symbol {
+			cm.Symbol = o.Symbol
+		}
+		if "" != o.TimeZone {
+			cm.TimeZone = o.TimeZone
+		}
+		if 0.0 != o.SpreadPips {
+			cm.SpreadPips = o.SpreadPips
+		}
+
+		return &GenericCsvReader{Path: path, Mapping: cm}
+	}
+
+	panic("unknown data format: " + format + " (must be one of: " + strings.Join(Formats, ", ") + ")")
+}
@@ -0,0 +1,133 @@
+package importers
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"../ticks"
+)
+
+// what a vendor's row should turn into, the ask being the bid plus spread
+type bar struct {
+	at                     time.Time
+	open, high, low, close float64
+	spread                 float64
+	volume                 int64
+}
+
+func fixture(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "importers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "fixture.csv")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func closeEnough(a, b float64) bool {
+	return math.Abs(a - b) < 1e-9
+}
+
+func expectBars(t *testing.T, format string, ticker ticks.MarketTicker, symbol string, expected []bar) {
+	got := []*ticks.MarketTick{}
+	for tick := range ticker.Ticks() {
+		got = append(got, tick)
+	}
+
+	if len(expected) != len(got) {
+		t.Fatalf("%s: expected %d ticks, got %d", format, len(expected), len(got))
+	}
+
+	for i, e := range expected {
+		g := got[i]
+
+		if symbol != g.Symbol || !e.at.Equal(g.Time) || time.UTC != g.Time.Location() || e.volume != g.Volume {
+			t.Errorf("%s row %d: expected %s at %s UTC with volume %d, got %s at %s with %d", format, i, symbol, e.at, e.volume, g.Symbol, g.Time, g.Volume)
+		}
+
+		bids := []float64{g.OpenBid, g.HighBid, g.LowBid, g.CloseBid}
+		asks := []float64{g.OpenAsk, g.HighAsk, g.LowAsk, g.CloseAsk}
+
+		for j, price := range []float64{e.open, e.high, e.low, e.close} {
+			if !closeEnough(price, bids[j]) || !closeEnough(price + e.spread, asks[j]) {
+				t.Errorf("%s row %d: expected bid %.5f and ask %.5f, got %.5f and %.5f", format, i, price, price + e.spread, bids[j], asks[j])
+			}
+		}
+	}
+}
+
+// HistData is EST all year, so summer rows are 5 hours behind UTC too, not 4
+func TestHistData(t *testing.T) {
+	path := fixture(t, "20140115 093000;1.366000;1.366400;1.365800;1.366200;0\n"+
+		"20140701 000000;1.369100;1.369300;1.368900;1.369000;12\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	expectBars(t, "histdata", Open("histdata", path, Options{Symbol: "EURUSD", SpreadPips: 1.5}), "EURUSD", []bar{
+		{time.Date(2014, 1, 15, 14, 30, 0, 0, time.UTC), 1.3660, 1.3664, 1.3658, 1.3662, 0.00015, 0},
+		{time.Date(2014, 7, 1, 5, 0, 0, 0, time.UTC), 1.3691, 1.3693, 1.3689, 1.3690, 0.00015, 12},
+	})
+}
+
+// MetaTrader is in the broker's server time, here EET/EEST which does have DST
+func TestMetaTrader(t *testing.T) {
+	path := fixture(t, "2014.01.02,00:00,105.120,105.150,105.100,105.140,123\n"+
+		"2014.07.01,00:00,101.300,101.320,101.280,101.310,45\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	o := Options{Symbol: "USDJPY", TimeZone: "Europe/Helsinki", SpreadPips: 2.0}
+
+	expectBars(t, "metatrader", Open("metatrader", path, o), "USDJPY", []bar{
+		{time.Date(2014, 1, 1, 22, 0, 0, 0, time.UTC), 105.120, 105.150, 105.100, 105.140, 0.02, 123},
+		{time.Date(2014, 6, 30, 21, 0, 0, 0, time.UTC), 101.300, 101.320, 101.280, 101.310, 0.02, 45},
+	})
+}
+
+// Dukascopy is already in GMT, with the ask before the bid
+func TestDukascopy(t *testing.T) {
+	path := fixture(t, "Gmt time,Ask,Bid,AskVolume,BidVolume\n"+
+		"01.01.2014 22:00:00.123,1.37612,1.37605,1.5,2.25\n"+
+		"01.01.2014 22:00:01.456,1.37615,1.37607,0.75,1.5\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	expectBars(t, "dukascopy", Open("dukascopy", path, Options{Symbol: "EURUSD"}), "EURUSD", []bar{
+		{time.Date(2014, 1, 1, 22, 0, 0, 123000000, time.UTC), 1.37605, 1.37605, 1.37605, 1.37605, 0.00007, 1},
+		{time.Date(2014, 1, 1, 22, 0, 1, 456000000, time.UTC), 1.37607, 1.37607, 1.37607, 1.37607, 0.00008, 1},
+	})
+}
+
+func TestBidOnlyNeedsSpread(t *testing.T) {
+	for _, format := range BidOnlyFormats {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("expected %s without a spread to panic", format)
+				}
+			}()
+
+			Open(format, "unread.csv", Options{Symbol: "EURUSD"}).Ticks()
+		}()
+	}
+
+	bars := ColumnMapping{
+		Symbol:     "EURUSD",
+		TimeLayout: HISTDATA_TIME_LAYOUT,
+		Columns:    map[string]int{"datetime": 0, "open_bid": 1, "high_bid": 2, "low_bid": 3, "close_bid": 4, "open_ask": 5},
+	}
+
+	defer func() {
+		if nil == recover() {
+			t.Errorf("expected generic bars missing asks without a spread to panic")
+		}
+	}()
+
+	bars.validate()
+}
@@ -1,28 +1,80 @@
-package close_location_value
+package close_location_values
 
 import (
-	"../../algorithms"
+	"encoding/gob"
+
+	"../../candles"
+	"../../indicators"
 	"../../ticks"
 )
 
 // ===== CLOSE LOCATION VALUE ======================================================================
 
+// Where the close sits in the candle's range: 1.0 at the high, -1.0 at the low
 func NewCLV() *CloseLocationValue {
-	return &CloseLocationValue
+	clv := &CloseLocationValue{}
+	clv.series = clv.Outputs().AddFloat("value")
+
+	return clv
 }
 
 type CloseLocationValue struct {
-	Values float64
+	indicators.Base
+
+	value  float64
+	ready  bool
+	series *indicators.FloatSeries
+}
+
+func CLV(c *candles.Candle) float64 {
+	if c.HighBid == c.LowBid {
+		return 0.0
+	}
+
+	return ((c.CloseBid - c.LowBid) - (c.HighBid - c.CloseBid)) / (c.HighBid - c.LowBid)
 }
 
 func (clv *CloseLocationValue) Init() {
 }
 
 func (clv *CloseLocationValue) OnTick(algo interface{}, tick *ticks.MarketTick) {
-	// clv.Value = ()
 }
 
-func (clv *CloseLocationValue) Value() interface{} {
-	return clv.Value
+func (clv *CloseLocationValue) OnBar(c *candles.Candle) {
+	clv.value = CLV(c)
+	clv.ready = true
+
+	clv.series.Push(clv.value)
+}
+
+func (clv *CloseLocationValue) Ready() bool {
+	return clv.ready
+}
+
+func (clv *CloseLocationValue) WarmUp() int64 {
+	return 1
 }
 
+func (clv *CloseLocationValue) Value() float64 {
+	return clv.value
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(clvState{})
+}
+
+type clvState struct {
+	Value float64
+	Ready bool
+}
+
+func (clv *CloseLocationValue) State() interface{} {
+	return clvState{Value: clv.value, Ready: clv.ready}
+}
+
+func (clv *CloseLocationValue) Restore(saved interface{}) {
+	s := saved.(clvState)
+	clv.value, clv.ready = s.Value, s.Ready
+}
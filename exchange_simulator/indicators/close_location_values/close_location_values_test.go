@@ -0,0 +1,31 @@
+package close_location_values
+
+import (
+	"testing"
+
+	"../../candles"
+)
+
+func TestCLV(t *testing.T) {
+	tests := []struct {
+		high, low, close float64
+		expected         float64
+	}{
+		{1.3020, 1.3000, 1.3020, 1.0},
+		{1.3020, 1.3000, 1.3000, -1.0},
+		{1.3020, 1.3000, 1.3010, 0.0},
+		{1.3020, 1.3000, 1.3015, 0.5},
+		{1.3000, 1.3000, 1.3000, 0.0},
+	}
+
+	clv := NewCLV()
+	clv.Init()
+
+	for _, test := range tests {
+		clv.OnBar(&candles.Candle{HighBid: test.high, LowBid: test.low, CloseBid: test.close})
+
+		if diff := clv.Value() - test.expected; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Expected CLV of %.4f in [%.4f, %.4f] to be %.2f, got %.4f", test.close, test.low, test.high, test.expected, clv.Value())
+		}
+	}
+}
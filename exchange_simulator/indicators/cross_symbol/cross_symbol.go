@@ -0,0 +1,385 @@
+package cross_symbol
+
+import (
+	"encoding/gob"
+
+	"../../candles"
+	"../../indicators"
+	"../../ticks"
+)
+
+// Everything here works on the bid close of each symbol's candles, see indicators.CrossIndicator
+
+// ===== CORRELATION ===============================================================================
+
+// Rolling Pearson correlation of two symbols' candle to candle returns, from -1.0 to 1.0
+func NewCorrelation(a, b string, periods int64) *Correlation {
+	indicators.CheckPeriods("correlation", periods, 2)
+
+	c := &Correlation{
+		symbols: []string{a, b},
+		xs:      indicators.NewWindow(periods),
+		ys:      indicators.NewWindow(periods),
+		xys:     indicators.NewWindow(periods),
+	}
+	c.series = c.Outputs().AddFloat("value")
+
+	return c
+}
+
+type Correlation struct {
+	indicators.Base
+
+	symbols []string
+
+	started bool
+	prevA   float64
+	prevB   float64
+
+	xs  *indicators.Window
+	ys  *indicators.Window
+	xys *indicators.Window
+
+	series *indicators.FloatSeries
+}
+
+func (c *Correlation) Init() {
+}
+
+func (c *Correlation) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (c *Correlation) Symbols() []string {
+	return c.symbols
+}
+
+func (c *Correlation) OnBars(bars []*candles.Candle) {
+	c.Add(bars[0].CloseBid, bars[1].CloseBid)
+}
+
+// Add takes a price for each symbol
+func (c *Correlation) Add(a, b float64) {
+	if c.started {
+		x, y := a / c.prevA - 1.0, b / c.prevB - 1.0
+
+		c.xs.Add(x)
+		c.ys.Add(y)
+		c.xys.Add(x * y)
+	}
+
+	c.prevA, c.prevB, c.started = a, b, true
+
+	if c.Ready() {
+		c.series.Push(c.Value())
+	}
+}
+
+func (c *Correlation) Ready() bool {
+	return c.xs.Full()
+}
+
+// One more than Periods, returns need a previous price
+func (c *Correlation) WarmUp() int64 {
+	return c.xs.Size() + 1
+}
+
+func (c *Correlation) Value() float64 {
+	deviations := c.xs.StdDev() * c.ys.StdDev()
+	if 0.0 == deviations {
+		return 0.0
+	}
+
+	return (c.xys.Mean() - c.xs.Mean() * c.ys.Mean()) / deviations
+}
+
+// ===== SPREAD ====================================================================================
+
+// A - HedgeRatio * B, with the z-score of the latest value against the last Periods, for pairs
+// trading
+func NewSpread(a, b string, hedgeRatio float64, periods int64) *Spread {
+	indicators.CheckPeriods("spread", periods, 2)
+
+	return newSpread(a, b, periods, func(pa, pb float64) float64 { return pa - hedgeRatio * pb })
+}
+
+// A / B, with the z-score of the latest value against the last Periods
+func NewRatio(a, b string, periods int64) *Spread {
+	indicators.CheckPeriods("ratio", periods, 2)
+
+	return newSpread(a, b, periods, func(pa, pb float64) float64 { return pa / pb })
+}
+
+func newSpread(a, b string, periods int64, f func(float64, float64) float64) *Spread {
+	s := &Spread{symbols: []string{a, b}, function: f, window: indicators.NewWindow(periods)}
+
+	s.valueSeries = s.Outputs().AddFloat("value")
+	s.zScoreSeries = s.Outputs().AddFloat("zscore")
+
+	return s
+}
+
+type Spread struct {
+	indicators.Base
+
+	symbols  []string
+	function func(float64, float64) float64
+
+	window *indicators.Window
+
+	valueSeries  *indicators.FloatSeries
+	zScoreSeries *indicators.FloatSeries
+}
+
+func (s *Spread) Init() {
+}
+
+func (s *Spread) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (s *Spread) Symbols() []string {
+	return s.symbols
+}
+
+func (s *Spread) OnBars(bars []*candles.Candle) {
+	s.Add(bars[0].CloseBid, bars[1].CloseBid)
+}
+
+// Add takes a price for each symbol
+func (s *Spread) Add(a, b float64) {
+	s.window.Add(s.function(a, b))
+
+	if s.Ready() {
+		s.valueSeries.Push(s.Value())
+		s.zScoreSeries.Push(s.ZScore())
+	}
+}
+
+func (s *Spread) Ready() bool {
+	return s.window.Full()
+}
+
+func (s *Spread) WarmUp() int64 {
+	return s.window.Size()
+}
+
+func (s *Spread) Value() float64 {
+	return s.window.At(0)
+}
+
+func (s *Spread) ZScore() float64 {
+	sd := s.window.StdDev()
+	if 0.0 == sd {
+		return 0.0
+	}
+
+	return (s.Value() - s.window.Mean()) / sd
+}
+
+// ===== CURRENCY STRENGTH =========================================================================
+
+// Splits pairs into their currencies: a pair's % change over Periods candles counts for its base
+// and against its quote, and each currency's strength is the average over the pairs it's in. There's
+// a float output for each currency, e.g. "EUR".
+func NewCurrencyStrength(periods int64, symbols ...string) *CurrencyStrength {
+	indicators.CheckPeriods("currency strength", periods, 1)
+
+	if len(symbols) < 2 {
+		panic("currency strength needs at least two pairs")
+	}
+
+	cs := &CurrencyStrength{symbols: symbols, pairs: make(map[string]int64)}
+
+	for _, symbol := range symbols {
+		if 6 != len(symbol) {
+			panic("currency strength needs six letter pairs like EURUSD, got " + symbol)
+		}
+
+		cs.closes = append(cs.closes, indicators.NewWindow(periods + 1))
+
+		for _, currency := range []string{symbol[:3], symbol[3:]} {
+			if _, exists := cs.pairs[currency]; !exists {
+				cs.currencies = append(cs.currencies, currency)
+				cs.Outputs().AddFloat(currency)
+			}
+
+			cs.pairs[currency] += 1
+		}
+	}
+
+	cs.strengths = make(map[string]float64)
+
+	return cs
+}
+
+type CurrencyStrength struct {
+	indicators.Base
+
+	symbols    []string
+	currencies []string
+	pairs      map[string]int64 // currency -> how many of symbols it's in
+
+	closes    []*indicators.Window
+	strengths map[string]float64
+}
+
+func (cs *CurrencyStrength) Init() {
+}
+
+func (cs *CurrencyStrength) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (cs *CurrencyStrength) Symbols() []string {
+	return cs.symbols
+}
+
+func (cs *CurrencyStrength) Currencies() []string {
+	return cs.currencies
+}
+
+func (cs *CurrencyStrength) OnBars(bars []*candles.Candle) {
+	prices := make([]float64, len(bars))
+	for i, bar := range bars {
+		prices[i] = bar.CloseBid
+	}
+
+	cs.Add(prices...)
+}
+
+// Add takes a price for each symbol, in the same order as Symbols
+func (cs *CurrencyStrength) Add(prices ...float64) {
+	for i, price := range prices {
+		cs.closes[i].Add(price)
+	}
+
+	if !cs.Ready() {
+		return
+	}
+
+	for _, currency := range cs.currencies {
+		cs.strengths[currency] = 0.0
+	}
+
+	for i, symbol := range cs.symbols {
+		w := cs.closes[i]
+		change := 100.0 * (w.At(0) / w.At(w.Len() - 1) - 1.0)
+
+		cs.strengths[symbol[:3]] += change
+		cs.strengths[symbol[3:]] -= change
+	}
+
+	for _, currency := range cs.currencies {
+		cs.strengths[currency] /= float64(cs.pairs[currency])
+		cs.Outputs().Float(currency).Push(cs.strengths[currency])
+	}
+}
+
+func (cs *CurrencyStrength) Ready() bool {
+	return cs.closes[0].Full()
+}
+
+func (cs *CurrencyStrength) WarmUp() int64 {
+	return cs.closes[0].Size()
+}
+
+func (cs *CurrencyStrength) Strength(currency string) float64 {
+	strength, ok := cs.strengths[currency]
+	if !ok {
+		panic("currency strength has no pairs with " + currency)
+	}
+
+	return strength
+}
+
+// Strongest and weakest currencies, handy for picking which pair to trade
+func (cs *CurrencyStrength) Extremes() (string, string) {
+	strongest, weakest := cs.currencies[0], cs.currencies[0]
+
+	for _, currency := range cs.currencies {
+		if cs.strengths[currency] > cs.strengths[strongest] {
+			strongest = currency
+		}
+		if cs.strengths[currency] < cs.strengths[weakest] {
+			weakest = currency
+		}
+	}
+
+	return strongest, weakest
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(correlationState{})
+	gob.Register(strengthState{})
+}
+
+type correlationState struct {
+	Started bool
+	PrevA   float64
+	PrevB   float64
+
+	Xs  indicators.WindowState
+	Ys  indicators.WindowState
+	XYs indicators.WindowState
+}
+
+func (c *Correlation) State() interface{} {
+	return correlationState{
+		Started: c.started,
+		PrevA:   c.prevA,
+		PrevB:   c.prevB,
+
+		Xs:  c.xs.State(),
+		Ys:  c.ys.State(),
+		XYs: c.xys.State(),
+	}
+}
+
+func (c *Correlation) Restore(saved interface{}) {
+	s := saved.(correlationState)
+
+	c.started, c.prevA, c.prevB = s.Started, s.PrevA, s.PrevB
+
+	c.xs.Restore(s.Xs)
+	c.ys.Restore(s.Ys)
+	c.xys.Restore(s.XYs)
+}
+
+func (s *Spread) State() interface{} {
+	return s.window.State()
+}
+
+func (s *Spread) Restore(saved interface{}) {
+	s.window.Restore(saved.(indicators.WindowState))
+}
+
+type strengthState struct {
+	Closes    []indicators.WindowState // by symbol
+	Strengths map[string]float64
+}
+
+func (cs *CurrencyStrength) State() interface{} {
+	s := strengthState{Strengths: make(map[string]float64)}
+
+	for _, w := range cs.closes {
+		s.Closes = append(s.Closes, w.State())
+	}
+
+	for currency, strength := range cs.strengths {
+		s.Strengths[currency] = strength
+	}
+
+	return s
+}
+
+func (cs *CurrencyStrength) Restore(saved interface{}) {
+	s := saved.(strengthState)
+
+	for i, w := range cs.closes {
+		w.Restore(s.Closes[i])
+	}
+
+	for currency, strength := range s.Strengths {
+		cs.strengths[currency] = strength
+	}
+}
@@ -0,0 +1,90 @@
+package cross_symbol
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	th "../test_helpers"
+)
+
+func TestCorrelation(t *testing.T) {
+	same, opposite := NewCorrelation("EURUSD", "GBPUSD", 20), NewCorrelation("EURUSD", "USDCHF", 20)
+
+	r := rand.New(rand.NewSource(3))
+	price := 1.3
+
+	for i := 0; i < 50; i++ {
+		price *= 1.0 + r.NormFloat64() / 1000.0
+
+		same.Add(price, 2.0 * price)
+		opposite.Add(price, 1.0 / price)
+	}
+
+	if !same.Ready() || !th.CloseEnough(same.Value(), 1.0, 1e-6) {
+		t.Errorf("Expected a correlation of 1.0, got %.6f", same.Value())
+	}
+
+	// 1/x doesn't move exactly opposite, but close enough over small moves
+	if !th.CloseEnough(opposite.Value(), -1.0, 1e-3) {
+		t.Errorf("Expected a correlation of about -1.0, got %.6f", opposite.Value())
+	}
+}
+
+func TestSpreadZScore(t *testing.T) {
+	s := NewSpread("AUDUSD", "NZDUSD", 2.0, 4)
+
+	values := []float64{}
+	for i, b := range []float64{0.5, 0.4, 0.45, 0.3, 0.35} {
+		a := 1.0 + float64(i) / 10.0
+		s.Add(a, b)
+		values = append(values, a - 2.0 * b)
+	}
+
+	window := values[1:]
+
+	mean := 0.0
+	for _, v := range window {
+		mean += v
+	}
+	mean /= 4.0
+
+	variance := 0.0
+	for _, v := range window {
+		variance += (v - mean) * (v - mean)
+	}
+	sd := math.Sqrt(variance / 4.0)
+
+	if !th.CloseEnough(s.Value(), values[4], 1e-9) || !th.CloseEnough(s.ZScore(), (values[4] - mean) / sd, 1e-9) {
+		t.Errorf("Expected spread %.4f with z-score %.4f, got %.4f and %.4f", values[4], (values[4] - mean) / sd, s.Value(), s.ZScore())
+	}
+
+	if 2 != s.Outputs().Float("zscore").Len() {
+		t.Errorf("Expected z-scores from the fourth value on")
+	}
+}
+
+func TestCurrencyStrength(t *testing.T) {
+	cs := NewCurrencyStrength(2, "EURUSD", "GBPUSD", "EURGBP")
+
+	cs.Add(1.00, 1.50, 0.80)
+	cs.Add(1.01, 1.50, 0.80)
+
+	if cs.Ready() {
+		t.Fatalf("Expected to need three candles for a change over two")
+	}
+
+	// EURUSD +2%, GBPUSD flat, EURGBP +1%
+	cs.Add(1.02, 1.50, 0.808)
+
+	expected := map[string]float64{"EUR": 1.5, "USD": -1.0, "GBP": -0.5}
+	for currency, strength := range expected {
+		if !th.CloseEnough(cs.Strength(currency), strength, 1e-9) {
+			t.Errorf("Expected %s strength %.2f, got %.4f", currency, strength, cs.Strength(currency))
+		}
+	}
+
+	if strongest, weakest := cs.Extremes(); "EUR" != strongest || "USD" != weakest {
+		t.Errorf("Expected EUR strongest and USD weakest, got %s and %s", strongest, weakest)
+	}
+}
@@ -1,10 +1,603 @@
 package indicators
 
 import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"../candles"
 	"../ticks"
 )
 
 type Indicator interface {
 	Init()
 	OnTick(interface{}, *ticks.MarketTick)
+
+	// Ready is false until the indicator has seen enough data for its outputs to mean anything
+	Ready() bool
+	Outputs() *Outputs
+}
+
+// Indicators that update once per finished candle of the chart they're bound to (M1 unless the
+// algorithm says otherwise) rather than on every tick. The algorithm calls OnBar for them after the
+// candle closes; their OnTick is usually a no-op.
+type BarIndicator interface {
+	Indicator
+	OnBar(*candles.Candle)
+}
+
+// Bar indicators that look back further than the candle that just closed. SetChart is called with
+// the chart they're bound to before the run starts.
+type ChartIndicator interface {
+	BarIndicator
+	SetChart(candles.Chart)
+}
+
+// Indicators that know how many candles of their chart they need before they're Ready, so the
+// exchange can feed them history from before the start date. See Algorithm.WarmUpPeriod.
+type WarmUpper interface {
+	WarmUp() int64
+}
+
+// Indicators calculated from other indicators' float outputs instead of candles, e.g. an RSI of an
+// EMA. The algorithm updates them once per finished candle of their first input's chart, after
+// everything they depend on, as soon as all of their inputs are ready.
+type DerivedIndicator interface {
+	Indicator
+	Inputs() []Input
+
+	// OnInputs gets the latest value of each input, in the same order as Inputs
+	OnInputs(values []float64)
+}
+
+// Indicators over several symbols at once, e.g. the correlation between two pairs. There's one of
+// them shared by every currency. OnBars gets a candle for each of Symbols, in the same order, once
+// they've all closed the same period on the chart it's bound to, so only time based charts make
+// sense. Ticks reach the algorithm merged in time order, which keeps the symbols in step.
+type CrossIndicator interface {
+	Indicator
+	Symbols() []string
+	OnBars([]*candles.Candle)
+}
+
+// Input names another indicator (as it was named in Algorithm.AddIndicator) and one of its outputs
+type Input struct {
+	Indicator string
+	Output    string
+}
+
+// CheckPeriods is for constructors, it panics unless periods is at least min
+func CheckPeriods(name string, periods, min int64) {
+	if periods < min {
+		panic(fmt.Sprintf("%s periods must be >= %d, got %d", name, min, periods))
+	}
+}
+
+// ===== COMPOSITE =================================================================================
+
+// Anything that can be fed values directly, which is most of the bar indicators
+type Adder interface {
+	Indicator
+	Add(float64)
+}
+
+// NewComposite feeds another indicator's output into inner instead of the close, e.g.
+//
+//   algo.AddIndicator("ema20", func() indicators.Indicator { return moving_averages.NewEMA(20) })
+//   algo.AddIndicator("rsi_of_ema", func() indicators.Indicator {
+//       return indicators.NewComposite(oscillators.NewRSI(14), "ema20", "value")
+//   })
+func NewComposite(inner Adder, indicator, output string) *Composite {
+	return &Composite{Adder: inner, input: Input{Indicator: indicator, Output: output}}
+}
+
+type Composite struct {
+	Adder
+
+	input Input
+}
+
+func (c *Composite) Inputs() []Input {
+	return []Input{c.input}
+}
+
+func (c *Composite) OnInputs(values []float64) {
+	c.Add(values[0])
+}
+
+// WarmUp is the inner indicator's, the algorithm adds on the input's
+func (c *Composite) WarmUp() int64 {
+	if wu, ok := c.Adder.(WarmUpper); ok {
+		return wu.WarmUp()
+	}
+
+	return 1
+}
+
+// Inner is the wrapped indicator, for reading anything that isn't an output
+func (c *Composite) Inner() Adder {
+	return c.Adder
+}
+
+// ===== OUTPUTS ===================================================================================
+
+const DEFAULT_LOOKBACK = 100 // values of history each output keeps
+
+const (
+	FLOAT = "float"
+	BOOL  = "bool"
+)
+
+func NewOutputs() *Outputs {
+	return &Outputs{
+		lookback: DEFAULT_LOOKBACK,
+		kinds:    make(map[string]string),
+		floats:   make(map[string]*FloatSeries),
+		bools:    make(map[string]*BoolSeries),
+	}
+}
+
+// The named series an indicator publishes, e.g. "macd", "signal" and "histogram" for MACD.
+// Indicators only push values once they're Ready.
+type Outputs struct {
+	lookback int64
+
+	names  []string
+	kinds  map[string]string
+	floats map[string]*FloatSeries
+	bools  map[string]*BoolSeries
+}
+
+func (o *Outputs) add(name, kind string) {
+	if _, exists := o.kinds[name]; exists {
+		panic("output already exists: " + name)
+	}
+
+	o.names = append(o.names, name)
+	o.kinds[name] = kind
+}
+
+func (o *Outputs) AddFloat(name string) *FloatSeries {
+	o.add(name, FLOAT)
+
+	fs := &FloatSeries{Name: name, window: NewWindow(o.lookback)}
+	o.floats[name] = fs
+
+	return fs
+}
+
+func (o *Outputs) AddBool(name string) *BoolSeries {
+	o.add(name, BOOL)
+
+	bs := &BoolSeries{Name: name, values: make([]bool, o.lookback)}
+	o.bools[name] = bs
+
+	return bs
+}
+
+// SetLookback changes how much history every output keeps. It throws away what's there, so call
+// it before the run starts.
+func (o *Outputs) SetLookback(n int64) {
+	if n < 1 {
+		panic(fmt.Sprintf("lookback must be >= 1, got %d", n))
+	}
+
+	o.lookback = n
+
+	for _, fs := range o.floats {
+		fs.window = NewWindow(n)
+	}
+
+	for _, bs := range o.bools {
+		bs.values = make([]bool, n)
+		bs.next, bs.count = 0, 0
+	}
+}
+
+func (o *Outputs) Names() []string {
+	return o.names
+}
+
+// Kind is FLOAT or BOOL, or "" for outputs that don't exist
+func (o *Outputs) Kind(name string) string {
+	return o.kinds[name]
+}
+
+func (o *Outputs) Float(name string) *FloatSeries {
+	fs, ok := o.floats[name]
+	if !ok {
+		panic("unknown float output: " + name)
+	}
+
+	return fs
+}
+
+func (o *Outputs) Bool(name string) *BoolSeries {
+	bs, ok := o.bools[name]
+	if !ok {
+		panic("unknown bool output: " + name)
+	}
+
+	return bs
+}
+
+// Embed this in an indicator to get its Outputs
+type Base struct {
+	outputs *Outputs
+}
+
+func (b *Base) Outputs() *Outputs {
+	if nil == b.outputs {
+		b.outputs = NewOutputs()
+	}
+
+	return b.outputs
+}
+
+// ----- SERIES ------------------------------------------------------------------------------------
+
+type FloatSeries struct {
+	Name string
+
+	window *Window
+}
+
+func (fs *FloatSeries) Push(v float64) {
+	fs.window.Add(v)
+}
+
+func (fs *FloatSeries) Len() int64 {
+	return fs.window.Len()
+}
+
+// At returns the value i updates ago
+func (fs *FloatSeries) At(i int64) float64 {
+	return fs.window.At(i)
+}
+
+func (fs *FloatSeries) Value() float64 {
+	return fs.window.At(0)
+}
+
+type BoolSeries struct {
+	Name string
+
+	values []bool
+	next   int64
+	count  int64
+}
+
+func (bs *BoolSeries) Push(v bool) {
+	size := int64(len(bs.values))
+
+	bs.values[bs.next] = v
+	bs.next = (bs.next + 1) % size
+
+	if bs.count < size {
+		bs.count += 1
+	}
+}
+
+func (bs *BoolSeries) Len() int64 {
+	return bs.count
+}
+
+// At returns the value i updates ago
+func (bs *BoolSeries) At(i int64) bool {
+	if i < 0 || i >= bs.count {
+		panic(fmt.Sprintf("series index out of range! wanted: %d, have: %d", i, bs.count))
+	}
+
+	size := int64(len(bs.values))
+
+	return bs.values[(bs.next - 1 - i + 2 * size) % size]
+}
+
+func (bs *BoolSeries) Value() bool {
+	return bs.At(0)
+}
+
+// ===== WINDOW ====================================================================================
+
+// Rolling window over the last size values, keeping a running sum and sum of squares so the mean
+// and standard deviation are O(1)
+func NewWindow(size int64) *Window {
+	if size < 1 {
+		panic(fmt.Sprintf("window size must be >= 1, got %d", size))
+	}
+
+	return &Window{values: make([]float64, size)}
+}
+
+type Window struct {
+	values []float64
+	next   int64
+	count  int64
+
+	sum   float64
+	sumSq float64
+}
+
+// Add returns the value that fell out of the window, if one did
+func (w *Window) Add(v float64) (float64, bool) {
+	size := int64(len(w.values))
+
+	old, evicted := w.values[w.next], w.count == size
+
+	if evicted {
+		w.sum -= old
+		w.sumSq -= old * old
+	} else {
+		w.count += 1
+	}
+
+	w.values[w.next] = v
+	w.next = (w.next + 1) % size
+
+	w.sum += v
+	w.sumSq += v * v
+
+	return old, evicted
+}
+
+func (w *Window) Size() int64 {
+	return int64(len(w.values))
+}
+
+func (w *Window) Len() int64 {
+	return w.count
+}
+
+func (w *Window) Full() bool {
+	return w.count == int64(len(w.values))
+}
+
+// At returns the ith newest value, At(0) being the last one added
+func (w *Window) At(i int64) float64 {
+	if i < 0 || i >= w.count {
+		panic(fmt.Sprintf("window index out of range! wanted: %d, have: %d", i, w.count))
+	}
+
+	size := int64(len(w.values))
+
+	return w.values[(w.next - 1 - i + 2 * size) % size]
+}
+
+func (w *Window) Sum() float64 {
+	return w.sum
+}
+
+func (w *Window) Mean() float64 {
+	if 0 == w.count {
+		return 0.0
+	}
+
+	return w.sum / float64(w.count)
+}
+
+// StdDev is the population standard deviation, which is what Bollinger Bands use
+func (w *Window) StdDev() float64 {
+	if 0 == w.count {
+		return 0.0
+	}
+
+	mean := w.Mean()
+
+	return math.Sqrt(math.Max(0.0, w.sumSq / float64(w.count) - mean * mean))
+}
+
+// ===== EXTREMES ==================================================================================
+
+type extreme struct {
+	index int64
+	value float64
+}
+
+// Rolling max and min over the last size values. Monotonic queues make each Add amortized O(1).
+func NewExtremes(size int64) *Extremes {
+	if size < 1 {
+		panic(fmt.Sprintf("extremes size must be >= 1, got %d", size))
+	}
+
+	return &Extremes{size: size}
+}
+
+type Extremes struct {
+	size  int64
+	count int64
+
+	maxs []extreme
+	mins []extreme
+}
+
+func (e *Extremes) Add(v float64) {
+	for len(e.maxs) > 0 && e.maxs[len(e.maxs) - 1].value <= v {
+		e.maxs = e.maxs[:len(e.maxs) - 1]
+	}
+	e.maxs = append(e.maxs, extreme{index: e.count, value: v})
+
+	for len(e.mins) > 0 && e.mins[len(e.mins) - 1].value >= v {
+		e.mins = e.mins[:len(e.mins) - 1]
+	}
+	e.mins = append(e.mins, extreme{index: e.count, value: v})
+
+	e.count += 1
+
+	for e.maxs[0].index <= e.count - 1 - e.size {
+		e.maxs = e.maxs[1:]
+	}
+	for e.mins[0].index <= e.count - 1 - e.size {
+		e.mins = e.mins[1:]
+	}
+}
+
+func (e *Extremes) Full() bool {
+	return e.count >= e.size
+}
+
+func (e *Extremes) Size() int64 {
+	return e.size
+}
+
+func (e *Extremes) Max() float64 {
+	if 0 == e.count {
+		return 0.0
+	}
+
+	return e.maxs[0].value
+}
+
+func (e *Extremes) Min() float64 {
+	if 0 == e.count {
+		return 0.0
+	}
+
+	return e.mins[0].value
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+// indicators with nothing but a window to save use its state as theirs
+func init() {
+	gob.Register(WindowState{})
+}
+
+// Indicators save whatever changes as they update for checkpoints, other than their outputs which
+// are saved for them. What State returns has to be something encoding/gob can write, registered
+// with gob.Register in the indicator's package, and Restore gets it back.
+type Stateful interface {
+	State() interface{}
+	Restore(interface{})
+}
+
+type State struct {
+	Outputs OutputsState
+	Saved   interface{}
+}
+
+// Save is indi's state, a Composite's is the indicator it wraps
+func Save(indi Indicator) State {
+	if c, ok := indi.(*Composite); ok {
+		indi = c.Adder
+	}
+
+	s, ok := indi.(Stateful)
+	if !ok {
+		panic(fmt.Sprintf("%T can't be saved in a checkpoint, it needs to be an indicators.Stateful", indi))
+	}
+
+	return State{Outputs: indi.Outputs().State(), Saved: s.State()}
+}
+
+// Restore puts indi back the way it was saved, it has to be set up the same way
+func Restore(indi Indicator, s State) {
+	if c, ok := indi.(*Composite); ok {
+		indi = c.Adder
+	}
+
+	indi.Outputs().Restore(s.Outputs)
+	indi.(Stateful).Restore(s.Saved)
+}
+
+// ----- outputs -----------------------------------------------------------------------------------
+
+type OutputsState struct {
+	Floats map[string]WindowState
+	Bools  map[string]BoolSeriesState
+}
+
+func (o *Outputs) State() OutputsState {
+	s := OutputsState{Floats: make(map[string]WindowState), Bools: make(map[string]BoolSeriesState)}
+
+	for name, fs := range o.floats {
+		s.Floats[name] = fs.window.State()
+	}
+
+	for name, bs := range o.bools {
+		s.Bools[name] = BoolSeriesState{Values: append([]bool{}, bs.values...), Next: bs.next, Count: bs.count}
+	}
+
+	return s
+}
+
+func (o *Outputs) Restore(s OutputsState) {
+	for name, ws := range s.Floats {
+		o.Float(name).window.Restore(ws)
+	}
+
+	for name, bss := range s.Bools {
+		bs := o.Bool(name)
+
+		if len(bss.Values) != len(bs.values) {
+			panic(fmt.Sprintf("can't restore %d values of %s to a lookback of %d", len(bss.Values), name, len(bs.values)))
+		}
+
+		bs.values, bs.next, bs.count = bss.Values, bss.Next, bss.Count
+	}
+}
+
+type BoolSeriesState struct {
+	Values []bool
+	Next   int64
+	Count  int64
+}
+
+// ----- window & extremes -------------------------------------------------------------------------
+
+// The running sums are saved rather than worked out again, so they carry on exactly the same.
+// States are copies, restoring one doesn't tie the window to the one it came from.
+type WindowState struct {
+	Values []float64
+	Next   int64
+	Count  int64
+	Sum    float64
+	SumSq  float64
+}
+
+func (w *Window) State() WindowState {
+	return WindowState{Values: append([]float64{}, w.values...), Next: w.next, Count: w.count, Sum: w.sum, SumSq: w.sumSq}
+}
+
+func (w *Window) Restore(s WindowState) {
+	if len(s.Values) != len(w.values) {
+		panic(fmt.Sprintf("can't restore %d values to a window of %d", len(s.Values), len(w.values)))
+	}
+
+	w.values, w.next, w.count, w.sum, w.sumSq = s.Values, s.Next, s.Count, s.Sum, s.SumSq
+}
+
+type ExtremesState struct {
+	Count int64
+
+	// the monotonic queues, see Extremes.Add
+	MaxIndexes []int64
+	Maxs       []float64
+	MinIndexes []int64
+	Mins       []float64
+}
+
+func (e *Extremes) State() ExtremesState {
+	s := ExtremesState{Count: e.count}
+
+	for _, m := range e.maxs {
+		s.MaxIndexes, s.Maxs = append(s.MaxIndexes, m.index), append(s.Maxs, m.value)
+	}
+
+	for _, m := range e.mins {
+		s.MinIndexes, s.Mins = append(s.MinIndexes, m.index), append(s.Mins, m.value)
+	}
+
+	return s
+}
+
+func (e *Extremes) Restore(s ExtremesState) {
+	e.count, e.maxs, e.mins = s.Count, nil, nil
+
+	for i, index := range s.MaxIndexes {
+		e.maxs = append(e.maxs, extreme{index: index, value: s.Maxs[i]})
+	}
+
+	for i, index := range s.MinIndexes {
+		e.mins = append(e.mins, extreme{index: index, value: s.Mins[i]})
+	}
 }
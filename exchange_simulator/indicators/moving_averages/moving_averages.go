@@ -2,8 +2,11 @@ package moving_averages
 
 import (
 	"container/list"
+	"encoding/gob"
 	"time"
 
+	"../../candles"
+	"../../indicators"
 	"../../ticks"
 )
 
@@ -16,7 +19,7 @@ func (ma *MAValue) OlderThan(cutoff time.Time) bool {
 	return ma.TimeCreated.Before(cutoff)
 }
 
-// ===== SIMPLE MOVING AVERAGE =====================================================================
+// ===== TIME BOUNDED MOVING AVERAGE ===============================================================
 
 func NewTBMA(ttl time.Duration, f func(*ticks.MarketTick) float64) *TimeBoundedMovingAverage {
 	return &TimeBoundedMovingAverage{TTL: ttl, Function: f}
@@ -58,3 +61,222 @@ func (tma *TimeBoundedMovingAverage) Value() interface{} {
 	return total / float64(tma.Values.Len())
 }
 
+// The bar based averages below run on the bid close unless they're fed with Add directly
+
+// ===== SIMPLE MOVING AVERAGE =====================================================================
+
+func NewSMA(periods int64) *SimpleMovingAverage {
+	indicators.CheckPeriods("SMA", periods, 1)
+
+	sma := &SimpleMovingAverage{Periods: periods, window: indicators.NewWindow(periods)}
+	sma.value = sma.Outputs().AddFloat("value")
+
+	return sma
+}
+
+type SimpleMovingAverage struct {
+	indicators.Base
+
+	Periods int64
+
+	window *indicators.Window
+	value  *indicators.FloatSeries
+}
+
+func (sma *SimpleMovingAverage) Init() {
+}
+
+func (sma *SimpleMovingAverage) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (sma *SimpleMovingAverage) OnBar(c *candles.Candle) {
+	sma.Add(c.CloseBid)
+}
+
+func (sma *SimpleMovingAverage) Add(v float64) {
+	sma.window.Add(v)
+
+	if sma.Ready() {
+		sma.value.Push(sma.Value())
+	}
+}
+
+func (sma *SimpleMovingAverage) Ready() bool {
+	return sma.window.Full()
+}
+
+func (sma *SimpleMovingAverage) WarmUp() int64 {
+	return sma.Periods
+}
+
+func (sma *SimpleMovingAverage) Value() float64 {
+	return sma.window.Mean()
+}
+
+// ===== EXPONENTIAL MOVING AVERAGE ================================================================
+
+// Seeded with the SMA of the first Periods values, like most charting packages
+func NewEMA(periods int64) *ExponentialMovingAverage {
+	indicators.CheckPeriods("EMA", periods, 1)
+
+	ema := &ExponentialMovingAverage{Periods: periods, alpha: 2.0 / float64(periods + 1)}
+	ema.series = ema.Outputs().AddFloat("value")
+
+	return ema
+}
+
+type ExponentialMovingAverage struct {
+	indicators.Base
+
+	Periods int64
+
+	alpha  float64
+	count  int64
+	value  float64
+	series *indicators.FloatSeries
+}
+
+func (ema *ExponentialMovingAverage) Init() {
+}
+
+func (ema *ExponentialMovingAverage) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (ema *ExponentialMovingAverage) OnBar(c *candles.Candle) {
+	ema.Add(c.CloseBid)
+}
+
+func (ema *ExponentialMovingAverage) Add(v float64) {
+	ema.count += 1
+
+	if ema.count <= ema.Periods {
+		ema.value += (v - ema.value) / float64(ema.count)
+	} else {
+		ema.value += ema.alpha * (v - ema.value)
+	}
+
+	if ema.Ready() {
+		ema.series.Push(ema.value)
+	}
+}
+
+func (ema *ExponentialMovingAverage) Ready() bool {
+	return ema.count >= ema.Periods
+}
+
+func (ema *ExponentialMovingAverage) WarmUp() int64 {
+	return ema.Periods
+}
+
+func (ema *ExponentialMovingAverage) Value() float64 {
+	return ema.value
+}
+
+// ===== WEIGHTED MOVING AVERAGE ===================================================================
+
+// Linearly weighted, the newest value counts Periods times, the oldest once
+func NewWMA(periods int64) *WeightedMovingAverage {
+	indicators.CheckPeriods("WMA", periods, 1)
+
+	wma := &WeightedMovingAverage{Periods: periods, window: indicators.NewWindow(periods)}
+	wma.value = wma.Outputs().AddFloat("value")
+
+	return wma
+}
+
+type WeightedMovingAverage struct {
+	indicators.Base
+
+	Periods int64
+
+	window   *indicators.Window
+	weighted float64
+	value    *indicators.FloatSeries
+}
+
+func (wma *WeightedMovingAverage) Init() {
+}
+
+func (wma *WeightedMovingAverage) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (wma *WeightedMovingAverage) OnBar(c *candles.Candle) {
+	wma.Add(c.CloseBid)
+}
+
+func (wma *WeightedMovingAverage) Add(v float64) {
+	if wma.window.Full() {
+		// every value loses one weight, which drops the oldest entirely
+		wma.weighted += float64(wma.Periods) * v - wma.window.Sum()
+	} else {
+		wma.weighted += float64(wma.window.Len() + 1) * v
+	}
+
+	wma.window.Add(v)
+
+	if wma.Ready() {
+		wma.value.Push(wma.Value())
+	}
+}
+
+func (wma *WeightedMovingAverage) Ready() bool {
+	return wma.window.Full()
+}
+
+func (wma *WeightedMovingAverage) WarmUp() int64 {
+	return wma.Periods
+}
+
+func (wma *WeightedMovingAverage) Value() float64 {
+	n := float64(wma.window.Len())
+	if 0.0 == n {
+		return 0.0
+	}
+
+	return wma.weighted / (n * (n + 1.0) / 2.0)
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(emaState{})
+	gob.Register(wmaState{})
+}
+
+func (sma *SimpleMovingAverage) State() interface{} {
+	return sma.window.State()
+}
+
+func (sma *SimpleMovingAverage) Restore(saved interface{}) {
+	sma.window.Restore(saved.(indicators.WindowState))
+}
+
+type emaState struct {
+	Count int64
+	Value float64
+}
+
+func (ema *ExponentialMovingAverage) State() interface{} {
+	return emaState{Count: ema.count, Value: ema.value}
+}
+
+func (ema *ExponentialMovingAverage) Restore(saved interface{}) {
+	s := saved.(emaState)
+	ema.count, ema.value = s.Count, s.Value
+}
+
+type wmaState struct {
+	Window   indicators.WindowState
+	Weighted float64
+}
+
+func (wma *WeightedMovingAverage) State() interface{} {
+	return wmaState{Window: wma.window.State(), Weighted: wma.weighted}
+}
+
+func (wma *WeightedMovingAverage) Restore(saved interface{}) {
+	s := saved.(wmaState)
+
+	wma.window.Restore(s.Window)
+	wma.weighted = s.Weighted
+}
@@ -0,0 +1,56 @@
+package moving_averages
+
+import (
+	"testing"
+
+	"../../candles"
+	th "../test_helpers"
+)
+
+// run feeds an average the closes, returning its values once it's ready
+func run(ma interface {
+	OnBar(*candles.Candle)
+	Ready() bool
+	Value() float64
+}, data []*candles.Candle) []float64 {
+	res := []float64{}
+
+	for _, c := range data {
+		ma.OnBar(c)
+
+		if ma.Ready() {
+			res = append(res, ma.Value())
+		}
+	}
+
+	return res
+}
+
+// StockCharts' 10 day examples
+func TestSMA(t *testing.T) {
+	expected := []float64{
+		22.22, 22.21, 22.23, 22.26, 22.31, 22.42, 22.61, 22.77, 22.91, 23.08, 23.21,
+		23.38, 23.53, 23.65, 23.71, 23.68, 23.61, 23.51, 23.43, 23.28, 23.13,
+	}
+
+	th.ExpectValues(t, "SMA", expected, run(NewSMA(10), th.Closes(th.MovingAverageCloses)), 0.01)
+}
+
+func TestEMA(t *testing.T) {
+	expected := []float64{
+		22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28, 23.34,
+		23.43, 23.51, 23.54, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92,
+	}
+
+	th.ExpectValues(t, "EMA", expected, run(NewEMA(10), th.Closes(th.MovingAverageCloses)), 0.01)
+}
+
+// worked from the definition over the same closes, there's no published example
+func TestWMA(t *testing.T) {
+	expected := []float64{
+		22.2429, 22.2300, 22.2629, 22.2904, 22.3542, 22.5464, 22.8425, 23.0493, 23.2429, 23.4329, 23.5336,
+		23.6445, 23.7342, 23.7569, 23.6729, 23.5620, 23.4976, 23.3282, 23.2545, 23.0669, 22.8656,
+	}
+
+	th.ExpectValues(t, "WMA", expected, run(NewWMA(10), th.Closes(th.MovingAverageCloses)), 0.0001)
+}
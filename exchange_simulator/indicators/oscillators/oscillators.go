@@ -0,0 +1,402 @@
+package oscillators
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"../../candles"
+	"../../indicators"
+	"../../ticks"
+	"../moving_averages"
+)
+
+// ===== RELATIVE STRENGTH INDEX ===================================================================
+
+// Wilder's RSI: the first averages are simple, after that they're smoothed by 1/Periods
+func NewRSI(periods int64) *RelativeStrengthIndex {
+	indicators.CheckPeriods("RSI", periods, 1)
+
+	rsi := &RelativeStrengthIndex{Periods: periods}
+	rsi.value = rsi.Outputs().AddFloat("value")
+
+	return rsi
+}
+
+type RelativeStrengthIndex struct {
+	indicators.Base
+
+	Periods int64
+
+	value *indicators.FloatSeries
+
+	started bool
+	count   int64 // changes seen
+	last    float64
+	avgGain float64
+	avgLoss float64
+}
+
+func (rsi *RelativeStrengthIndex) Init() {
+}
+
+func (rsi *RelativeStrengthIndex) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (rsi *RelativeStrengthIndex) OnBar(c *candles.Candle) {
+	rsi.Add(c.CloseBid)
+}
+
+func (rsi *RelativeStrengthIndex) Add(v float64) {
+	change := v - rsi.last
+	rsi.last = v
+
+	// nothing to compare the first value to
+	if !rsi.started {
+		rsi.started = true
+		return
+	}
+
+	rsi.count += 1
+
+	gain, loss := math.Max(change, 0.0), math.Max(-change, 0.0)
+
+	n := float64(rsi.Periods)
+
+	if rsi.count <= rsi.Periods {
+		rsi.avgGain += (gain - rsi.avgGain) / float64(rsi.count)
+		rsi.avgLoss += (loss - rsi.avgLoss) / float64(rsi.count)
+	} else {
+		rsi.avgGain = (rsi.avgGain * (n - 1.0) + gain) / n
+		rsi.avgLoss = (rsi.avgLoss * (n - 1.0) + loss) / n
+	}
+
+	if rsi.Ready() {
+		rsi.value.Push(rsi.Value())
+	}
+}
+
+func (rsi *RelativeStrengthIndex) Ready() bool {
+	return rsi.count >= rsi.Periods
+}
+
+// One more than Periods, the first close only starts the changes
+func (rsi *RelativeStrengthIndex) WarmUp() int64 {
+	return rsi.Periods + 1
+}
+
+func (rsi *RelativeStrengthIndex) Value() float64 {
+	if 0.0 == rsi.avgLoss {
+		if 0.0 == rsi.avgGain {
+			return 50.0
+		}
+
+		return 100.0
+	}
+
+	return 100.0 - 100.0 / (1.0 + rsi.avgGain / rsi.avgLoss)
+}
+
+// ===== MACD ======================================================================================
+
+// NewMACD(12, 26, 9) is the usual one
+func NewMACD(fast, slow, signal int64) *MACD {
+	if fast >= slow {
+		panic(fmt.Sprintf("MACD fast periods (%d) must be < slow periods (%d)", fast, slow))
+	}
+
+	m := &MACD{
+		fast:   moving_averages.NewEMA(fast),
+		slow:   moving_averages.NewEMA(slow),
+		signal: moving_averages.NewEMA(signal),
+	}
+
+	m.macdSeries = m.Outputs().AddFloat("macd")
+	m.signalSeries = m.Outputs().AddFloat("signal")
+	m.histogramSeries = m.Outputs().AddFloat("histogram")
+
+	return m
+}
+
+type MACD struct {
+	indicators.Base
+
+	fast   *moving_averages.ExponentialMovingAverage
+	slow   *moving_averages.ExponentialMovingAverage
+	signal *moving_averages.ExponentialMovingAverage
+
+	macdSeries      *indicators.FloatSeries
+	signalSeries    *indicators.FloatSeries
+	histogramSeries *indicators.FloatSeries
+}
+
+func (m *MACD) Init() {
+}
+
+func (m *MACD) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (m *MACD) OnBar(c *candles.Candle) {
+	m.Add(c.CloseBid)
+}
+
+func (m *MACD) Add(v float64) {
+	m.fast.Add(v)
+	m.slow.Add(v)
+
+	if m.slow.Ready() {
+		m.signal.Add(m.Value())
+	}
+
+	if m.Ready() {
+		m.macdSeries.Push(m.Value())
+		m.signalSeries.Push(m.Signal())
+		m.histogramSeries.Push(m.Histogram())
+	}
+}
+
+func (m *MACD) Ready() bool {
+	return m.signal.Ready()
+}
+
+func (m *MACD) WarmUp() int64 {
+	return m.slow.WarmUp() + m.signal.WarmUp() - 1
+}
+
+func (m *MACD) Value() float64 {
+	return m.fast.Value() - m.slow.Value()
+}
+
+func (m *MACD) Signal() float64 {
+	return m.signal.Value()
+}
+
+func (m *MACD) Histogram() float64 {
+	return m.Value() - m.Signal()
+}
+
+// ===== STOCHASTIC ================================================================================
+
+// %K over kPeriods, %D is its dPeriods SMA
+func NewStochastic(kPeriods, dPeriods int64) *Stochastic {
+	indicators.CheckPeriods("stochastic %K", kPeriods, 1)
+	indicators.CheckPeriods("stochastic %D", dPeriods, 1)
+
+	s := &Stochastic{
+		highs: indicators.NewExtremes(kPeriods),
+		lows:  indicators.NewExtremes(kPeriods),
+		d:     moving_averages.NewSMA(dPeriods),
+	}
+
+	s.kSeries = s.Outputs().AddFloat("k")
+	s.dSeries = s.Outputs().AddFloat("d")
+
+	return s
+}
+
+type Stochastic struct {
+	indicators.Base
+
+	kSeries *indicators.FloatSeries
+	dSeries *indicators.FloatSeries
+
+	highs *indicators.Extremes
+	lows  *indicators.Extremes
+	d     *moving_averages.SimpleMovingAverage
+
+	k float64
+}
+
+func (s *Stochastic) Init() {
+}
+
+func (s *Stochastic) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (s *Stochastic) OnBar(c *candles.Candle) {
+	s.highs.Add(c.HighBid)
+	s.lows.Add(c.LowBid)
+
+	if !s.highs.Full() {
+		return
+	}
+
+	hh, ll := s.highs.Max(), s.lows.Min()
+
+	if hh == ll {
+		s.k = 50.0
+	} else {
+		s.k = 100.0 * (c.CloseBid - ll) / (hh - ll)
+	}
+
+	s.d.Add(s.k)
+
+	if s.Ready() {
+		s.kSeries.Push(s.K())
+		s.dSeries.Push(s.D())
+	}
+}
+
+func (s *Stochastic) Ready() bool {
+	return s.d.Ready()
+}
+
+func (s *Stochastic) WarmUp() int64 {
+	return s.highs.Size() + s.d.WarmUp() - 1
+}
+
+func (s *Stochastic) K() float64 {
+	return s.k
+}
+
+func (s *Stochastic) D() float64 {
+	return s.d.Value()
+}
+
+// ===== COMMODITY CHANNEL INDEX ===================================================================
+
+const CCI_CONSTANT = 0.015
+
+// Note: the mean deviation has to look at the whole window, so this one's O(Periods) per bar
+func NewCCI(periods int64) *CommodityChannelIndex {
+	indicators.CheckPeriods("CCI", periods, 1)
+
+	cci := &CommodityChannelIndex{Periods: periods, window: indicators.NewWindow(periods)}
+	cci.series = cci.Outputs().AddFloat("value")
+
+	return cci
+}
+
+type CommodityChannelIndex struct {
+	indicators.Base
+
+	Periods int64
+
+	window *indicators.Window
+	value  float64
+	series *indicators.FloatSeries
+}
+
+func (cci *CommodityChannelIndex) Init() {
+}
+
+func (cci *CommodityChannelIndex) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (cci *CommodityChannelIndex) OnBar(c *candles.Candle) {
+	tp := (c.HighBid + c.LowBid + c.CloseBid) / 3.0
+
+	cci.window.Add(tp)
+
+	if !cci.window.Full() {
+		return
+	}
+
+	mean := cci.window.Mean()
+
+	deviation := 0.0
+	for i := int64(0); i < cci.Periods; i++ {
+		deviation += math.Abs(cci.window.At(i) - mean)
+	}
+	deviation /= float64(cci.Periods)
+
+	if 0.0 == deviation {
+		cci.value = 0.0
+	} else {
+		cci.value = (tp - mean) / (CCI_CONSTANT * deviation)
+	}
+
+	cci.series.Push(cci.value)
+}
+
+func (cci *CommodityChannelIndex) Ready() bool {
+	return cci.window.Full()
+}
+
+func (cci *CommodityChannelIndex) WarmUp() int64 {
+	return cci.Periods
+}
+
+func (cci *CommodityChannelIndex) Value() float64 {
+	return cci.value
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(rsiState{})
+	gob.Register(macdState{})
+	gob.Register(stochasticState{})
+	gob.Register(cciState{})
+}
+
+type rsiState struct {
+	Started bool
+	Count   int64
+	Last    float64
+	AvgGain float64
+	AvgLoss float64
+}
+
+func (rsi *RelativeStrengthIndex) State() interface{} {
+	return rsiState{Started: rsi.started, Count: rsi.count, Last: rsi.last, AvgGain: rsi.avgGain, AvgLoss: rsi.avgLoss}
+}
+
+func (rsi *RelativeStrengthIndex) Restore(saved interface{}) {
+	s := saved.(rsiState)
+	rsi.started, rsi.count, rsi.last, rsi.avgGain, rsi.avgLoss = s.Started, s.Count, s.Last, s.AvgGain, s.AvgLoss
+}
+
+type macdState struct {
+	Fast   indicators.State
+	Slow   indicators.State
+	Signal indicators.State
+}
+
+func (m *MACD) State() interface{} {
+	return macdState{Fast: indicators.Save(m.fast), Slow: indicators.Save(m.slow), Signal: indicators.Save(m.signal)}
+}
+
+func (m *MACD) Restore(saved interface{}) {
+	s := saved.(macdState)
+
+	indicators.Restore(m.fast, s.Fast)
+	indicators.Restore(m.slow, s.Slow)
+	indicators.Restore(m.signal, s.Signal)
+}
+
+type stochasticState struct {
+	Highs indicators.ExtremesState
+	Lows  indicators.ExtremesState
+	D     indicators.State
+	K     float64
+}
+
+func (s *Stochastic) State() interface{} {
+	return stochasticState{Highs: s.highs.State(), Lows: s.lows.State(), D: indicators.Save(s.d), K: s.k}
+}
+
+func (s *Stochastic) Restore(saved interface{}) {
+	ss := saved.(stochasticState)
+
+	s.highs.Restore(ss.Highs)
+	s.lows.Restore(ss.Lows)
+	indicators.Restore(s.d, ss.D)
+	s.k = ss.K
+}
+
+type cciState struct {
+	Window indicators.WindowState
+	Value  float64
+}
+
+func (cci *CommodityChannelIndex) State() interface{} {
+	return cciState{Window: cci.window.State(), Value: cci.value}
+}
+
+func (cci *CommodityChannelIndex) Restore(saved interface{}) {
+	s := saved.(cciState)
+
+	cci.window.Restore(s.Window)
+	cci.value = s.Value
+}
@@ -0,0 +1,112 @@
+package oscillators
+
+import (
+	"testing"
+
+	th "../test_helpers"
+)
+
+// StockCharts' example
+var rsiExpected = []float64{
+	70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38, 54.71, 50.42, 39.99,
+	41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+}
+
+func TestRSI(t *testing.T) {
+	rsi := NewRSI(14)
+	rsi.Init()
+
+	values := []float64{}
+	for _, v := range th.RSICloses {
+		rsi.Add(v)
+
+		if rsi.Ready() {
+			values = append(values, rsi.Value())
+		}
+	}
+
+	th.ExpectValues(t, "RSI", rsiExpected, values, 0.01)
+}
+
+// worked from the definition over StockCharts' moving average closes, a short MACD so there's
+// enough of them
+func TestMACD(t *testing.T) {
+	m := NewMACD(5, 10, 4)
+
+	macds, signals, histograms := []float64{}, []float64{}, []float64{}
+	for _, c := range th.Closes(th.MovingAverageCloses) {
+		m.OnBar(c)
+
+		if m.Ready() {
+			macds = append(macds, m.Value())
+			signals = append(signals, m.Signal())
+			histograms = append(histograms, m.Outputs().Float("histogram").Value())
+		}
+	}
+
+	expectedMACD := []float64{
+		0.0487, 0.0845, 0.2126, 0.3741, 0.3941, 0.3932, 0.3871, 0.3118, 0.2806,
+		0.2542, 0.1910, 0.0753, -0.0060, -0.0152, -0.1177, -0.1029, -0.1946, -0.2677,
+	}
+	expectedSignal := []float64{
+		0.0396, 0.0576, 0.1196, 0.2214, 0.2904, 0.3315, 0.3538, 0.3370, 0.3144,
+		0.2903, 0.2506, 0.1805, 0.1059, 0.0575, -0.0126, -0.0487, -0.1071, -0.1713,
+	}
+
+	expectedHistogram := []float64{}
+	for i := range expectedMACD {
+		expectedHistogram = append(expectedHistogram, expectedMACD[i] - expectedSignal[i])
+	}
+
+	th.ExpectValues(t, "MACD", expectedMACD, macds, 0.0001)
+	th.ExpectValues(t, "MACD signal", expectedSignal, signals, 0.0001)
+	th.ExpectValues(t, "MACD histogram", expectedHistogram, histograms, 0.0002)
+
+	// one value per candle since it was ready
+	if 18 != m.Outputs().Float("signal").Len() || m.Outputs().Float("macd").At(1) != macds[16] {
+		t.Errorf("Expected 18 values of history, got %d", m.Outputs().Float("signal").Len())
+	}
+}
+
+// StockCharts' example gives %K, %D is its 3 day SMA
+func TestStochastic(t *testing.T) {
+	s := NewStochastic(14, 3)
+
+	ks, ds := []float64{}, []float64{}
+	for _, c := range th.Bars(th.StochasticHighs, th.StochasticLows, th.StochasticCloses) {
+		s.OnBar(c)
+
+		if s.Ready() {
+			ks = append(ks, s.K())
+			ds = append(ds, s.D())
+		}
+	}
+
+	expectedK := []float64{
+		89.20, 65.81, 81.75, 64.52, 74.53, 98.58, 70.10, 73.06, 73.42, 61.23, 60.96, 40.39, 40.39,
+		66.83, 56.73,
+	}
+	expectedD := []float64{
+		75.75, 74.21, 78.92, 70.69, 73.60, 79.21, 81.07, 80.58, 72.19, 69.24, 65.20, 54.19, 47.24,
+		49.20, 54.65,
+	}
+
+	th.ExpectValues(t, "stochastic %K", expectedK, ks, 0.01)
+	th.ExpectValues(t, "stochastic %D", expectedD, ds, 0.01)
+}
+
+// worked from the definition over the stochastic example's bars
+func TestCCI(t *testing.T) {
+	cci := NewCCI(10)
+
+	values := []float64{}
+	for _, c := range th.StochasticBars() {
+		cci.OnBar(c)
+
+		if cci.Ready() {
+			values = append(values, cci.Value())
+		}
+	}
+
+	th.ExpectValues(t, "CCI", []float64{181.16, 90.85, 42.82, 13.31, -51.69, -36.01, 50.37, -26.83}, values, 0.01)
+}
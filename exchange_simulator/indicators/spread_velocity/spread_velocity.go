@@ -1,20 +1,36 @@
 package spread_velocity
 
 import (
+	"encoding/gob"
 	// "fmt"
-	"../../algorithms"
+
+	"../../candles"
+	"../../indicators"
 	"../../ticks"
 )
 
 func NewSV(n int64) *SpreadVelocity {
-	return &SpreadVelocity{numCandles: n}
+	sv := &SpreadVelocity{numCandles: n}
+
+	sv.expandingSeries = sv.Outputs().AddBool("expanding")
+	sv.contractingSeries = sv.Outputs().AddBool("contracting")
+
+	return sv
 }
 
 type SpreadVelocity struct {
+	indicators.Base
+
+	ready       bool
 	expanding   bool
 	contracting bool
 
+	expandingSeries   *indicators.BoolSeries
+	contractingSeries *indicators.BoolSeries
+
 	numCandles int64
+
+	chart candles.Chart
 }
 
 func (sv *SpreadVelocity) Init() {
@@ -23,27 +39,29 @@ func (sv *SpreadVelocity) Init() {
 	}
 }
 
+func (sv *SpreadVelocity) SetChart(chart candles.Chart) {
+	sv.chart = chart
+	sv.chart.Keep(sv.numCandles)
+}
+
+func (sv *SpreadVelocity) WarmUp() int64 {
+	return sv.numCandles
+}
+
 func (sv *SpreadVelocity) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (sv *SpreadVelocity) OnBar(c *candles.Candle) {
 	sv.expanding   = false
 	sv.contracting = false
 
-	chart := a.(*algorithms.Algorithm).Charts[tick.Symbol]["M1"]
-	if int64(chart.Len()) < sv.numCandles + 1 {
+	chart := sv.chart
+	if chart.Finished() < sv.numCandles {
 		return
 	}
 
-	var first, last float64
-
-	count := int64(0)
-	for _, val := range chart.GetCandles(sv.numCandles) {
-		if count == 0 {
-			first = val.Spread()
-		} else if count == sv.numCandles - 1 {
-			last = val.Spread()
-		}
-
-		count++
-	}
+	first := chart.At(0).Spread()
+	last  := chart.At(sv.numCandles - 1).Spread()
 
 	// fmt.Printf("F: %.1f, L: %.1f\n", first, last)
 
@@ -53,6 +71,13 @@ func (sv *SpreadVelocity) OnTick(a interface{}, tick *ticks.MarketTick) {
 		sv.expanding = true
 	}
 
+	sv.ready = true
+	sv.expandingSeries.Push(sv.expanding)
+	sv.contractingSeries.Push(sv.contracting)
+}
+
+func (sv *SpreadVelocity) Ready() bool {
+	return sv.ready
 }
 
 func (sv *SpreadVelocity) IsExpanding() bool {
@@ -66,3 +91,24 @@ func (sv *SpreadVelocity) IsContracting() bool {
 func (sv *SpreadVelocity) IsConstant() bool {
 	return !sv.expanding && !sv.contracting
 }
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(svState{})
+}
+
+type svState struct {
+	Ready       bool
+	Expanding   bool
+	Contracting bool
+}
+
+func (sv *SpreadVelocity) State() interface{} {
+	return svState{Ready: sv.ready, Expanding: sv.expanding, Contracting: sv.contracting}
+}
+
+func (sv *SpreadVelocity) Restore(saved interface{}) {
+	s := saved.(svState)
+	sv.ready, sv.expanding, sv.contracting = s.Ready, s.Expanding, s.Contracting
+}
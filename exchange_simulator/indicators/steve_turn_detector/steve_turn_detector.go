@@ -1,22 +1,37 @@
 package steve_turn_detector
 
 import (
+	"encoding/gob"
 	"fmt"
 
-	"../../algorithms"
+	"../../candles"
+	"../../indicators"
 	"../../ticks"
 )
 
 func NewSTD(lower, upper float64) *SteveTurnDetector {
-	return &SteveTurnDetector{lower: lower, upper: upper}
+	std := &SteveTurnDetector{lower: lower, upper: upper}
+
+	std.turningUpSeries = std.Outputs().AddBool("turning_up")
+	std.turningDownSeries = std.Outputs().AddBool("turning_down")
+
+	return std
 }
 
 type SteveTurnDetector struct {
+	indicators.Base
+
 	lower float64
 	upper float64
 
+	chart candles.Chart
+
+	ready       bool
 	turningUp   bool
 	turningDown bool
+
+	turningUpSeries   *indicators.BoolSeries
+	turningDownSeries *indicators.BoolSeries
 }
 
 func (std *SteveTurnDetector) Init(){
@@ -43,23 +58,39 @@ func (std *SteveTurnDetector) Init(){
 	}
 }
 
+const NUM_CANDLES = 60
+
+func (std *SteveTurnDetector) SetChart(chart candles.Chart) {
+	std.chart = chart
+	std.chart.Keep(NUM_CANDLES)
+}
+
+func (std *SteveTurnDetector) WarmUp() int64 {
+	return NUM_CANDLES
+}
+
 func (std *SteveTurnDetector) OnTick(a interface{}, tick *ticks.MarketTick) {
-	chart := a.(*algorithms.Algorithm).Charts[tick.Symbol]["M1"]
-	if chart.Len() < 61 {
+}
+
+func (std *SteveTurnDetector) OnBar(c *candles.Candle) {
+	chart := std.chart
+	if chart.Finished() < NUM_CANDLES {
 		return
 	}
 
+	opens := chart.Column(candles.OPEN_BID, NUM_CANDLES)
+
 	ma5 := 0.0
-	for _, val := range chart.GetCandles(5) {
-		ma5 += val.OpenBid
+	for _, val := range opens[:5] {
+		ma5 += val
 	}
 	ma5 /= 5.0
 
 	ma60 := 0.0
-	for _, val := range chart.GetCandles(60) {
-		ma60 += val.OpenBid
+	for _, val := range opens {
+		ma60 += val
 	}
-	ma60 /= 60.0
+	ma60 /= float64(NUM_CANDLES)
 
 	div := ma60 / ma5
 
@@ -68,6 +99,14 @@ func (std *SteveTurnDetector) OnTick(a interface{}, tick *ticks.MarketTick) {
 
 	std.turningUp   = div >= x && div <= y
 	std.turningDown = div >= (1.00 + (1.00 - y)) && div <= (1.00 + (1.00 - x))
+
+	std.ready = true
+	std.turningUpSeries.Push(std.turningUp)
+	std.turningDownSeries.Push(std.turningDown)
+}
+
+func (std *SteveTurnDetector) Ready() bool {
+	return std.ready
 }
 
 func (std *SteveTurnDetector) IsTurningUp() bool {
@@ -77,3 +116,24 @@ func (std *SteveTurnDetector) IsTurningUp() bool {
 func (std *SteveTurnDetector) IsTurningDown() bool {
 	return std.turningDown
 }
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(stdState{})
+}
+
+type stdState struct {
+	Ready       bool
+	TurningUp   bool
+	TurningDown bool
+}
+
+func (std *SteveTurnDetector) State() interface{} {
+	return stdState{Ready: std.ready, TurningUp: std.turningUp, TurningDown: std.turningDown}
+}
+
+func (std *SteveTurnDetector) Restore(saved interface{}) {
+	s := saved.(stdState)
+	std.ready, std.turningUp, std.turningDown = s.Ready, s.TurningUp, s.TurningDown
+}
@@ -0,0 +1,140 @@
+package test_helpers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"../../candles"
+	"../../synthetic"
+)
+
+// What the indicator packages' tests share: price series from StockCharts' ChartSchool worked
+// examples, which publish each indicator's values to 2 decimal places alongside, and ways to turn
+// prices into candles.
+
+// ===== WORKED EXAMPLES ===========================================================================
+
+// 10 day SMA and EMA
+var MovingAverageCloses = []float64{
+	22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+	22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+	23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+}
+
+// 14 day RSI
+var RSICloses = []float64{
+	44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955, 45.4245, 45.8433, 46.0826,
+	45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439,
+	46.2122, 46.2521, 45.7137, 46.4515, 45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672,
+	43.4205, 42.6628, 43.1314,
+}
+
+// 20 day, 2 standard deviation Bollinger Bands, the first 29 days
+var BollingerCloses = []float64{
+	86.16, 89.09, 88.78, 90.32, 89.07, 91.15, 89.44, 89.18, 86.93, 87.68,
+	86.96, 89.43, 89.32, 88.72, 87.45, 87.26, 89.50, 87.90, 89.13, 90.70,
+	92.90, 92.98, 91.80, 92.66, 92.68, 92.30, 92.77, 92.54, 92.95,
+}
+
+// 14 day stochastic. The example only gives closes from the 14th day, before that the highs and
+// lows are just the range %K starts from.
+var StochasticHighs = []float64{
+	127.0090, 127.6159, 126.5911, 127.3472, 128.1730, 128.4317, 127.3671, 126.4220, 126.8995, 126.8498,
+	125.6460, 125.7156, 127.1582, 127.7154, 127.6855, 128.2228, 128.2725, 128.0934, 128.2725, 127.7353,
+	128.7700, 129.2873, 130.0633, 129.1182, 129.2873, 128.4715, 128.0934, 128.6506, 129.1381, 128.6406,
+}
+
+var StochasticLows = []float64{
+	125.3574, 126.1633, 124.9296, 126.0937, 126.8199, 126.4817, 126.0340, 124.8301, 126.3921, 125.7156,
+	124.5615, 124.5715, 125.0689, 126.8597, 126.6309, 126.8001, 126.7105, 126.8001, 126.1335, 125.9245,
+	126.9891, 127.8148, 128.4715, 128.0641, 127.6059, 127.5960, 126.9990, 126.8995, 127.4865, 127.3970,
+}
+
+var StochasticCloses = []float64{
+	127.2876, 127.1781, 128.0138, 127.1085, 127.7253, 127.0587, 127.3273, 128.7103, 127.8745, 128.5809,
+	128.6008, 127.9342, 128.1133, 127.5960, 127.5960, 128.6904, 128.2725,
+}
+
+// StochasticBars are the 17 days of the stochastic example with a high, low and close, for
+// indicators that need all three
+func StochasticBars() []*candles.Candle {
+	n := len(StochasticCloses)
+	return Bars(StochasticHighs[len(StochasticHighs) - n:], StochasticLows[len(StochasticLows) - n:], StochasticCloses)
+}
+
+// ===== CANDLES ===================================================================================
+
+// Closes are candles which open, close and range at each price
+func Closes(closes []float64) []*candles.Candle {
+	return Bars(closes, closes, closes)
+}
+
+// Bars are candles with each high, low and close, opening at the close before. If there are fewer
+// closes than highs they line up with the end, and the bars before them close mid range.
+func Bars(highs, lows, closes []float64) []*candles.Candle {
+	if len(highs) != len(lows) || len(closes) > len(highs) {
+		panic("need a low for every high, and no more closes than highs")
+	}
+
+	res := []*candles.Candle{}
+	skipped := len(highs) - len(closes)
+
+	for i := range highs {
+		c := &candles.Candle{HighBid: highs[i], LowBid: lows[i], CloseBid: (highs[i] + lows[i]) / 2.0, Volume: 1}
+		if i >= skipped {
+			c.CloseBid = closes[i - skipped]
+		}
+
+		c.OpenBid = c.CloseBid
+		if i > 0 {
+			c.OpenBid = res[i - 1].CloseBid
+		}
+
+		res = append(res, c)
+	}
+
+	return res
+}
+
+// Synthetic is n M1 candles of a random walk around EURUSD 1.35, the same every time
+func Synthetic(n int) []*candles.Candle {
+	start := time.Date(2014, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	g := synthetic.NewWithDeets("EURUSD", start, start.Add(time.Duration(n) * time.Minute), 7, 1.35, &synthetic.GBM{Volatility: 0.2})
+	g.WeekendGaps = false
+
+	res := []*candles.Candle{}
+	for tick := range g.Ticks() {
+		res = append(res, &candles.Candle{
+			OpenBid:  tick.OpenBid,
+			HighBid:  tick.HighBid,
+			LowBid:   tick.LowBid,
+			CloseBid: tick.CloseBid,
+			Volume:   tick.Volume,
+		})
+	}
+
+	return res
+}
+
+// ===== CHECKS ====================================================================================
+
+func CloseEnough(a, b, tolerance float64) bool {
+	return math.Abs(a - b) <= tolerance
+}
+
+// ExpectValues compares every value an indicator gave once it was ready with the expected ones,
+// e.g. published to 2 decimal places with a tolerance of 0.01
+func ExpectValues(t *testing.T, name string, expected, got []float64, tolerance float64) {
+	if len(expected) != len(got) {
+		t.Errorf("%s: expected %d values, got %d", name, len(expected), len(got))
+		return
+	}
+
+	for i := range expected {
+		if !CloseEnough(expected[i], got[i], tolerance) {
+			t.Errorf("%s: value %d: expected %.4f, got %.4f", name, i, expected[i], got[i])
+		}
+	}
+}
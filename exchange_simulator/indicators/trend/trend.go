@@ -0,0 +1,510 @@
+package trend
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"../../candles"
+	"../../indicators"
+	"../../ticks"
+	"../volatility"
+)
+
+// ===== ADX / DMI =================================================================================
+
+// Wilder's directional movement system. +DI and -DI are ready after Periods candles, the ADX
+// (a smoothed average of DX) needs twice that.
+func NewADX(periods int64) *AverageDirectionalIndex {
+	indicators.CheckPeriods("ADX", periods, 1)
+
+	adx := &AverageDirectionalIndex{Periods: periods}
+
+	adx.adxSeries = adx.Outputs().AddFloat("adx")
+	adx.plusDI = adx.Outputs().AddFloat("plus_di")
+	adx.minusDI = adx.Outputs().AddFloat("minus_di")
+
+	return adx
+}
+
+type AverageDirectionalIndex struct {
+	indicators.Base
+
+	Periods int64
+
+	adxSeries *indicators.FloatSeries
+	plusDI    *indicators.FloatSeries
+	minusDI   *indicators.FloatSeries
+
+	count int64
+	prev  candles.Candle
+
+	// Wilder sums, not averages
+	tr      float64
+	plusDM  float64
+	minusDM float64
+
+	dxCount int64
+	adx     float64
+}
+
+func (adx *AverageDirectionalIndex) Init() {
+}
+
+func (adx *AverageDirectionalIndex) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (adx *AverageDirectionalIndex) OnBar(c *candles.Candle) {
+	adx.count += 1
+
+	if 1 == adx.count {
+		adx.prev = *c
+		return
+	}
+
+	up := c.HighBid - adx.prev.HighBid
+	down := adx.prev.LowBid - c.LowBid
+
+	plusDM, minusDM := 0.0, 0.0
+	if up > down && up > 0.0 {
+		plusDM = up
+	}
+	if down > up && down > 0.0 {
+		minusDM = down
+	}
+
+	tr := volatility.TrueRange(c, adx.prev.CloseBid, true)
+
+	adx.prev = *c
+
+	n := float64(adx.Periods)
+	moves := adx.count - 1
+
+	if moves <= adx.Periods {
+		adx.tr += tr
+		adx.plusDM += plusDM
+		adx.minusDM += minusDM
+	} else {
+		adx.tr = adx.tr - adx.tr / n + tr
+		adx.plusDM = adx.plusDM - adx.plusDM / n + plusDM
+		adx.minusDM = adx.minusDM - adx.minusDM / n + minusDM
+	}
+
+	if moves < adx.Periods {
+		return
+	}
+
+	adx.dxCount += 1
+
+	if adx.dxCount <= adx.Periods {
+		adx.adx += (adx.DX() - adx.adx) / float64(adx.dxCount)
+	} else {
+		adx.adx = (adx.adx * (n - 1.0) + adx.DX()) / n
+	}
+
+	if adx.Ready() {
+		adx.adxSeries.Push(adx.Value())
+		adx.plusDI.Push(adx.PlusDI())
+		adx.minusDI.Push(adx.MinusDI())
+	}
+}
+
+func (adx *AverageDirectionalIndex) DIReady() bool {
+	return adx.dxCount > 0
+}
+
+func (adx *AverageDirectionalIndex) Ready() bool {
+	return adx.dxCount >= adx.Periods
+}
+
+// One candle to start from, then Periods for the DI and another Periods - 1 for the ADX
+func (adx *AverageDirectionalIndex) WarmUp() int64 {
+	return 2 * adx.Periods
+}
+
+func (adx *AverageDirectionalIndex) PlusDI() float64 {
+	if 0.0 == adx.tr {
+		return 0.0
+	}
+
+	return 100.0 * adx.plusDM / adx.tr
+}
+
+func (adx *AverageDirectionalIndex) MinusDI() float64 {
+	if 0.0 == adx.tr {
+		return 0.0
+	}
+
+	return 100.0 * adx.minusDM / adx.tr
+}
+
+func (adx *AverageDirectionalIndex) DX() float64 {
+	plus, minus := adx.PlusDI(), adx.MinusDI()
+	if 0.0 == plus + minus {
+		return 0.0
+	}
+
+	return 100.0 * math.Abs(plus - minus) / (plus + minus)
+}
+
+func (adx *AverageDirectionalIndex) Value() float64 {
+	return adx.adx
+}
+
+// ===== PARABOLIC SAR =============================================================================
+
+// NewParabolicSAR(0.02, 0.2) is the usual one. Value is the stop for the next candle.
+func NewParabolicSAR(step, max float64) *ParabolicSAR {
+	ps := &ParabolicSAR{Step: step, Max: max}
+
+	ps.sarSeries = ps.Outputs().AddFloat("sar")
+	ps.longSeries = ps.Outputs().AddBool("long")
+
+	return ps
+}
+
+type ParabolicSAR struct {
+	indicators.Base
+
+	Step float64
+	Max  float64
+
+	sarSeries  *indicators.FloatSeries
+	longSeries *indicators.BoolSeries
+
+	count int64
+	prev  candles.Candle
+	prev2 candles.Candle
+
+	long bool
+	sar  float64
+	ep   float64 // extreme point
+	af   float64 // acceleration factor
+}
+
+func (ps *ParabolicSAR) Init() {
+	if ps.Step <= 0.0 || ps.Max < ps.Step {
+		panic("parabolic SAR needs 0.0 < step <= max")
+	}
+}
+
+func (ps *ParabolicSAR) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (ps *ParabolicSAR) OnBar(c *candles.Candle) {
+	ps.count += 1
+
+	switch ps.count {
+	case 1:
+		ps.prev = *c
+		return
+	case 2:
+		// pick the starting trend from the first two closes
+		ps.long = c.CloseBid >= ps.prev.CloseBid
+		ps.af = ps.Step
+
+		if ps.long {
+			ps.sar = math.Min(ps.prev.LowBid, c.LowBid)
+			ps.ep = math.Max(ps.prev.HighBid, c.HighBid)
+		} else {
+			ps.sar = math.Max(ps.prev.HighBid, c.HighBid)
+			ps.ep = math.Min(ps.prev.LowBid, c.LowBid)
+		}
+
+		ps.prev2, ps.prev = ps.prev, *c
+		ps.advance()
+		ps.push()
+		return
+	}
+
+	if ps.long && c.LowBid <= ps.sar {
+		ps.long = false
+		ps.sar = ps.ep
+		ps.ep = c.LowBid
+		ps.af = ps.Step
+	} else if !ps.long && c.HighBid >= ps.sar {
+		ps.long = true
+		ps.sar = ps.ep
+		ps.ep = c.HighBid
+		ps.af = ps.Step
+	} else if ps.long && c.HighBid > ps.ep {
+		ps.ep = c.HighBid
+		ps.af = math.Min(ps.af + ps.Step, ps.Max)
+	} else if !ps.long && c.LowBid < ps.ep {
+		ps.ep = c.LowBid
+		ps.af = math.Min(ps.af + ps.Step, ps.Max)
+	}
+
+	ps.prev2, ps.prev = ps.prev, *c
+	ps.advance()
+	ps.push()
+}
+
+func (ps *ParabolicSAR) push() {
+	ps.sarSeries.Push(ps.sar)
+	ps.longSeries.Push(ps.long)
+}
+
+// advance moves the SAR towards the extreme point for the next candle, never into the range of the
+// last two
+func (ps *ParabolicSAR) advance() {
+	ps.sar += ps.af * (ps.ep - ps.sar)
+
+	if ps.long {
+		ps.sar = math.Min(ps.sar, math.Min(ps.prev.LowBid, ps.prev2.LowBid))
+	} else {
+		ps.sar = math.Max(ps.sar, math.Max(ps.prev.HighBid, ps.prev2.HighBid))
+	}
+}
+
+func (ps *ParabolicSAR) Ready() bool {
+	return ps.count >= 2
+}
+
+func (ps *ParabolicSAR) WarmUp() int64 {
+	return 2
+}
+
+func (ps *ParabolicSAR) IsLong() bool {
+	return ps.long
+}
+
+func (ps *ParabolicSAR) Value() float64 {
+	return ps.sar
+}
+
+// ===== ICHIMOKU ==================================================================================
+
+// NewIchimoku(9, 26, 52) is the usual one. The leading spans are drawn displacement (the kijun
+// periods) candles ahead, so SpanA and SpanB are the cloud under the latest candle and LeadingA
+// and LeadingB are what was just calculated.
+func NewIchimoku(tenkan, kijun, senkouB int64) *Ichimoku {
+	if tenkan < 1 || kijun < tenkan || senkouB < kijun {
+		panic(fmt.Sprintf("Ichimoku needs 1 <= tenkan (%d) <= kijun (%d) <= senkou B (%d)", tenkan, kijun, senkouB))
+	}
+
+	i := &Ichimoku{
+		senkouBPeriods: senkouB,
+
+		tenkan:  newMidpoint(tenkan),
+		kijun:   newMidpoint(kijun),
+		senkouB: newMidpoint(senkouB),
+		spanA:   indicators.NewWindow(kijun + 1),
+		spanB:   indicators.NewWindow(kijun + 1),
+	}
+
+	for _, name := range ICHIMOKU_OUTPUTS {
+		i.Outputs().AddFloat(name)
+	}
+
+	return i
+}
+
+var ICHIMOKU_OUTPUTS = []string{"tenkan", "kijun", "span_a", "span_b", "leading_a", "leading_b", "chikou"}
+
+type midpoint struct {
+	highs *indicators.Extremes
+	lows  *indicators.Extremes
+}
+
+func newMidpoint(periods int64) *midpoint {
+	return &midpoint{highs: indicators.NewExtremes(periods), lows: indicators.NewExtremes(periods)}
+}
+
+func (m *midpoint) add(c *candles.Candle) {
+	m.highs.Add(c.HighBid)
+	m.lows.Add(c.LowBid)
+}
+
+func (m *midpoint) value() float64 {
+	return (m.highs.Max() + m.lows.Min()) / 2.0
+}
+
+type Ichimoku struct {
+	indicators.Base
+
+	senkouBPeriods int64
+	count          int64
+
+	tenkan  *midpoint
+	kijun   *midpoint
+	senkouB *midpoint
+
+	// leading spans waiting to be displaced
+	spanA *indicators.Window
+	spanB *indicators.Window
+
+	close float64
+}
+
+func (i *Ichimoku) Init() {
+}
+
+func (i *Ichimoku) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (i *Ichimoku) OnBar(c *candles.Candle) {
+	i.tenkan.add(c)
+	i.kijun.add(c)
+	i.senkouB.add(c)
+
+	i.close = c.CloseBid
+	i.count += 1
+
+	i.spanA.Add(i.LeadingA())
+	i.spanB.Add(i.LeadingB())
+
+	if i.Ready() {
+		values := []float64{i.Tenkan(), i.Kijun(), i.SpanA(), i.SpanB(), i.LeadingA(), i.LeadingB(), i.Chikou()}
+
+		for j, name := range ICHIMOKU_OUTPUTS {
+			i.Outputs().Float(name).Push(values[j])
+		}
+	}
+}
+
+// Ready once the cloud under the latest candle was calculated from a full senkou B window
+func (i *Ichimoku) Ready() bool {
+	return i.count >= i.senkouBPeriods + i.spanB.Size() - 1
+}
+
+func (i *Ichimoku) WarmUp() int64 {
+	return i.senkouBPeriods + i.spanB.Size() - 1
+}
+
+func (i *Ichimoku) Tenkan() float64 {
+	return i.tenkan.value()
+}
+
+func (i *Ichimoku) Kijun() float64 {
+	return i.kijun.value()
+}
+
+func (i *Ichimoku) LeadingA() float64 {
+	return (i.Tenkan() + i.Kijun()) / 2.0
+}
+
+func (i *Ichimoku) LeadingB() float64 {
+	return i.senkouB.value()
+}
+
+func (i *Ichimoku) SpanA() float64 {
+	return i.spanA.At(i.spanA.Len() - 1)
+}
+
+func (i *Ichimoku) SpanB() float64 {
+	return i.spanB.At(i.spanB.Len() - 1)
+}
+
+// Chikou is just the close, it's compared against price displacement candles back
+func (i *Ichimoku) Chikou() float64 {
+	return i.close
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(adxState{})
+	gob.Register(sarState{})
+	gob.Register(ichimokuState{})
+}
+
+type adxState struct {
+	Count   int64
+	Prev    candles.Candle
+	TR      float64
+	PlusDM  float64
+	MinusDM float64
+	DXCount int64
+	ADX     float64
+}
+
+func (adx *AverageDirectionalIndex) State() interface{} {
+	return adxState{
+		Count:   adx.count,
+		Prev:    adx.prev,
+		TR:      adx.tr,
+		PlusDM:  adx.plusDM,
+		MinusDM: adx.minusDM,
+		DXCount: adx.dxCount,
+		ADX:     adx.adx,
+	}
+}
+
+func (adx *AverageDirectionalIndex) Restore(saved interface{}) {
+	s := saved.(adxState)
+
+	adx.count, adx.prev = s.Count, s.Prev
+	adx.tr, adx.plusDM, adx.minusDM = s.TR, s.PlusDM, s.MinusDM
+	adx.dxCount, adx.adx = s.DXCount, s.ADX
+}
+
+type sarState struct {
+	Count int64
+	Prev  candles.Candle
+	Prev2 candles.Candle
+	Long  bool
+	SAR   float64
+	EP    float64
+	AF    float64
+}
+
+func (ps *ParabolicSAR) State() interface{} {
+	return sarState{Count: ps.count, Prev: ps.prev, Prev2: ps.prev2, Long: ps.long, SAR: ps.sar, EP: ps.ep, AF: ps.af}
+}
+
+func (ps *ParabolicSAR) Restore(saved interface{}) {
+	s := saved.(sarState)
+
+	ps.count, ps.prev, ps.prev2 = s.Count, s.Prev, s.Prev2
+	ps.long, ps.sar, ps.ep, ps.af = s.Long, s.SAR, s.EP, s.AF
+}
+
+type ichimokuState struct {
+	Count int64
+	Close float64
+
+	// highs and lows of each
+	Tenkan  [2]indicators.ExtremesState
+	Kijun   [2]indicators.ExtremesState
+	SenkouB [2]indicators.ExtremesState
+
+	SpanA indicators.WindowState
+	SpanB indicators.WindowState
+}
+
+func (m *midpoint) state() [2]indicators.ExtremesState {
+	return [2]indicators.ExtremesState{m.highs.State(), m.lows.State()}
+}
+
+func (m *midpoint) restore(s [2]indicators.ExtremesState) {
+	m.highs.Restore(s[0])
+	m.lows.Restore(s[1])
+}
+
+func (i *Ichimoku) State() interface{} {
+	return ichimokuState{
+		Count: i.count,
+		Close: i.close,
+
+		Tenkan:  i.tenkan.state(),
+		Kijun:   i.kijun.state(),
+		SenkouB: i.senkouB.state(),
+
+		SpanA: i.spanA.State(),
+		SpanB: i.spanB.State(),
+	}
+}
+
+func (i *Ichimoku) Restore(saved interface{}) {
+	s := saved.(ichimokuState)
+
+	i.count, i.close = s.Count, s.Close
+
+	i.tenkan.restore(s.Tenkan)
+	i.kijun.restore(s.Kijun)
+	i.senkouB.restore(s.SenkouB)
+
+	i.spanA.Restore(s.SpanA)
+	i.spanB.Restore(s.SpanB)
+}
@@ -0,0 +1,143 @@
+package trend
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	th "../test_helpers"
+)
+
+// The ADX and SAR expectations are TA-Lib's output (ADX, PLUS_DI, MINUS_DI and SAR, via the
+// go-talib port) for the bars each test feeds in
+
+// TA-Lib starts Wilder's sums from Periods - 1 moves and a smoothing step where Wilder, and
+// StockCharts, add up the first Periods moves. That's long gone 290 candles in.
+func TestADX(t *testing.T) {
+	adx := NewADX(5)
+
+	first := -1
+	values, plus, minus := []float64{}, []float64{}, []float64{}
+	for i, c := range th.Synthetic(300) {
+		adx.OnBar(c)
+
+		if adx.Ready() && first < 0 {
+			first = i
+		}
+
+		if i >= 292 {
+			values = append(values, adx.Value())
+			plus = append(plus, adx.PlusDI())
+			minus = append(minus, adx.MinusDI())
+		}
+	}
+
+	// TA-Lib's lookback is 2 * Periods - 1
+	if 9 != first {
+		t.Errorf("Expected the ADX to be ready on candle 9, was on %d", first)
+	}
+
+	th.ExpectValues(t, "ADX", []float64{
+		31.1546356296, 26.1785066114, 28.5972519544, 34.6436555420, 41.1131644144, 46.2887715123, 42.7505639612, 36.0528215409,
+	}, values, 1e-6)
+	th.ExpectValues(t, "+DI", []float64{
+		31.2061907986, 26.9707294363, 19.0658515968, 14.4516985269, 12.1239802515, 10.5807741060, 24.9349297335, 39.1093488584,
+	}, plus, 1e-6)
+	th.ExpectValues(t, "-DI", []float64{
+		35.3840566213, 30.5815542707, 42.7081364290, 55.7520529695, 61.3350986377, 53.5280336980, 44.9085949761, 32.4789469516,
+	}, minus, 1e-6)
+}
+
+// TA-Lib's SAR for a candle is the stop in force during it, Value is that for the next candle. On
+// a reversal TA-Lib gives the new SAR, the old extreme point, where Value was the stop that got hit.
+// Either way the SAR after a reversal candle has already moved AF towards the new extreme point.
+func TestParabolicSAR(t *testing.T) {
+	ps := NewParabolicSAR(0.02, 0.2)
+	ps.Init()
+
+	// candles 1 to 16, reversing on the 2nd, 5th, 7th and 14th
+	taLib := []float64{
+		127.7154, 126.6309, 126.6309, 126.6966, 128.2725, 128.2725, 125.9245, 125.9245,
+		126.0590, 126.2993, 126.5251, 126.7374, 126.9370, 130.0633, 130.0000, 129.9380,
+	}
+	reversals := map[int]bool{2: true, 5: true, 7: true, 14: true}
+
+	expectedLongs := []bool{
+		false, true, true, true, false, false, true, true,
+		true, true, true, true, true, false, false, false,
+	}
+
+	for i, c := range th.StochasticBars() {
+		ps.OnBar(c)
+
+		if 0 == i {
+			continue
+		}
+
+		if expectedLongs[i - 1] != ps.IsLong() {
+			t.Errorf("Candle %d: expected long: %t, got %t", i, expectedLongs[i - 1], ps.IsLong())
+		}
+
+		if i < len(taLib) && !reversals[i + 1] {
+			th.ExpectValues(t, fmt.Sprintf("SAR after candle %d", i), taLib[i:i + 1], []float64{ps.Value()}, 0.0001)
+		}
+	}
+
+	// 27 reversals in, TA-Lib's SAR for candles 292 to 299
+	ps = NewParabolicSAR(0.02, 0.2)
+	ps.Init()
+
+	values := []float64{}
+	for i, c := range th.Synthetic(299) {
+		ps.OnBar(c)
+
+		if i >= 291 {
+			values = append(values, ps.Value())
+		}
+	}
+
+	expected := []float64{
+		1.3503696800, 1.3502746044, 1.3501852335, 1.3501012248,
+		1.3499453899, 1.3497251454, 1.3495269253, 1.3493485272,
+	}
+
+	th.ExpectValues(t, "SAR", expected, values, 1e-9)
+}
+
+func TestIchimoku(t *testing.T) {
+	ichi := NewIchimoku(9, 26, 52)
+	data := th.Synthetic(300)
+
+	mid := func(end, periods int) float64 {
+		hh, ll := math.Inf(-1), math.Inf(1)
+		for _, c := range data[end - periods + 1 : end + 1] {
+			hh = math.Max(hh, c.HighBid)
+			ll = math.Min(ll, c.LowBid)
+		}
+
+		return (hh + ll) / 2.0
+	}
+
+	for i, c := range data {
+		ichi.OnBar(c)
+
+		if i < 51 + 26 {
+			if ichi.Ready() {
+				t.Fatalf("Candle %d: expected Ichimoku not to be ready", i)
+			}
+			continue
+		}
+
+		then := i - 26
+		spanA := (mid(then, 9) + mid(then, 26)) / 2.0
+		spanB := mid(then, 52)
+
+		if !ichi.Ready() || !th.CloseEnough(ichi.SpanA(), spanA, 1e-12) || !th.CloseEnough(ichi.SpanB(), spanB, 1e-12) {
+			t.Fatalf("Candle %d: expected cloud %.5f/%.5f, got %.5f/%.5f", i, spanA, spanB, ichi.SpanA(), ichi.SpanB())
+		}
+
+		if ichi.Tenkan() != mid(i, 9) || ichi.Kijun() != mid(i, 26) {
+			t.Fatalf("Candle %d: bad tenkan/kijun", i)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package volatility
+
+import (
+	"encoding/gob"
+	"math"
+
+	"../../candles"
+	"../../indicators"
+	"../../ticks"
+	"../moving_averages"
+)
+
+// ===== BOLLINGER BANDS ===========================================================================
+
+// NewBollingerBands(20, 2.0) is the usual one
+func NewBollingerBands(periods int64, deviations float64) *BollingerBands {
+	indicators.CheckPeriods("Bollinger Bands", periods, 1)
+
+	bb := &BollingerBands{Deviations: deviations, window: indicators.NewWindow(periods)}
+
+	bb.middle = bb.Outputs().AddFloat("middle")
+	bb.upper = bb.Outputs().AddFloat("upper")
+	bb.lower = bb.Outputs().AddFloat("lower")
+	bb.width = bb.Outputs().AddFloat("width")
+
+	return bb
+}
+
+type BollingerBands struct {
+	indicators.Base
+
+	Deviations float64
+
+	window *indicators.Window
+
+	middle *indicators.FloatSeries
+	upper  *indicators.FloatSeries
+	lower  *indicators.FloatSeries
+	width  *indicators.FloatSeries
+}
+
+func (bb *BollingerBands) Init() {
+	if bb.Deviations <= 0.0 {
+		panic("Bollinger Band deviations must be > 0.0")
+	}
+}
+
+func (bb *BollingerBands) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (bb *BollingerBands) OnBar(c *candles.Candle) {
+	bb.Add(c.CloseBid)
+}
+
+func (bb *BollingerBands) Add(v float64) {
+	bb.window.Add(v)
+
+	if bb.Ready() {
+		bb.middle.Push(bb.Middle())
+		bb.upper.Push(bb.Upper())
+		bb.lower.Push(bb.Lower())
+		bb.width.Push(bb.Width())
+	}
+}
+
+func (bb *BollingerBands) Ready() bool {
+	return bb.window.Full()
+}
+
+func (bb *BollingerBands) WarmUp() int64 {
+	return bb.window.Size()
+}
+
+func (bb *BollingerBands) Middle() float64 {
+	return bb.window.Mean()
+}
+
+func (bb *BollingerBands) Upper() float64 {
+	return bb.window.Mean() + bb.Deviations * bb.window.StdDev()
+}
+
+func (bb *BollingerBands) Lower() float64 {
+	return bb.window.Mean() - bb.Deviations * bb.window.StdDev()
+}
+
+// Width is the distance between the bands relative to the middle
+func (bb *BollingerBands) Width() float64 {
+	if 0.0 == bb.Middle() {
+		return 0.0
+	}
+
+	return (bb.Upper() - bb.Lower()) / bb.Middle()
+}
+
+// ===== AVERAGE TRUE RANGE ========================================================================
+
+func TrueRange(c *candles.Candle, prevClose float64, hasPrev bool) float64 {
+	if !hasPrev {
+		return c.HighBid - c.LowBid
+	}
+
+	return math.Max(c.HighBid - c.LowBid, math.Max(math.Abs(c.HighBid - prevClose), math.Abs(c.LowBid - prevClose)))
+}
+
+// Wilder's ATR: a simple average of the first Periods true ranges, smoothed by 1/Periods after
+func NewATR(periods int64) *AverageTrueRange {
+	indicators.CheckPeriods("ATR", periods, 1)
+
+	atr := &AverageTrueRange{Periods: periods}
+	atr.series = atr.Outputs().AddFloat("value")
+
+	return atr
+}
+
+type AverageTrueRange struct {
+	indicators.Base
+
+	Periods int64
+
+	series *indicators.FloatSeries
+
+	count     int64
+	prevClose float64
+	value     float64
+}
+
+func (atr *AverageTrueRange) Init() {
+}
+
+func (atr *AverageTrueRange) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (atr *AverageTrueRange) OnBar(c *candles.Candle) {
+	atr.Add(TrueRange(c, atr.prevClose, atr.count > 0))
+	atr.prevClose = c.CloseBid
+}
+
+// Add takes a true range
+func (atr *AverageTrueRange) Add(tr float64) {
+	atr.count += 1
+
+	if atr.count <= atr.Periods {
+		atr.value += (tr - atr.value) / float64(atr.count)
+	} else {
+		n := float64(atr.Periods)
+		atr.value = (atr.value * (n - 1.0) + tr) / n
+	}
+
+	if atr.Ready() {
+		atr.series.Push(atr.value)
+	}
+}
+
+func (atr *AverageTrueRange) Ready() bool {
+	return atr.count >= atr.Periods
+}
+
+func (atr *AverageTrueRange) WarmUp() int64 {
+	return atr.Periods
+}
+
+func (atr *AverageTrueRange) Value() float64 {
+	return atr.value
+}
+
+// ===== KELTNER CHANNELS ==========================================================================
+
+// An EMA of the close with bands Multiplier ATRs either side. NewKeltnerChannels(20, 10, 2.0) is
+// the usual one.
+func NewKeltnerChannels(emaPeriods, atrPeriods int64, multiplier float64) *KeltnerChannels {
+	kc := &KeltnerChannels{
+		Multiplier: multiplier,
+		ema:        moving_averages.NewEMA(emaPeriods),
+		atr:        NewATR(atrPeriods),
+	}
+
+	kc.middle = kc.Outputs().AddFloat("middle")
+	kc.upper = kc.Outputs().AddFloat("upper")
+	kc.lower = kc.Outputs().AddFloat("lower")
+
+	return kc
+}
+
+type KeltnerChannels struct {
+	indicators.Base
+
+	Multiplier float64
+
+	middle *indicators.FloatSeries
+	upper  *indicators.FloatSeries
+	lower  *indicators.FloatSeries
+
+	ema *moving_averages.ExponentialMovingAverage
+	atr *AverageTrueRange
+}
+
+func (kc *KeltnerChannels) Init() {
+	if kc.Multiplier <= 0.0 {
+		panic("Keltner Channel multiplier must be > 0.0")
+	}
+}
+
+func (kc *KeltnerChannels) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (kc *KeltnerChannels) OnBar(c *candles.Candle) {
+	kc.ema.OnBar(c)
+	kc.atr.OnBar(c)
+
+	if kc.Ready() {
+		kc.middle.Push(kc.Middle())
+		kc.upper.Push(kc.Upper())
+		kc.lower.Push(kc.Lower())
+	}
+}
+
+func (kc *KeltnerChannels) Ready() bool {
+	return kc.ema.Ready() && kc.atr.Ready()
+}
+
+func (kc *KeltnerChannels) WarmUp() int64 {
+	if kc.ema.WarmUp() > kc.atr.WarmUp() {
+		return kc.ema.WarmUp()
+	}
+
+	return kc.atr.WarmUp()
+}
+
+func (kc *KeltnerChannels) Middle() float64 {
+	return kc.ema.Value()
+}
+
+func (kc *KeltnerChannels) Upper() float64 {
+	return kc.ema.Value() + kc.Multiplier * kc.atr.Value()
+}
+
+func (kc *KeltnerChannels) Lower() float64 {
+	return kc.ema.Value() - kc.Multiplier * kc.atr.Value()
+}
+
+// ===== DONCHIAN CHANNELS =========================================================================
+
+// Highest high and lowest low of the last Periods candles, including the latest
+func NewDonchianChannels(periods int64) *DonchianChannels {
+	indicators.CheckPeriods("Donchian Channels", periods, 1)
+
+	dc := &DonchianChannels{highs: indicators.NewExtremes(periods), lows: indicators.NewExtremes(periods)}
+
+	dc.upper = dc.Outputs().AddFloat("upper")
+	dc.lower = dc.Outputs().AddFloat("lower")
+	dc.middle = dc.Outputs().AddFloat("middle")
+
+	return dc
+}
+
+type DonchianChannels struct {
+	indicators.Base
+
+	highs *indicators.Extremes
+	lows  *indicators.Extremes
+
+	upper  *indicators.FloatSeries
+	lower  *indicators.FloatSeries
+	middle *indicators.FloatSeries
+}
+
+func (dc *DonchianChannels) Init() {
+}
+
+func (dc *DonchianChannels) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (dc *DonchianChannels) OnBar(c *candles.Candle) {
+	dc.highs.Add(c.HighBid)
+	dc.lows.Add(c.LowBid)
+
+	if dc.Ready() {
+		dc.upper.Push(dc.Upper())
+		dc.lower.Push(dc.Lower())
+		dc.middle.Push(dc.Middle())
+	}
+}
+
+func (dc *DonchianChannels) Ready() bool {
+	return dc.highs.Full()
+}
+
+func (dc *DonchianChannels) WarmUp() int64 {
+	return dc.highs.Size()
+}
+
+func (dc *DonchianChannels) Upper() float64 {
+	return dc.highs.Max()
+}
+
+func (dc *DonchianChannels) Lower() float64 {
+	return dc.lows.Min()
+}
+
+func (dc *DonchianChannels) Middle() float64 {
+	return (dc.Upper() + dc.Lower()) / 2.0
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(atrState{})
+	gob.Register(keltnerState{})
+	gob.Register(donchianState{})
+}
+
+func (bb *BollingerBands) State() interface{} {
+	return bb.window.State()
+}
+
+func (bb *BollingerBands) Restore(saved interface{}) {
+	bb.window.Restore(saved.(indicators.WindowState))
+}
+
+type atrState struct {
+	Count     int64
+	PrevClose float64
+	Value     float64
+}
+
+func (atr *AverageTrueRange) State() interface{} {
+	return atrState{Count: atr.count, PrevClose: atr.prevClose, Value: atr.value}
+}
+
+func (atr *AverageTrueRange) Restore(saved interface{}) {
+	s := saved.(atrState)
+	atr.count, atr.prevClose, atr.value = s.Count, s.PrevClose, s.Value
+}
+
+type keltnerState struct {
+	EMA indicators.State
+	ATR indicators.State
+}
+
+func (kc *KeltnerChannels) State() interface{} {
+	return keltnerState{EMA: indicators.Save(kc.ema), ATR: indicators.Save(kc.atr)}
+}
+
+func (kc *KeltnerChannels) Restore(saved interface{}) {
+	s := saved.(keltnerState)
+
+	indicators.Restore(kc.ema, s.EMA)
+	indicators.Restore(kc.atr, s.ATR)
+}
+
+type donchianState struct {
+	Highs indicators.ExtremesState
+	Lows  indicators.ExtremesState
+}
+
+func (dc *DonchianChannels) State() interface{} {
+	return donchianState{Highs: dc.highs.State(), Lows: dc.lows.State()}
+}
+
+func (dc *DonchianChannels) Restore(saved interface{}) {
+	s := saved.(donchianState)
+
+	dc.highs.Restore(s.Highs)
+	dc.lows.Restore(s.Lows)
+}
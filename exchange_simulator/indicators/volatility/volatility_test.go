@@ -0,0 +1,103 @@
+package volatility
+
+import (
+	"math"
+	"testing"
+
+	th "../test_helpers"
+)
+
+// Bollinger Bands are StockCharts' published example, the ATR and Keltner Channels are worked from
+// Wilder's and Keltner's definitions over the bars of its stochastic example
+
+func TestBollingerBands(t *testing.T) {
+	bb := NewBollingerBands(20, 2.0)
+	bb.Init()
+
+	middles, uppers, lowers := []float64{}, []float64{}, []float64{}
+	for _, c := range th.Closes(th.BollingerCloses) {
+		bb.OnBar(c)
+
+		if bb.Ready() {
+			middles = append(middles, bb.Middle())
+			uppers = append(uppers, bb.Upper())
+			lowers = append(lowers, bb.Lower())
+		}
+	}
+
+	th.ExpectValues(t, "middle", []float64{88.71, 89.05, 89.24, 89.39, 89.51, 89.69, 89.75, 89.91, 90.08, 90.38}, middles, 0.01)
+	th.ExpectValues(t, "upper", []float64{91.29, 91.95, 92.61, 92.93, 93.31, 93.73, 93.90, 94.27, 94.57, 94.79}, uppers, 0.01)
+	th.ExpectValues(t, "lower", []float64{86.13, 86.14, 85.87, 85.85, 85.70, 85.65, 85.59, 85.56, 85.60, 85.98}, lowers, 0.01)
+}
+
+func atrValues(periods int64) []float64 {
+	atr := NewATR(periods)
+
+	res := []float64{}
+	for _, c := range th.StochasticBars() {
+		atr.OnBar(c)
+
+		if atr.Ready() {
+			res = append(res, atr.Value())
+		}
+	}
+
+	return res
+}
+
+func TestATR(t *testing.T) {
+	th.ExpectValues(t, "ATR(14)", []float64{1.4504, 1.4719, 1.4847, 1.4711}, atrValues(14), 0.0001)
+
+	th.ExpectValues(t, "ATR(5)", []float64{
+		1.2377, 1.4179, 1.4965, 1.5534, 1.5372, 1.6675, 1.5448, 1.5722, 1.4328, 1.3691, 1.4455, 1.4867, 1.4481,
+	}, atrValues(5), 0.0001)
+}
+
+func TestKeltnerChannels(t *testing.T) {
+	kc := NewKeltnerChannels(10, 5, 2.0)
+	kc.Init()
+
+	middles, uppers, lowers := []float64{}, []float64{}, []float64{}
+	for _, c := range th.StochasticBars() {
+		kc.OnBar(c)
+
+		if kc.Ready() {
+			middles = append(middles, kc.Middle())
+			uppers = append(uppers, kc.Upper())
+			lowers = append(lowers, kc.Lower())
+		}
+	}
+
+	th.ExpectValues(t, "middle", []float64{
+		127.6865, 127.8527, 127.8675, 127.9122, 127.8547, 127.8077, 127.9682, 128.0235,
+	}, middles, 0.0001)
+	th.ExpectValues(t, "upper", []float64{
+		131.0215, 130.9424, 131.0119, 130.7779, 130.5930, 130.6987, 130.9416, 130.9196,
+	}, uppers, 0.0001)
+	th.ExpectValues(t, "lower", []float64{
+		124.3515, 124.7631, 124.7232, 125.0466, 125.1165, 124.9167, 124.9947, 125.1274,
+	}, lowers, 0.0001)
+}
+
+func TestDonchianChannels(t *testing.T) {
+	dc := NewDonchianChannels(20)
+	data := th.Synthetic(300)
+
+	for i, c := range data {
+		dc.OnBar(c)
+
+		if i < 19 {
+			continue
+		}
+
+		hh, ll := math.Inf(-1), math.Inf(1)
+		for _, prev := range data[i - 19 : i + 1] {
+			hh = math.Max(hh, prev.HighBid)
+			ll = math.Min(ll, prev.LowBid)
+		}
+
+		if !dc.Ready() || hh != dc.Upper() || ll != dc.Lower() {
+			t.Fatalf("Candle %d: expected %.5f/%.5f, got %.5f/%.5f", i, hh, ll, dc.Upper(), dc.Lower())
+		}
+	}
+}
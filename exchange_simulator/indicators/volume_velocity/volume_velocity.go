@@ -1,9 +1,11 @@
 package volume_velocity
 
 import (
+	"encoding/gob"
 	// "fmt"
 
-	"../../algorithms"
+	"../../candles"
+	"../../indicators"
 	"../../ticks"
 	"../../utils"
 )
@@ -11,15 +13,28 @@ import (
 const NUM_CANDLES = 5
 
 func NewVV(n int64, change float64) *VolumeVelocity {
-	return &VolumeVelocity{numCandles: n, change: change}
+	vv := &VolumeVelocity{numCandles: n, change: change}
+
+	vv.increasingSeries = vv.Outputs().AddBool("increasing")
+	vv.decreasingSeries = vv.Outputs().AddBool("decreasing")
+
+	return vv
 }
 
 type VolumeVelocity struct {
+	indicators.Base
+
+	ready      bool
 	increasing bool
 	decreasing bool
 
+	increasingSeries *indicators.BoolSeries
+	decreasingSeries *indicators.BoolSeries
+
 	numCandles int64
 	change float64
+
+	chart candles.Chart
 }
 
 func (vv *VolumeVelocity) Init(){
@@ -32,27 +47,29 @@ func (vv *VolumeVelocity) Init(){
 	}
 }
 
+func (vv *VolumeVelocity) SetChart(chart candles.Chart) {
+	vv.chart = chart
+	vv.chart.Keep(NUM_CANDLES)
+}
+
+func (vv *VolumeVelocity) WarmUp() int64 {
+	return NUM_CANDLES
+}
+
 func (vv *VolumeVelocity) OnTick(a interface{}, tick *ticks.MarketTick) {
+}
+
+func (vv *VolumeVelocity) OnBar(c *candles.Candle) {
 	vv.increasing = false
 	vv.decreasing = false
 
-	chart := a.(*algorithms.Algorithm).Charts[tick.Symbol]["M1"]
-	if chart.Len() < NUM_CANDLES + 1 {
+	chart := vv.chart
+	if chart.Finished() < NUM_CANDLES {
 		return
 	}
 
-	var first, last float64
-
-	count := 0
-	for _, val := range chart.GetCandles(NUM_CANDLES) {
-		if count == 0 {
-			first = float64(val.Volume)
-		} else if count == NUM_CANDLES - 1 {
-			last = float64(val.Volume)
-		}
-
-		count++
-	}
+	first := float64(chart.At(0).Volume)
+	last  := float64(chart.At(NUM_CANDLES - 1).Volume)
 
 	move := utils.PercentChange(first, last)
 
@@ -64,6 +81,13 @@ func (vv *VolumeVelocity) OnTick(a interface{}, tick *ticks.MarketTick) {
 		vv.decreasing = true
 	}
 
+	vv.ready = true
+	vv.increasingSeries.Push(vv.increasing)
+	vv.decreasingSeries.Push(vv.decreasing)
+}
+
+func (vv *VolumeVelocity) Ready() bool {
+	return vv.ready
 }
 
 func (vv *VolumeVelocity) IsIncreasing() bool {
@@ -77,3 +101,24 @@ func (vv *VolumeVelocity) IsDecreasing() bool {
 func (vv *VolumeVelocity) IsConstant() bool {
 	return !vv.increasing && !vv.decreasing
 }
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(vvState{})
+}
+
+type vvState struct {
+	Ready      bool
+	Increasing bool
+	Decreasing bool
+}
+
+func (vv *VolumeVelocity) State() interface{} {
+	return vvState{Ready: vv.ready, Increasing: vv.increasing, Decreasing: vv.decreasing}
+}
+
+func (vv *VolumeVelocity) Restore(saved interface{}) {
+	s := saved.(vvState)
+	vv.ready, vv.increasing, vv.decreasing = s.Ready, s.Increasing, s.Decreasing
+}
@@ -0,0 +1,250 @@
+package journals
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"../orders"
+)
+
+// ===== EVENTS ====================================================================================
+
+type EventType string
+
+const (
+	ACCOUNT_OPENED          = EventType("account_opened")
+	ORDER_SUBMITTED         = EventType("order_submitted")
+	ORDER_REJECTED          = EventType("order_rejected")
+	ORDER_FILLED            = EventType("order_filled")
+	STOPS_MOVED             = EventType("stops_moved")
+	ORDER_CLOSED            = EventType("order_closed")
+	MARGIN_CALL             = EventType("margin_call")
+	DRAWDOWN_EXCEEDED       = EventType("drawdown_exceeded")
+	CIRCUIT_BREAKER_TRIPPED = EventType("circuit_breaker_tripped")
+	CIRCUIT_BREAKER_RESET   = EventType("circuit_breaker_reset")
+	CUSTOM                  = EventType("custom")
+	FINAL_STATE             = EventType("final_state")
+)
+
+// An Entry is one line of the journal. At is simulated time, and money is in the account's currency
+// as it stood just after the event.
+type Entry struct {
+	Seq      int64     `json:"seq"`
+	At       time.Time `json:"at"`
+	Type     EventType `json:"type"`
+	Account  string    `json:"account"`
+	Strategy string    `json:"strategy,omitempty"` // portfolio tag
+
+	Order      int64   `json:"order,omitempty"` // see orders.Order.ID
+	Symbol     string  `json:"symbol,omitempty"`
+	Direction  string  `json:"direction,omitempty"`
+	Lots       float64 `json:"lots,omitempty"`
+	Price      float64 `json:"price,omitempty"`
+	Bid        float64 `json:"bid,omitempty"`
+	Ask        float64 `json:"ask,omitempty"`
+	StopLoss   float64 `json:"stop_loss,omitempty"`   // pips
+	TakeProfit float64 `json:"take_profit,omitempty"` // pips
+	Profit     float64 `json:"profit,omitempty"`
+	Commission float64 `json:"commission,omitempty"`
+
+	Balance    float64 `json:"balance,omitempty"`
+	Equity     float64 `json:"equity,omitempty"`
+	Drawdown   float64 `json:"drawdown,omitempty"` // %
+	Trades     int64   `json:"trades,omitempty"`
+	OpenOrders int64   `json:"open_orders,omitempty"`
+
+	Name   string                 `json:"name,omitempty"` // custom event or circuit breaker
+	Reason string                 `json:"reason,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"` // custom event
+}
+
+func direction(d orders.TradeDirection) string {
+	if orders.BUY == d {
+		return "buy"
+	}
+
+	return "sell"
+}
+
+// Request is an order a strategy asked for, before anything between it and the exchange had a say
+func Request(t EventType, at time.Time, symbol string, d orders.TradeDirection, lots float64, sl, tp float64) Entry {
+	return Entry{At: at, Type: t, Symbol: symbol, Direction: direction(d), Lots: lots, StopLoss: sl, TakeProfit: tp}
+}
+
+// Filled, StopsMoved and Closed describe o as it was at the time
+
+func Filled(o *orders.Order) Entry {
+	e := stops(ORDER_FILLED, o.OpenedAt, o)
+	e.Price, e.Bid, e.Ask = o.OpenPrice, o.OpenBid, o.OpenAsk
+	e.Balance, e.Equity, e.Drawdown = o.BalanceAtOpen, o.EquityAtOpen, o.DrawdownAtOpen
+
+	return e
+}
+
+func StopsMoved(at time.Time, o *orders.Order) Entry {
+	return stops(STOPS_MOVED, at, o)
+}
+
+func Closed(o *orders.Order) Entry {
+	e := order(ORDER_CLOSED, o.ClosedAt, o)
+	e.Price, e.Bid, e.Ask = o.ClosePrice, o.CloseBid, o.CloseAsk
+	e.Profit, e.Commission = o.Profit(), o.Commission()
+	e.Balance, e.Equity, e.Drawdown = o.BalanceAtClose, o.EquityAtClose, o.DrawdownAtClose
+	e.Reason = string(o.CloseReason)
+
+	return e
+}
+
+func order(t EventType, at time.Time, o *orders.Order) Entry {
+	return Entry{
+		At:        at,
+		Type:      t,
+		Strategy:  o.Tag,
+		Order:     o.ID,
+		Symbol:    o.Symbol,
+		Direction: direction(o.Direction),
+		Lots:      o.LotSize,
+	}
+}
+
+func stops(t EventType, at time.Time, o *orders.Order) Entry {
+	e := order(t, at, o)
+	e.StopLoss = float64(o.GetStopLoss().Pips)
+	e.TakeProfit = float64(o.GetTakeProfit().Pips)
+
+	return e
+}
+
+// ===== JOURNAL ===================================================================================
+
+// NewWithDeets journals to w. Entries are buffered, Close flushes them.
+func NewWithDeets(w io.Writer) *Journal {
+	return &Journal{out: bufio.NewWriter(w)}
+}
+
+// NewFile journals to path, which is created (or truncated) when the first entry's written
+func NewFile(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// A Journal is an append-only record of everything that happened to the accounts in a run, one JSON
+// object per line. Algorithms share one, so it's safe to use concurrently, and it carries on from
+// where it was when a run's resumed from a checkpoint. See Replay.
+type Journal struct {
+	path string
+	file *os.File
+	out  *bufio.Writer
+
+	mutex sync.Mutex
+
+	seq     int64
+	written int64 // bytes
+	resumed bool  // the file has entries to keep
+}
+
+func (j *Journal) Record(e Entry) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if nil == j.out {
+		j.open()
+	}
+
+	j.seq += 1
+	e.Seq = j.seq
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Fatalln("can't journal " + string(e.Type) + ": " + err.Error())
+	}
+
+	n, err := j.out.Write(append(line, '\n'))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	j.written += int64(n)
+}
+
+func (j *Journal) open() {
+	var err error
+
+	if j.resumed {
+		j.file, err = os.OpenFile(j.path, os.O_WRONLY, 0644)
+		if err == nil {
+			err = j.file.Truncate(j.written)
+		}
+		if err == nil {
+			_, err = j.file.Seek(j.written, os.SEEK_SET)
+		}
+	} else {
+		j.file, err = os.Create(j.path)
+	}
+
+	if err != nil {
+		log.Fatalln("can't open journal: " + err.Error())
+	}
+
+	j.out = bufio.NewWriter(j.file)
+}
+
+func (j *Journal) flush() {
+	if nil == j.out {
+		return
+	}
+
+	if err := j.out.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (j *Journal) Close() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.flush()
+
+	if nil != j.file {
+		if err := j.file.Close(); err != nil {
+			log.Fatalln(err)
+		}
+
+		j.file = nil
+		j.out = nil
+		j.resumed = true
+	}
+}
+
+// ----- CHECKPOINTS -------------------------------------------------------------------------------
+
+// State is enough to carry on writing the file where the checkpoint was saved, dropping whatever
+// was written after it
+type State struct {
+	Path    string
+	Seq     int64
+	Written int64
+}
+
+func (j *Journal) State() State {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.flush()
+
+	return State{Path: j.path, Seq: j.seq, Written: j.written}
+}
+
+func (j *Journal) Restore(s State) {
+	j.seq = s.Seq
+	j.written = s.Written
+
+	if "" != s.Path {
+		j.path = s.Path
+		j.resumed = true
+	}
+}
@@ -0,0 +1,110 @@
+package journals
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"../orders"
+	"../ticks"
+)
+
+func at(minute int) time.Time {
+	return time.Date(2014, 3, 3, 9, minute, 0, 0, time.UTC)
+}
+
+func tick(minute int, bid float64) *ticks.MarketTick {
+	return &ticks.MarketTick{Symbol: "EURUSD", Time: at(minute), OpenBid: bid, OpenAsk: bid + 0.0002}
+}
+
+// trade journals a 1 lot buy from 1.37000 to 1.37100, 8 pips or $80 after the spread
+func trade(j *Journal, id int64, balance float64) float64 {
+	o := &orders.Order{ID: id, Symbol: "EURUSD", Direction: orders.BUY, LotSize: 1.0, OpenedAt: at(0)}
+	o.OpenPrice, o.OpenBid, o.OpenAsk, o.BalanceAtOpen = 1.37020, 1.37000, 1.37020, balance
+	o.Ticks.PushBack(tick(0, 1.37000))
+	o.SetStopLoss(20)
+
+	j.Record(Request(ORDER_SUBMITTED, at(0), "EURUSD", orders.BUY, 1.0, 20, 0))
+	j.Record(Filled(o))
+
+	o.Ticks.PushBack(tick(5, 1.37100))
+	o.ClosedAt, o.ClosePrice, o.CloseReason = at(5), 1.37100, orders.CLOSED_BY_STRATEGY
+	o.BalanceAtClose = balance + o.Profit()
+	j.Record(Closed(o))
+
+	return o.BalanceAtClose
+}
+
+// journal two trades, finishing with the balance off by finalError
+func journal(finalError float64) string {
+	var buffer bytes.Buffer
+	j := NewWithDeets(&buffer)
+
+	j.Record(Entry{At: at(0), Type: ACCOUNT_OPENED, Balance: 10000.0})
+	balance := trade(j, 1, 10000.0)
+	balance = trade(j, 2, balance)
+	j.Record(Entry{At: at(6), Type: CUSTOM, Name: "skipped", Data: map[string]interface{}{"spread": 2.5}})
+	j.Record(Entry{At: at(6), Type: FINAL_STATE, Balance: balance + finalError, Trades: 2})
+	j.Close()
+
+	return buffer.String()
+}
+
+func TestReplay(t *testing.T) {
+	rp := Replay(strings.NewReader(journal(0.0)))
+	if !rp.Verified() {
+		t.Fatalf("expected the journal to replay cleanly, got %v", rp.Problems)
+	}
+
+	acc, _ := rp.Account("")
+	if 2 != acc.Trades || 2 != acc.Winners || math.Abs(160.0 - acc.Profit) > TOLERANCE || 1 != acc.Events[CUSTOM] {
+		t.Errorf("expected 2 winners making $160, got %+v", acc)
+	}
+
+	if rp := Replay(strings.NewReader(journal(0.01))); 1 != len(rp.Problems) {
+		t.Errorf("expected the final balance being a cent out to be caught, got %v", rp.Problems)
+	}
+
+	// and so is a missing close
+	lines := strings.Split(journal(0.0), "\n")
+	missing := strings.Join(append(lines[:3:3], lines[4:]...), "\n")
+	if rp := Replay(strings.NewReader(missing)); rp.Verified() {
+		t.Errorf("expected a missing entry to be caught")
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "run.jsonl")
+
+	j := NewFile(path)
+	j.Record(Entry{At: at(0), Type: ACCOUNT_OPENED, Balance: 10000.0})
+	balance := trade(j, 1, 10000.0)
+
+	saved := j.State()
+
+	// written after the checkpoint, then thrown away when it's resumed
+	trade(j, 2, balance)
+	j.Close()
+
+	resumed := NewFile(filepath.Join(dir, "elsewhere.jsonl"))
+	resumed.Restore(saved)
+	balance = trade(resumed, 2, balance)
+	resumed.Record(Entry{At: at(6), Type: FINAL_STATE, Balance: balance, Trades: 2})
+	resumed.Close()
+
+	rp := ReplayFile(path)
+	if !rp.Verified() || 8 != rp.Entries {
+		t.Errorf("expected the resumed journal to carry on from the checkpoint, got %d entries: %v", rp.Entries, rp.Problems)
+	}
+}
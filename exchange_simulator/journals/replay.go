@@ -0,0 +1,249 @@
+package journals
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+
+	"../utils"
+)
+
+// ===== REPLAY ====================================================================================
+
+// balances are checked to the cent
+const TOLERANCE = 0.005
+
+// Replay rebuilds every account in a journal from its fills and closes, checking each step and the
+// final state against what the run recorded
+func Replay(r io.Reader) *Replayed {
+	rp := &Replayed{byName: make(map[string]*AccountState)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64 * 1024), 16 * 1024 * 1024)
+
+	for scanner.Scan() {
+		if 0 == len(scanner.Bytes()) {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			rp.problem("line %d isn't an entry: %s", rp.Entries + 1, err)
+			break
+		}
+
+		rp.apply(&e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		rp.problem("can't read the journal: %s", err)
+	}
+
+	for _, acc := range rp.Accounts {
+		if nil == acc.Final {
+			rp.problem("%s has no final state, the run didn't finish", acc.Name)
+		}
+	}
+
+	return rp
+}
+
+func ReplayFile(path string) *Replayed {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+
+	return Replay(f)
+}
+
+type Replayed struct {
+	Accounts []*AccountState // in the order they were opened
+	Entries  int64
+	Problems []string
+
+	byName map[string]*AccountState
+}
+
+type AccountState struct {
+	Name    string
+	Deposit float64
+	Balance float64
+
+	Open map[int64]*Entry // fills, by order
+
+	Trades     int64
+	Winners    int64
+	Profit     float64
+	Commission float64
+
+	Submitted    int64
+	Rejected     int64
+	MarginCalled bool
+	Events       map[EventType]int64
+
+	Final *Entry
+}
+
+func (rp *Replayed) Verified() bool {
+	return 0 == len(rp.Problems)
+}
+
+func (rp *Replayed) Account(name string) (*AccountState, bool) {
+	acc, ok := rp.byName[name]
+	return acc, ok
+}
+
+func (rp *Replayed) problem(format string, args ...interface{}) {
+	rp.Problems = append(rp.Problems, fmt.Sprintf(format, args...))
+}
+
+func (rp *Replayed) apply(e *Entry) {
+	rp.Entries += 1
+
+	if e.Seq != rp.Entries {
+		rp.problem("entry %d has seq %d, entries are missing or out of order", rp.Entries, e.Seq)
+	}
+
+	acc, ok := rp.byName[e.Account]
+
+	if ACCOUNT_OPENED == e.Type {
+		if ok {
+			rp.problem("seq %d: %s opened twice", e.Seq, e.Account)
+			return
+		}
+
+		acc = &AccountState{
+			Name:    e.Account,
+			Deposit: e.Balance,
+			Balance: e.Balance,
+			Open:    make(map[int64]*Entry),
+			Events:  make(map[EventType]int64),
+		}
+
+		rp.byName[e.Account] = acc
+		rp.Accounts = append(rp.Accounts, acc)
+	}
+
+	if nil == acc {
+		rp.problem("seq %d: %s %s before the account was opened", e.Seq, e.Account, e.Type)
+		return
+	}
+
+	acc.Events[e.Type] += 1
+
+	switch e.Type {
+	case ORDER_SUBMITTED:
+		acc.Submitted += 1
+	case ORDER_REJECTED:
+		acc.Rejected += 1
+	case ORDER_FILLED:
+		if _, open := acc.Open[e.Order]; open {
+			rp.problem("seq %d: order %d filled twice", e.Seq, e.Order)
+		}
+
+		acc.Open[e.Order] = e
+		acc.Trades += 1
+	case STOPS_MOVED:
+		if _, open := acc.Open[e.Order]; !open {
+			rp.problem("seq %d: stops moved on order %d, which isn't open", e.Seq, e.Order)
+		}
+	case ORDER_CLOSED:
+		if _, open := acc.Open[e.Order]; !open {
+			rp.problem("seq %d: order %d closed, but it isn't open", e.Seq, e.Order)
+		}
+
+		delete(acc.Open, e.Order)
+
+		acc.Balance += e.Profit
+		acc.Balance += e.Commission
+		acc.Profit += e.Profit
+		acc.Commission += e.Commission
+
+		if e.Profit > 0.0 {
+			acc.Winners += 1
+		}
+
+		if math.Abs(acc.Balance - e.Balance) > TOLERANCE {
+			rp.problem(
+				"seq %d: order %d closed with a balance of %s, replayed it's %s",
+				e.Seq,
+				e.Order,
+				utils.FormatMoney(e.Balance),
+				utils.FormatMoney(acc.Balance),
+			)
+		}
+	case MARGIN_CALL:
+		acc.MarginCalled = true
+	case FINAL_STATE:
+		acc.Final = e
+
+		if math.Abs(acc.Balance - e.Balance) > TOLERANCE {
+			rp.problem(
+				"%s finished with a balance of %s, replayed it's %s",
+				acc.Name,
+				utils.FormatMoney(e.Balance),
+				utils.FormatMoney(acc.Balance),
+			)
+		}
+
+		if acc.Trades != e.Trades {
+			rp.problem("%s finished with %d trades, replayed there were %d", acc.Name, e.Trades, acc.Trades)
+		}
+
+		if int64(len(acc.Open)) != e.OpenOrders {
+			rp.problem("%s finished with %d open orders, replayed there are %d", acc.Name, e.OpenOrders, len(acc.Open))
+		}
+	}
+}
+
+func (rp *Replayed) PrintSummary() {
+	fmt.Printf("***** REPLAY *****\n\n")
+	fmt.Printf("Entries: %s\n\n", utils.AddCommas(rp.Entries))
+
+	for _, acc := range rp.Accounts {
+		fmt.Println("Name:", acc.Name)
+		fmt.Printf(
+			"Deposit: %s, Balance: %s, Profit: %s, Commission paid: %s\n",
+			utils.FormatMoney(acc.Deposit),
+			utils.FormatMoney(acc.Balance),
+			utils.FormatMoney(acc.Profit),
+			utils.FormatMoney(acc.Commission),
+		)
+		fmt.Printf(
+			"Orders submitted: %d, Rejected: %d, Filled: %d, Winners: %d, Still open: %d\n",
+			acc.Submitted,
+			acc.Rejected,
+			acc.Trades,
+			acc.Winners,
+			len(acc.Open),
+		)
+
+		if acc.MarginCalled {
+			fmt.Println("Margin called")
+		}
+
+		if n := acc.Events[CUSTOM]; n > 0 {
+			fmt.Printf("Custom events: %d\n", n)
+		}
+
+		fmt.Println("")
+	}
+
+	if rp.Verified() {
+		fmt.Printf("Verified against the run's final state\n\n")
+		return
+	}
+
+	fmt.Printf("%d problems:\n", len(rp.Problems))
+	for _, p := range rp.Problems {
+		fmt.Printf("  %s\n", p)
+	}
+
+	fmt.Println("")
+}
@@ -0,0 +1,292 @@
+package loggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===== LEVELS ====================================================================================
+
+type Level int64
+
+const (
+	DEBUG = Level(0)
+	INFO  = Level(1)
+	WARN  = Level(2)
+	ERROR = Level(3)
+	OFF   = Level(4)
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, bool) {
+	for i, name := range levelNames {
+		if strings.ToLower(s) == name {
+			return Level(i), true
+		}
+	}
+
+	return INFO, false
+}
+
+// ===== OUTPUT ====================================================================================
+
+type Format string
+
+const (
+	TEXT = Format("text")
+	JSON = Format("json")
+)
+
+type output struct {
+	w      io.Writer
+	file   *os.File
+	level  Level
+	format Format
+
+	// algorithms log concurrently
+	mutex sync.Mutex
+}
+
+// what every Component logs to, see Configure
+var standard = &output{w: os.Stdout, level: INFO, format: TEXT}
+
+// Configure sets the level and format for every component, and sends them to path, which is
+// created (or truncated), or to stdout if it's empty. Until it's called it's text to stdout, info
+// and up.
+func Configure(level Level, format Format, path string) {
+	standard.mutex.Lock()
+	defer standard.mutex.Unlock()
+
+	standard.close()
+
+	standard.w = os.Stdout
+	standard.level = level
+	standard.format = format
+
+	if "" != path {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatalln("can't open log: " + err.Error())
+		}
+
+		standard.w = f
+		standard.file = f
+	}
+}
+
+// Close closes the file Configure opened, if it did
+func Close() {
+	standard.mutex.Lock()
+	defer standard.mutex.Unlock()
+
+	standard.close()
+	standard.w = os.Stdout
+}
+
+func (o *output) close() {
+	if nil == o.file {
+		return
+	}
+
+	if err := o.file.Close(); err != nil {
+		log.Fatalln(err)
+	}
+
+	o.file = nil
+}
+
+// ===== LOGGERS ===================================================================================
+
+// Component is a logger for a part of the engine, e.g. loggers.Component("exchanges"), logging
+// wherever Configure says
+func Component(name string) *Logger {
+	return &Logger{out: standard, fields: []interface{}{"component", name}}
+}
+
+// NewWithDeets is a logger with an output of its own, rather than the one Configure sets up
+func NewWithDeets(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w, level: level, format: format}}
+}
+
+// A Logger writes a line per message: its fields, then the message's own key, value pairs, e.g.
+//
+//   l := loggers.Component("algorithms").With("algorithm", "EURUSD trend")
+//   l.At(tick.Time).Warn("margin available below minimum", "margin", 95.0)
+//
+// Lines are stamped with simulated time, from At or Clock, rather than the wall clock.
+type Logger struct {
+	out    *output
+	fields []interface{}
+	clock  func() time.Time
+}
+
+// With is a logger with more fields, which come in key, value pairs
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	pairs(keyvals)
+
+	fields := make([]interface{}, 0, len(l.fields) + len(keyvals))
+	fields = append(fields, l.fields...)
+
+	return &Logger{out: l.out, fields: append(fields, keyvals...), clock: l.clock}
+}
+
+// Clock stamps lines with whatever now returns at the time, e.g. the time of the tick an
+// algorithm's dealing with
+func (l *Logger) Clock(now func() time.Time) *Logger {
+	return &Logger{out: l.out, fields: l.fields, clock: now}
+}
+
+func (l *Logger) At(t time.Time) *Logger {
+	return l.Clock(func() time.Time { return t })
+}
+
+// Enabled is for skipping the work of logging something that'd be thrown away
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(DEBUG, msg, keyvals)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(INFO, msg, keyvals)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(WARN, msg, keyvals)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(ERROR, msg, keyvals)
+}
+
+func pairs(keyvals []interface{}) {
+	if 0 != len(keyvals) % 2 {
+		panic(fmt.Sprintf("log fields come in key, value pairs (got: %v)", keyvals))
+	}
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	pairs(keyvals)
+
+	var at time.Time
+	if nil != l.clock {
+		at = l.clock()
+	}
+
+	fields := append(append([]interface{}{}, l.fields...), keyvals...)
+
+	var line string
+	if JSON == l.out.format {
+		line = jsonLine(at, level, msg, fields)
+	} else {
+		line = textLine(at, level, msg, fields)
+	}
+
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+
+	if _, err := io.WriteString(l.out.w, line + "\n"); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// ----- FORMATS -----------------------------------------------------------------------------------
+
+// textLine is e.g.
+//
+//   2014-03-03 09:00:00 WARN  margin available below minimum  component=algorithms margin=95
+func textLine(at time.Time, level Level, msg string, fields []interface{}) string {
+	line := fmt.Sprintf("%-5s %s", strings.ToUpper(level.String()), msg)
+
+	if !at.IsZero() {
+		line = at.Format("2006-01-02 15:04:05 ") + line
+	}
+
+	if 0 != len(fields) {
+		line += " "
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		line += fmt.Sprintf(" %v=%s", fields[i], textValue(fields[i + 1]))
+	}
+
+	return line
+}
+
+func textValue(v interface{}) string {
+	var s string
+
+	switch v := v.(type) {
+	case time.Time:
+		s = v.Format("2006-01-02 15:04:05")
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if "" == s || strings.ContainsAny(s, " =\"") {
+		return fmt.Sprintf("%q", s)
+	}
+
+	return s
+}
+
+func jsonLine(at time.Time, level Level, msg string, fields []interface{}) string {
+	entry := map[string]interface{}{"level": level.String(), "msg": msg}
+
+	if !at.IsZero() {
+		entry["at"] = at
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		entry[fmt.Sprint(fields[i])] = jsonValue(fields[i + 1])
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Fatalln("can't log " + msg + ": " + err.Error())
+	}
+
+	return string(line)
+}
+
+// jsonValue leaves whatever encoding/json can write as it is, and writes the rest as text
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string, int, int64, time.Time:
+		return v
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprint(v)
+		}
+
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return v
+}
@@ -0,0 +1,74 @@
+package loggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevels(t *testing.T) {
+	var buffer bytes.Buffer
+	l := NewWithDeets(&buffer, WARN, TEXT)
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	if "WARN  warn\nERROR error\n" != buffer.String() {
+		t.Errorf("expected warn and up, got %q", buffer.String())
+	}
+
+	if level, ok := ParseLevel("Debug"); !ok || DEBUG != level {
+		t.Errorf("expected debug, got %s", level)
+	}
+}
+
+func TestText(t *testing.T) {
+	var buffer bytes.Buffer
+	at := time.Date(2014, 3, 3, 9, 30, 0, 0, time.UTC)
+
+	l := NewWithDeets(&buffer, DEBUG, TEXT).With("algorithm", "EURUSD trend")
+
+	// fields added to one logger don't end up on another made from the same one
+	a := l.With("strategy", "a")
+	l.With("strategy", "b")
+
+	a.At(at).Info("closing", "equity", 9950.5, "reason", "")
+
+	expected := `2014-03-03 09:30:00 INFO  closing  algorithm="EURUSD trend" strategy=a equity=9950.5 reason=""` + "\n"
+	if expected != buffer.String() {
+		t.Errorf("expected:\n%s\nGot:\n%s", expected, buffer.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	now := time.Date(2014, 3, 3, 9, 30, 0, 0, time.UTC)
+
+	l := NewWithDeets(&buffer, DEBUG, JSON).With("component", "algorithms").Clock(func() time.Time { return now })
+	l.Warn("margin available is below minimum", "margin", 95.0, "every", time.Hour)
+
+	now = now.Add(time.Minute)
+	l.Debug("later")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if 2 != len(lines) {
+		t.Fatalf("expected 2 lines, got %q", buffer.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if "warn" != entry["level"] || "algorithms" != entry["component"] || 95.0 != entry["margin"] || "1h0m0s" != entry["every"] {
+		t.Errorf("expected the fields, got %v", entry)
+	}
+
+	if !strings.Contains(lines[1], `"at":"2014-03-03T09:31:00Z"`) {
+		t.Errorf("expected the clock to be read when logging, got %s", lines[1])
+	}
+}
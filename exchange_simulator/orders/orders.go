@@ -23,10 +23,27 @@ const (
 	SELL = TradeDirection(-1)
 )
 
+// ===== CLOSE REASONS =============================================================================
+
+type CloseReason string
+
+const (
+	CLOSED_BY_STRATEGY    = CloseReason("strategy")
+	CLOSED_BY_STOP_LOSS   = CloseReason("stop_loss")
+	CLOSED_BY_TAKE_PROFIT = CloseReason("take_profit")
+	CLOSED_BY_DRAWDOWN    = CloseReason("drawdown")
+	CLOSED_BY_MARGIN_CALL = CloseReason("margin_call")
+	CLOSED_AT_END         = CloseReason("end_of_run")
+
+	CLOSED_BY_CIRCUIT_BREAKER = CloseReason("circuit_breaker")
+)
+
 // ===== ORDERS ====================================================================================
 
 type Order struct {
+	ID     int64  // from the exchange, in the order they were filled
 	Symbol string
+	Tag    string // the portfolio strategy that opened it, see algorithms.NewPortfolio
 
 	OpenedAt          time.Time
 	ClosedAt          time.Time
@@ -47,6 +64,11 @@ type Order struct {
 	StopLossHit   bool
 	TakeProfitHit bool
 
+	// set before closing, whoever closes the order without one is the strategy
+	CloseReason CloseReason
+
+	onStopsModified []func(*Order)
+
 	// for calculating spreads and actual slippage
 	OpenBid  float64
 	OpenAsk  float64
@@ -182,11 +204,16 @@ func (o *Order) PrintDetails() {
 		o.LowestAsk,
 	)
 	fmt.Printf(
-		"DD @ open/close: %.2f%%/%.2f%%\n",
+		"DD @ open/close: %.2f%%/%.2f%%, Closed by: %s\n",
 		o.DrawdownAtOpen,
 		o.DrawdownAtClose,
+		o.CloseReason,
 	)
 
+	if "" != o.Tag {
+		fmt.Printf("Strategy: %s\n", o.Tag)
+	}
+
 	fmt.Printf("Ticks: %d\n", o.Ticks.Len())
 	count := int64(1)
 
@@ -359,8 +386,8 @@ func (o *Order) GetStopLoss() stops.StopLoss {
 }
 
 func (o *Order) SetStopLoss(p pips.Pip) {
-
 	o.stopLoss = append(o.stopLoss, stops.NewStopLoss(p))
+	o.stopsModified()
 }
 
 func (o *Order) GetTakeProfit() stops.TakeProfit {
@@ -373,6 +400,18 @@ func (o *Order) GetTakeProfit() stops.TakeProfit {
 
 func (o *Order) SetTakeProfit(p pips.Pip) {
 	o.takeProfit = append(o.takeProfit, stops.NewTakeProfit(p))
+	o.stopsModified()
+}
+
+// OnStopsModified registers f to be called whenever the stop loss or take profit is set
+func (o *Order) OnStopsModified(f func(*Order)) {
+	o.onStopsModified = append(o.onStopsModified, f)
+}
+
+func (o *Order) stopsModified() {
+	for _, f := range o.onStopsModified {
+		f(o)
+	}
 }
 
 func (o *Order) StopLossPrice() float64 {
@@ -414,9 +453,11 @@ func (o *Order) checkStopLoss(tick *ticks.MarketTick) bool {
 
 	if o.IsBuy() && o.StopLossPrice() >= tick.OpenBid {
 		o.StopLossHit = true
+		o.CloseReason = CLOSED_BY_STOP_LOSS
 		return true
 	} else if o.IsSell() && o.StopLossPrice() <= o.OpenAsk {
 		o.StopLossHit = true
+		o.CloseReason = CLOSED_BY_STOP_LOSS
 		return true
 	}
 
@@ -430,9 +471,11 @@ func (o *Order) checkTakeProfit(tick *ticks.MarketTick) bool {
 
 	if o.IsBuy() && o.TakeProfitPrice() <= tick.OpenBid {
 		o.TakeProfitHit = true
+		o.CloseReason = CLOSED_BY_TAKE_PROFIT
 		return true
 	} else if o.IsSell() && o.TakeProfitPrice() >= tick.OpenAsk {
 		o.TakeProfitHit = true
+		o.CloseReason = CLOSED_BY_TAKE_PROFIT
 		return true
 	}
 
@@ -442,3 +485,204 @@ func (o *Order) checkTakeProfit(tick *ticks.MarketTick) bool {
 func (o *Order) ProcessStops(tick *ticks.MarketTick) bool {
 	return o.checkStopLoss(tick) || o.checkTakeProfit(tick)
 }
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	ticks.KeepMetadata("percent_to_tp", "percent_to_sl")
+}
+
+// State is an order as it's saved in a checkpoint. Whoever's told about its stops changing has to
+// register again, see OnStopsModified.
+type State struct {
+	ID     int64
+	Symbol string
+	Tag    string
+
+	OpenedAt time.Time
+	ClosedAt time.Time
+
+	OpenPrice         float64
+	ClosePrice        float64
+	DesiredOpenPrice  float64
+	DesiredClosePrice float64
+
+	Direction TradeDirection
+	LotSize   float64
+
+	AllowedSlippage float64
+
+	StopLoss   []stops.StopLoss // every one it's had, the last is the current one
+	TakeProfit []stops.TakeProfit
+
+	StopLossHit   bool
+	TakeProfitHit bool
+	CloseReason   CloseReason
+
+	OpenBid  float64
+	OpenAsk  float64
+	CloseBid float64
+	CloseAsk float64
+
+	EquityAtOpen   float64
+	EquityAtClose  float64
+	BalanceAtOpen  float64
+	BalanceAtClose float64
+
+	LowestBid  float64
+	LowestAsk  float64
+	HighestBid float64
+	HighestAsk float64
+
+	OrdersOpenAtOpen  int64
+	OrdersOpenAtClose int64
+
+	DrawdownAtOpen  float64
+	DrawdownAtClose float64
+
+	ClosestPercentageToTakeProfit float64
+	ClosestPercentageToStopLoss   float64
+
+	Ticks        []*ticks.State
+	LeadingTicks []*ticks.State // see Algorithm.CloneLeadingTicksOntoOrder
+
+	Metadata map[string]interface{} // see ticks.KeepMetadata
+}
+
+func (o *Order) State() State {
+	s := State{
+		ID:     o.ID,
+		Symbol: o.Symbol,
+		Tag:    o.Tag,
+
+		OpenedAt: o.OpenedAt,
+		ClosedAt: o.ClosedAt,
+
+		OpenPrice:         o.OpenPrice,
+		ClosePrice:        o.ClosePrice,
+		DesiredOpenPrice:  o.DesiredOpenPrice,
+		DesiredClosePrice: o.DesiredClosePrice,
+
+		Direction: o.Direction,
+		LotSize:   o.LotSize,
+
+		AllowedSlippage: o.AllowedSlippage,
+
+		StopLoss:   append([]stops.StopLoss{}, o.stopLoss...),
+		TakeProfit: append([]stops.TakeProfit{}, o.takeProfit...),
+
+		StopLossHit:   o.StopLossHit,
+		TakeProfitHit: o.TakeProfitHit,
+		CloseReason:   o.CloseReason,
+
+		OpenBid:  o.OpenBid,
+		OpenAsk:  o.OpenAsk,
+		CloseBid: o.CloseBid,
+		CloseAsk: o.CloseAsk,
+
+		EquityAtOpen:   o.EquityAtOpen,
+		EquityAtClose:  o.EquityAtClose,
+		BalanceAtOpen:  o.BalanceAtOpen,
+		BalanceAtClose: o.BalanceAtClose,
+
+		LowestBid:  o.LowestBid,
+		LowestAsk:  o.LowestAsk,
+		HighestBid: o.HighestBid,
+		HighestAsk: o.HighestAsk,
+
+		OrdersOpenAtOpen:  o.OrdersOpenAtOpen,
+		OrdersOpenAtClose: o.OrdersOpenAtClose,
+
+		DrawdownAtOpen:  o.DrawdownAtOpen,
+		DrawdownAtClose: o.DrawdownAtClose,
+
+		ClosestPercentageToTakeProfit: o.ClosestPercentageToTakeProfit,
+		ClosestPercentageToStopLoss:   o.ClosestPercentageToStopLoss,
+
+		Metadata: ticks.SaveMetadata(&o.Metadata),
+	}
+
+	for el := o.Ticks.Front(); el != nil; el = el.Next() {
+		s.Ticks = append(s.Ticks, el.Value.(*ticks.MarketTick).State())
+	}
+
+	if leading, ok := o.Metadata.Get("leading_ticks"); ok {
+		l := leading.(list.List)
+
+		for el := l.Front(); el != nil; el = el.Next() {
+			s.LeadingTicks = append(s.LeadingTicks, el.Value.(*ticks.MarketTick).State())
+		}
+	}
+
+	return s
+}
+
+// Order is a new order from s
+func (s State) Order() *Order {
+	o := &Order{
+		ID:     s.ID,
+		Symbol: s.Symbol,
+		Tag:    s.Tag,
+
+		OpenedAt: s.OpenedAt,
+		ClosedAt: s.ClosedAt,
+
+		OpenPrice:         s.OpenPrice,
+		ClosePrice:        s.ClosePrice,
+		DesiredOpenPrice:  s.DesiredOpenPrice,
+		DesiredClosePrice: s.DesiredClosePrice,
+
+		Direction: s.Direction,
+		LotSize:   s.LotSize,
+
+		AllowedSlippage: s.AllowedSlippage,
+
+		stopLoss:   s.StopLoss,
+		takeProfit: s.TakeProfit,
+
+		StopLossHit:   s.StopLossHit,
+		TakeProfitHit: s.TakeProfitHit,
+		CloseReason:   s.CloseReason,
+
+		OpenBid:  s.OpenBid,
+		OpenAsk:  s.OpenAsk,
+		CloseBid: s.CloseBid,
+		CloseAsk: s.CloseAsk,
+
+		EquityAtOpen:   s.EquityAtOpen,
+		EquityAtClose:  s.EquityAtClose,
+		BalanceAtOpen:  s.BalanceAtOpen,
+		BalanceAtClose: s.BalanceAtClose,
+
+		LowestBid:  s.LowestBid,
+		LowestAsk:  s.LowestAsk,
+		HighestBid: s.HighestBid,
+		HighestAsk: s.HighestAsk,
+
+		OrdersOpenAtOpen:  s.OrdersOpenAtOpen,
+		OrdersOpenAtClose: s.OrdersOpenAtClose,
+
+		DrawdownAtOpen:  s.DrawdownAtOpen,
+		DrawdownAtClose: s.DrawdownAtClose,
+
+		ClosestPercentageToTakeProfit: s.ClosestPercentageToTakeProfit,
+		ClosestPercentageToStopLoss:   s.ClosestPercentageToStopLoss,
+	}
+
+	for _, t := range s.Ticks {
+		o.Ticks.PushBack(t.Tick())
+	}
+
+	ticks.RestoreMetadata(&o.Metadata, s.Metadata)
+
+	if nil != s.LeadingTicks {
+		var leading list.List
+		for _, t := range s.LeadingTicks {
+			leading.PushBack(t.Tick())
+		}
+
+		o.Metadata.Set("leading_ticks", leading)
+	}
+
+	return o
+}
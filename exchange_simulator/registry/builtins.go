@@ -0,0 +1,191 @@
+package registry
+
+import (
+	td "../../ga/trading_decisions"
+
+	"../indicators"
+	"../indicators/close_location_values"
+	"../indicators/cross_symbol"
+	"../indicators/moving_averages"
+	"../indicators/oscillators"
+	"../indicators/spread_velocity"
+	"../indicators/steve_turn_detector"
+	"../indicators/trend"
+	"../indicators/volatility"
+	"../indicators/volume_velocity"
+)
+
+// ===== BUILT IN INDICATORS =======================================================================
+
+func init() {
+	// ----- moving averages -----------------------------------------------------------------------
+
+	RegisterIndicator("sma", "simple moving average of closes",
+		Schema{IntParam("periods", 20, "bars averaged")},
+		func(p Params) indicators.Indicator { return moving_averages.NewSMA(p.Int("periods")) })
+
+	RegisterIndicator("ema", "exponential moving average of closes",
+		Schema{IntParam("periods", 20, "bars averaged, the first value is their SMA")},
+		func(p Params) indicators.Indicator { return moving_averages.NewEMA(p.Int("periods")) })
+
+	RegisterIndicator("wma", "linearly weighted moving average of closes",
+		Schema{IntParam("periods", 20, "bars averaged")},
+		func(p Params) indicators.Indicator { return moving_averages.NewWMA(p.Int("periods")) })
+
+	// ----- oscillators ---------------------------------------------------------------------------
+
+	RegisterIndicator("rsi", "Wilder's relative strength index",
+		Schema{IntParam("periods", 14, "smoothing periods")},
+		func(p Params) indicators.Indicator { return oscillators.NewRSI(p.Int("periods")) })
+
+	RegisterIndicator("macd", "moving average convergence divergence",
+		Schema{
+			IntParam("fast", 12, "fast EMA periods"),
+			IntParam("slow", 26, "slow EMA periods"),
+			IntParam("signal", 9, "signal line EMA periods"),
+		},
+		func(p Params) indicators.Indicator {
+			return oscillators.NewMACD(p.Int("fast"), p.Int("slow"), p.Int("signal"))
+		})
+
+	RegisterIndicator("stochastic", "stochastic oscillator",
+		Schema{
+			IntParam("k", 14, "%K periods"),
+			IntParam("d", 3, "%D periods, an SMA of %K"),
+		},
+		func(p Params) indicators.Indicator { return oscillators.NewStochastic(p.Int("k"), p.Int("d")) })
+
+	RegisterIndicator("cci", "commodity channel index",
+		Schema{IntParam("periods", 20, "typical price periods")},
+		func(p Params) indicators.Indicator { return oscillators.NewCCI(p.Int("periods")) })
+
+	// ----- volatility ----------------------------------------------------------------------------
+
+	RegisterIndicator("bollinger", "Bollinger bands",
+		Schema{
+			IntParam("periods", 20, "SMA periods"),
+			FloatParam("deviations", 2.0, "standard deviations to the bands"),
+		},
+		func(p Params) indicators.Indicator {
+			return volatility.NewBollingerBands(p.Int("periods"), p.Float("deviations"))
+		})
+
+	RegisterIndicator("atr", "average true range",
+		Schema{IntParam("periods", 14, "smoothing periods")},
+		func(p Params) indicators.Indicator { return volatility.NewATR(p.Int("periods")) })
+
+	RegisterIndicator("keltner", "Keltner channels",
+		Schema{
+			IntParam("ema_periods", 20, "middle line EMA periods"),
+			IntParam("atr_periods", 10, "ATR periods"),
+			FloatParam("multiplier", 2.0, "ATRs to the channels"),
+		},
+		func(p Params) indicators.Indicator {
+			return volatility.NewKeltnerChannels(p.Int("ema_periods"), p.Int("atr_periods"), p.Float("multiplier"))
+		})
+
+	RegisterIndicator("donchian", "Donchian channels",
+		Schema{IntParam("periods", 20, "bars for the highest high and lowest low")},
+		func(p Params) indicators.Indicator { return volatility.NewDonchianChannels(p.Int("periods")) })
+
+	// ----- trend ---------------------------------------------------------------------------------
+
+	RegisterIndicator("adx", "average directional index",
+		Schema{IntParam("periods", 14, "smoothing periods")},
+		func(p Params) indicators.Indicator { return trend.NewADX(p.Int("periods")) })
+
+	RegisterIndicator("psar", "parabolic SAR",
+		Schema{
+			FloatParam("step", 0.02, "acceleration factor step"),
+			FloatParam("max", 0.2, "maximum acceleration factor"),
+		},
+		func(p Params) indicators.Indicator { return trend.NewParabolicSAR(p.Float("step"), p.Float("max")) })
+
+	RegisterIndicator("ichimoku", "Ichimoku cloud",
+		Schema{
+			IntParam("tenkan", 9, "conversion line periods"),
+			IntParam("kijun", 26, "base line periods"),
+			IntParam("senkou_b", 52, "leading span B periods"),
+		},
+		func(p Params) indicators.Indicator {
+			return trend.NewIchimoku(p.Int("tenkan"), p.Int("kijun"), p.Int("senkou_b"))
+		})
+
+	RegisterIndicator("clv", "close location value",
+		Schema{},
+		func(p Params) indicators.Indicator { return close_location_values.NewCLV() })
+
+	// ----- steve's -------------------------------------------------------------------------------
+
+	RegisterIndicator("std", "Steve's turn detector",
+		Schema{
+			FloatParam("lower", 0.9969, "turning up below this"),
+			FloatParam("upper", 0.9975, "turning down above this"),
+		},
+		func(p Params) indicators.Indicator { return steve_turn_detector.NewSTD(p.Float("lower"), p.Float("upper")) })
+
+	RegisterIndicator("sv", "spread velocity",
+		Schema{IntParam("candles", 5, "candles the spread is compared over")},
+		func(p Params) indicators.Indicator { return spread_velocity.NewSV(p.Int("candles")) })
+
+	RegisterIndicator("vv", "volume velocity",
+		Schema{
+			IntParam("candles", 5, "candles the volume is compared over"),
+			FloatParam("change", 0.33, "fractional change that counts as increasing"),
+		},
+		func(p Params) indicators.Indicator { return volume_velocity.NewVV(p.Int("candles"), p.Float("change")) })
+
+	// ----- cross symbol --------------------------------------------------------------------------
+
+	RegisterIndicator("correlation", "correlation of two symbols' returns",
+		Schema{
+			StringParam("a", "", "first symbol"),
+			StringParam("b", "", "second symbol"),
+			IntParam("periods", 20, "bars correlated"),
+		},
+		func(p Params) indicators.Indicator {
+			return cross_symbol.NewCorrelation(p.String("a"), p.String("b"), p.Int("periods"))
+		})
+
+	RegisterIndicator("spread", "a - hedge_ratio * b, with its z-score",
+		Schema{
+			StringParam("a", "", "first symbol"),
+			StringParam("b", "", "second symbol"),
+			FloatParam("hedge_ratio", 1.0, "units of b per unit of a"),
+			IntParam("periods", 20, "z-score periods"),
+		},
+		func(p Params) indicators.Indicator {
+			return cross_symbol.NewSpread(p.String("a"), p.String("b"), p.Float("hedge_ratio"), p.Int("periods"))
+		})
+
+	RegisterIndicator("ratio", "a / b, with its z-score",
+		Schema{
+			StringParam("a", "", "first symbol"),
+			StringParam("b", "", "second symbol"),
+			IntParam("periods", 20, "z-score periods"),
+		},
+		func(p Params) indicators.Indicator {
+			return cross_symbol.NewRatio(p.String("a"), p.String("b"), p.Int("periods"))
+		})
+
+	RegisterIndicator("currency_strength", "strength of each currency across symbols",
+		Schema{
+			IntParam("periods", 20, "bars of returns"),
+			StringsParam("symbols", "symbols to measure across"),
+		},
+		func(p Params) indicators.Indicator {
+			return cross_symbol.NewCurrencyStrength(p.Int("periods"), p.Strings("symbols")...)
+		})
+}
+
+// ===== BUILT IN TRADING DECISIONS ================================================================
+
+func init() {
+	RegisterTradingDecision("std", "buys on a 60 minute crossover with a steady spread and volume",
+		Schema{},
+		func(p Params) td.TradingDecision { return td.NewSTD() })
+
+	RegisterTradingDecision("noop", "never trades, for testing",
+		Schema{},
+		func(p Params) td.TradingDecision { return &td.NOOPTrader{} })
+}
@@ -0,0 +1,342 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	td "../../ga/trading_decisions"
+
+	"../algorithms"
+	"../indicators"
+)
+
+// ===== PARAMETERS ================================================================================
+
+type Kind string
+
+const (
+	FLOAT   = Kind("number")
+	INT     = Kind("whole number")
+	STRING  = Kind("string")
+	BOOL    = Kind("true/false")
+	STRINGS = Kind("list of strings")
+)
+
+type Param struct {
+	Name    string
+	Kind    Kind
+	Default interface{} // as it would come out of JSON, so numbers are float64s
+	Doc     string
+}
+
+func FloatParam(name string, def float64, doc string) Param {
+	return Param{Name: name, Kind: FLOAT, Default: def, Doc: doc}
+}
+
+func IntParam(name string, def int64, doc string) Param {
+	return Param{Name: name, Kind: INT, Default: float64(def), Doc: doc}
+}
+
+func StringParam(name string, def string, doc string) Param {
+	return Param{Name: name, Kind: STRING, Default: def, Doc: doc}
+}
+
+func BoolParam(name string, def bool, doc string) Param {
+	return Param{Name: name, Kind: BOOL, Default: def, Doc: doc}
+}
+
+// StringsParam defaults to an empty list
+func StringsParam(name string, doc string) Param {
+	return Param{Name: name, Kind: STRINGS, Default: []interface{}{}, Doc: doc}
+}
+
+func (p Param) accepts(v interface{}) bool {
+	switch p.Kind {
+	case FLOAT:
+		_, ok := v.(float64)
+		return ok
+	case INT:
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case STRING:
+		_, ok := v.(string)
+		return ok
+	case BOOL:
+		_, ok := v.(bool)
+		return ok
+	case STRINGS:
+		list, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// DefaultString is the default the way it'd be written in a config
+func (p Param) DefaultString() string {
+	switch p.Kind {
+	case STRING:
+		return fmt.Sprintf("%q", p.Default)
+	case STRINGS:
+		return "[]"
+	}
+
+	return fmt.Sprintf("%v", p.Default)
+}
+
+type Schema []Param
+
+func (s Schema) param(name string) (Param, bool) {
+	for _, p := range s {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return Param{}, false
+}
+
+// Problems lists parameters the schema doesn't have or that are the wrong kind
+func (s Schema) Problems(params Params) []string {
+	problems := []string{}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p, ok := s.param(name)
+		if !ok {
+			problems = append(problems, "unknown parameter: " + name)
+			continue
+		}
+
+		if !p.accepts(params[name]) {
+			problems = append(problems, fmt.Sprintf("parameter %s should be a %s, got %v", name, p.Kind, params[name]))
+		}
+	}
+
+	return problems
+}
+
+// WithDefaults is params plus the defaults for anything missing
+func (s Schema) WithDefaults(params Params) Params {
+	res := make(Params)
+
+	for _, p := range s {
+		res[p.Name] = p.Default
+	}
+
+	for name, v := range params {
+		res[name] = v
+	}
+
+	return res
+}
+
+// Parameters straight from the JSON, so numbers are float64s. The registry fills in defaults and
+// checks kinds before a constructor sees them, so the getters just panic if something's off.
+type Params map[string]interface{}
+
+func (p Params) get(name string) interface{} {
+	v, exists := p[name]
+	if !exists {
+		panic("missing parameter: " + name)
+	}
+
+	return v
+}
+
+func (p Params) Float(name string) float64 {
+	return p.get(name).(float64)
+}
+
+func (p Params) Int(name string) int64 {
+	return int64(p.get(name).(float64))
+}
+
+func (p Params) String(name string) string {
+	return p.get(name).(string)
+}
+
+func (p Params) Bool(name string) bool {
+	return p.get(name).(bool)
+}
+
+func (p Params) Strings(name string) []string {
+	res := []string{}
+
+	for _, item := range p.get(name).([]interface{}) {
+		res = append(res, item.(string))
+	}
+
+	return res
+}
+
+// ===== COMPONENTS ================================================================================
+
+// What list and describe show about something registered
+type Component struct {
+	Name   string
+	Doc    string
+	Params Schema
+}
+
+func (c Component) Problems(params Params) []string {
+	return c.Params.Problems(params)
+}
+
+func (c Component) check(kind string, params Params) Params {
+	if problems := c.Problems(params); len(problems) > 0 {
+		panic(fmt.Sprintf("bad parameters for %s %s: %v", kind, c.Name, problems))
+	}
+
+	return c.Params.WithDefaults(params)
+}
+
+type strategy struct {
+	Component
+	f func(Params) algorithms.Strategy
+}
+
+type indicator struct {
+	Component
+	f func(Params) indicators.Indicator
+}
+
+type tradingDecision struct {
+	Component
+	f func(Params) td.TradingDecision
+}
+
+var (
+	strategies       = make(map[string]strategy)
+	indicatorTypes   = make(map[string]indicator)
+	tradingDecisions = make(map[string]tradingDecision)
+)
+
+func ensureNew(kind, name string, exists bool) {
+	if exists {
+		panic(kind + " already registered: " + name)
+	}
+}
+
+// ----- strategies --------------------------------------------------------------------------------
+
+func RegisterStrategy(name, doc string, params Schema, f func(Params) algorithms.Strategy) {
+	_, exists := strategies[name]
+	ensureNew("strategy", name, exists)
+
+	strategies[name] = strategy{Component{name, doc, params}, f}
+}
+
+func Strategy(name string) (Component, bool) {
+	s, ok := strategies[name]
+	return s.Component, ok
+}
+
+func NewStrategy(name string, params Params) algorithms.Strategy {
+	s, ok := strategies[name]
+	if !ok {
+		panic("unknown strategy: " + name)
+	}
+
+	return s.f(s.check("strategy", params))
+}
+
+func Strategies() []Component {
+	res := []Component{}
+	for _, s := range strategies {
+		res = append(res, s.Component)
+	}
+
+	return sorted(res)
+}
+
+// ----- indicators --------------------------------------------------------------------------------
+
+// RegisterIndicator adds an indicator type, the built in ones are in builtins.go
+func RegisterIndicator(name, doc string, params Schema, f func(Params) indicators.Indicator) {
+	_, exists := indicatorTypes[name]
+	ensureNew("indicator", name, exists)
+
+	indicatorTypes[name] = indicator{Component{name, doc, params}, f}
+}
+
+func Indicator(name string) (Component, bool) {
+	i, ok := indicatorTypes[name]
+	return i.Component, ok
+}
+
+func NewIndicator(name string, params Params) indicators.Indicator {
+	i, ok := indicatorTypes[name]
+	if !ok {
+		panic("unknown indicator: " + name)
+	}
+
+	return i.f(i.check("indicator", params))
+}
+
+func Indicators() []Component {
+	res := []Component{}
+	for _, i := range indicatorTypes {
+		res = append(res, i.Component)
+	}
+
+	return sorted(res)
+}
+
+// ----- trading decisions -------------------------------------------------------------------------
+
+func RegisterTradingDecision(name, doc string, params Schema, f func(Params) td.TradingDecision) {
+	_, exists := tradingDecisions[name]
+	ensureNew("trading decision", name, exists)
+
+	tradingDecisions[name] = tradingDecision{Component{name, doc, params}, f}
+}
+
+func TradingDecision(name string) (Component, bool) {
+	d, ok := tradingDecisions[name]
+	return d.Component, ok
+}
+
+func NewTradingDecision(name string, params Params) td.TradingDecision {
+	d, ok := tradingDecisions[name]
+	if !ok {
+		panic("unknown trading decision: " + name)
+	}
+
+	return d.f(d.check("trading decision", params))
+}
+
+func TradingDecisions() []Component {
+	res := []Component{}
+	for _, d := range tradingDecisions {
+		res = append(res, d.Component)
+	}
+
+	return sorted(res)
+}
+
+type byName []Component
+
+func (bn byName) Len() int           { return len(bn) }
+func (bn byName) Swap(i, j int)      { bn[i], bn[j] = bn[j], bn[i] }
+func (bn byName) Less(i, j int) bool { return bn[i].Name < bn[j].Name }
+
+func sorted(cs []Component) []Component {
+	sort.Sort(byName(cs))
+	return cs
+}
@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema{
+		IntParam("n", 3, ""),
+		FloatParam("f", 1.5, ""),
+		StringParam("s", "x", ""),
+		BoolParam("b", true, ""),
+		StringsParam("l", ""),
+	}
+
+	p := s.WithDefaults(Params{"f": 2.5, "l": []interface{}{"EURUSD", "GBPUSD"}})
+
+	if 3 != p.Int("n") || 2.5 != p.Float("f") || "x" != p.String("s") || !p.Bool("b") {
+		t.Errorf("defaults not applied: %v", p)
+	}
+
+	if l := p.Strings("l"); 2 != len(l) || "GBPUSD" != l[1] {
+		t.Errorf("Strings: %v", l)
+	}
+
+	problems := strings.Join(s.Problems(Params{"n": 1.5, "s": 1.0, "l": []interface{}{1.0}, "x": true}), "\n")
+
+	expected := []string{
+		"parameter n should be a whole number, got 1.5",
+		"parameter s should be a string, got 1",
+		"parameter l should be a list of strings",
+		"unknown parameter: x",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(problems, e) {
+			t.Errorf("expected problem %q in:\n%s", e, problems)
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	for _, c := range Indicators() {
+		for _, p := range c.Params {
+			if !p.accepts(p.Default) {
+				t.Errorf("%s's %s default doesn't fit its own schema", c.Name, p.Name)
+			}
+		}
+	}
+
+	if rsi := NewIndicator("rsi", Params{"periods": 7.0}); nil == rsi {
+		t.Errorf("expected an RSI")
+	}
+
+	defer func() {
+		if nil == recover() {
+			t.Errorf("expected a panic for a bad parameter")
+		}
+	}()
+
+	NewIndicator("rsi", Params{"periods": "7"})
+}
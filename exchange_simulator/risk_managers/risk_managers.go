@@ -0,0 +1,561 @@
+package risk_managers
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"../accounts"
+	"../brokers"
+	"../candles"
+	"../loggers"
+	"../orders"
+	"../stops"
+	"../ticks"
+	"../utils"
+)
+
+var log = loggers.Component("risk_managers")
+
+// ===== RISK MANAGER ==============================================================================
+
+// NewWithDeets wraps b, usually the exchange, for a portfolio worth capital:
+//
+//   rm := risk_managers.NewWithDeets(e, 50000.0)
+//   rm.SetMaxExposure(2.0, 1.0)
+//   rm.SetDailyLossLimit(3.0)
+//   e.SetBroker(rm)
+//
+// Every limit is off until it's set. Closing orders is never refused.
+func NewWithDeets(b brokers.Broker, capital float64) *RiskManager {
+	utils.EnsureZeroOrGreater(capital)
+
+	return &RiskManager{
+		broker:           b,
+		capital:          capital,
+		currencyExposure: make(map[string]exposureLimit),
+		allocations:      make(map[*accounts.Account]float64),
+		startEquity:      make(map[*accounts.Account]float64),
+		equity:           make(map[*accounts.Account]float64),
+		lastRejections:   make(map[*accounts.Account]brokers.Rejection),
+		rejectionCounts:  make(map[string]int64),
+	}
+}
+
+type RiskManager struct {
+	broker  brokers.Broker
+	capital float64
+
+	// algorithms run concurrently, everything below is behind this
+	mutex sync.Mutex
+
+	// ----- limits -----
+
+	exposure         exposureLimit
+	currencyExposure map[string]exposureLimit
+	maxPositions     int64
+	maxCorrelated    int64
+	dailyLossLimit   float64 // % of capital
+	allocations      map[*accounts.Account]float64 // % of capital
+
+	// ----- state -----
+
+	positions []*position
+
+	day         time.Time
+	haltedUntil time.Time
+	startEquity map[*accounts.Account]float64 // at the start of the trading day
+	equity      map[*accounts.Account]float64 // the last time each account came through
+
+	lastRejections  map[*accounts.Account]brokers.Rejection
+	rejectionCounts map[string]int64 // by limit
+	rejections      int64
+	halts           int64
+}
+
+// Gross is the sum of every position's exposure to a currency, net is what's left after longs and
+// shorts cancel out. Both are in lots of the currency (100,000 units), so a 1 lot EURUSD buy at
+// 1.37 is long 1 lot of EUR and short 1.37 lots of USD. 0 for no limit.
+type exposureLimit struct {
+	gross float64
+	net   float64
+}
+
+func (rm *RiskManager) SetMaxExposure(gross, net float64) {
+	rm.exposure = exposureLimit{gross, net}
+}
+
+// SetCurrencyExposure overrides SetMaxExposure for one currency, e.g. JPY where a lot is worth far
+// less
+func (rm *RiskManager) SetCurrencyExposure(currency string, gross, net float64) {
+	rm.currencyExposure[currency] = exposureLimit{gross, net}
+}
+
+// SetMaxOpenPositions limits open orders across every account
+func (rm *RiskManager) SetMaxOpenPositions(n int64) {
+	rm.maxPositions = n
+}
+
+// SetMaxCorrelated limits how many positions can be long, or short, the same currency, e.g. a long
+// EURUSD, long EURGBP and short USDCHF are all long EUR or short USD and count as 2 for each
+func (rm *RiskManager) SetMaxCorrelated(n int64) {
+	rm.maxCorrelated = n
+}
+
+// SetDailyLossLimit halts new orders until the next trading day (17:00 New York) once the accounts
+// have lost percent of capital since the day started. Equity is sampled whenever an account goes
+// through the risk manager.
+func (rm *RiskManager) SetDailyLossLimit(percent float64) {
+	if percent <= 0.0 || percent >= 100.0 {
+		panic(fmt.Sprintf("daily loss limit must be between 0.0 and 100.0 (got: %.1f)", percent))
+	}
+
+	rm.dailyLossLimit = percent
+}
+
+// Allocate limits the margin a's open orders can use to percent of capital
+func (rm *RiskManager) Allocate(a *accounts.Account, percent float64) {
+	if percent <= 0.0 || percent > 100.0 {
+		panic(fmt.Sprintf("allocation must be between 0.0 and 100.0 (got: %.1f)", percent))
+	}
+
+	rm.allocations[a] = percent
+}
+
+// ----- positions ---------------------------------------------------------------------------------
+
+type position struct {
+	account   *accounts.Account
+	symbol    string
+	direction orders.TradeDirection
+	lots      float64
+	price     float64
+
+	order *orders.Order // nil while the broker's still filling it
+}
+
+// exposures is how much of each currency the position is long (> 0) or short (< 0)
+func (p *position) exposures() map[string]float64 {
+	sign := float64(p.direction)
+
+	if len(p.symbol) < 6 {
+		return map[string]float64{p.symbol: sign * p.lots}
+	}
+
+	return map[string]float64{
+		p.symbol[0:3]: sign * p.lots,
+		p.symbol[3:6]: -sign * p.lots * p.price,
+	}
+}
+
+func (rm *RiskManager) limitFor(currency string) exposureLimit {
+	if l, ok := rm.currencyExposure[currency]; ok {
+		return l
+	}
+
+	return rm.exposure
+}
+
+// ===== CHECKS ====================================================================================
+
+// check returns which limit p would break and why, "" if none
+func (rm *RiskManager) check(p *position, tick *ticks.MarketTick) (limit string, reason string) {
+	rm.sampleEquity(p.account, tick.Time)
+
+	if tick.Time.Before(rm.haltedUntil) {
+		return "daily loss", "daily loss limit hit, halted until " + rm.haltedUntil.Format("2006-01-02 15:04 MST")
+	}
+
+	if rm.maxPositions > 0 && int64(len(rm.positions)) >= rm.maxPositions {
+		return "open positions", fmt.Sprintf("%d positions open, the max", len(rm.positions))
+	}
+
+	if percent, ok := rm.allocations[p.account]; ok {
+		used := 0.0
+		for _, open := range rm.positions {
+			if open.account == p.account {
+				used += open.lots
+			}
+		}
+
+		margin := (used + p.lots) * p.account.MarginRequirementPerLot()
+		allowed := rm.capital * percent / 100.0
+
+		if margin > allowed {
+			return "allocation", fmt.Sprintf(
+				"margin %s would exceed the %.1f%% allocation of %s",
+				utils.FormatMoney(margin), percent, utils.FormatMoney(allowed),
+			)
+		}
+	}
+
+	currencies := []string{}
+	for currency := range p.exposures() {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		exposure := p.exposures()[currency]
+
+		gross, net := math.Abs(exposure), exposure
+		correlated := int64(0)
+
+		for _, open := range rm.positions {
+			e, ok := open.exposures()[currency]
+			if !ok {
+				continue
+			}
+
+			gross += math.Abs(e)
+			net += e
+
+			if (e > 0.0) == (exposure > 0.0) {
+				correlated += 1
+			}
+		}
+
+		max := rm.limitFor(currency)
+
+		if max.gross > 0.0 && gross > max.gross {
+			return "gross exposure", fmt.Sprintf("gross %s exposure of %.2f lots would exceed %.2f", currency, gross, max.gross)
+		}
+
+		if max.net > 0.0 && math.Abs(net) > max.net {
+			return "net exposure", fmt.Sprintf("net %s exposure of %.2f lots would exceed %.2f", currency, net, max.net)
+		}
+
+		if rm.maxCorrelated > 0 && correlated >= rm.maxCorrelated {
+			side := "long"
+			if exposure < 0.0 {
+				side = "short"
+			}
+
+			return "correlated positions", fmt.Sprintf("%d positions already %s %s, the max", correlated, side, currency)
+		}
+	}
+
+	return "", ""
+}
+
+// sampleEquity records a's equity and halts trading if the day's losses are over the limit
+func (rm *RiskManager) sampleEquity(a *accounts.Account, now time.Time) {
+	day := candles.TradingDay(now)
+
+	if day.After(rm.day) {
+		rm.day = day
+
+		for acc, equity := range rm.equity {
+			rm.startEquity[acc] = equity
+		}
+	}
+
+	equity := a.GetEquity()
+	rm.equity[a] = equity
+
+	if _, ok := rm.startEquity[a]; !ok {
+		rm.startEquity[a] = equity
+	}
+
+	if 0.0 == rm.dailyLossLimit || now.Before(rm.haltedUntil) {
+		return
+	}
+
+	loss := 0.0
+	for acc, start := range rm.startEquity {
+		loss += start - rm.equity[acc]
+	}
+
+	if loss >= rm.capital * rm.dailyLossLimit / 100.0 {
+		rm.haltedUntil = day.AddDate(0, 0, 1)
+		rm.halts += 1
+
+		log.At(now).Info(
+			"daily loss is over the limit, halting new orders",
+			"loss", loss,
+			"limit", rm.dailyLossLimit,
+			"until", rm.haltedUntil,
+		)
+	}
+}
+
+func (rm *RiskManager) reject(p *position, tick *ticks.MarketTick, limit, reason string) {
+	r := brokers.Rejection{
+		At:        tick.Time,
+		Account:   p.account,
+		Symbol:    p.symbol,
+		Direction: p.direction,
+		Lots:      p.lots,
+		Reason:    reason,
+	}
+
+	rm.lastRejections[p.account] = r
+	rm.rejections += 1
+	rm.rejectionCounts[limit] += 1
+}
+
+// ===== BROKER ====================================================================================
+
+func (rm *RiskManager) LastRejection(a *accounts.Account) (brokers.Rejection, bool) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	r, ok := rm.lastRejections[a]
+	return r, ok
+}
+
+func (rm *RiskManager) OpenBuyOrder(a *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
+	p := &position{account: a, symbol: symbol, direction: orders.BUY, lots: lots, price: tick.OpenAsk}
+
+	return rm.open(p, tick, func() *orders.Order {
+		return rm.broker.OpenBuyOrder(a, symbol, tick, lots, sl, tp)
+	})
+}
+
+func (rm *RiskManager) OpenSellOrder(a *accounts.Account, symbol string, tick *ticks.MarketTick, lots float64, sl stops.StopLoss, tp stops.TakeProfit) *orders.Order {
+	p := &position{account: a, symbol: symbol, direction: orders.SELL, lots: lots, price: tick.OpenBid}
+
+	return rm.open(p, tick, func() *orders.Order {
+		return rm.broker.OpenSellOrder(a, symbol, tick, lots, sl, tp)
+	})
+}
+
+// open holds p's place while the broker fills it, without the lock since the fill calls back into
+// the strategy
+func (rm *RiskManager) open(p *position, tick *ticks.MarketTick, fill func() *orders.Order) *orders.Order {
+	rm.mutex.Lock()
+
+	if limit, reason := rm.check(p, tick); "" != limit {
+		rm.reject(p, tick, limit, reason)
+		rm.mutex.Unlock()
+		return nil
+	}
+
+	rm.positions = append(rm.positions, p)
+	rm.mutex.Unlock()
+
+	o := fill()
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	if nil == o || o.IsClosed() {
+		rm.remove(p)
+		return o
+	}
+
+	p.order = o
+
+	return o
+}
+
+func (rm *RiskManager) remove(p *position) {
+	for i, open := range rm.positions {
+		if open == p {
+			rm.positions = append(rm.positions[:i], rm.positions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (rm *RiskManager) CloseOrder(a *accounts.Account, o *orders.Order, tick *ticks.MarketTick) {
+	rm.broker.CloseOrder(a, o, tick)
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	for _, p := range rm.positions {
+		if p.order == o {
+			rm.remove(p)
+			break
+		}
+	}
+
+	rm.sampleEquity(a, tick.Time)
+}
+
+func (rm *RiskManager) CloseAllOrders(a *accounts.Account, finalTicks map[string]*ticks.MarketTick) {
+	for _, o := range a.OpenOrders() {
+		rm.CloseOrder(a, o, finalTicks[o.Symbol])
+	}
+}
+
+// ===== SUMMARY ===================================================================================
+
+func (rm *RiskManager) PrintSummary() {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	fmt.Printf(
+		"***** RISK MANAGER *****\n\n" +
+		"Capital: %s\n" +
+		"Orders rejected: %s\n" +
+		"Daily loss halts: %s\n",
+		utils.FormatMoney(rm.capital),
+		utils.AddCommas(rm.rejections),
+		utils.AddCommas(rm.halts),
+	)
+
+	limits := []string{}
+	for limit := range rm.rejectionCounts {
+		limits = append(limits, limit)
+	}
+	sort.Strings(limits)
+
+	for _, limit := range limits {
+		fmt.Printf("  %s: %s\n", limit, utils.AddCommas(rm.rejectionCounts[limit]))
+	}
+
+	fmt.Println("")
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+func init() {
+	gob.Register(state{})
+}
+
+// The limits are set up again with the run, only what's happened since it started is saved.
+// Accounts are saved by their index in the run's, orders by their ID.
+type state struct {
+	Positions []positionState
+
+	Day         time.Time
+	HaltedUntil time.Time
+	StartEquity map[int64]float64
+	Equity      map[int64]float64
+
+	LastRejections  map[int64]brokers.RejectionState
+	RejectionCounts map[string]int64
+	Rejections      int64
+	Halts           int64
+}
+
+type positionState struct {
+	Account   int64
+	Symbol    string
+	Direction orders.TradeDirection
+	Lots      float64
+	Price     float64
+	OrderID   int64 // 0 while the broker's still filling it
+}
+
+func (rm *RiskManager) State(accts []*accounts.Account) interface{} {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	s := state{
+		Day:             rm.day,
+		HaltedUntil:     rm.haltedUntil,
+		StartEquity:     make(map[int64]float64),
+		Equity:          make(map[int64]float64),
+		LastRejections:  make(map[int64]brokers.RejectionState),
+		RejectionCounts: make(map[string]int64),
+		Rejections:      rm.rejections,
+		Halts:           rm.halts,
+	}
+
+	for _, p := range rm.positions {
+		ps := positionState{
+			Account:   accountIndex(accts, p.account),
+			Symbol:    p.symbol,
+			Direction: p.direction,
+			Lots:      p.lots,
+			Price:     p.price,
+		}
+
+		if nil != p.order {
+			ps.OrderID = p.order.ID
+		}
+
+		s.Positions = append(s.Positions, ps)
+	}
+
+	for acc, equity := range rm.startEquity {
+		s.StartEquity[accountIndex(accts, acc)] = equity
+	}
+
+	for acc, equity := range rm.equity {
+		s.Equity[accountIndex(accts, acc)] = equity
+	}
+
+	for acc, r := range rm.lastRejections {
+		s.LastRejections[accountIndex(accts, acc)] = r.State()
+	}
+
+	for limit, count := range rm.rejectionCounts {
+		s.RejectionCounts[limit] = count
+	}
+
+	return s
+}
+
+func (rm *RiskManager) Restore(saved interface{}, accts []*accounts.Account) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	s := saved.(state)
+
+	rm.positions = nil
+	for _, ps := range s.Positions {
+		p := &position{
+			account:   accts[ps.Account],
+			symbol:    ps.Symbol,
+			direction: ps.Direction,
+			lots:      ps.Lots,
+			price:     ps.Price,
+		}
+
+		if 0 != ps.OrderID {
+			p.order = p.account.Order(ps.OrderID)
+		}
+
+		rm.positions = append(rm.positions, p)
+	}
+
+	rm.day, rm.haltedUntil = newYork(s.Day), newYork(s.HaltedUntil)
+
+	rm.startEquity = make(map[*accounts.Account]float64)
+	for i, equity := range s.StartEquity {
+		rm.startEquity[accts[i]] = equity
+	}
+
+	rm.equity = make(map[*accounts.Account]float64)
+	for i, equity := range s.Equity {
+		rm.equity[accts[i]] = equity
+	}
+
+	rm.lastRejections = make(map[*accounts.Account]brokers.Rejection)
+	for i, rs := range s.LastRejections {
+		rm.lastRejections[accts[i]] = rs.Rejection(accts[i])
+	}
+
+	rm.rejectionCounts = make(map[string]int64)
+	for limit, count := range s.RejectionCounts {
+		rm.rejectionCounts[limit] = count
+	}
+
+	rm.rejections, rm.halts = s.Rejections, s.Halts
+}
+
+func accountIndex(accts []*accounts.Account, a *accounts.Account) int64 {
+	for i, acc := range accts {
+		if acc == a {
+			return int64(i)
+		}
+	}
+
+	panic("account isn't one of the run's, can't save the risk manager")
+}
+
+// gob only keeps a time's offset, the halt is printed with New York's zone name
+func newYork(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	return t.In(candles.NEW_YORK)
+}
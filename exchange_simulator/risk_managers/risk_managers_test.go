@@ -0,0 +1,194 @@
+package risk_managers
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+	"time"
+
+	"../accounts"
+	"../exchanges"
+	"../orders"
+	"../stops"
+	"../ticks"
+)
+
+var monday = time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+
+func tick(symbol string, at time.Time, bid float64) *ticks.MarketTick {
+	return ticks.NewRawTick(symbol, at, bid, bid + 0.0002)
+}
+
+func newRiskManager(capital float64) (*RiskManager, *accounts.Account) {
+	rm := NewWithDeets(exchanges.NewWithDeets(nil), capital)
+	return rm, accounts.NewWithDeets("test", capital)
+}
+
+func expectRejection(t *testing.T, rm *RiskManager, a *accounts.Account, o *orders.Order, reason string) {
+	if nil != o {
+		t.Errorf("expected a rejection for %q, got an order", reason)
+		return
+	}
+
+	r, ok := rm.LastRejection(a)
+	if !ok || !strings.Contains(r.Reason, reason) {
+		t.Errorf("expected a rejection for %q, got %q", reason, r.Reason)
+	}
+}
+
+func TestExposure(t *testing.T) {
+	rm, a := newRiskManager(10000.0)
+	rm.SetMaxExposure(2.5, 1.5)
+
+	sl, tp := stops.NoStopLoss(), stops.NoTakeProfit()
+
+	if nil == rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 1.0, sl, tp) {
+		t.Fatalf("first order should be fine")
+	}
+
+	// long 2 EUR
+	o := rm.OpenBuyOrder(a, "EURGBP", tick("EURGBP", monday, 0.8200), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "net EUR exposure of 2.00 lots would exceed 1.50")
+
+	// long 1 EUR, short 1 EUR nets out, but it's 2 gross
+	if nil == rm.OpenSellOrder(a, "EURGBP", tick("EURGBP", monday, 0.8200), 1.0, sl, tp) {
+		t.Fatalf("hedge should be fine")
+	}
+
+	o = rm.OpenBuyOrder(a, "EURJPY", tick("EURJPY", monday, 140.00), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "gross EUR exposure of 3.00 lots would exceed 2.50")
+
+	// JPY lots are tiny, but its own limit lets through less than the default would
+	rm.SetCurrencyExposure("EUR", 10.0, 10.0)
+	rm.SetCurrencyExposure("JPY", 1000.0, 100.0)
+
+	o = rm.OpenBuyOrder(a, "EURJPY", tick("EURJPY", monday, 140.00), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "net JPY exposure of -140.00 lots would exceed 100.00")
+}
+
+func TestPositionLimits(t *testing.T) {
+	rm, a := newRiskManager(10000.0)
+	rm.SetMaxOpenPositions(3)
+	rm.SetMaxCorrelated(2)
+
+	sl, tp := stops.NoStopLoss(), stops.NoTakeProfit()
+
+	eurusd := rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 0.1, sl, tp)
+	rm.OpenBuyOrder(a, "EURGBP", tick("EURGBP", monday, 0.8200), 0.1, sl, tp)
+
+	// a third long EUR
+	o := rm.OpenSellOrder(a, "GBPEUR", tick("GBPEUR", monday, 1.2200), 0.1, sl, tp)
+	expectRejection(t, rm, a, o, "2 positions already long EUR, the max")
+
+	if nil == rm.OpenBuyOrder(a, "AUDNZD", tick("AUDNZD", monday, 1.0800), 0.1, sl, tp) {
+		t.Fatalf("uncorrelated order should be fine")
+	}
+
+	o = rm.OpenBuyOrder(a, "GBPJPY", tick("GBPJPY", monday, 170.00), 0.1, sl, tp)
+	expectRejection(t, rm, a, o, "3 positions open, the max")
+
+	// closing makes room
+	rm.CloseOrder(a, eurusd, tick("EURUSD", monday, 1.3700))
+
+	if nil == rm.OpenBuyOrder(a, "GBPJPY", tick("GBPJPY", monday, 170.00), 0.1, sl, tp) {
+		t.Errorf("closing an order should make room")
+	}
+}
+
+func TestAllocation(t *testing.T) {
+	rm, a := newRiskManager(10000.0)
+	other := accounts.NewWithDeets("other", 10000.0)
+
+	a.SetMargin(10)
+	rm.Allocate(a, 25.0)
+
+	sl, tp := stops.NoStopLoss(), stops.NoTakeProfit()
+
+	// 1,000 per lot of margin
+	if nil == rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 2.0, sl, tp) {
+		t.Fatalf("2 lots should fit in 2,500")
+	}
+
+	o := rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "allocation")
+
+	if nil == rm.OpenBuyOrder(other, "EURUSD", tick("EURUSD", monday, 1.3700), 1.0, sl, tp) {
+		t.Errorf("allocations are per account")
+	}
+}
+
+func TestDailyLossLimit(t *testing.T) {
+	rm, a := newRiskManager(10000.0)
+	rm.SetDailyLossLimit(5.0)
+
+	sl, tp := stops.NoStopLoss(), stops.NoTakeProfit()
+
+	o := rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 1.0, sl, tp)
+
+	// -102 pips on a lot
+	falling := tick("EURUSD", monday.Add(time.Hour), 1.3600)
+	o.RecordTick(falling)
+	rm.CloseOrder(a, o, falling)
+
+	o = rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday.Add(2 * time.Hour), 1.3600), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "halted until 2014-03-03 17:00 EST")
+
+	// the trading day ends at 17:00 New York
+	if nil == rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", time.Date(2014, 3, 3, 22, 1, 0, 0, time.UTC), 1.3600), 1.0, sl, tp) {
+		t.Errorf("trading should start again the next day")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	rm, a := newRiskManager(10000.0)
+	rm.SetDailyLossLimit(5.0)
+	rm.SetMaxOpenPositions(2)
+
+	sl, tp := stops.NoStopLoss(), stops.NoTakeProfit()
+
+	open := rm.OpenBuyOrder(a, "GBPUSD", tick("GBPUSD", monday, 1.6600), 0.1, sl, tp)
+
+	o := rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday, 1.3700), 1.0, sl, tp)
+	falling := tick("EURUSD", monday.Add(time.Hour), 1.3600)
+	o.RecordTick(falling)
+	rm.CloseOrder(a, o, falling)
+
+	o = rm.OpenBuyOrder(a, "EURUSD", tick("EURUSD", monday.Add(2 * time.Hour), 1.3600), 1.0, sl, tp)
+	expectRejection(t, rm, a, o, "halted until 2014-03-03 17:00 EST")
+
+	// through gob, like a checkpoint
+	saved := struct {
+		Account accounts.State
+		Risk    interface{}
+	}{a.State(), rm.State([]*accounts.Account{a})}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	saved.Risk = nil
+	if err := gob.NewDecoder(&buf).Decode(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, b := newRiskManager(10000.0)
+	restored.SetDailyLossLimit(5.0)
+	restored.SetMaxOpenPositions(2)
+
+	b.Restore(saved.Account)
+	restored.Restore(saved.Risk, []*accounts.Account{b})
+
+	if 1 != len(restored.positions) || restored.positions[0].order != b.Order(open.ID) {
+		t.Errorf("expected the open position to be restored with its order")
+	}
+
+	o = restored.OpenBuyOrder(b, "EURUSD", tick("EURUSD", monday.Add(3 * time.Hour), 1.3600), 1.0, sl, tp)
+	expectRejection(t, restored, b, o, "halted until 2014-03-03 17:00 EST")
+
+	// the open position still counts once the halt's over
+	restored.OpenBuyOrder(b, "EURUSD", tick("EURUSD", time.Date(2014, 3, 3, 22, 1, 0, 0, time.UTC), 1.3600), 0.1, sl, tp)
+	o = restored.OpenBuyOrder(b, "AUDUSD", tick("AUDUSD", time.Date(2014, 3, 3, 22, 2, 0, 0, time.UTC), 0.8900), 0.1, sl, tp)
+	expectRejection(t, restored, b, o, "2 positions open, the max")
+}
@@ -0,0 +1,441 @@
+package schedulers
+
+import (
+	"container/heap"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===== CRON ======================================================================================
+
+// ParseCron takes the usual five fields, "minute hour day-of-month month day-of-week", each a *, a
+// number, a range (1-5), a list (0,30) or any of those with a step (*/15). Sunday is 0 (or 7). Like
+// cron, when both day fields are restricted either one matching is enough.
+func ParseCron(expr string) *Cron {
+	fields := strings.Fields(expr)
+	if 5 != len(fields) {
+		panic("cron expressions need 5 fields, got: " + expr)
+	}
+
+	c := &Cron{expr: expr}
+
+	c.minutes = parseField(expr, fields[0], 0, 59)
+	c.hours = parseField(expr, fields[1], 0, 23)
+	c.days = parseField(expr, fields[2], 1, 31)
+	c.months = parseField(expr, fields[3], 1, 12)
+	c.weekdays = parseField(expr, fields[4], 0, 7)
+
+	// 7 is sunday too
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+
+	c.anyDay = "*" == fields[2]
+	c.anyWeekday = "*" == fields[4]
+
+	return c
+}
+
+type Cron struct {
+	expr string
+
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+func parseField(expr, field string, min, max int) map[int]bool {
+	res := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i + 1:])
+			if err != nil || step < 1 {
+				panic(fmt.Sprintf("bad step %q in cron expression: %s", part, expr))
+			}
+
+			part = part[:i]
+		}
+
+		from, to := min, max
+
+		if "*" != part {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				panic(fmt.Sprintf("bad value %q in cron expression: %s", part, expr))
+			}
+
+			to = from
+			if 2 == len(bounds) {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					panic(fmt.Sprintf("bad range %q in cron expression: %s", part, expr))
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			panic(fmt.Sprintf("%q is out of range (%d-%d) in cron expression: %s", part, min, max, expr))
+		}
+
+		for v := from; v <= to; v += step {
+			res[v] = true
+		}
+	}
+
+	return res
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+
+	return day || weekday
+}
+
+// Next is the first time after t that matches, on the wall clock in loc. Wall clock times DST
+// skips never match, and ones it repeats only match the first time round, e.g. "30 1 * * *" in New
+// York fires at 01:30 EDT on the day the clocks go back, but not at 01:30 EST an hour later.
+func (c *Cron) Next(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+
+	// a matching time turns up within a few years if it's ever going to, i.e. not on 30 February
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+
+		if !c.months[int(m)] {
+			t = time.Date(y, m + 1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(y, m, d + 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.hours[t.Hour()] {
+			next := time.Date(y, m, d, t.Hour() + 1, 0, 0, 0, loc)
+
+			// the hour DST skips can normalize backwards
+			if !next.After(t) {
+				next = t.Add(time.Duration(60 - t.Minute()) * time.Minute)
+			}
+
+			t = next
+			continue
+		}
+
+		if !c.minutes[t.Minute()] || repeated(t) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	panic("cron expression never matches: " + c.expr)
+}
+
+// repeated is whether the wall clock already showed t's time, before DST ended and put it back
+// (by up to 2 hours, usually 1)
+func repeated(t time.Time) bool {
+	_, offset := t.Zone()
+
+	for back := 30 * time.Minute; back <= 2 * time.Hour; back += 30 * time.Minute {
+		if _, earlier := t.Add(-back).Zone(); time.Duration(earlier - offset) * time.Second == back {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ===== SESSIONS ==================================================================================
+
+// Events OnSession takes
+const (
+	SYDNEY_OPEN    = "sydney_open"
+	SYDNEY_CLOSE   = "sydney_close"
+	TOKYO_OPEN     = "tokyo_open"
+	TOKYO_CLOSE    = "tokyo_close"
+	LONDON_OPEN    = "london_open"
+	LONDON_CLOSE   = "london_close"
+	NEW_YORK_OPEN  = "new_york_open"
+	NEW_YORK_CLOSE = "new_york_close"
+
+	// the forex week runs from 17:00 Sunday to 17:00 Friday in New York
+	WEEKLY_OPEN  = "weekly_open"
+	WEEKLY_CLOSE = "weekly_close"
+)
+
+type SessionEvent struct {
+	Location string
+	Cron     string
+}
+
+// Local trading hours, weekdays only. Each one follows its own DST rules.
+var SESSION_EVENTS = map[string]SessionEvent{
+	SYDNEY_OPEN:    {"Australia/Sydney", "0 7 * * 1-5"},
+	SYDNEY_CLOSE:   {"Australia/Sydney", "0 16 * * 1-5"},
+	TOKYO_OPEN:     {"Asia/Tokyo", "0 9 * * 1-5"},
+	TOKYO_CLOSE:    {"Asia/Tokyo", "0 18 * * 1-5"},
+	LONDON_OPEN:    {"Europe/London", "0 8 * * 1-5"},
+	LONDON_CLOSE:   {"Europe/London", "0 17 * * 1-5"},
+	NEW_YORK_OPEN:  {"America/New_York", "0 8 * * 1-5"},
+	NEW_YORK_CLOSE: {"America/New_York", "0 17 * * 1-5"},
+	WEEKLY_OPEN:    {"America/New_York", "0 17 * * 0"},
+	WEEKLY_CLOSE:   {"America/New_York", "0 17 * * 5"},
+}
+
+// ===== SCHEDULER =================================================================================
+
+// Timers that fire against simulated time. The algorithm calls Advance with each tick's time, so a
+// timer fires on the first tick at or after it's due, with the time it was due. Recurring timers
+// that were due more than once since the last tick (over a weekend, say) only fire once.
+func NewScheduler() *Scheduler {
+	return &Scheduler{names: make(map[string]*timer)}
+}
+
+type Scheduler struct {
+	now     time.Time
+	started bool
+
+	timers timerHeap
+	names  map[string]*timer
+	added  int64
+}
+
+type timer struct {
+	name string
+	due  time.Time
+	f    func(time.Time)
+
+	cron *Cron
+	loc  *time.Location
+
+	order int64 // ties fire in the order they were added
+	index int64
+}
+
+// At fires f once at t
+func (s *Scheduler) At(name string, t time.Time, f func(time.Time)) {
+	s.add(&timer{name: name, due: t, f: f})
+}
+
+// In fires f once, d after the last Advance
+func (s *Scheduler) In(name string, d time.Duration, f func(time.Time)) {
+	if !s.started {
+		panic("can't schedule relative to now before the first tick, use At or Every")
+	}
+
+	s.At(name, s.now.Add(d), f)
+}
+
+// Every fires f whenever the cron expression matches in loc, e.g. "45 16 * * 5" in New York to
+// flatten before the weekly close. See ParseCron.
+func (s *Scheduler) Every(name, expr string, loc *time.Location, f func(time.Time)) {
+	t := &timer{name: name, f: f, cron: ParseCron(expr), loc: loc}
+
+	if s.started {
+		t.due = t.cron.Next(s.now, loc)
+	}
+
+	s.add(t)
+}
+
+// OnSession fires f for one of the session events, e.g. LONDON_OPEN
+func (s *Scheduler) OnSession(event string, f func(time.Time)) {
+	se, ok := SESSION_EVENTS[event]
+	if !ok {
+		panic("unknown session event: " + event)
+	}
+
+	loc, err := time.LoadLocation(se.Location)
+	if err != nil {
+		panic(err)
+	}
+
+	s.Every(event, se.Cron, loc, f)
+}
+
+func (s *Scheduler) add(t *timer) {
+	if _, exists := s.names[t.name]; exists {
+		panic("timer already exists: " + t.name)
+	}
+
+	s.added += 1
+	t.order = s.added
+
+	s.names[t.name] = t
+	heap.Push(&s.timers, t)
+}
+
+// Cancel removes a timer, it's fine if it's already fired or never existed
+func (s *Scheduler) Cancel(name string) {
+	t, exists := s.names[name]
+	if !exists {
+		return
+	}
+
+	heap.Remove(&s.timers, int(t.index))
+	delete(s.names, name)
+}
+
+func (s *Scheduler) Has(name string) bool {
+	_, exists := s.names[name]
+
+	return exists
+}
+
+// Advance fires everything due by now, in order
+func (s *Scheduler) Advance(now time.Time) {
+	if !s.started {
+		s.started = true
+
+		// recurring timers added before the first tick start from it
+		for _, t := range s.timers {
+			if nil != t.cron && t.due.IsZero() {
+				t.due = t.cron.Next(now.Add(-time.Nanosecond), t.loc)
+			}
+		}
+
+		heap.Init(&s.timers)
+	}
+
+	s.now = now
+
+	for 0 != len(s.timers) && !s.timers[0].due.After(now) {
+		t := s.timers[0]
+
+		due := t.due
+
+		if nil == t.cron {
+			heap.Pop(&s.timers)
+			delete(s.names, t.name)
+		} else {
+			// it might be due again right now
+			t.due = t.cron.Next(now.Add(-time.Nanosecond), t.loc)
+			if !t.due.After(due) {
+				t.due = t.cron.Next(due, t.loc)
+			}
+
+			heap.Fix(&s.timers, 0)
+		}
+
+		t.f(due)
+	}
+}
+
+// ----- CHECKPOINTS -------------------------------------------------------------------------------
+
+// timers' callbacks can't be saved, so they're matched by name to the ones the strategy set up
+// again in OnStart, see Algorithm.Restore
+type State struct {
+	Now     time.Time
+	Started bool
+	Added   int64
+	Timers  []TimerState // in heap order
+}
+
+type TimerState struct {
+	Name  string
+	Due   time.Time
+	Order int64
+}
+
+func (s *Scheduler) State() State {
+	saved := State{Now: s.now, Started: s.started, Added: s.added}
+
+	for _, t := range s.timers {
+		saved.Timers = append(saved.Timers, TimerState{Name: t.name, Due: t.due, Order: t.order})
+	}
+
+	return saved
+}
+
+func (s *Scheduler) Restore(saved State) {
+	timers := timerHeap{}
+	names := make(map[string]*timer)
+
+	for _, st := range saved.Timers {
+		t, ok := s.names[st.Name]
+		if !ok {
+			panic("can't restore timer " + st.Name + ", only timers set up in OnStart can be")
+		}
+
+		t.due, t.order, t.index = st.Due, st.Order, int64(len(timers))
+
+		timers = append(timers, t)
+		names[t.name] = t
+	}
+
+	s.now, s.started, s.added = saved.Now, saved.Started, saved.Added
+	s.timers, s.names = timers, names
+}
+
+// ----- TIMER HEAP --------------------------------------------------------------------------------
+
+type timerHeap []*timer
+
+func (th timerHeap) Len() int {
+	return len(th)
+}
+
+func (th timerHeap) Less(i, j int) bool {
+	if !th[i].due.Equal(th[j].due) {
+		return th[i].due.Before(th[j].due)
+	}
+
+	return th[i].order < th[j].order
+}
+
+func (th timerHeap) Swap(i, j int) {
+	th[i], th[j] = th[j], th[i]
+	th[i].index = int64(i)
+	th[j].index = int64(j)
+}
+
+func (th *timerHeap) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = int64(len(*th))
+
+	*th = append(*th, t)
+}
+
+func (th *timerHeap) Pop() interface{} {
+	old := *th
+	t := old[len(old) - 1]
+
+	*th = old[:len(old) - 1]
+
+	return t
+}
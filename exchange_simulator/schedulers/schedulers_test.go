@@ -0,0 +1,144 @@
+package schedulers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+func TestCronNext(t *testing.T) {
+	ny := mustLoad("America/New_York")
+
+	tests := []struct {
+		expr     string
+		after    time.Time
+		expected time.Time
+	}{
+		// every 15 minutes
+		{"*/15 * * * *", time.Date(2014, 3, 3, 10, 7, 30, 0, ny), time.Date(2014, 3, 3, 10, 15, 0, 0, ny)},
+		{"*/15 * * * *", time.Date(2014, 3, 3, 10, 45, 0, 0, ny), time.Date(2014, 3, 3, 11, 0, 0, 0, ny)},
+		// friday afternoon, from a saturday
+		{"45 16 * * 5", time.Date(2014, 3, 1, 12, 0, 0, 0, ny), time.Date(2014, 3, 7, 16, 45, 0, 0, ny)},
+		// either day field matches when both are set
+		{"0 0 1 * 1", time.Date(2014, 3, 4, 0, 0, 0, 0, ny), time.Date(2014, 3, 10, 0, 0, 0, 0, ny)},
+		// across the year and the month lengths
+		{"30 8 31 * *", time.Date(2014, 11, 1, 0, 0, 0, 0, ny), time.Date(2014, 12, 31, 8, 30, 0, 0, ny)},
+		{"0 12 29 2 *", time.Date(2014, 3, 1, 0, 0, 0, 0, ny), time.Date(2016, 2, 29, 12, 0, 0, 0, ny)},
+		// 02:30 doesn't exist when the clocks go forward
+		{"30 2 * * *", time.Date(2014, 3, 8, 12, 0, 0, 0, ny), time.Date(2014, 3, 10, 2, 30, 0, 0, ny)},
+		// 17:00 keeps to New York time either side of DST
+		{"0 17 * * *", time.Date(2014, 3, 8, 18, 0, 0, 0, ny), time.Date(2014, 3, 9, 17, 0, 0, 0, ny)},
+		// 01:30 happens twice when the clocks go back, 05:30 then 06:30 UTC, and only fires the first time
+		{"30 1 * * *", time.Date(2014, 11, 1, 12, 0, 0, 0, ny), time.Date(2014, 11, 2, 5, 30, 0, 0, time.UTC)},
+		{"30 1 * * *", time.Date(2014, 11, 2, 5, 30, 0, 0, time.UTC), time.Date(2014, 11, 3, 1, 30, 0, 0, ny)},
+		{"0 * * * *", time.Date(2014, 11, 2, 5, 0, 0, 0, time.UTC), time.Date(2014, 11, 2, 2, 0, 0, 0, ny)},
+	}
+
+	for _, test := range tests {
+		got := ParseCron(test.expr).Next(test.after, ny)
+
+		if !got.Equal(test.expected) {
+			t.Errorf("%q after %s: expected %s, got %s", test.expr, test.after, test.expected, got)
+		}
+	}
+}
+
+func TestBadCronPanics(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("Expected %q to panic", expr)
+				}
+			}()
+
+			ParseCron(expr)
+		}()
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+	fired := []string{}
+
+	record := func(name string) func(time.Time) {
+		return func(at time.Time) {
+			fired = append(fired, name + " " + at.UTC().Format("Mon 15:04"))
+		}
+	}
+
+	start := time.Date(2014, 3, 7, 20, 0, 0, 0, time.UTC) // friday
+
+	s.Every("hourly", "0 * * * *", time.UTC, record("hourly"))
+	s.At("once", start.Add(90 * time.Minute), record("once"))
+	s.At("cancelled", start.Add(30 * time.Minute), record("cancelled"))
+	s.Cancel("cancelled")
+
+	s.Advance(start)
+	s.Advance(start.Add(59 * time.Minute))
+	s.Advance(start.Add(2 * time.Hour))
+
+	// nothing over the weekend, the hourly timer only catches up once before it's due again
+	s.Advance(start.Add(50 * time.Hour))
+
+	s.In("later", time.Minute, record("later"))
+	s.Advance(start.Add(50 * time.Hour + time.Minute))
+
+	expected := []string{
+		"hourly Fri 20:00",
+		"hourly Fri 21:00",
+		"once Fri 21:30",
+		"hourly Fri 22:00",
+		"hourly Fri 23:00",
+		"hourly Sun 22:00",
+		"later Sun 22:01",
+	}
+
+	if strings.Join(expected, ", ") != strings.Join(fired, ", ") {
+		t.Errorf("Expected %s, got %s", strings.Join(expected, ", "), strings.Join(fired, ", "))
+	}
+
+	if s.Has("once") || !s.Has("hourly") {
+		t.Errorf("Expected one-shot timers to go once they've fired")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := NewScheduler()
+	fired := []string{}
+
+	for _, event := range []string{LONDON_OPEN, NEW_YORK_CLOSE, WEEKLY_OPEN, WEEKLY_CLOSE, TOKYO_OPEN} {
+		e := event
+		s.OnSession(e, func(at time.Time) {
+			fired = append(fired, e + " " + at.UTC().Format("Mon 15:04"))
+		})
+	}
+
+	// a week either side of the US going onto DST, London stays on GMT until the 30th
+	start := time.Date(2014, 3, 7, 18, 0, 0, 0, time.UTC)
+	for now := start; now.Before(start.Add(78 * time.Hour)); now = now.Add(time.Minute) {
+		s.Advance(now)
+	}
+
+	expected := []string{
+		"new_york_close Fri 22:00",
+		"weekly_close Fri 22:00",
+		"weekly_open Sun 21:00",
+		"tokyo_open Mon 00:00",
+		"london_open Mon 08:00",
+		"new_york_close Mon 21:00",
+	}
+
+	if strings.Join(expected, ", ") != strings.Join(fired, ", ") {
+		t.Errorf("Expected %s, got %s", strings.Join(expected, ", "), strings.Join(fired, ", "))
+	}
+}
@@ -3,153 +3,330 @@ package main
 import (
 	"flag"
 	"fmt"
-	"time"
-
-	std "../indicators/steve_turn_detector"
-	sv  "../indicators/spread_velocity"
-	vv  "../indicators/volume_velocity"
-
-	td "../../ga/trading_decisions"
-
-	"../accounts"
-	"../algorithms"
-	"../exchanges"
-	"../indicators"
-	"../pips"
-	"../quotes"
-	"../stops"
-	"../ticks"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"../checkpoints"
+	"../configs"
+	"../journals"
+	"../loggers"
+	"../registry"
+
+	_ "../strategies"
 )
 
-// ===== STEVE'S ALGORITHM #2 ======================================================================
+const USAGE = `usage: simulator <command> [arguments]
 
-func steveorithm2(algo *algorithms.Algorithm, tick *ticks.MarketTick) {
-	if algo.Account.HasOpenOrders() {
-		openOrderCount := 0
+commands:
+  run [flags] <config.json>     run a strategy config, e.g. steveorithm2.json
+  resume [flags] <checkpoint>   pick up a run from a checkpoint it saved
+  replay <journal.jsonl>        rebuild the accounts in a run's journal and check them
+  list                          list registered strategies, indicators and trading decisions
+  describe <name>               show a registered component's parameters and defaults
+  validate <config.json>...     check configs without running them
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, USAGE)
+	os.Exit(2)
+}
 
-		for _, order := range algo.Account.OpenOrders() {
-			openOrderCount += 1
+// ===== LOGGING ===================================================================================
 
-			if order.Symbol != tick.Symbol {
-				continue
-			}
+// logFlags adds the logging flags to fs, what it returns sets logging up once fs is parsed
+func logFlags(fs *flag.FlagSet) func() {
+	var level, format, path string
 
-			if order.Metadata.Fetch("expiration_time").(time.Time).Before(tick.Time) {
-				algo.Broker.CloseOrder(algo.Account, order, tick)
-				continue
-			}
+	fs.StringVar(&level, "log-level", "info", "least severe to log: debug, info, warn, error or off")
+	fs.StringVar(&format, "log-format", "text", "log as text or json")
+	fs.StringVar(&path, "log-file", "", "log here instead of stdout")
 
-			if order.PercentToTakeProfit() > 75.0 {
-				p := pips.Pip(-(float64(order.GetTakeProfit().Pips) / 1.5))
-				order.SetStopLoss(p)
-			}
+	return func() {
+		l, ok := loggers.ParseLevel(level)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown log level %q\n", level)
+			os.Exit(2)
 		}
 
-		// if openOrderCount >= 3 {
-		// 	return
-		// }
+		f := loggers.Format(format)
+		if loggers.TEXT != f && loggers.JSON != f {
+			fmt.Fprintf(os.Stderr, "unknown log format %q\n", format)
+			os.Exit(2)
+		}
+
+		loggers.Configure(l, f, path)
 	}
+}
 
-	chart := algo.Charts[tick.Symbol]["M1"]
-	candles := chart.GetCandles(60)
+// ===== RUN =======================================================================================
 
-	// 1. previous minute’s open_bid/(60 minute m1 MA) >=1.0026
-	ma60 := 0.0
-	for _, candle := range candles {
-		ma60 += candle.OpenBid
+func run(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+
+	var path, start, end, checkpoint, journal string
+	var showOrders bool
+	var checkpointDays int64
+
+	fs.StringVar(&path, "path", "", "data file(s) to use instead of the config's, comma separated files are merged")
+	fs.StringVar(&start, "start", "", "start date to use instead of the config's, e.g. 2014-03-03")
+	fs.StringVar(&end, "end", "", "end date to use instead of the config's")
+	fs.BoolVar(&showOrders, "show-orders", false, "show order summary after account summary")
+	fs.StringVar(&checkpoint, "checkpoint", "", "save a checkpoint here when interrupted (ctrl-c), see resume")
+	fs.Int64Var(&checkpointDays, "checkpoint-days", 0, "save a checkpoint every this many simulated days too")
+	fs.StringVar(&journal, "journal", "", "journal every order and account event here, as JSON lines, see replay")
+	logging := logFlags(fs)
+	fs.Parse(args)
+
+	if checkpointDays > 0 && "" == checkpoint {
+		fmt.Fprintln(os.Stderr, "-checkpoint-days needs a -checkpoint path")
+		os.Exit(2)
+	}
+
+	if 1 != fs.NArg() {
+		usage()
 	}
-	ma60 /= 60.0
 
-	lastOpen := candles[0].OpenBid
+	logging()
+	defer loggers.Close()
 
-	crossover := lastOpen / ma60
+	fmt.Println("Running config file:", fs.Arg(0))
 
-	// 2. previous minute’s spread has increased since opening (open_ask-open_bid)<(close_ask-close_bid)
-	// spreadIncreased := (candles[0].OpenAsk - candles[0].OpenBid) < (candles[0].CloseAsk - candles[0].CloseBid)
-	spreadsies := candles[0].OpenBid - candles[0].CloseBid
-	spreadIncreased := spreadsies <= 0.0011 && spreadsies >= -0.0011
+	c := configs.Load(fs.Arg(0))
 
-	// 3. last 5 ticks have more than  100 ticks  (you might be able to use turning up for this)
-	totalTicks := int64(0)
-	for _, candle := range chart.GetCandles(5) {
-		totalTicks += candle.Volume
+	if "" != path {
+		c.Data.Paths = strings.Split(path, ",")
 	}
 
-	_ = spreadIncreased
-	_ = totalTicks
-	_ = crossover
+	if "" != start {
+		c.Start = start
+	}
 
-	data := make(map[string]interface{})
-	data["spreadIncreased"] = spreadIncreased
-	data["totalTicks"] = totalTicks
-	data["crossover"] = crossover
+	if "" != end {
+		c.End = end
+	}
 
-	switch algo.TradingDecision.Run(data) {
-	default:
-	case td.BUY:
-		tpPips := quotes.DifferenceInPips(tick.Symbol, tick.OpenAsk, tick.OpenAsk * 1.004)
-		slPips := pips.Pip(float64(tpPips) * 0.90)
-
-		o := algo.Broker.OpenBuyOrder(
-			algo.Account,
-			tick.Symbol,
-			tick,
-			// algo.Metadata.Fetch("lots").(float64),
-			algo.Account.LotSizeForTrade(slPips),
-			stops.NoStopLoss(),
-			stops.NoTakeProfit(),
-		)
-		o.SetStopLoss(slPips)
-		o.SetTakeProfit(tpPips)
-		o.Metadata.Set("expiration_time", o.OpenedAt.Add(60 * time.Minute))
-	case td.SELL:
-		fmt.Println("SELL")
+	if showOrders {
+		c.Account.ShowOrders = true
+	}
+
+	if !report(fs.Arg(0), c) {
+		os.Exit(1)
+	}
+
+	e, _ := c.Build()
+
+	if "" != journal {
+		e.SetJournal(journals.NewFile(journal))
+	}
+
+	if "" != checkpoint {
+		e.SetCheckpoint(checkpoint, checkpointDays)
 	}
+
+	e.Run()
 }
 
+// ===== RESUME ====================================================================================
 
-// ===== PROGRAM ENTRYPOINT ========================================================================
+// A run's journal, if it kept one, carries on from the checkpoint in the same file by itself
+func resume(args []string) {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
 
-func main() {
-	var csvPath string
-	var showOrders bool
-	var lots float64
-	var margin int
+	var checkpoint string
+	var checkpointDays int64
 
-	flag.StringVar(&csvPath, "path", "", "path to CSV files")
-	flag.Float64Var(&lots, "lots", 0.01, "lots to use")
-	flag.IntVar(&margin, "margin", 1, "margin level (default: 1)")
-	flag.BoolVar(&showOrders, "show-orders", false, "show order summary after account summary")
-	flag.Parse()
+	fs.StringVar(&checkpoint, "checkpoint", "", "save checkpoints here instead of over the one resumed from")
+	fs.Int64Var(&checkpointDays, "checkpoint-days", -1, "days between checkpoints, instead of the resumed run's")
+	logging := logFlags(fs)
+	fs.Parse(args)
 
-	// ===== SETUP =============================================================================
+	if 1 != fs.NArg() {
+		usage()
+	}
 
-	fmt.Println("Running CSV file:", csvPath)
+	cp := checkpoints.Read(fs.Arg(0))
+	if nil == cp.Config {
+		fmt.Fprintf(os.Stderr, "%s wasn't saved from a config, so there's nothing to set the run up from\n", fs.Arg(0))
+		os.Exit(1)
+	}
 
-	e := exchanges.NewWithDeets(&ticks.FXCMM1CsvReader{Path: csvPath})
+	logging()
+	defer loggers.Close()
 
-	// ----- STEVE ALGORITHM 2 v0.0.1 ----------------------------------------------------------
+	fmt.Println("Resuming checkpoint:", fs.Arg(0))
 
-	acc := accounts.NewWithDeets("Steve's Algorithm 2 v0.0.1", 10000.0)
-	// acc.SetDrawdownLimit(6.0)
-	acc.SetMargin(int64(margin))
-	acc.SetMaxRiskPerTrade(1.0)
+	c := configs.Parse(cp.Config, fs.Arg(0))
 
-	if showOrders {
-		acc.ShowOrders()
+	if !report(fs.Arg(0), c) {
+		os.Exit(1)
 	}
 
-	algo := algorithms.NewWithDeets(acc, steveorithm2)
-	algo.TradingDecision = td.NewSTD()
-	algo.AddCurrency("EURUSD")//, "AUDUSD")//, "GBPUSD")
-	algo.AttachCharts("M1")
-	algo.SetStartupDelay(120 * time.Minute) // TODO: remove this
-	algo.AddIndicator("STD", func() indicators.Indicator { return std.NewSTD(0.9969, 0.9975) })
-	algo.AddIndicator("SV",  func() indicators.Indicator { return sv.NewSV(5)                })
-	algo.AddIndicator("VV",  func() indicators.Indicator { return vv.NewVV(5, 0.33)          })
-	algo.Metadata.Set("lots", lots)
+	if "" == checkpoint {
+		checkpoint = fs.Arg(0)
+	}
 
-	e.AddAlgorithm(algo)
+	if checkpointDays < 0 {
+		checkpointDays = cp.Every
+	}
 
+	e, _ := c.Build()
+	e.SetCheckpoint(checkpoint, checkpointDays)
+	e.Resume(cp)
 	e.Run()
 }
+
+// ===== REPLAY ====================================================================================
+
+func replay(path string) {
+	rp := journals.ReplayFile(path)
+	rp.PrintSummary()
+
+	if !rp.Verified() {
+		os.Exit(1)
+	}
+}
+
+// ===== LIST ======================================================================================
+
+func list() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	section := func(title string, components []registry.Component) {
+		fmt.Fprintf(w, "%s:\n", title)
+
+		for _, c := range components {
+			fmt.Fprintf(w, "  %s\t%s\n", c.Name, c.Doc)
+		}
+
+		fmt.Fprintln(w, "")
+	}
+
+	section("Strategies", registry.Strategies())
+	section("Indicators", registry.Indicators())
+	section("Trading decisions", registry.TradingDecisions())
+
+	w.Flush()
+}
+
+// ===== DESCRIBE ==================================================================================
+
+func describe(name string) {
+	found := false
+
+	show := func(kind string, c registry.Component, ok bool) {
+		if !ok {
+			return
+		}
+
+		found = true
+
+		fmt.Printf("%s (%s)\n  %s\n\n", c.Name, kind, c.Doc)
+
+		if 0 == len(c.Params) {
+			fmt.Printf("  No parameters\n\n")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "  PARAMETER\tTYPE\tDEFAULT\t")
+
+		for _, p := range c.Params {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", p.Name, p.Kind, p.DefaultString(), p.Doc)
+		}
+
+		w.Flush()
+		fmt.Println("")
+	}
+
+	c, ok := registry.Strategy(name)
+	show("strategy", c, ok)
+
+	c, ok = registry.Indicator(name)
+	show("indicator", c, ok)
+
+	c, ok = registry.TradingDecision(name)
+	show("trading decision", c, ok)
+
+	if !found {
+		fmt.Fprintf(os.Stderr, "nothing registered as %q, see simulator list\n", name)
+		os.Exit(1)
+	}
+}
+
+// ===== VALIDATE ==================================================================================
+
+// report prints a config's problems, true if there weren't any
+func report(path string, c *configs.Config) bool {
+	problems := c.Problems()
+
+	for _, p := range c.Data.Paths {
+		if _, err := os.Stat(p); err != nil {
+			problems = append(problems, "can't read data: " + err.Error())
+		}
+	}
+
+	if 0 == len(problems) {
+		return true
+	}
+
+	fmt.Printf("%s:\n", path)
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+	}
+
+	return false
+}
+
+func validate(paths []string) {
+	if 0 == len(paths) {
+		usage()
+	}
+
+	ok := true
+
+	for _, path := range paths {
+		if report(path, configs.Load(path)) {
+			fmt.Printf("%s: ok\n", path)
+		} else {
+			ok = false
+		}
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// ===== PROGRAM ENTRYPOINT ========================================================================
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "run":
+		run(os.Args[2:])
+	case "resume":
+		resume(os.Args[2:])
+	case "replay":
+		if 3 != len(os.Args) {
+			usage()
+		}
+
+		replay(os.Args[2])
+	case "list":
+		list()
+	case "describe":
+		if 3 != len(os.Args) {
+			usage()
+		}
+
+		describe(os.Args[2])
+	case "validate":
+		validate(os.Args[2:])
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,145 @@
+package strategies
+
+import (
+	"encoding/gob"
+	"time"
+
+	td "../../ga/trading_decisions"
+
+	"../algorithms"
+	"../pips"
+	"../quotes"
+	"../registry"
+	"../stops"
+	"../ticks"
+)
+
+// ===== STEVE'S ALGORITHM #2 ======================================================================
+
+func init() {
+	registry.RegisterStrategy("steveorithm2", "Steve's algorithm #2, buys when the trading decision says so",
+		registry.Schema{
+			registry.FloatParam("lots", 0.01, "stored as the lots metadata, orders are sized by risk"),
+			registry.StringParam("decision", "std", "registered trading decision to use"),
+		},
+		func(p registry.Params) algorithms.Strategy {
+			if _, ok := registry.TradingDecision(p.String("decision")); !ok {
+				panic("unknown trading decision: " + p.String("decision"))
+			}
+
+			return &Steve2{Lots: p.Float("lots"), Decision: p.String("decision")}
+		})
+
+	// saved in checkpoints, see ticks.KeepMetadata
+	ticks.KeepMetadata("lots", "expiration_time")
+	gob.Register(time.Time{})
+}
+
+// Steve2 runs Steveorithm2 with a registered trading decision
+type Steve2 struct {
+	algorithms.BaseStrategy
+
+	Lots     float64
+	Decision string
+}
+
+func (s *Steve2) OnStart(algo *algorithms.Algorithm) {
+	algo.TradingDecision = registry.NewTradingDecision(s.Decision, nil)
+	algo.Metadata.Set("lots", s.Lots)
+}
+
+func (s *Steve2) OnTick(algo *algorithms.Algorithm, tick *ticks.MarketTick) {
+	Steveorithm2(algo, tick)
+}
+
+// Steveorithm2 is the logic function, for algorithms.NewWithDeets. It needs an M1 chart with 60
+// candles of history and algo.TradingDecision set.
+func Steveorithm2(algo *algorithms.Algorithm, tick *ticks.MarketTick) {
+	if algo.HasOpenOrders() {
+		openOrderCount := 0
+
+		for _, order := range algo.OpenOrders() {
+			openOrderCount += 1
+
+			if order.Symbol != tick.Symbol {
+				continue
+			}
+
+			if order.Metadata.Fetch("expiration_time").(time.Time).Before(tick.Time) {
+				algo.Broker.CloseOrder(algo.Account, order, tick)
+				continue
+			}
+
+			if order.PercentToTakeProfit() > 75.0 {
+				p := pips.Pip(-(float64(order.GetTakeProfit().Pips) / 1.5))
+				order.SetStopLoss(p)
+			}
+		}
+
+		// if openOrderCount >= 3 {
+		// 	return
+		// }
+	}
+
+	chart := algo.Charts[tick.Symbol]["M1"]
+	candles := chart.GetCandles(60)
+
+	// 1. previous minute’s open_bid/(60 minute m1 MA) >=1.0026
+	ma60 := 0.0
+	for _, candle := range candles {
+		ma60 += candle.OpenBid
+	}
+	ma60 /= 60.0
+
+	lastOpen := candles[0].OpenBid
+
+	crossover := lastOpen / ma60
+
+	// 2. previous minute’s spread has increased since opening (open_ask-open_bid)<(close_ask-close_bid)
+	// spreadIncreased := (candles[0].OpenAsk - candles[0].OpenBid) < (candles[0].CloseAsk - candles[0].CloseBid)
+	spreadsies := candles[0].OpenBid - candles[0].CloseBid
+	spreadIncreased := spreadsies <= 0.0011 && spreadsies >= -0.0011
+
+	// 3. last 5 ticks have more than  100 ticks  (you might be able to use turning up for this)
+	totalTicks := int64(0)
+	for _, candle := range chart.GetCandles(5) {
+		totalTicks += candle.Volume
+	}
+
+	_ = spreadIncreased
+	_ = totalTicks
+	_ = crossover
+
+	data := make(map[string]interface{})
+	data["spreadIncreased"] = spreadIncreased
+	data["totalTicks"] = totalTicks
+	data["crossover"] = crossover
+
+	switch algo.TradingDecision.Run(data) {
+	default:
+	case td.BUY:
+		tpPips := quotes.DifferenceInPips(tick.Symbol, tick.OpenAsk, tick.OpenAsk * 1.004)
+		slPips := pips.Pip(float64(tpPips) * 0.90)
+
+		o := algo.Broker.OpenBuyOrder(
+			algo.Account,
+			tick.Symbol,
+			tick,
+			// algo.Metadata.Fetch("lots").(float64),
+			algo.Account.LotSizeForTrade(slPips),
+			stops.NoStopLoss(),
+			stops.NoTakeProfit(),
+		)
+
+		// refused by a risk manager, see algo.LastRejection
+		if nil == o {
+			return
+		}
+
+		o.SetStopLoss(slPips)
+		o.SetTakeProfit(tpPips)
+		o.Metadata.Set("expiration_time", o.OpenedAt.Add(60 * time.Minute))
+	case td.SELL:
+		algo.Log().Debug("sell signal, but selling isn't implemented", "symbol", tick.Symbol)
+	}
+}
@@ -0,0 +1,349 @@
+package synthetic
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"../pips"
+	"../quotes"
+	"../ticks"
+	"../utils"
+)
+
+// Seeded, reproducible fake market data for unit and stress testing strategies and the engine.
+// The same Generator settings and seed always produce the same ticks.
+
+const YEAR = float64(365 * 24 * time.Hour)
+
+// dt as a fraction of a year, which is what the models' annualized parameters are in
+func years(dt time.Duration) float64 {
+	return float64(dt) / YEAR
+}
+
+// ===== PRICE MODELS ==============================================================================
+
+type PriceModel interface {
+	// Next returns the price dt after price. Models may keep state (e.g., the current regime), see
+	// Resetter.
+	Next(r *rand.Rand, price float64, dt time.Duration) float64
+}
+
+// Models with state put it back the way it started with Reset, which the generator calls at the
+// start of every run so the same seed always gives the same ticks
+type Resetter interface {
+	Reset()
+}
+
+// ----- GEOMETRIC BROWNIAN MOTION -----------------------------------------------------------------
+
+// Drift and Volatility are annualized, e.g. 0.0 and 0.08 for a quiet major pair
+type GBM struct {
+	Drift      float64
+	Volatility float64
+}
+
+func (g *GBM) Next(r *rand.Rand, price float64, dt time.Duration) float64 {
+	t := years(dt)
+
+	return price * math.Exp((g.Drift - (g.Volatility * g.Volatility) / 2.0) * t + g.Volatility * math.Sqrt(t) * r.NormFloat64())
+}
+
+// ----- ORNSTEIN-UHLENBECK ------------------------------------------------------------------------
+
+// Mean reverting towards Mean at Reversion (per year). Volatility is in price units per sqrt(year).
+type OrnsteinUhlenbeck struct {
+	Mean       float64
+	Reversion  float64
+	Volatility float64
+}
+
+func (ou *OrnsteinUhlenbeck) Next(r *rand.Rand, price float64, dt time.Duration) float64 {
+	t := years(dt)
+
+	return price + ou.Reversion * (ou.Mean - price) * t + ou.Volatility * math.Sqrt(t) * r.NormFloat64()
+}
+
+// ----- REGIME SWITCHING --------------------------------------------------------------------------
+
+// GBM whose volatility jumps between Volatilities. SwitchRate is the expected number of regime
+// changes per year.
+type RegimeSwitching struct {
+	Drift        float64
+	Volatilities []float64
+	SwitchRate   float64
+
+	regime int
+}
+
+func (rs *RegimeSwitching) Regime() int {
+	return rs.regime
+}
+
+func (rs *RegimeSwitching) Reset() {
+	rs.regime = 0
+}
+
+func (rs *RegimeSwitching) Next(r *rand.Rand, price float64, dt time.Duration) float64 {
+	if 0 == len(rs.Volatilities) {
+		panic("regime switching model needs at least one volatility")
+	}
+
+	if r.Float64() < 1.0 - math.Exp(-rs.SwitchRate * years(dt)) {
+		rs.regime = r.Intn(len(rs.Volatilities))
+	}
+
+	g := GBM{Drift: rs.Drift, Volatility: rs.Volatilities[rs.regime]}
+
+	return g.Next(r, price, dt)
+}
+
+// ----- JUMP DIFFUSION ----------------------------------------------------------------------------
+
+// Merton jump diffusion: GBM plus JumpIntensity jumps per year, each a log return drawn from
+// N(JumpMean, JumpStdDev)
+type JumpDiffusion struct {
+	GBM
+
+	JumpIntensity float64
+	JumpMean      float64
+	JumpStdDev    float64
+}
+
+func (jd *JumpDiffusion) Next(r *rand.Rand, price float64, dt time.Duration) float64 {
+	price = jd.GBM.Next(r, price, dt)
+
+	for i := poisson(r, jd.JumpIntensity * years(dt)); i > 0; i-- {
+		price *= math.Exp(jd.JumpMean + jd.JumpStdDev * r.NormFloat64())
+	}
+
+	return price
+}
+
+// ===== SPREAD & VOLUME MODELS ====================================================================
+
+type SpreadModel interface {
+	Spread(r *rand.Rand, t time.Time) pips.Pip
+}
+
+type ConstantSpread struct {
+	Pips pips.Pip
+}
+
+func (cs *ConstantSpread) Spread(r *rand.Rand, t time.Time) pips.Pip {
+	return cs.Pips
+}
+
+// Normally distributed spread, never below Min. Multiplied by RolloverMultiplier around the
+// 17:00 New York rollover (21:00-22:00 UTC), when real spreads blow out.
+type NormalSpread struct {
+	Mean   pips.Pip
+	StdDev pips.Pip
+	Min    pips.Pip
+
+	RolloverMultiplier float64
+}
+
+func (ns *NormalSpread) Spread(r *rand.Rand, t time.Time) pips.Pip {
+	s := float64(ns.Mean) + float64(ns.StdDev) * r.NormFloat64()
+
+	hour := t.UTC().Hour()
+	if ns.RolloverMultiplier > 0.0 && hour >= utils.WEEKEND_CLOSE_HOUR_UTC && hour < utils.WEEKEND_OPEN_HOUR_UTC {
+		s *= ns.RolloverMultiplier
+	}
+
+	return pips.Pip(math.Max(s, float64(ns.Min)))
+}
+
+type VolumeModel interface {
+	Volume(r *rand.Rand, t time.Time) int64
+}
+
+// Poisson distributed tick count per bar
+type PoissonVolume struct {
+	Mean float64
+}
+
+func (pv *PoissonVolume) Volume(r *rand.Rand, t time.Time) int64 {
+	return poisson(r, pv.Mean)
+}
+
+func poisson(r *rand.Rand, mean float64) int64 {
+	if mean <= 0.0 {
+		return 0
+	}
+
+	// normal approximation, Knuth's method gets slow (and underflows) for big means
+	if mean > 30.0 {
+		return int64(math.Max(0.0, math.Floor(mean + math.Sqrt(mean) * r.NormFloat64() + 0.5)))
+	}
+
+	limit := math.Exp(-mean)
+	k := int64(0)
+
+	for p := r.Float64(); p > limit; p *= r.Float64() {
+		k++
+	}
+
+	return k
+}
+
+// ===== GENERATOR =================================================================================
+
+func NewWithDeets(symbol string, start, end time.Time, seed int64, startPrice float64, model PriceModel) *Generator {
+	return &Generator{
+		Symbol:      symbol,
+		Start:       start,
+		End:         end,
+		Seed:        seed,
+		StartPrice:  startPrice,
+		Price:       model,
+		Spread:      &ConstantSpread{Pips: 1.0},
+		Volume:      &PoissonVolume{Mean: 60.0},
+		Period:      time.Minute,
+		SubSteps:    10,
+		WeekendGaps: true,
+	}
+}
+
+// Generates bid/ask bars (or ticks, see EmitTicks) for one symbol from Start until End. Merge
+// several with ticks.NewMerger for multi-symbol data.
+type Generator struct {
+	Symbol     string
+	Start      time.Time
+	End        time.Time
+	Seed       int64
+	StartPrice float64
+
+	Price  PriceModel
+	Spread SpreadModel
+	Volume VolumeModel
+
+	Period   time.Duration // bar period
+	SubSteps int64         // price steps per bar, which make up each bar's high/low
+
+	EmitTicks   bool // emit every sub-step as a raw tick instead of emitting bars
+	WeekendGaps bool // skip the weekend close like the real market
+}
+
+func (g *Generator) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(g)
+}
+
+func (g *Generator) ask(r *rand.Rand, bid float64, t time.Time) float64 {
+	q := quotes.NewQuote(g.Symbol, bid)
+	q.AddPips(g.Spread.Spread(r, t))
+
+	return q.ToFloat64()
+}
+
+func (g *Generator) Ticks() chan *ticks.MarketTick {
+	if g.SubSteps < 1 || g.Period <= 0 {
+		panic("generator needs a period > 0 and at least one sub-step")
+	}
+
+	if rs, ok := g.Price.(Resetter); ok {
+		rs.Reset()
+	}
+
+	c := make(chan *ticks.MarketTick, 100)
+
+	go func() {
+		r := rand.New(rand.NewSource(g.Seed))
+		step := g.Period / time.Duration(g.SubSteps)
+		bid := g.StartPrice
+
+		for t := g.Start; t.Before(g.End); t = t.Add(g.Period) {
+			if g.WeekendGaps && utils.IsWeekend(t) {
+				continue
+			}
+
+			bar := &ticks.MarketTick{
+				Symbol:  g.Symbol,
+				Time:    t,
+				OpenBid: bid,
+				HighBid: bid,
+				LowBid:  bid,
+			}
+			bar.OpenAsk = g.ask(r, bid, t)
+			bar.HighAsk = bar.OpenAsk
+			bar.LowAsk  = bar.OpenAsk
+			bar.CloseAsk = bar.OpenAsk
+
+			if g.EmitTicks {
+				c <- ticks.NewRawTick(g.Symbol, t, bar.OpenBid, bar.OpenAsk)
+			}
+
+			for i := int64(1); i < g.SubSteps; i++ {
+				tickTime := t.Add(time.Duration(i) * step)
+
+				bid = math.Max(g.Price.Next(r, bid, step), 0.00001)
+				ask := g.ask(r, bid, tickTime)
+
+				if g.EmitTicks {
+					c <- ticks.NewRawTick(g.Symbol, tickTime, bid, ask)
+				}
+
+				bar.HighBid  = math.Max(bar.HighBid, bid)
+				bar.LowBid   = math.Min(bar.LowBid, bid)
+				bar.HighAsk  = math.Max(bar.HighAsk, ask)
+				bar.LowAsk   = math.Min(bar.LowAsk, ask)
+				bar.CloseAsk = ask
+			}
+
+			bar.CloseBid = bid
+			bar.Volume   = g.Volume.Volume(r, t)
+
+			if !g.EmitTicks {
+				c <- bar
+			}
+
+			// the last step of the period, so the next bar opens a step on from this one's close
+			bid = math.Max(g.Price.Next(r, bid, step), 0.00001)
+		}
+
+		close(c)
+	}()
+
+	return c
+}
+
+// ===== SCRIPTED ==================================================================================
+
+// NewScripted replays an exact list of bids as flat bars, one per period, with a constant spread.
+// Handy for checking fills, stops and margin against hand-worked scenarios.
+func NewScripted(symbol string, start time.Time, period time.Duration, spread pips.Pip, bids ...float64) *Scripted {
+	return &Scripted{Symbol: symbol, Start: start, Period: period, Spread: spread, Bids: bids}
+}
+
+type Scripted struct {
+	Symbol string
+	Start  time.Time
+	Period time.Duration
+	Spread pips.Pip
+	Bids   []float64
+}
+
+func (s *Scripted) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(s)
+}
+
+func (s *Scripted) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	go func() {
+		for i, bid := range s.Bids {
+			q := quotes.NewQuote(s.Symbol, bid)
+			q.AddPips(s.Spread)
+
+			tick := ticks.NewRawTick(s.Symbol, s.Start.Add(time.Duration(i) * s.Period), bid, q.ToFloat64())
+			tick.Volume = 0
+
+			c <- tick
+		}
+
+		close(c)
+	}()
+
+	return c
+}
@@ -0,0 +1,150 @@
+package synthetic
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"../ticks"
+	"../utils"
+)
+
+func collect(mt ticks.MarketTicker) []*ticks.MarketTick {
+	res := []*ticks.MarketTick{}
+	for tick := range mt.Ticks() {
+		res = append(res, tick)
+	}
+
+	return res
+}
+
+// Friday afternoon through Monday morning, so the weekend is in there
+var start = time.Date(2014, 3, 7, 12, 0, 0, 0, time.UTC)
+var end = time.Date(2014, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func TestSameSeedSameTicks(t *testing.T) {
+	a := collect(NewWithDeets("EURUSD", start, end, 42, 1.3, &GBM{Volatility: 0.1}))
+	b := collect(NewWithDeets("EURUSD", start, end, 42, 1.3, &GBM{Volatility: 0.1}))
+	c := collect(NewWithDeets("EURUSD", start, end, 43, 1.3, &GBM{Volatility: 0.1}))
+
+	if len(a) != len(b) || 0 == len(a) {
+		t.Fatalf("Expected the same number of ticks, got %d and %d", len(a), len(b))
+	}
+
+	for i := range a {
+		if a[i].Time != b[i].Time || a[i].CloseBid != b[i].CloseBid || a[i].CloseAsk != b[i].CloseAsk || a[i].Volume != b[i].Volume {
+			t.Fatalf("Tick %d differs between runs with the same seed", i)
+		}
+	}
+
+	if a[len(a) - 1].CloseBid == c[len(c) - 1].CloseBid {
+		t.Errorf("Expected a different seed to give a different path")
+	}
+}
+
+// the regime a run finishes in mustn't carry over into the next one
+func TestSameGeneratorSameTicks(t *testing.T) {
+	g := NewWithDeets("EURUSD", start, end, 42, 1.3, &RegimeSwitching{Volatilities: []float64{0.05, 0.3, 0.6}, SwitchRate: 500.0})
+
+	a := collect(g)
+	if 0 == g.Price.(*RegimeSwitching).Regime() {
+		t.Fatalf("Expected the first run to finish out of the starting regime")
+	}
+
+	b := collect(g)
+
+	if len(a) != len(b) {
+		t.Fatalf("Expected the same number of ticks, got %d and %d", len(a), len(b))
+	}
+
+	for i := range a {
+		if a[i].CloseBid != b[i].CloseBid || a[i].HighBid != b[i].HighBid || a[i].LowBid != b[i].LowBid {
+			t.Fatalf("Tick %d differs between runs of the same generator", i)
+		}
+	}
+}
+
+func TestBarsAreConsistent(t *testing.T) {
+	models := []PriceModel{
+		&GBM{Volatility: 0.1},
+		&OrnsteinUhlenbeck{Mean: 1.3, Reversion: 50.0, Volatility: 0.1},
+		&RegimeSwitching{Volatilities: []float64{0.05, 0.3}, SwitchRate: 500.0},
+		&JumpDiffusion{GBM: GBM{Volatility: 0.1}, JumpIntensity: 1000.0, JumpStdDev: 0.01},
+	}
+
+	for _, model := range models {
+		g := NewWithDeets("EURUSD", start, end, 1, 1.3, model)
+		g.Spread = &NormalSpread{Mean: 1.5, StdDev: 0.5, Min: 0.5, RolloverMultiplier: 4.0}
+
+		var last *ticks.MarketTick
+
+		for _, bar := range collect(g) {
+			if utils.IsWeekend(bar.Time) {
+				t.Fatalf("Got a weekend bar at %s", bar.Time)
+			}
+
+			if bar.HighBid < math.Max(bar.OpenBid, bar.CloseBid) || bar.LowBid > math.Min(bar.OpenBid, bar.CloseBid) {
+				t.Fatalf("Bid OHLC inconsistent at %s: %+v", bar.Time, bar)
+			}
+
+			if bar.OpenAsk < bar.OpenBid || bar.CloseAsk < bar.CloseBid || bar.LowAsk < bar.LowBid {
+				t.Fatalf("Negative spread at %s: %+v", bar.Time, bar)
+			}
+
+			if nil != last && !bar.Time.After(last.Time) {
+				t.Fatalf("Bars out of order at %s", bar.Time)
+			}
+
+			last = bar
+		}
+	}
+}
+
+func TestWeekendGapsAreOptional(t *testing.T) {
+	g := NewWithDeets("EURUSD", start, end, 1, 1.3, &GBM{Volatility: 0.1})
+	withGaps := len(collect(g))
+
+	g.WeekendGaps = false
+	withoutGaps := len(collect(g))
+
+	if withoutGaps != int(end.Sub(start) / time.Minute) {
+		t.Errorf("Expected a bar for every minute, got %d", withoutGaps)
+	}
+
+	if withGaps != int(utils.TradingDuration(start, end) / time.Minute) {
+		t.Errorf("Expected no bars over the weekend, got %d", withGaps)
+	}
+}
+
+func TestEmitTicks(t *testing.T) {
+	g := NewWithDeets("EURUSD", start, start.Add(time.Hour), 1, 1.3, &GBM{Volatility: 0.1})
+	g.EmitTicks = true
+
+	res := collect(g)
+
+	if len(res) != 60 * 10 {
+		t.Fatalf("Expected 10 ticks per minute, got %d", len(res))
+	}
+
+	for _, tick := range res {
+		if tick.OpenBid != tick.CloseBid || tick.HighAsk != tick.LowAsk {
+			t.Fatalf("Expected raw ticks to be flat, got %+v", tick)
+		}
+	}
+}
+
+func TestScripted(t *testing.T) {
+	res := collect(NewScripted("USDJPY", start, time.Minute, 2.0, 100.0, 100.5, 99.75))
+
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 ticks, got %d", len(res))
+	}
+
+	if res[1].CloseBid != 100.5 || math.Abs(res[1].CloseAsk - 100.52) > 0.000001 {
+		t.Errorf("Expected 100.5/100.52, got %.5f/%.5f", res[1].CloseBid, res[1].CloseAsk)
+	}
+
+	if res[2].Time != start.Add(2 * time.Minute) {
+		t.Errorf("Expected ticks one period apart, got %s", res[2].Time)
+	}
+}
@@ -0,0 +1,504 @@
+package tick_cache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"../ticks"
+)
+
+// A compact on-disk format for MarketTick series so repeated runs don't spend all their time in
+// strconv.ParseFloat.
+//
+// File layout:
+//
+//   header: "TKC1" | price scale (uint32) | block size (uint32)
+//   blocks: gzip'd columnar data, see encodeBlock
+//   index:  symbol table + one entry per block (time range, offset, length, tick count)
+//   footer: index offset (uint64) | "TKC1"
+//
+// Times are delta encoded nanoseconds, prices are fixed-point (price * scale) and delta encoded
+// per column against the last tick of the same symbol, all as varints. The index lets readers
+// seek straight to the block holding a given time without decompressing anything before it.
+
+const MAGIC = "TKC1"
+
+const DEFAULT_PRICE_SCALE = 1000000 // 6 decimal places, FXCM quotes have at most 5
+const DEFAULT_BLOCK_SIZE  = 4096    // ticks per block
+
+const headerSize = 12
+const footerSize = 12
+
+// ===== BLOCK INDEX ===============================================================================
+
+type BlockInfo struct {
+	Start  time.Time
+	End    time.Time
+	Offset int64
+	Length int64
+	Count  int64
+}
+
+// ===== COLUMN ENCODING ===========================================================================
+
+type columnWriter struct {
+	buf  bytes.Buffer
+	tmp  [binary.MaxVarintLen64]byte
+	last int64
+
+	lastBySymbol map[uint64]int64
+}
+
+func (cw *columnWriter) putDelta(v int64) {
+	n := binary.PutVarint(cw.tmp[:], v - cw.last)
+	cw.buf.Write(cw.tmp[:n])
+	cw.last = v
+}
+
+// putSymbolDelta deltas against the last value for the same symbol, so interleaved symbols
+// trading at very different prices (EURUSD vs USDJPY) still encode small
+func (cw *columnWriter) putSymbolDelta(symbol uint64, v int64) {
+	if nil == cw.lastBySymbol {
+		cw.lastBySymbol = make(map[uint64]int64)
+	}
+
+	cw.last = cw.lastBySymbol[symbol]
+	cw.putDelta(v)
+	cw.lastBySymbol[symbol] = v
+}
+
+func (cw *columnWriter) putUvarint(v uint64) {
+	n := binary.PutUvarint(cw.tmp[:], v)
+	cw.buf.Write(cw.tmp[:n])
+}
+
+type columnReader struct {
+	r    *bytes.Reader
+	last int64
+
+	lastBySymbol map[uint64]int64
+}
+
+func (cr *columnReader) delta() int64 {
+	d, err := binary.ReadVarint(cr.r)
+	if err != nil {
+		panic("tick_cache: corrupt block: " + err.Error())
+	}
+
+	cr.last += d
+
+	return cr.last
+}
+
+func (cr *columnReader) symbolDelta(symbol uint64) int64 {
+	if nil == cr.lastBySymbol {
+		cr.lastBySymbol = make(map[uint64]int64)
+	}
+
+	cr.last = cr.lastBySymbol[symbol]
+	v := cr.delta()
+	cr.lastBySymbol[symbol] = v
+
+	return v
+}
+
+func (cr *columnReader) uvarint() uint64 {
+	v, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		panic("tick_cache: corrupt block: " + err.Error())
+	}
+
+	return v
+}
+
+// ===== WRITER ====================================================================================
+
+func NewWriter(path string) *Writer {
+	return NewWriterWithDeets(path, DEFAULT_PRICE_SCALE, DEFAULT_BLOCK_SIZE)
+}
+
+func NewWriterWithDeets(path string, scale, blockSize int64) *Writer {
+	if scale <= 0 || blockSize <= 0 {
+		panic("price scale and block size must be > 0")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	w := Writer{
+		file:      f,
+		scale:     float64(scale),
+		blockSize: blockSize,
+		symbolIds: make(map[string]uint64),
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, MAGIC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(scale))
+	binary.LittleEndian.PutUint32(header[8:], uint32(blockSize))
+	w.write(header)
+
+	return &w
+}
+
+type Writer struct {
+	file   *os.File
+	offset int64
+
+	scale     float64
+	blockSize int64
+
+	symbols   []string
+	symbolIds map[string]uint64
+
+	pending []*ticks.MarketTick
+	blocks  []BlockInfo
+
+	lastTime time.Time
+	count    int64
+}
+
+func (w *Writer) write(b []byte) {
+	n, err := w.file.Write(b)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	w.offset += int64(n)
+}
+
+func (w *Writer) toFixed(price float64) int64 {
+	return int64(math.Floor(price * w.scale + 0.5))
+}
+
+func (w *Writer) symbolId(symbol string) uint64 {
+	id, ok := w.symbolIds[symbol]
+	if !ok {
+		id = uint64(len(w.symbols))
+		w.symbols = append(w.symbols, symbol)
+		w.symbolIds[symbol] = id
+	}
+
+	return id
+}
+
+// Write appends a tick. Ticks must be written in time order or the index is useless.
+func (w *Writer) Write(tick *ticks.MarketTick) {
+	if tick.Time.Before(w.lastTime) {
+		panic(fmt.Sprintf(
+			"tick_cache: ticks must be written in time order (got %s after %s)",
+			tick.Time,
+			w.lastTime,
+		))
+	}
+
+	w.lastTime = tick.Time
+	w.pending = append(w.pending, tick)
+	w.count += 1
+
+	if int64(len(w.pending)) >= w.blockSize {
+		w.flushBlock()
+	}
+}
+
+func (w *Writer) Count() int64 {
+	return w.count
+}
+
+// columns: symbol ids, times, the 8 OHLC bid/ask prices and volume
+func (w *Writer) encodeBlock(block []*ticks.MarketTick) []byte {
+	var count, symbols, times, volumes columnWriter
+	prices := make([]columnWriter, 8)
+
+	count.putUvarint(uint64(len(block)))
+
+	for _, t := range block {
+		id := w.symbolId(t.Symbol)
+
+		symbols.putUvarint(id)
+		times.putDelta(t.Time.UnixNano())
+
+		for i, p := range []float64{
+			t.OpenBid, t.HighBid, t.LowBid, t.CloseBid,
+			t.OpenAsk, t.HighAsk, t.LowAsk, t.CloseAsk,
+		} {
+			prices[i].putSymbolDelta(id, w.toFixed(p))
+		}
+
+		volumes.putSymbolDelta(id, t.Volume)
+	}
+
+	var raw bytes.Buffer
+	raw.Write(count.buf.Bytes())
+	raw.Write(symbols.buf.Bytes())
+	raw.Write(times.buf.Bytes())
+	for i := range prices {
+		raw.Write(prices[i].buf.Bytes())
+	}
+	raw.Write(volumes.buf.Bytes())
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(raw.Bytes())
+	gz.Close()
+
+	return compressed.Bytes()
+}
+
+func (w *Writer) flushBlock() {
+	if 0 == len(w.pending) {
+		return
+	}
+
+	data := w.encodeBlock(w.pending)
+
+	w.blocks = append(w.blocks, BlockInfo{
+		Start:  w.pending[0].Time,
+		End:    w.pending[len(w.pending) - 1].Time,
+		Offset: w.offset,
+		Length: int64(len(data)),
+		Count:  int64(len(w.pending)),
+	})
+
+	w.write(data)
+	w.pending = w.pending[:0]
+}
+
+// Close flushes the last block and writes the index. The file isn't readable until this is done.
+func (w *Writer) Close() {
+	w.flushBlock()
+
+	indexOffset := w.offset
+
+	var index columnWriter
+	index.putUvarint(uint64(len(w.symbols)))
+	for _, symbol := range w.symbols {
+		index.putUvarint(uint64(len(symbol)))
+		index.buf.WriteString(symbol)
+	}
+
+	index.putUvarint(uint64(len(w.blocks)))
+	for _, b := range w.blocks {
+		index.putUvarint(uint64(b.Start.UnixNano()))
+		index.putUvarint(uint64(b.End.UnixNano()))
+		index.putUvarint(uint64(b.Offset))
+		index.putUvarint(uint64(b.Length))
+		index.putUvarint(uint64(b.Count))
+	}
+
+	w.write(index.buf.Bytes())
+
+	footer := make([]byte, footerSize)
+	binary.LittleEndian.PutUint64(footer, uint64(indexOffset))
+	copy(footer[8:], MAGIC)
+	w.write(footer)
+
+	if err := w.file.Close(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// ===== READER ====================================================================================
+
+func NewReader(path string) *Reader {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+
+	r := Reader{Path: path}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil || MAGIC != string(header[:4]) {
+		panic("tick_cache: not a tick cache file: " + path)
+	}
+
+	r.scale = float64(binary.LittleEndian.Uint32(header[4:]))
+
+	stat, err := f.Stat()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := f.ReadAt(footer, stat.Size() - footerSize); err != nil || MAGIC != string(footer[8:]) {
+		panic("tick_cache: missing footer, was the writer closed? " + path)
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(footer))
+
+	index := make([]byte, stat.Size() - footerSize - indexOffset)
+	if _, err := f.ReadAt(index, indexOffset); err != nil {
+		log.Fatalln(err)
+	}
+
+	cr := columnReader{r: bytes.NewReader(index)}
+
+	numSymbols := cr.uvarint()
+	for i := uint64(0); i < numSymbols; i++ {
+		name := make([]byte, cr.uvarint())
+		if _, err := io.ReadFull(cr.r, name); err != nil {
+			panic("tick_cache: corrupt index: " + err.Error())
+		}
+
+		r.symbols = append(r.symbols, string(name))
+	}
+
+	numBlocks := cr.uvarint()
+	for i := uint64(0); i < numBlocks; i++ {
+		r.blocks = append(r.blocks, BlockInfo{
+			Start:  time.Unix(0, int64(cr.uvarint())).UTC(),
+			End:    time.Unix(0, int64(cr.uvarint())).UTC(),
+			Offset: int64(cr.uvarint()),
+			Length: int64(cr.uvarint()),
+			Count:  int64(cr.uvarint()),
+		})
+	}
+
+	return &r
+}
+
+type Reader struct {
+	Path string
+
+	scale   float64
+	symbols []string
+	blocks  []BlockInfo
+
+	seekTo time.Time
+}
+
+func (r *Reader) Ticker() ticks.MarketTicker {
+	return ticks.MarketTicker(r)
+}
+
+func (r *Reader) Blocks() []BlockInfo {
+	return r.blocks
+}
+
+func (r *Reader) Symbols() []string {
+	return r.symbols
+}
+
+func (r *Reader) Count() int64 {
+	total := int64(0)
+
+	for _, b := range r.blocks {
+		total += b.Count
+	}
+
+	return total
+}
+
+// Seek makes the next call to Ticks() start at the first tick at or after t
+func (r *Reader) Seek(t time.Time) {
+	r.seekTo = t
+}
+
+func (r *Reader) firstBlock() int {
+	return sort.Search(len(r.blocks), func(i int) bool {
+		return !r.blocks[i].End.Before(r.seekTo)
+	})
+}
+
+func (r *Reader) decodeBlock(f *os.File, b BlockInfo) []*ticks.MarketTick {
+	gz, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(f, b.Offset, b.Length)))
+	if err != nil {
+		panic("tick_cache: corrupt block: " + err.Error())
+	}
+
+	raw, err := ioutil.ReadAll(gz)
+	if err != nil {
+		panic("tick_cache: corrupt block: " + err.Error())
+	}
+
+	// every column is read sequentially out of the same buffer, one column at a time
+	cr := columnReader{r: bytes.NewReader(raw)}
+	n := int(cr.uvarint())
+
+	ids := make([]uint64, n)
+	res := make([]*ticks.MarketTick, n)
+	for i := range res {
+		ids[i] = cr.uvarint()
+		res[i] = &ticks.MarketTick{Symbol: r.symbols[ids[i]]}
+	}
+
+	cr.last = 0
+	for _, t := range res {
+		t.Time = time.Unix(0, cr.delta()).UTC()
+	}
+
+	for col := 0; col < 8; col++ {
+		cr.lastBySymbol = nil
+
+		for i, t := range res {
+			p := float64(cr.symbolDelta(ids[i])) / r.scale
+
+			switch col {
+			case 0:
+				t.OpenBid = p
+			case 1:
+				t.HighBid = p
+			case 2:
+				t.LowBid = p
+			case 3:
+				t.CloseBid = p
+			case 4:
+				t.OpenAsk = p
+			case 5:
+				t.HighAsk = p
+			case 6:
+				t.LowAsk = p
+			case 7:
+				t.CloseAsk = p
+			}
+		}
+	}
+
+	cr.lastBySymbol = nil
+	for i, t := range res {
+		t.Volume = cr.symbolDelta(ids[i])
+	}
+
+	return res
+}
+
+func (r *Reader) Ticks() chan *ticks.MarketTick {
+	c := make(chan *ticks.MarketTick, 100)
+
+	go func() {
+		f, err := os.Open(r.Path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		defer f.Close()
+
+		for i := r.firstBlock(); i < len(r.blocks); i++ {
+			for _, tick := range r.decodeBlock(f, r.blocks[i]) {
+				if tick.Time.Before(r.seekTo) {
+					continue
+				}
+
+				c <- tick
+			}
+		}
+
+		close(c)
+	}()
+
+	return c
+}
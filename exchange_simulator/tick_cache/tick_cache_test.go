@@ -0,0 +1,206 @@
+package tick_cache
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"../ticks"
+)
+
+var start = time.Date(2014, 3, 3, 0, 0, 0, 0, time.UTC)
+
+// testTicks interleaves EURUSD and USDJPY, which trade at very different prices, sometimes at the
+// same time. Prices are whole points so they survive the fixed-point round trip exactly.
+func testTicks(n int) []*ticks.MarketTick {
+	res := []*ticks.MarketTick{}
+	at := start
+
+	for i := 0; i < n; i++ {
+		if 0 != i % 3 {
+			at = at.Add(time.Duration(i % 7 + 1) * 250 * time.Millisecond)
+		}
+
+		t := &ticks.MarketTick{Symbol: "EURUSD", Time: at}
+		base, point := 137000 + (i * 37) % 500, 100000.0
+		if 0 == i % 2 {
+			t.Symbol = "USDJPY"
+			base, point = 10200 + (i * 11) % 300, 100.0
+		}
+
+		t.OpenBid = float64(base) / point
+		t.HighBid = float64(base + 9) / point
+		t.LowBid = float64(base - 4) / point
+		t.CloseBid = float64(base + 3) / point
+		t.OpenAsk = float64(base + 2) / point
+		t.HighAsk = float64(base + 11) / point
+		t.LowAsk = float64(base - 2) / point
+		t.CloseAsk = float64(base + 5) / point
+		t.Volume = int64((i * 7919) % 100000)
+
+		res = append(res, t)
+	}
+
+	return res
+}
+
+// writeCache writes ticks to a cache in dir, 7 to a block so there are plenty of blocks
+func writeCache(dir string, data []*ticks.MarketTick) string {
+	path := filepath.Join(dir, "test.tkc")
+
+	w := NewWriterWithDeets(path, DEFAULT_PRICE_SCALE, 7)
+	for _, tick := range data {
+		w.Write(tick)
+	}
+	w.Close()
+
+	return path
+}
+
+func read(r *Reader) []*ticks.MarketTick {
+	res := []*ticks.MarketTick{}
+	for tick := range r.Ticks() {
+		res = append(res, tick)
+	}
+
+	return res
+}
+
+func expectTicks(t *testing.T, expected, got []*ticks.MarketTick) {
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d ticks, got %d", len(expected), len(got))
+	}
+
+	for i, e := range expected {
+		g := got[i]
+
+		if e.Symbol != g.Symbol || !e.Time.Equal(g.Time) || e.Volume != g.Volume ||
+			e.OpenBid != g.OpenBid || e.HighBid != g.HighBid || e.LowBid != g.LowBid || e.CloseBid != g.CloseBid ||
+			e.OpenAsk != g.OpenAsk || e.HighAsk != g.HighAsk || e.LowAsk != g.LowAsk || e.CloseAsk != g.CloseAsk {
+			t.Errorf("tick %d: expected %+v, got %+v", i, e, g)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tick_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := testTicks(100)
+	r := NewReader(writeCache(dir, data))
+
+	if 100 != r.Count() || 15 != len(r.Blocks()) {
+		t.Errorf("expected 100 ticks in 15 blocks, got %d in %d", r.Count(), len(r.Blocks()))
+	}
+
+	if 2 != len(r.Symbols()) || "USDJPY" != r.Symbols()[0] || "EURUSD" != r.Symbols()[1] {
+		t.Errorf("expected USDJPY and EURUSD, got %v", r.Symbols())
+	}
+
+	expectTicks(t, data, read(r))
+}
+
+func TestSeek(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := testTicks(100)
+	r := NewReader(writeCache(dir, data))
+
+	// exactly on a tick in the middle of a block, so everything at that time comes back too
+	at := data[45].Time
+	first := 45
+	for first > 0 && data[first - 1].Time.Equal(at) {
+		first -= 1
+	}
+
+	r.Seek(at)
+	expectTicks(t, data[first:], read(r))
+
+	// between ticks
+	r.Seek(data[61].Time.Add(-time.Millisecond))
+	expectTicks(t, data[61:], read(r))
+
+	r.Seek(data[99].Time.Add(time.Nanosecond))
+	expectTicks(t, []*ticks.MarketTick{}, read(r))
+
+	r.Seek(time.Time{})
+	expectTicks(t, data, read(r))
+}
+
+func expectPanic(t *testing.T, what string, f func()) {
+	defer func() {
+		if nil == recover() {
+			t.Errorf("expected %s to panic", what)
+		}
+	}()
+
+	f()
+}
+
+func TestBadFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := writeCache(dir, testTicks(100))
+	good, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := func(name string, corrupt func(b []byte) []byte) string {
+		b := corrupt(append([]byte{}, good...))
+
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	truncated := broken("truncated.tkc", func(b []byte) []byte { return b[:len(b) / 2] })
+	expectPanic(t, "a truncated file", func() { NewReader(truncated) })
+
+	short := broken("short.tkc", func(b []byte) []byte { return b[:5] })
+	expectPanic(t, "a file shorter than the header", func() { NewReader(short) })
+
+	notCache := broken("not_cache.tkc", func(b []byte) []byte { return append([]byte("Time,Bid,Ask\n"), b[13:]...) })
+	expectPanic(t, "a file without the magic", func() { NewReader(notCache) })
+
+	badIndex := broken("bad_index.tkc", func(b []byte) []byte {
+		binary.LittleEndian.PutUint64(b[len(b) - footerSize:], uint64(len(b) * 2))
+		return b
+	})
+	expectPanic(t, "an index offset past the end", func() { NewReader(badIndex) })
+
+	// blocks are only decoded as they're read, which happens on Ticks' goroutine, so decode the
+	// broken one directly
+	r := NewReader(path)
+	badBlock := broken("bad_block.tkc", func(b []byte) []byte {
+		for i := r.Blocks()[3].Offset; i < r.Blocks()[3].Offset + 10; i++ {
+			b[i] ^= 0xff
+		}
+		return b
+	})
+
+	f, err := os.Open(badBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	expectPanic(t, "a corrupt block", func() { r.decodeBlock(f, r.Blocks()[3]) })
+}
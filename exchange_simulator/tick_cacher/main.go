@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"../importers"
+	"../tick_cache"
+	"../ticks"
+	"../utils"
+)
+
+// Converts CSV market data into the binary tick_cache format so the simulator doesn't have to
+// reparse it on every run.
+
+func main() {
+	var csvPath string
+	var format string
+	var output string
+	var resample time.Duration
+	var importOpts importers.Options
+	var blockSize int64
+
+	flag.StringVar(&csvPath, "path", "", "path to data file(s), comma separated files are merged")
+	flag.StringVar(&format, "format", "fxcm-m1", "data format: " + strings.Join(importers.Formats, ", "))
+	flag.StringVar(&importOpts.Symbol, "symbol", "", "symbol for formats which don't include one (dukascopy, histdata, metatrader)")
+	flag.StringVar(&importOpts.TimeZone, "tz", "", "time zone the data was exported in, e.g. America/New_York (default depends on format)")
+	flag.Float64Var(&importOpts.SpreadPips, "spread", 0.0, "synthetic spread in pips, required for bid-only formats (histdata, metatrader)")
+	flag.StringVar(&importOpts.Mapping, "mapping", "", "column mapping JSON for the generic format")
+	flag.StringVar(&output, "output", "", "destination cache file (default: <path>.tkc, required for several paths)")
+	flag.DurationVar(&resample, "resample", 0, "resample the data into bars of this period before caching, e.g. 1m")
+	flag.Int64Var(&blockSize, "block-size", tick_cache.DEFAULT_BLOCK_SIZE, "ticks per compressed block")
+	flag.Parse()
+
+	if "" == csvPath {
+		panic("must supply --path")
+	}
+
+	paths := strings.Split(csvPath, ",")
+
+	if "" == output {
+		if len(paths) > 1 {
+			panic("must supply --output when merging several files")
+		}
+
+		output = csvPath + ".tkc"
+	}
+
+	source := importers.Open(format, csvPath, importOpts)
+
+	if resample > 0 {
+		source = ticks.NewResampler(source, resample)
+	}
+
+	startTime := time.Now()
+
+	w := tick_cache.NewWriterWithDeets(output, tick_cache.DEFAULT_PRICE_SCALE, blockSize)
+	for tick := range source.Ticks() {
+		w.Write(tick)
+	}
+	w.Close()
+
+	csvSize := int64(0)
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		csvSize += stat.Size()
+	}
+
+	cacheStat, err := os.Stat(output)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	r := tick_cache.NewReader(output)
+
+	fmt.Printf(
+		"Cached %s ticks (%d symbols, %d blocks) from %s to %s in %.2fs\n",
+		utils.AddCommas(w.Count()),
+		len(r.Symbols()),
+		len(r.Blocks()),
+		csvPath,
+		output,
+		time.Since(startTime).Seconds(),
+	)
+
+	fmt.Printf(
+		"Size: %s -> %s bytes (%.1f%%)\n",
+		utils.AddCommas(csvSize),
+		utils.AddCommas(cacheStat.Size()),
+		(float64(cacheStat.Size()) / float64(csvSize)) * 100.0,
+	)
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"../quotes"
@@ -43,6 +47,107 @@ func (mt *MarketTick) Spread() float64 {
 	return float64(quotes.DifferenceInPips(mt.Symbol, mt.OpenBid, mt.OpenAsk))
 }
 
+// ----- checkpoints -------------------------------------------------------------------------------
+
+// State is a tick as it's saved in a checkpoint, see KeepMetadata for what of its Metadata is
+type State struct {
+	Symbol   string
+	Time     time.Time
+	OpenBid  float64
+	HighBid  float64
+	LowBid   float64
+	CloseBid float64
+	OpenAsk  float64
+	HighAsk  float64
+	LowAsk   float64
+	CloseAsk float64
+	Volume   int64
+
+	Metadata map[string]interface{}
+}
+
+// State is nil for a nil tick
+func (mt *MarketTick) State() *State {
+	if nil == mt {
+		return nil
+	}
+
+	return &State{
+		Symbol:   mt.Symbol,
+		Time:     mt.Time,
+		OpenBid:  mt.OpenBid,
+		HighBid:  mt.HighBid,
+		LowBid:   mt.LowBid,
+		CloseBid: mt.CloseBid,
+		OpenAsk:  mt.OpenAsk,
+		HighAsk:  mt.HighAsk,
+		LowAsk:   mt.LowAsk,
+		CloseAsk: mt.CloseAsk,
+		Volume:   mt.Volume,
+
+		Metadata: SaveMetadata(&mt.Metadata),
+	}
+}
+
+// Tick is a new tick from s, nil for a nil s
+func (s *State) Tick() *MarketTick {
+	if nil == s {
+		return nil
+	}
+
+	mt := &MarketTick{
+		Symbol:   s.Symbol,
+		Time:     s.Time,
+		OpenBid:  s.OpenBid,
+		HighBid:  s.HighBid,
+		LowBid:   s.LowBid,
+		CloseBid: s.CloseBid,
+		OpenAsk:  s.OpenAsk,
+		HighAsk:  s.HighAsk,
+		LowAsk:   s.LowAsk,
+		CloseAsk: s.CloseAsk,
+		Volume:   s.Volume,
+	}
+
+	RestoreMetadata(&mt.Metadata, s.Metadata)
+
+	return mt
+}
+
+// ===== METADATA ==================================================================================
+
+// A Metastore can't list its keys, so checkpoints only save the ones kept here, from ticks, orders
+// and algorithms alike
+var keptMetadata []string
+
+// KeepMetadata saves keys with any Metadata in a checkpoint. Whatever sets them should keep them
+// in an init, and gob.Register their values' types unless they're basic ones.
+func KeepMetadata(keys ...string) {
+	for _, key := range keys {
+		if !utils.StringArrayContainsString(keptMetadata, key) {
+			keptMetadata = append(keptMetadata, key)
+		}
+	}
+}
+
+func SaveMetadata(m *metastore.Metastore) map[string]interface{} {
+	saved := make(map[string]interface{})
+
+	for _, key := range keptMetadata {
+		if v, ok := m.Get(key); ok {
+			saved[key] = v
+		}
+	}
+
+	return saved
+}
+
+func RestoreMetadata(m *metastore.Metastore, saved map[string]interface{}) {
+	for key, v := range saved {
+		m.Set(key, v)
+	}
+}
+
 // ===== FXCM M1 CSV READER ========================================================================
 
 type FXCMM1CsvReader struct {
@@ -85,34 +190,12 @@ func (m1cr *FXCMM1CsvReader) Ticks() chan *MarketTick {
 				continue
 			}
 
-			// "Symbol"
-			// "Date"
-			// "Time"
-			// "OpenBid"
-			// "HighBid"
-			// "LowBid"
-			// "CloseBid"
-			// "OpenAsk"
-			// "HighAsk"
-			// "LowAsk"
-			// "CloseAsk"
-			// "Total Ticks" // aka "volume"
-
-			tick := MarketTick{
-				Symbol:   record[0],
-				Time:     m1cr.mergeDateTime(record[1], record[2]),
-				OpenBid:  utils.StringToFloat(record[3]),
-				HighBid:  utils.StringToFloat(record[4]),
-				LowBid:   utils.StringToFloat(record[5]),
-				CloseBid: utils.StringToFloat(record[6]),
-				OpenAsk:  utils.StringToFloat(record[7]),
-				HighAsk:  utils.StringToFloat(record[8]),
-				LowAsk:   utils.StringToFloat(record[9]),
-				CloseAsk: utils.StringToFloat(record[10]),
-				Volume:   utils.StringToInt(record[11]),
+			tick, err := ParseFXCMM1Record(record)
+			if err != nil {
+				log.Fatalln(err)
 			}
 
-			c <- &tick
+			c <- tick
 		}
 
 		close(c)
@@ -121,13 +204,324 @@ func (m1cr *FXCMM1CsvReader) Ticks() chan *MarketTick {
 	return c
 }
 
-func (m1cr *FXCMM1CsvReader) mergeDateTime(part1, part2 string) time.Time {
-	str := part1 + " " + part2
+var FXCMM1Header = []string{
+	"Symbol", "Date", "Time",
+	"OpenBid", "HighBid", "LowBid", "CloseBid",
+	"OpenAsk", "HighAsk", "LowAsk", "CloseAsk",
+	"Total Ticks",
+}
 
-	t, err := time.Parse("2006-01-02 15:04:05", str)
+const FXCM_DATE_FORMAT = "2006-01-02"
+const FXCM_TIME_FORMAT = "15:04:05"
+
+// older FXCM exports use US style dates, see the example above
+const FXCM_US_DATE_FORMAT = "01/02/2006"
+
+// ParseFXCMM1Record turns one row of an FXCM M1 CSV into a tick
+func ParseFXCMM1Record(record []string) (*MarketTick, error) {
+	if len(record) != len(FXCMM1Header) {
+		return nil, fmt.Errorf("expected %d columns, got %d: %#v", len(FXCMM1Header), len(record), record)
+	}
+
+	layout := FXCM_DATE_FORMAT + " " + FXCM_TIME_FORMAT
+	if strings.Contains(record[1], "/") {
+		layout = FXCM_US_DATE_FORMAT + " " + FXCM_TIME_FORMAT
+	}
+
+	t, err := time.Parse(layout, record[1] + " " + record[2])
+	if err != nil {
+		return nil, fmt.Errorf("time.Parse failed on \"%s %s\": %s", record[1], record[2], err.Error())
+	}
+
+	// "OpenBid" "HighBid" "LowBid" "CloseBid" "OpenAsk" "HighAsk" "LowAsk" "CloseAsk"
+	prices := make([]float64, 8)
+	for i := range prices {
+		prices[i], err = strconv.ParseFloat(record[i + 3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s \"%s\": %s", FXCMM1Header[i + 3], record[i + 3], err.Error())
+		}
+	}
+
+	// "Total Ticks" aka "volume"
+	volume, err := strconv.ParseInt(record[11], 10, 64)
 	if err != nil {
-		panic(fmt.Sprintf("time.Parse failed on \"%#v\": %s\n", str, err.Error()))
+		return nil, fmt.Errorf("bad %s \"%s\": %s", FXCMM1Header[11], record[11], err.Error())
+	}
+
+	return &MarketTick{
+		Symbol:   record[0],
+		Time:     t,
+		OpenBid:  prices[0],
+		HighBid:  prices[1],
+		LowBid:   prices[2],
+		CloseBid: prices[3],
+		OpenAsk:  prices[4],
+		HighAsk:  prices[5],
+		LowAsk:   prices[6],
+		CloseAsk: prices[7],
+		Volume:   volume,
+	}, nil
+}
+
+// FXCMM1Record is the inverse of ParseFXCMM1Record
+func FXCMM1Record(tick *MarketTick) []string {
+	return []string{
+		tick.Symbol,
+		tick.Time.Format(FXCM_DATE_FORMAT),
+		tick.Time.Format(FXCM_TIME_FORMAT),
+		utils.FloatToString(tick.OpenBid),
+		utils.FloatToString(tick.HighBid),
+		utils.FloatToString(tick.LowBid),
+		utils.FloatToString(tick.CloseBid),
+		utils.FloatToString(tick.OpenAsk),
+		utils.FloatToString(tick.HighAsk),
+		utils.FloatToString(tick.LowAsk),
+		utils.FloatToString(tick.CloseAsk),
+		fmt.Sprintf("%d", tick.Volume),
 	}
+}
+
+// ===== BID/ASK TICK CSV READER ===================================================================
+
+// Raw ticks as dumped from PG, the same format nn/csv_exploder reads:
+// symbol,bid,ask,time
+// EURUSD,1.25264,1.25278,2014-10-30 07:47:42.190842-07
+//
+// Each row becomes a MarketTick with identical open/high/low/close and a volume of 1.
+type BidAskCsvReader struct {
+	Path   string
+	Symbol string // only emit ticks for this symbol, emit everything if blank
+}
+
+func (bacr *BidAskCsvReader) Ticker() MarketTicker {
+	return MarketTicker(bacr)
+}
+
+func (bacr *BidAskCsvReader) Ticks() chan *MarketTick {
+	c := make(chan *MarketTick, 100)
+
+	go func() {
+		csvfile, err := os.Open(bacr.Path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		defer csvfile.Close()
+
+		reader := csv.NewReader(csvfile)
 
-	return t
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				log.Fatalln(err)
+			}
+
+			if "" != bacr.Symbol && bacr.Symbol != record[0] {
+				continue
+			}
+
+			c <- NewRawTick(
+				record[0],
+				utils.ParsePGTime(record[3]),
+				utils.StringToFloat(record[1]),
+				utils.StringToFloat(record[2]),
+			)
+		}
+
+		close(c)
+	}()
+
+	return c
+}
+
+// NewRawTick builds a single-price MarketTick from a raw bid/ask quote
+func NewRawTick(symbol string, t time.Time, bid, ask float64) *MarketTick {
+	return &MarketTick{
+		Symbol:   symbol,
+		Time:     t,
+		OpenBid:  bid,
+		HighBid:  bid,
+		LowBid:   bid,
+		CloseBid: bid,
+		OpenAsk:  ask,
+		HighAsk:  ask,
+		LowAsk:   ask,
+		CloseAsk: ask,
+		Volume:   1,
+	}
+}
+
+// ===== RESAMPLER =================================================================================
+
+// Builds OHLC bid/ask bars of any period out of another MarketTicker's ticks (or smaller bars).
+// Volume is the number of ticks that went into the bar when the source is raw ticks, or the
+// summed volume when it's bars. Bars are stamped with the start of their period, like FXCM's.
+// The source should be in time order, ticks older than one before them are dropped and counted.
+func NewResampler(source MarketTicker, period time.Duration) *Resampler {
+	if period <= 0 {
+		panic("resample period must be > 0")
+	}
+
+	return &Resampler{source: source, period: period}
+}
+
+type Resampler struct {
+	source MarketTicker
+	period time.Duration
+
+	dropped int64
+}
+
+func (r *Resampler) Ticker() MarketTicker {
+	return MarketTicker(r)
+}
+
+// Dropped counts the ticks that were out of order, it's only complete once Ticks' channel closes
+func (r *Resampler) Dropped() int64 {
+	return r.dropped
+}
+
+func (r *Resampler) bucket(t time.Time) int64 {
+	return t.UnixNano() / int64(r.period)
+}
+
+func (r *Resampler) Ticks() chan *MarketTick {
+	c := make(chan *MarketTick, 100)
+
+	go func() {
+		r.resample(r.source.Ticks(), func(bar *MarketTick) { c <- bar })
+
+		close(c)
+	}()
+
+	return c
+}
+
+// resample builds bars out of ticks until it's closed, passing each to emit as it's finished. A
+// tick older than the last one for its symbol would change a bar's close to an earlier price, or
+// belong in a bar that's been emitted already, so it's dropped instead.
+func (r *Resampler) resample(source chan *MarketTick, emit func(*MarketTick)) {
+	forming := make(map[string]*MarketTick)
+	last := make(map[string]time.Time)
+
+	// emits every forming bar, they're always all in the same bucket
+	flush := func() {
+		symbols := []string{}
+
+		for symbol := range forming {
+			symbols = append(symbols, symbol)
+		}
+
+		sort.Strings(symbols)
+
+		for _, symbol := range symbols {
+			emit(forming[symbol])
+			delete(forming, symbol)
+		}
+	}
+
+	lastBucket := int64(0)
+
+	for tick := range source {
+		b := r.bucket(tick.Time)
+
+		if b < lastBucket || tick.Time.Before(last[tick.Symbol]) {
+			r.dropped += 1
+			continue
+		}
+
+		last[tick.Symbol] = tick.Time
+
+		if b > lastBucket {
+			flush()
+			lastBucket = b
+		}
+
+		bar, ok := forming[tick.Symbol]
+		if !ok {
+			bar = &MarketTick{
+				Symbol:   tick.Symbol,
+				Time:     time.Unix(0, b * int64(r.period)).UTC(),
+				OpenBid:  tick.OpenBid,
+				HighBid:  tick.HighBid,
+				LowBid:   tick.LowBid,
+				OpenAsk:  tick.OpenAsk,
+				HighAsk:  tick.HighAsk,
+				LowAsk:   tick.LowAsk,
+			}
+
+			forming[tick.Symbol] = bar
+		}
+
+		bar.HighBid  = math.Max(bar.HighBid, tick.HighBid)
+		bar.LowBid   = math.Min(bar.LowBid, tick.LowBid)
+		bar.HighAsk  = math.Max(bar.HighAsk, tick.HighAsk)
+		bar.LowAsk   = math.Min(bar.LowAsk, tick.LowAsk)
+		bar.CloseBid = tick.CloseBid
+		bar.CloseAsk = tick.CloseAsk
+		bar.Volume  += tick.Volume
+	}
+
+	flush()
+
+	if r.dropped > 0 {
+		log.Printf("resampler: dropped %d ticks that were out of order\n", r.dropped)
+	}
+}
+
+// ===== MERGER ====================================================================================
+
+// Merges several MarketTickers (e.g., one file per symbol) into a single time ordered stream.
+// Each source must already be in time order. Ties go to whichever source was passed first.
+func NewMerger(sources ...MarketTicker) *Merger {
+	return &Merger{sources: sources}
+}
+
+type Merger struct {
+	sources []MarketTicker
+}
+
+func (m *Merger) Ticker() MarketTicker {
+	return MarketTicker(m)
+}
+
+func (m *Merger) Ticks() chan *MarketTick {
+	c := make(chan *MarketTick, 100)
+
+	go func() {
+		chans := make([]chan *MarketTick, len(m.sources))
+		heads := make([]*MarketTick, len(m.sources))
+
+		for i, source := range m.sources {
+			chans[i] = source.Ticks()
+			heads[i] = <- chans[i]
+		}
+
+		for {
+			next := -1
+
+			for i, head := range heads {
+				if nil == head {
+					continue
+				}
+
+				if -1 == next || head.Time.Before(heads[next].Time) {
+					next = i
+				}
+			}
+
+			if -1 == next {
+				break
+			}
+
+			c <- heads[next]
+			heads[next] = <- chans[next]
+		}
+
+		close(c)
+	}()
+
+	return c
 }
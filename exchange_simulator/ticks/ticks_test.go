@@ -0,0 +1,179 @@
+package ticks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+
+type sliceTicker []*MarketTick
+
+func (st sliceTicker) Ticks() chan *MarketTick {
+	c := make(chan *MarketTick, len(st))
+
+	for _, tick := range st {
+		c <- tick
+	}
+	close(c)
+
+	return c
+}
+
+func tick(symbol string, after time.Duration, bid float64) *MarketTick {
+	return NewRawTick(symbol, start.Add(after), bid, bid + 0.0002)
+}
+
+func read(ticker MarketTicker) []*MarketTick {
+	res := []*MarketTick{}
+	for tick := range ticker.Ticks() {
+		res = append(res, tick)
+	}
+
+	return res
+}
+
+func TestBidAskCsvReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ticks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ticks.csv")
+	rows := "EURUSD,1.25264,1.25278,2014-10-30 07:47:42.190842-07\n" +
+		"GBPUSD,1.60120,1.60140,2014-10-30 07:47:42.5-07\n" +
+		"EURUSD,1.25270,1.25281,2014-10-30 07:47:43-07\n"
+
+	if err := ioutil.WriteFile(path, []byte(rows), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := read(&BidAskCsvReader{Path: path, Symbol: "EURUSD"})
+	if 2 != len(got) {
+		t.Fatalf("expected the 2 EURUSD ticks, got %d", len(got))
+	}
+
+	at := time.Date(2014, 10, 30, 14, 47, 42, 190842000, time.UTC)
+	if !at.Equal(got[0].Time) || 1.25264 != got[0].CloseBid || 1.25278 != got[0].LowAsk || 1 != got[0].Volume {
+		t.Errorf("expected EURUSD 1.25264/1.25278 at %s, got %+v", at, got[0])
+	}
+
+	if all := read(&BidAskCsvReader{Path: path}); 3 != len(all) || "GBPUSD" != all[1].Symbol {
+		t.Errorf("expected every tick without a symbol, got %d", len(all))
+	}
+}
+
+func TestResampler(t *testing.T) {
+	source := sliceTicker{
+		tick("EURUSD", 10 * time.Second, 1.3700),
+		tick("GBPUSD", 15 * time.Second, 1.6600),
+		tick("EURUSD", 20 * time.Second, 1.3710),
+		tick("EURUSD", 30 * time.Second, 1.3690),
+		tick("EURUSD", 59 * time.Second, 1.3705),
+		tick("GBPUSD", 60 * time.Second, 1.6610),
+		tick("EURUSD", 3 * time.Minute, 1.3720), // nothing in the 2nd minute
+	}
+
+	expected := []struct {
+		symbol                 string
+		at                     time.Duration
+		open, high, low, close float64
+		volume                 int64
+	}{
+		{"EURUSD", 0, 1.3700, 1.3710, 1.3690, 1.3705, 4},
+		{"GBPUSD", 0, 1.6600, 1.6600, 1.6600, 1.6600, 1},
+		{"GBPUSD", time.Minute, 1.6610, 1.6610, 1.6610, 1.6610, 1},
+		{"EURUSD", 3 * time.Minute, 1.3720, 1.3720, 1.3720, 1.3720, 1},
+	}
+
+	got := read(NewResampler(source, time.Minute))
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d bars, got %d", len(expected), len(got))
+	}
+
+	for i, e := range expected {
+		g := got[i]
+
+		if e.symbol != g.Symbol || !start.Add(e.at).Equal(g.Time) || e.volume != g.Volume {
+			t.Errorf("bar %d: expected %s at %s with volume %d, got %s at %s with %d", i, e.symbol, start.Add(e.at), e.volume, g.Symbol, g.Time, g.Volume)
+		}
+
+		if e.open != g.OpenBid || e.high != g.HighBid || e.low != g.LowBid || e.close != g.CloseBid || e.high + 0.0002 != g.HighAsk {
+			t.Errorf("bar %d: expected %.4f %.4f %.4f %.4f, got %+v", i, e.open, e.high, e.low, e.close, g)
+		}
+	}
+
+	// bars of bars sum their volume
+	bars := read(NewResampler(sliceTicker(got), 5 * time.Minute))
+	if 2 != len(bars) || 5 != bars[0].Volume || 1.3720 != bars[0].CloseBid || 2 != bars[1].Volume || 1.6610 != bars[1].CloseBid {
+		t.Errorf("expected a 5 minute EURUSD bar of 5 ticks and GBPUSD of 2, got %+v", bars)
+	}
+}
+
+func TestResamplerDropsOlderTicks(t *testing.T) {
+	r := NewResampler(sliceTicker{
+		tick("EURUSD", 30 * time.Second, 1.3700),
+		tick("EURUSD", 10 * time.Second, 1.3690), // late, but still in the forming bar
+		tick("GBPUSD", 20 * time.Second, 1.6600), // another symbol's clock is its own
+		tick("EURUSD", 40 * time.Second, 1.3710),
+		tick("EURUSD", 70 * time.Second, 1.3720),
+		tick("GBPUSD", 50 * time.Second, 1.6610), // from a bar that's already been emitted
+		tick("EURUSD", 80 * time.Second, 1.3730),
+	}, time.Minute)
+
+	bars := read(r)
+
+	if 3 != len(bars) || 2 != r.Dropped() {
+		t.Fatalf("expected 3 bars and 2 ticks dropped, got %d and %d", len(bars), r.Dropped())
+	}
+
+	eurusd := bars[0]
+	if 2 != eurusd.Volume || 1.3700 != eurusd.LowBid || 1.3710 != eurusd.CloseBid {
+		t.Errorf("expected the late tick to be left out of the first EURUSD bar, got %+v", eurusd)
+	}
+
+	if "GBPUSD" != bars[1].Symbol || 1 != bars[1].Volume || 2 != bars[2].Volume || 1.3730 != bars[2].CloseBid {
+		t.Errorf("expected a GBPUSD bar of 1 tick and a second EURUSD bar of 2, got %+v and %+v", bars[1], bars[2])
+	}
+}
+
+func TestMerger(t *testing.T) {
+	eurusd := sliceTicker{
+		tick("EURUSD", 1 * time.Second, 1.3700),
+		tick("EURUSD", 3 * time.Second, 1.3701),
+		tick("EURUSD", 5 * time.Second, 1.3702),
+	}
+	gbpusd := sliceTicker{
+		tick("GBPUSD", 2 * time.Second, 1.6600),
+		tick("GBPUSD", 3 * time.Second, 1.6601),
+		tick("GBPUSD", 6 * time.Second, 1.6602),
+		tick("GBPUSD", 7 * time.Second, 1.6603),
+	}
+	empty := sliceTicker{}
+
+	// the 3 second tie goes to EURUSD, which was passed first
+	expected := []string{"EURUSD 1s", "GBPUSD 2s", "EURUSD 3s", "GBPUSD 3s", "EURUSD 5s", "GBPUSD 6s", "GBPUSD 7s"}
+
+	got := read(NewMerger(eurusd, empty, gbpusd))
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d ticks, got %d", len(expected), len(got))
+	}
+
+	for i, e := range expected {
+		if d := describe(got[i]); e != d {
+			t.Errorf("tick %d: expected %s, got %s", i, e, d)
+		}
+	}
+
+	if reversed := read(NewMerger(gbpusd, eurusd)); "GBPUSD 3s" != describe(reversed[2]) {
+		t.Errorf("expected the tie to go to GBPUSD when it's first, got %s", describe(reversed[2]))
+	}
+}
+
+func describe(tick *MarketTick) string {
+	return tick.Symbol + " " + tick.Time.Sub(start).String()
+}
@@ -158,3 +158,62 @@ func NiceTimeFormat(t time.Time) string {
 func NiceYearMonthFormat(t time.Time) string {
 	return t.Format("2006-01")
 }
+
+// ===== MARKET HOURS ==============================================================================
+
+// The FX market closes Friday 17:00 New York and reopens Sunday 17:00 New York. That's 21:00 or
+// 22:00 UTC depending on DST, so we use the wider of the two windows.
+const WEEKEND_CLOSE_HOUR_UTC = 21
+const WEEKEND_OPEN_HOUR_UTC  = 22
+
+func IsWeekend(t time.Time) bool {
+	t = t.UTC()
+
+	switch t.Weekday() {
+	case time.Saturday:
+		return true
+	case time.Friday:
+		return t.Hour() >= WEEKEND_CLOSE_HOUR_UTC
+	case time.Sunday:
+		return t.Hour() < WEEKEND_OPEN_HOUR_UTC
+	}
+
+	return false
+}
+
+// TradingDuration is the time between from and to, minus any weekend closures in between
+func TradingDuration(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+
+	total := to.Sub(from)
+
+	// walk back to the Friday on or before from, then subtract every weekend overlapping the range
+	y, m, d := from.UTC().Date()
+	friday := time.Date(y, m, d, WEEKEND_CLOSE_HOUR_UTC, 0, 0, 0, time.UTC)
+	for time.Friday != friday.Weekday() {
+		friday = friday.Add(-Day)
+	}
+
+	for ; friday.Before(to); friday = friday.Add(7 * Day) {
+		weekendStart := friday
+		weekendEnd   := friday.Add(2 * Day).Add((WEEKEND_OPEN_HOUR_UTC - WEEKEND_CLOSE_HOUR_UTC) * time.Hour)
+
+		overlapStart := weekendStart
+		if from.After(overlapStart) {
+			overlapStart = from
+		}
+
+		overlapEnd := weekendEnd
+		if to.Before(overlapEnd) {
+			overlapEnd = to
+		}
+
+		if overlapEnd.After(overlapStart) {
+			total -= overlapEnd.Sub(overlapStart)
+		}
+	}
+
+	return total
+}
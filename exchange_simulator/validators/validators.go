@@ -0,0 +1,654 @@
+package validators
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"../loggers"
+	"../ticks"
+	"../utils"
+)
+
+var log = loggers.Component("validators")
+
+// ===== POLICY ====================================================================================
+
+type Policy int64
+
+const (
+	FAIL   = Policy(0) // stop the run
+	SKIP   = Policy(1) // drop the tick
+	REPAIR = Policy(2) // fix the tick up (forward-fill, clamp, etc.) and carry on
+	WARN   = Policy(3) // complain and pass the tick through untouched
+)
+
+func (p Policy) String() string {
+	switch p {
+	case FAIL:
+		return "fail"
+	case SKIP:
+		return "skip"
+	case REPAIR:
+		return "repair"
+	case WARN:
+		return "warn"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int64(p))
+}
+
+// ===== ISSUES ====================================================================================
+
+type Issue string
+
+const (
+	SPIKE               = Issue("spike")
+	NEGATIVE_SPREAD     = Issue("negative_spread")
+	CROSSED_SPREAD      = Issue("crossed_spread")
+	DUPLICATE_TIMESTAMP = Issue("duplicate_timestamp")
+	OUT_OF_ORDER        = Issue("out_of_order")
+	GAP                 = Issue("gap")
+)
+
+// ===== RULES =====================================================================================
+
+type Rule interface {
+	Issue() Issue
+	Policy() Policy
+
+	// Check reports whether current is bad. last is the previous good tick for the same
+	// symbol, or nil if current is the first one we've seen.
+	Check(current, last *ticks.MarketTick) bool
+
+	// Describe explains what Check found, for warnings and failures
+	Describe(current, last *ticks.MarketTick) string
+
+	// Repair returns the tick(s) to use in place of current. The final tick returned is
+	// the one subsequent rules see, any before it are emitted ahead of it (e.g., gap fills).
+	// An empty result drops the tick.
+	Repair(current, last *ticks.MarketTick) []*ticks.MarketTick
+}
+
+// Rules that measure a tick against the last good one, so skipping can't get past a genuine jump
+// (a weekend gap, say) on its own: the last good tick never moves on. After MaxSkips of their
+// skips in a row the validator takes the latest tick as the last good one instead.
+type Rebaser interface {
+	Rebases() bool
+}
+
+type basicRule struct {
+	issue  Issue
+	policy Policy
+}
+
+func (br *basicRule) Issue() Issue {
+	return br.issue
+}
+
+func (br *basicRule) Policy() Policy {
+	return br.policy
+}
+
+// ----- SPIKE -------------------------------------------------------------------------------------
+
+const BID_ASK_DEVIATION_TOLERANCE = float64(0.005) // 0.5% (may need adjustment for EOW/SOW)
+
+// NewSpikeRule flags bids or asks which move more than tolerance (e.g., 0.005 for 0.5%) from the
+// last tick. Only day boundaries are checked unless Intraday is set, since that's where bad
+// data from FXCM tends to show up.
+func NewSpikeRule(p Policy, tolerance float64) *SpikeRule {
+	if tolerance <= 0.0 {
+		panic("spike tolerance must be > 0.0")
+	}
+
+	return &SpikeRule{basicRule: basicRule{issue: SPIKE, policy: p}, Tolerance: tolerance}
+}
+
+type SpikeRule struct {
+	basicRule
+
+	Tolerance float64
+	Intraday  bool
+}
+
+func (sr *SpikeRule) deviated(current, last float64) bool {
+	diff := last * sr.Tolerance
+
+	return current >= (last + diff) || current <= (last - diff)
+}
+
+func (sr *SpikeRule) Check(current, last *ticks.MarketTick) bool {
+	if nil == last {
+		return false
+	}
+
+	if !sr.Intraday && current.Time.Day() == last.Time.Day() {
+		return false
+	}
+
+	return sr.deviated(current.OpenBid, last.OpenBid) || sr.deviated(current.OpenAsk, last.OpenAsk)
+}
+
+func (sr *SpikeRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf(
+		"bid/ask deviated over %.1f%% (bid: %.1f%%, ask: %.1f%%) from last tick " +
+		"(curr: %.5f/%.5f @ %s, last: %.5f/%.5f @ %s)",
+		sr.Tolerance * 100,
+		math.Abs(((current.OpenBid - last.OpenBid) / current.OpenBid) * 100),
+		math.Abs(((current.OpenAsk - last.OpenAsk) / current.OpenAsk) * 100),
+		current.OpenBid,
+		current.OpenAsk,
+		utils.NiceTimeFormat(current.Time),
+		last.OpenBid,
+		last.OpenAsk,
+		utils.NiceTimeFormat(last.Time),
+	)
+}
+
+func (sr *SpikeRule) Rebases() bool {
+	return true
+}
+
+// Repair forward-fills the last tick's closing prices over the spike
+func (sr *SpikeRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	return []*ticks.MarketTick{forwardFill(last, current.Time, current.Volume)}
+}
+
+// ----- NEGATIVE SPREAD ---------------------------------------------------------------------------
+
+// NewNegativeSpreadRule flags ticks where the open or close ask is below the bid
+func NewNegativeSpreadRule(p Policy) *NegativeSpreadRule {
+	return &NegativeSpreadRule{basicRule{issue: NEGATIVE_SPREAD, policy: p}}
+}
+
+type NegativeSpreadRule struct {
+	basicRule
+}
+
+func (nsr *NegativeSpreadRule) Check(current, last *ticks.MarketTick) bool {
+	return current.OpenAsk < current.OpenBid || current.CloseAsk < current.CloseBid
+}
+
+func (nsr *NegativeSpreadRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf(
+		"negative spread (open: %.5f/%.5f, close: %.5f/%.5f @ %s)",
+		current.OpenBid,
+		current.OpenAsk,
+		current.CloseBid,
+		current.CloseAsk,
+		utils.NiceTimeFormat(current.Time),
+	)
+}
+
+// Repair clamps the ask up to the bid
+func (nsr *NegativeSpreadRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	t := copyTick(current)
+	t.OpenAsk  = math.Max(t.OpenAsk, t.OpenBid)
+	t.CloseAsk = math.Max(t.CloseAsk, t.CloseBid)
+
+	return []*ticks.MarketTick{t}
+}
+
+// ----- CROSSED SPREAD ----------------------------------------------------------------------------
+
+// NewCrossedSpreadRule flags ticks where the ask's high or low is below the bid's
+func NewCrossedSpreadRule(p Policy) *CrossedSpreadRule {
+	return &CrossedSpreadRule{basicRule{issue: CROSSED_SPREAD, policy: p}}
+}
+
+type CrossedSpreadRule struct {
+	basicRule
+}
+
+func (csr *CrossedSpreadRule) Check(current, last *ticks.MarketTick) bool {
+	return current.HighAsk < current.HighBid || current.LowAsk < current.LowBid
+}
+
+func (csr *CrossedSpreadRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf(
+		"crossed spread (high: %.5f/%.5f, low: %.5f/%.5f @ %s)",
+		current.HighBid,
+		current.HighAsk,
+		current.LowBid,
+		current.LowAsk,
+		utils.NiceTimeFormat(current.Time),
+	)
+}
+
+// Repair clamps the ask's high and low up to the bid's
+func (csr *CrossedSpreadRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	t := copyTick(current)
+	t.HighAsk = math.Max(t.HighAsk, t.HighBid)
+	t.LowAsk  = math.Max(t.LowAsk, t.LowBid)
+
+	return []*ticks.MarketTick{t}
+}
+
+// ----- DUPLICATE TIMESTAMP -----------------------------------------------------------------------
+
+// NewDuplicateTimestampRule flags ticks with the same timestamp as the last one. There's no
+// sensible way to merge two bars for the same minute so repairing keeps the first and drops the
+// duplicate.
+func NewDuplicateTimestampRule(p Policy) *DuplicateTimestampRule {
+	return &DuplicateTimestampRule{basicRule{issue: DUPLICATE_TIMESTAMP, policy: p}}
+}
+
+type DuplicateTimestampRule struct {
+	basicRule
+}
+
+func (dtr *DuplicateTimestampRule) Check(current, last *ticks.MarketTick) bool {
+	return nil != last && current.Time.Equal(last.Time)
+}
+
+func (dtr *DuplicateTimestampRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf("duplicate timestamp (%s)", utils.NiceTimeFormat(current.Time))
+}
+
+func (dtr *DuplicateTimestampRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	return []*ticks.MarketTick{}
+}
+
+// ----- OUT OF ORDER ------------------------------------------------------------------------------
+
+// NewOutOfOrderRule flags ticks older than the last one. We can't reorder a stream after the
+// fact so repairing drops the tick.
+func NewOutOfOrderRule(p Policy) *OutOfOrderRule {
+	return &OutOfOrderRule{basicRule{issue: OUT_OF_ORDER, policy: p}}
+}
+
+type OutOfOrderRule struct {
+	basicRule
+}
+
+func (oor *OutOfOrderRule) Check(current, last *ticks.MarketTick) bool {
+	return nil != last && current.Time.Before(last.Time)
+}
+
+func (oor *OutOfOrderRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf(
+		"tick is older than the last one (curr: %s, last: %s)",
+		utils.NiceTimeFormat(current.Time),
+		utils.NiceTimeFormat(last.Time),
+	)
+}
+
+func (oor *OutOfOrderRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	return []*ticks.MarketTick{}
+}
+
+// ----- GAP ---------------------------------------------------------------------------------------
+
+// NewGapRule flags ticks arriving more than maxGap after the last one, not counting weekends.
+// Repairing forward-fills the last tick's close every period until current.
+func NewGapRule(p Policy, maxGap, period time.Duration) *GapRule {
+	if maxGap < period {
+		panic("max gap must be >= the fill period")
+	}
+
+	return &GapRule{basicRule: basicRule{issue: GAP, policy: p}, MaxGap: maxGap, Period: period}
+}
+
+type GapRule struct {
+	basicRule
+
+	MaxGap time.Duration
+	Period time.Duration
+}
+
+func (gr *GapRule) Check(current, last *ticks.MarketTick) bool {
+	return nil != last && utils.TradingDuration(last.Time, current.Time) > gr.MaxGap
+}
+
+func (gr *GapRule) Describe(current, last *ticks.MarketTick) string {
+	return fmt.Sprintf(
+		"%s gap in trading hours (%s -> %s)",
+		utils.TradingDuration(last.Time, current.Time),
+		utils.NiceTimeFormat(last.Time),
+		utils.NiceTimeFormat(current.Time),
+	)
+}
+
+func (gr *GapRule) Rebases() bool {
+	return true
+}
+
+func (gr *GapRule) Repair(current, last *ticks.MarketTick) []*ticks.MarketTick {
+	res := []*ticks.MarketTick{}
+
+	for t := last.Time.Add(gr.Period); t.Before(current.Time); t = t.Add(gr.Period) {
+		if utils.IsWeekend(t) {
+			continue
+		}
+
+		res = append(res, forwardFill(last, t, 0))
+	}
+
+	return append(res, current)
+}
+
+// ----- HELPERS -----------------------------------------------------------------------------------
+
+func copyTick(t *ticks.MarketTick) *ticks.MarketTick {
+	return &ticks.MarketTick{
+		Symbol:   t.Symbol,
+		Time:     t.Time,
+		OpenBid:  t.OpenBid,
+		HighBid:  t.HighBid,
+		LowBid:   t.LowBid,
+		CloseBid: t.CloseBid,
+		OpenAsk:  t.OpenAsk,
+		HighAsk:  t.HighAsk,
+		LowAsk:   t.LowAsk,
+		CloseAsk: t.CloseAsk,
+		Volume:   t.Volume,
+	}
+}
+
+// a flat bar at from's closing prices
+func forwardFill(from *ticks.MarketTick, t time.Time, volume int64) *ticks.MarketTick {
+	return &ticks.MarketTick{
+		Symbol:   from.Symbol,
+		Time:     t,
+		OpenBid:  from.CloseBid,
+		HighBid:  from.CloseBid,
+		LowBid:   from.CloseBid,
+		CloseBid: from.CloseBid,
+		OpenAsk:  from.CloseAsk,
+		HighAsk:  from.CloseAsk,
+		LowAsk:   from.CloseAsk,
+		CloseAsk: from.CloseAsk,
+		Volume:   volume,
+	}
+}
+
+// ===== VALIDATOR =================================================================================
+
+const DEFAULT_MAX_SKIPS = 5 // bad data rarely lasts longer, see Rebaser
+
+func NewWithDeets(rules ...Rule) *Validator {
+	return &Validator{
+		rules:     rules,
+		lastTicks: make(map[string]*ticks.MarketTick),
+		skips:     make(map[string]int64),
+		MaxSkips:  DEFAULT_MAX_SKIPS,
+		Report:    NewReport(),
+	}
+}
+
+// Default roughly matches what the simulator has always checked, except bad data no longer kills
+// the process: spikes across day boundaries and backwards time still fail the run, everything
+// else is either dropped or warned about.
+func Default() *Validator {
+	return NewWithDeets(
+		NewOutOfOrderRule(FAIL),
+		NewDuplicateTimestampRule(SKIP),
+		NewSpikeRule(FAIL, BID_ASK_DEVIATION_TOLERANCE),
+		NewNegativeSpreadRule(WARN),
+		NewCrossedSpreadRule(WARN),
+		NewGapRule(WARN, 30 * time.Minute, time.Minute),
+	)
+}
+
+type Validator struct {
+	rules     []Rule
+	lastTicks map[string]*ticks.MarketTick
+	skips     map[string]int64 // by Rebasers, in a row
+
+	Quiet    bool  // don't print warnings, just count them
+	MaxSkips int64 // see Rebaser
+
+	Report *Report
+}
+
+func (v *Validator) AddRule(r Rule) {
+	v.rules = append(v.rules, r)
+}
+
+// Validate runs tick through every rule in order and returns the tick(s) to pass downstream.
+// ok is false if a FAIL rule tripped, in which case the run should stop.
+func (v *Validator) Validate(tick *ticks.MarketTick) (res []*ticks.MarketTick, ok bool) {
+	v.Report.ticksSeen += 1
+
+	last := v.lastTicks[tick.Symbol]
+	current := tick
+
+	for _, rule := range v.rules {
+		if !rule.Check(current, last) {
+			continue
+		}
+
+		v.Report.record(rule.Issue(), current.Symbol, rule.Policy())
+
+		switch rule.Policy() {
+		case FAIL:
+			v.Report.failure = fmt.Sprintf(
+				"BAD DATA: %s [%s]: %s",
+				current.Symbol,
+				rule.Issue(),
+				rule.Describe(current, last),
+			)
+
+			return nil, false
+		case SKIP:
+			v.skipped(rule, current)
+			return nil, true
+		case REPAIR:
+			repaired := rule.Repair(current, last)
+			if 0 == len(repaired) {
+				return nil, true
+			}
+
+			res = append(res, repaired[:len(repaired) - 1]...)
+			current = repaired[len(repaired) - 1]
+		case WARN:
+			if !v.Quiet {
+				log.At(current.Time).Warn(
+					"bad data",
+					"policy", rule.Policy(),
+					"symbol", current.Symbol,
+					"issue", rule.Issue(),
+					"detail", rule.Describe(current, last),
+				)
+			}
+		}
+	}
+
+	v.lastTicks[tick.Symbol] = current
+	v.skips[tick.Symbol] = 0
+
+	return append(res, current), true
+}
+
+// skipped moves the last good tick on to current once a Rebaser has skipped MaxSkips in a row
+func (v *Validator) skipped(rule Rule, current *ticks.MarketTick) {
+	if r, ok := rule.(Rebaser); !ok || !r.Rebases() {
+		return
+	}
+
+	v.skips[current.Symbol] += 1
+
+	if v.skips[current.Symbol] < v.MaxSkips {
+		return
+	}
+
+	if !v.Quiet {
+		log.At(current.Time).Warn(
+			"taking a skipped tick as the last good one",
+			"symbol", current.Symbol,
+			"issue", rule.Issue(),
+			"skipped", v.skips[current.Symbol],
+		)
+	}
+
+	v.lastTicks[current.Symbol] = current
+	v.skips[current.Symbol] = 0
+}
+
+// ===== REPORT ====================================================================================
+
+func NewReport() *Report {
+	return &Report{
+		counts:  make(map[Issue]map[string]int64),
+		actions: make(map[Policy]int64),
+	}
+}
+
+type Report struct {
+	counts  map[Issue]map[string]int64
+	actions map[Policy]int64
+
+	ticksSeen int64
+	failure   string
+}
+
+func (r *Report) record(issue Issue, symbol string, p Policy) {
+	if _, ok := r.counts[issue]; !ok {
+		r.counts[issue] = make(map[string]int64)
+	}
+
+	r.counts[issue][symbol] += 1
+	r.actions[p] += 1
+}
+
+func (r *Report) Count(issue Issue, symbol string) int64 {
+	return r.counts[issue][symbol]
+}
+
+func (r *Report) Total() int64 {
+	total := int64(0)
+
+	for _, symbols := range r.counts {
+		for _, count := range symbols {
+			total += count
+		}
+	}
+
+	return total
+}
+
+// Failure is the reason the run was stopped, if any
+func (r *Report) Failure() (string, bool) {
+	return r.failure, "" != r.failure
+}
+
+func (r *Report) Print() {
+	fmt.Println("***** DATA QUALITY REPORT *****")
+	fmt.Println("")
+	fmt.Printf(
+		"Ticks checked: %s, Issues: %s (failed: %d, skipped: %d, repaired: %d, warned: %d)\n",
+		utils.AddCommas(r.ticksSeen),
+		utils.AddCommas(r.Total()),
+		r.actions[FAIL],
+		r.actions[SKIP],
+		r.actions[REPAIR],
+		r.actions[WARN],
+	)
+
+	issues := []string{}
+	for issue := range r.counts {
+		issues = append(issues, string(issue))
+	}
+	sort.Strings(issues)
+
+	for _, issue := range issues {
+		symbols := []string{}
+		for symbol := range r.counts[Issue(issue)] {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+
+		for _, symbol := range symbols {
+			fmt.Printf(
+				"%-20s %s: %s\n",
+				issue,
+				symbol,
+				utils.AddCommas(r.counts[Issue(issue)][symbol]),
+			)
+		}
+	}
+
+	if failure, failed := r.Failure(); failed {
+		fmt.Println("")
+		fmt.Println("Run stopped early:", failure)
+	}
+
+	fmt.Println("")
+}
+
+// ===== CHECKPOINTS ===============================================================================
+
+// The rules don't change as ticks go through, so only what the validator has seen is saved
+type State struct {
+	LastTicks map[string]*ticks.State
+	Skips     map[string]int64
+
+	Counts    map[Issue]map[string]int64
+	Actions   map[Policy]int64
+	TicksSeen int64
+	Failure   string
+}
+
+func (v *Validator) State() State {
+	s := State{
+		LastTicks: make(map[string]*ticks.State),
+		Skips:     make(map[string]int64),
+		Counts:    make(map[Issue]map[string]int64),
+		Actions:   make(map[Policy]int64),
+		TicksSeen: v.Report.ticksSeen,
+		Failure:   v.Report.failure,
+	}
+
+	for symbol, tick := range v.lastTicks {
+		s.LastTicks[symbol] = tick.State()
+	}
+
+	for symbol, skips := range v.skips {
+		s.Skips[symbol] = skips
+	}
+
+	for issue, symbols := range v.Report.counts {
+		s.Counts[issue] = make(map[string]int64)
+
+		for symbol, count := range symbols {
+			s.Counts[issue][symbol] = count
+		}
+	}
+
+	for p, count := range v.Report.actions {
+		s.Actions[p] = count
+	}
+
+	return s
+}
+
+func (v *Validator) Restore(s State) {
+	v.lastTicks = make(map[string]*ticks.MarketTick)
+	v.skips = make(map[string]int64)
+	v.Report.counts = make(map[Issue]map[string]int64)
+	v.Report.actions = make(map[Policy]int64)
+
+	for symbol, ts := range s.LastTicks {
+		v.lastTicks[symbol] = ts.Tick()
+	}
+
+	for symbol, skips := range s.Skips {
+		v.skips[symbol] = skips
+	}
+
+	for issue, symbols := range s.Counts {
+		v.Report.counts[issue] = make(map[string]int64)
+
+		for symbol, count := range symbols {
+			v.Report.counts[issue][symbol] = count
+		}
+	}
+
+	for p, count := range s.Actions {
+		v.Report.actions[p] = count
+	}
+
+	v.Report.ticksSeen, v.Report.failure = s.TicksSeen, s.Failure
+}
@@ -0,0 +1,333 @@
+package validators
+
+import (
+	"testing"
+	"time"
+
+	"../ticks"
+)
+
+var monday = time.Date(2014, 3, 3, 12, 0, 0, 0, time.UTC)
+
+func tick(at time.Time, bid, ask float64) *ticks.MarketTick {
+	return ticks.NewRawTick("EURUSD", at, bid, ask)
+}
+
+func crossed(at time.Time) *ticks.MarketTick {
+	t := tick(at, 1.37000, 1.37020)
+	t.HighBid, t.LowAsk = 1.37050, 1.36990
+
+	return t
+}
+
+// what Validate should let through, in order
+type emitted struct {
+	at       time.Time
+	bid, ask float64
+}
+
+// validate runs last then current through a validator with just rule, returning what current
+// turned into
+func validate(rule Rule, last, current *ticks.MarketTick) ([]*ticks.MarketTick, bool, *Report) {
+	v := NewWithDeets(rule)
+	v.Quiet = true
+
+	if _, ok := v.Validate(last); !ok {
+		panic("last tick should be fine")
+	}
+
+	res, ok := v.Validate(current)
+	return res, ok, v.Report
+}
+
+func TestPolicies(t *testing.T) {
+	tuesday := monday.Add(12 * time.Hour)
+	late := monday.Add(11 * time.Hour + 59 * time.Minute)
+
+	cases := []struct {
+		name    string
+		rule    Rule
+		last    *ticks.MarketTick
+		current *ticks.MarketTick
+		ok      bool
+		emitted []emitted
+	}{
+		// spikes
+		{"spike fail", NewSpikeRule(FAIL, 0.005), tick(late, 1.37, 1.3702), tick(tuesday, 1.40, 1.4002), false, nil},
+		{"spike skip", NewSpikeRule(SKIP, 0.005), tick(late, 1.37, 1.3702), tick(tuesday, 1.40, 1.4002), true, nil},
+		{
+			"spike repair",
+			NewSpikeRule(REPAIR, 0.005),
+			tick(late, 1.37, 1.3702),
+			tick(tuesday, 1.40, 1.4002),
+			true,
+			[]emitted{{tuesday, 1.37, 1.3702}},
+		},
+		{
+			"spike warn",
+			NewSpikeRule(WARN, 0.005),
+			tick(late, 1.37, 1.3702),
+			tick(tuesday, 1.40, 1.4002),
+			true,
+			[]emitted{{tuesday, 1.40, 1.4002}},
+		},
+
+		// spreads
+		{
+			"negative spread repair",
+			NewNegativeSpreadRule(REPAIR),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(time.Minute), 1.3705, 1.3700),
+			true,
+			[]emitted{{monday.Add(time.Minute), 1.3705, 1.3705}},
+		},
+		{
+			"negative spread warn",
+			NewNegativeSpreadRule(WARN),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(time.Minute), 1.3705, 1.3700),
+			true,
+			[]emitted{{monday.Add(time.Minute), 1.3705, 1.3700}},
+		},
+		{"crossed spread skip", NewCrossedSpreadRule(SKIP), tick(monday, 1.37, 1.3702), crossed(monday.Add(time.Minute)), true, nil},
+		{"crossed spread fail", NewCrossedSpreadRule(FAIL), tick(monday, 1.37, 1.3702), crossed(monday.Add(time.Minute)), false, nil},
+
+		// timestamps, which can only be dropped
+		{"duplicate repair", NewDuplicateTimestampRule(REPAIR), tick(monday, 1.37, 1.3702), tick(monday, 1.38, 1.3802), true, nil},
+		{
+			"duplicate warn",
+			NewDuplicateTimestampRule(WARN),
+			tick(monday, 1.37, 1.3702),
+			tick(monday, 1.38, 1.3802),
+			true,
+			[]emitted{{monday, 1.38, 1.3802}},
+		},
+		{
+			"out of order fail",
+			NewOutOfOrderRule(FAIL),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(-time.Minute), 1.38, 1.3802),
+			false,
+			nil,
+		},
+		{
+			"out of order repair",
+			NewOutOfOrderRule(REPAIR),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(-time.Minute), 1.38, 1.3802),
+			true,
+			nil,
+		},
+
+		// gaps
+		{
+			"gap warn",
+			NewGapRule(WARN, 2 * time.Minute, time.Minute),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(5 * time.Minute), 1.38, 1.3802),
+			true,
+			[]emitted{{monday.Add(5 * time.Minute), 1.38, 1.3802}},
+		},
+		{
+			"gap repair",
+			NewGapRule(REPAIR, 2 * time.Minute, time.Minute),
+			tick(monday, 1.37, 1.3702),
+			tick(monday.Add(4 * time.Minute), 1.38, 1.3802),
+			true,
+			[]emitted{
+				{monday.Add(1 * time.Minute), 1.37, 1.3702},
+				{monday.Add(2 * time.Minute), 1.37, 1.3702},
+				{monday.Add(3 * time.Minute), 1.37, 1.3702},
+				{monday.Add(4 * time.Minute), 1.38, 1.3802},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		res, ok, report := validate(c.rule, c.last, c.current)
+
+		if c.ok != ok {
+			t.Errorf("%s: expected ok to be %v", c.name, c.ok)
+			continue
+		}
+
+		if 1 != report.Total() {
+			t.Errorf("%s: expected 1 issue, got %d", c.name, report.Total())
+		}
+
+		if _, failed := report.Failure(); failed == ok {
+			t.Errorf("%s: expected a failure only when ok is false", c.name)
+		}
+
+		if len(c.emitted) != len(res) {
+			t.Errorf("%s: expected %d ticks, got %d", c.name, len(c.emitted), len(res))
+			continue
+		}
+
+		for i, e := range c.emitted {
+			if !e.at.Equal(res[i].Time) || e.bid != res[i].OpenBid || e.ask != res[i].OpenAsk {
+				t.Errorf("%s: expected tick %d to be %+v, got %+v", c.name, i, e, res[i])
+			}
+		}
+	}
+}
+
+// spikes only count across days, and gaps only in trading hours
+func TestNotIssues(t *testing.T) {
+	res, _, report := validate(
+		NewSpikeRule(FAIL, 0.005),
+		tick(monday, 1.37, 1.3702),
+		tick(monday.Add(time.Minute), 1.40, 1.4002),
+	)
+	if 1 != len(res) || 0 != report.Total() {
+		t.Errorf("expected an intraday spike to pass, got %d issues", report.Total())
+	}
+
+	res, _, report = validate(
+		NewGapRule(FAIL, 2 * time.Minute, time.Minute),
+		tick(time.Date(2014, 3, 7, 20, 59, 0, 0, time.UTC), 1.37, 1.3702),
+		tick(time.Date(2014, 3, 9, 22, 0, 0, 0, time.UTC), 1.38, 1.3802),
+	)
+	if 1 != len(res) || 0 != report.Total() {
+		t.Errorf("expected the weekend not to be a gap, got %d issues", report.Total())
+	}
+}
+
+// gap fills skip the weekend, and carry on from the last tick's close with no volume
+func TestGapFill(t *testing.T) {
+	friday := time.Date(2014, 3, 7, 20, 50, 0, 0, time.UTC)
+	sunday := time.Date(2014, 3, 9, 22, 10, 0, 0, time.UTC)
+
+	last := tick(friday, 1.37, 1.3702)
+	last.CloseBid, last.CloseAsk = 1.3710, 1.3712
+
+	res, _, _ := validate(NewGapRule(REPAIR, 5 * time.Minute, time.Minute), last, tick(sunday, 1.38, 1.3802))
+
+	// 20:51 to 20:59, then 22:00 to 22:09, then the tick itself
+	if 9 + 10 + 1 != len(res) {
+		t.Fatalf("expected 20 ticks, got %d", len(res))
+	}
+
+	expected := friday
+	for i, fill := range res[:len(res) - 1] {
+		expected = expected.Add(time.Minute)
+		if 9 == i {
+			expected = time.Date(2014, 3, 9, 22, 0, 0, 0, time.UTC)
+		}
+
+		if !expected.Equal(fill.Time) {
+			t.Errorf("expected fill %d at %s, got %s", i, expected, fill.Time)
+		}
+
+		if 1.3710 != fill.OpenBid || 1.3710 != fill.HighBid || 1.3712 != fill.CloseAsk || 0 != fill.Volume {
+			t.Errorf("expected fill %d to be flat at the last close, got %+v", i, fill)
+		}
+	}
+
+	if !sunday.Equal(res[len(res) - 1].Time) {
+		t.Errorf("expected the tick itself last, got %s", res[len(res) - 1].Time)
+	}
+}
+
+func TestReport(t *testing.T) {
+	v := NewWithDeets(
+		NewDuplicateTimestampRule(SKIP),
+		NewNegativeSpreadRule(REPAIR),
+		NewGapRule(WARN, 2 * time.Minute, time.Minute),
+	)
+	v.Quiet = true
+
+	gbpusd := func(at time.Time, bid, ask float64) *ticks.MarketTick {
+		t := tick(at, bid, ask)
+		t.Symbol = "GBPUSD"
+
+		return t
+	}
+
+	feed := []*ticks.MarketTick{
+		tick(monday, 1.37, 1.3702),
+		tick(monday, 1.37, 1.3702), // duplicate
+		tick(monday.Add(time.Minute), 1.3705, 1.3700), // negative spread
+		tick(monday.Add(10 * time.Minute), 1.3705, 1.3700), // gap and negative spread
+		gbpusd(monday, 1.66, 1.6602),
+		gbpusd(monday, 1.66, 1.6602), // duplicate
+		gbpusd(monday.Add(time.Minute), 1.66, 1.6602),
+	}
+
+	for _, tick := range feed {
+		if _, ok := v.Validate(tick); !ok {
+			t.Fatalf("nothing should fail")
+		}
+	}
+
+	r := v.Report
+
+	if 7 != r.ticksSeen || 5 != r.Total() {
+		t.Errorf("expected 7 ticks and 5 issues, got %d and %d", r.ticksSeen, r.Total())
+	}
+
+	counts := []struct {
+		issue  Issue
+		symbol string
+		count  int64
+	}{
+		{DUPLICATE_TIMESTAMP, "EURUSD", 1},
+		{DUPLICATE_TIMESTAMP, "GBPUSD", 1},
+		{NEGATIVE_SPREAD, "EURUSD", 2},
+		{NEGATIVE_SPREAD, "GBPUSD", 0},
+		{GAP, "EURUSD", 1},
+	}
+
+	for _, c := range counts {
+		if c.count != r.Count(c.issue, c.symbol) {
+			t.Errorf("expected %d %s for %s, got %d", c.count, c.issue, c.symbol, r.Count(c.issue, c.symbol))
+		}
+	}
+
+	if 2 != r.actions[SKIP] || 2 != r.actions[REPAIR] || 1 != r.actions[WARN] || 0 != r.actions[FAIL] {
+		t.Errorf("expected 2 skipped, 2 repaired and 1 warned, got %v", r.actions)
+	}
+}
+
+// after a genuine jump the last good tick has to move on, or a skipping rule drops the rest of the
+// day
+func TestLevelShift(t *testing.T) {
+	v := NewWithDeets(NewSpikeRule(SKIP, 0.005))
+	v.Quiet = true
+
+	tuesday := monday.Add(12 * time.Hour)
+
+	feed := []*ticks.MarketTick{
+		tick(tuesday.Add(-time.Minute), 1.37, 1.3702),
+		tick(tuesday, 1.40, 1.4002), // a one-off, the next tick is back
+		tick(tuesday.Add(time.Minute), 1.3701, 1.3703),
+		tick(tuesday.Add(24 * time.Hour), 1.40, 1.4002), // then a real move
+	}
+
+	for i := 1; i < 8; i++ {
+		feed = append(feed, tick(tuesday.Add(24 * time.Hour + time.Duration(i) * time.Minute), 1.40, 1.4002))
+	}
+
+	passed := []int{}
+	for i, tick := range feed {
+		if res, _ := v.Validate(tick); 1 == len(res) {
+			passed = append(passed, i)
+		}
+	}
+
+	// DEFAULT_MAX_SKIPS of the move are dropped, the last of them is the new last good tick
+	expected := []int{0, 2, 8, 9, 10}
+	if len(expected) != len(passed) {
+		t.Fatalf("expected ticks %v to pass, got %v", expected, passed)
+	}
+
+	for i := range expected {
+		if expected[i] != passed[i] {
+			t.Fatalf("expected ticks %v to pass, got %v", expected, passed)
+		}
+	}
+
+	if 6 != v.Report.Count(SPIKE, "EURUSD") {
+		t.Errorf("expected 6 spikes, got %d", v.Report.Count(SPIKE, "EURUSD"))
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"math/rand"
 	"sort"
 	"sync"
-	"time"
 
 	std "../../exchange_simulator/indicators/steve_turn_detector"
 	sv  "../../exchange_simulator/indicators/spread_velocity"
@@ -18,9 +17,8 @@ import (
 	"../../exchange_simulator/accounts"
 	"../../exchange_simulator/exchanges"
 	"../../exchange_simulator/indicators"
-	"../../exchange_simulator/pips"
-	"../../exchange_simulator/quotes"
-	"../../exchange_simulator/stops"
+	"../../exchange_simulator/strategies"
+	"../../exchange_simulator/tick_cache"
 	"../../exchange_simulator/ticks"
 	"../../exchange_simulator/utils"
 
@@ -112,11 +110,11 @@ func newAlgo() *algorithms.Algorithm {
 		acc.ShowOrders()
 	}
 
-	algo := algorithms.NewWithDeets(acc, steveorithm2)
+	algo := algorithms.NewWithDeets(acc, strategies.Steveorithm2)
 	algo.TradingDecision = td.NewSTD()
 	algo.AddCurrency("EURUSD")//, "AUDUSD")//, "GBPUSD")
 	algo.AttachCharts("M1")
-	algo.SetStartupDelay(180 * time.Minute) // TODO: remove this
+	algo.RequireHistory("M1", 60)
 	algo.AddIndicator("STD", func() indicators.Indicator {
 		return std.NewSTD(0.9969, 0.9975)
 	})
@@ -131,93 +129,10 @@ func newAlgo() *algorithms.Algorithm {
 	return algo
 }
 
-func steveorithm2(algo *algorithms.Algorithm, tick *ticks.MarketTick) {
-	if algo.Account.HasOpenOrders() {
-		openOrderCount := 0
-
-		for _, order := range algo.Account.OpenOrders() {
-			openOrderCount += 1
-
-			if order.Symbol != tick.Symbol {
-				continue
-			}
-
-			if order.Metadata.Fetch("expiration_time").(time.Time).Before(tick.Time) {
-				algo.Broker.CloseOrder(algo.Account, order, tick)
-				continue
-			}
-
-			if order.PercentToTakeProfit() > 75.0 {
-				p := pips.Pip(-(float64(order.GetTakeProfit().Pips) / 1.5))
-				order.SetStopLoss(p)
-			}
-		}
-
-		// if openOrderCount >= 3 {
-		// 	return
-		// }
-	}
-
-	chart := algo.Charts[tick.Symbol]["M1"]
-	candles := chart.GetCandles(60)
-
-	// 1. previous minute’s open_bid/(60 minute m1 MA) >=1.0026
-	ma60 := 0.0
-	for _, candle := range candles {
-		ma60 += candle.OpenBid
-	}
-	ma60 /= 60.0
-
-	lastOpen := candles[0].OpenBid
-
-	crossover := lastOpen / ma60
-
-	// 2. previous minute’s spread has increased since opening (open_ask-open_bid)<(close_ask-close_bid)
-	// spreadIncreased := (candles[0].OpenAsk - candles[0].OpenBid) < (candles[0].CloseAsk - candles[0].CloseBid)
-	spreadsies := candles[0].OpenBid - candles[0].CloseBid
-	spreadIncreased := spreadsies <= 0.0011 && spreadsies >= -0.0011
-
-	// 3. last 5 ticks have more than  100 ticks  (you might be able to use turning up for this)
-	totalTicks := int64(0)
-	for _, candle := range chart.GetCandles(5) {
-		totalTicks += candle.Volume
-	}
-
-	_ = spreadIncreased
-	_ = totalTicks
-	_ = crossover
-
-	data := make(map[string]interface{})
-	data["spreadIncreased"] = spreadIncreased
-	data["totalTicks"] = totalTicks
-	data["crossover"] = crossover
-
-	switch algo.TradingDecision.Run(data) {
-	default:
-	case td.BUY:
-		tpPips := quotes.DifferenceInPips(tick.Symbol, tick.OpenAsk, tick.OpenAsk * 1.004)
-		slPips := pips.Pip(float64(tpPips) * 0.90)
-
-		o := algo.Broker.OpenBuyOrder(
-			algo.Account,
-			tick.Symbol,
-			tick,
-			// algo.Metadata.Fetch("lots").(float64),
-			algo.Account.LotSizeForTrade(slPips),
-			stops.NoStopLoss(),
-			stops.NoTakeProfit(),
-		)
-		o.SetStopLoss(slPips)
-		o.SetTakeProfit(tpPips)
-		o.Metadata.Set("expiration_time", o.OpenedAt.Add(60 * time.Minute))
-	case td.SELL:
-		fmt.Println("SELL")
-	}
-}
-
 // ===== PROGRAM ENTRYPOINT ========================================================================
 
 var csvPath string
+var useCache bool
 var showOrders bool
 var lots float64
 var margin int
@@ -225,6 +140,7 @@ var numberOfCompetitors int
 
 func main() {
 	flag.StringVar(&csvPath, "path", "", "path to CSV files")
+	flag.BoolVar(&useCache, "cache", false, "path is a tick_cache file rather than an FXCM M1 CSV")
 	flag.Float64Var(&lots, "lots", 0.01, "lots to use")
 	flag.IntVar(&margin, "margin", 1, "margin level (default: 1)")
 	flag.BoolVar(&showOrders, "show-orders", false, "show order summary after account summary")
@@ -240,7 +156,12 @@ func main() {
 	gladiators := []*ExchangeCompetitor{}
 
 	for i := 0; i < numberOfCompetitors; i++ {
-		e := exchanges.NewWithDeets(&ticks.FXCMM1CsvReader{Path: csvPath})
+		var source ticks.MarketTicker = &ticks.FXCMM1CsvReader{Path: csvPath}
+		if useCache {
+			source = tick_cache.NewReader(csvPath)
+		}
+
+		e := exchanges.NewWithDeets(source)
 		a := newAlgo()
 		e.AddAlgorithm(a)
 
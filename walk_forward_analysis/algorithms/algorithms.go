@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"../../exchange_simulator/validators"
 	"../accounts"
 	"../exchanges"
 	"../sample_sets"
@@ -70,28 +71,28 @@ func (algo *Algorithm) ExecuteOn(ss *sample_sets.SampleSet) float64 {
 	algo.StartReceiver()
 	go algo.TickReceiver()
 
-	first := true
-	var lastTick *ticks.Tick
+	validator := validators.Default()
 
 	for tick := range ss.Ticks() {
 		if tick.AfterCutoff() {
 			continue
 		}
 
-		if first {
-			first = false
-		} else {
-			ticks.Validate(tick, lastTick)
+		validated, ok := validator.Validate(tick.MarketTick())
+		if !ok {
+			break
 		}
 
-		algo.SendTick(tick)
-
-		lastTick = tick
+		for _, vt := range validated {
+			algo.SendTick(ticks.FromMarketTick(vt))
+		}
 	}
 
 	algo.StopReceiver(&wg)
 	wg.Wait()
 
+	validator.Report.Print()
+
 	algo.GetAccount().PrintSummary()
 
 	return algo.GetScore()
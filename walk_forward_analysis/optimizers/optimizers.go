@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"../../exchange_simulator/validators"
 	"../algorithms"
 	"../sample_sets"
 	"../ticks"
@@ -56,25 +57,23 @@ func (opti *Optimizer) AlgorithmFor(ss *sample_sets.SampleSet) algorithms.BaseAl
 		algos = append(algos, algo)
 	}
 
-	first := true
-	var lastTick *ticks.Tick
+	validator := validators.Default()
 
 	for tick := range ss.Ticks() {
 		if tick.AfterCutoff() {
 			continue
 		}
 
-		if first {
-			first = false
-		} else {
-			ticks.Validate(tick, lastTick)
+		validated, ok := validator.Validate(tick.MarketTick())
+		if !ok {
+			break
 		}
 
-		for _, algo := range algos {
-			algo.SendTick(tick)
+		for _, vt := range validated {
+			for _, algo := range algos {
+				algo.SendTick(ticks.FromMarketTick(vt))
+			}
 		}
-
-		lastTick = tick
 	}
 
 	for _, algo := range algos {
@@ -83,6 +82,8 @@ func (opti *Optimizer) AlgorithmFor(ss *sample_sets.SampleSet) algorithms.BaseAl
 
 	wg.Wait()
 
+	validator.Report.Print()
+
 	fmt.Printf(
 		"Optimized %d algorithms in %.2fs\n",
 		len(algos),
@@ -1,10 +1,9 @@
 package ticks
 
 import (
-	"fmt"
-	"log"
-	"math"
 	"time"
+
+	market "../../exchange_simulator/ticks"
 )
 
 // ===== TICK ======================================================================================
@@ -30,46 +29,38 @@ func (t *Tick) AfterCutoff() bool {
 	return t.Time.After(CUTOFF)
 }
 
-// ===== MISC FUNCTIONS ============================================================================
-
-const BID_ASK_DEVIATION_TOLERANCE = float64(0.005) // 0.5% (may need adjustment for EOW/SOW)
+// ----- MARKET TICKS ------------------------------------------------------------------------------
 
-func Validate(current *Tick, last *Tick) {
-	sameDay := current.Time.Day() == last.Time.Day()
+// the simulator's validators work on its own ticks, so we convert on the way in and out
 
-	bidDiff := last.OpenBid * BID_ASK_DEVIATION_TOLERANCE
-	if !sameDay && (current.OpenBid >= (last.OpenBid + bidDiff) || current.OpenBid <= (last.OpenBid - bidDiff)) {
-		fmt.Printf(
-			"BAD DATA: bid has deviated over %.1f%% (%.1f%%) from last Bid (curr: %.5f, last: %.5f)\n",
-			BID_ASK_DEVIATION_TOLERANCE * 100,
-			math.Abs(((current.OpenBid - last.OpenBid) / current.OpenBid) * 100),
-			current.OpenBid,
-			last.OpenBid,
-		)
-		fmt.Printf("Last:    %s - %#v\n", last.Time, last)
-		fmt.Printf("Current: %s - %#v\n", current.Time, current)
-		log.Fatalln("")
-	}
-
-	askDiff := last.OpenAsk * BID_ASK_DEVIATION_TOLERANCE
-	if !sameDay && (current.OpenAsk >= (last.OpenAsk + askDiff) || current.OpenAsk <= (last.OpenAsk - askDiff)) {
-		fmt.Printf(
-			"BAD DATA: ask has deviated over %.1f%% (%.1f%%) from last ask (curr: %.5f, last: %.5f)\n",
-			BID_ASK_DEVIATION_TOLERANCE * 100,
-			math.Abs(((current.OpenAsk - last.OpenAsk) / current.OpenAsk) * 100),
-			current.OpenAsk,
-			last.OpenAsk,
-		)
-		fmt.Printf("Last:    %s - %#v\n", last.Time, last)
-		fmt.Printf("Current: %s - %#v\n", current.Time, current)
-		log.Fatalln("")
+func (t *Tick) MarketTick() *market.MarketTick {
+	return &market.MarketTick{
+		Symbol:   t.Symbol,
+		Time:     t.Time,
+		OpenBid:  t.OpenBid,
+		HighBid:  t.HighBid,
+		LowBid:   t.LowBid,
+		CloseBid: t.CloseBid,
+		OpenAsk:  t.OpenAsk,
+		HighAsk:  t.HighAsk,
+		LowAsk:   t.LowAsk,
+		CloseAsk: t.CloseAsk,
+		Volume:   int64(t.Volume),
 	}
+}
 
-	if last.Time.Unix() > current.Time.Unix() {
-		log.Fatalf(
-			"BAD DATA: current tick is newer than last tick (curr: %#v, last: %#v)\n",
-			current.Time,
-			last.Time,
-		)
+func FromMarketTick(mt *market.MarketTick) *Tick {
+	return &Tick{
+		Symbol:   mt.Symbol,
+		Time:     mt.Time,
+		OpenBid:  mt.OpenBid,
+		HighBid:  mt.HighBid,
+		LowBid:   mt.LowBid,
+		CloseBid: mt.CloseBid,
+		OpenAsk:  mt.OpenAsk,
+		HighAsk:  mt.HighAsk,
+		LowAsk:   mt.LowAsk,
+		CloseAsk: mt.CloseAsk,
+		Volume:   int(mt.Volume),
 	}
 }
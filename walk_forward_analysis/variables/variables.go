@@ -3,8 +3,12 @@ package variables
 import (
 	"fmt"
 	"math/rand"
+
+	"../../exchange_simulator/loggers"
 )
 
+var log = loggers.Component("variables")
+
 // ===== BOOL ======================================================================================
 
 func NewBoolVar() *BoolVar {
@@ -45,7 +49,7 @@ type FloatVar struct {
 
 func (f *FloatVar) Randomize() {
 	f.value = (rand.Float64() * (f.upper - f.lower)) + f.lower
-	fmt.Printf("f: %f\n", f.value)
+	log.Debug("randomized float", "value", f.value)
 }
 
 func (f *FloatVar) Value() float64 {
@@ -89,11 +93,10 @@ type Variables struct {
 }
 
 func (v *Variables) Init() {
-	fmt.Println("variables.Init()")
 	v.floats = make(map[string]*FloatVar)
 	v.ints   = make(map[string]*IntVar)
 	v.bools  = make(map[string]*BoolVar)
-	fmt.Println("end of variables.Init()")
+	log.Debug("initialized variables")
 }
 
 func (v *Variables) CreateBool(name string) {
@@ -136,7 +139,7 @@ func (v *Variables) GetInt(name string) int {
 }
 
 func (vars *Variables) Randomize() {
-	fmt.Println("Randomize()")
+	log.Debug("randomizing variables", "bools", len(vars.bools), "ints", len(vars.ints), "floats", len(vars.floats))
 
 	for _, v := range vars.bools {
 		v.Randomize()
@@ -149,6 +152,4 @@ func (vars *Variables) Randomize() {
 	for _, v := range vars.floats {
 		v.Randomize()
 	}
-
-	fmt.Println("end of Randomize()")
 }